@@ -0,0 +1,103 @@
+package exdgo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportArchive is same as `Export`, but writes shards and the manifest into a single tar archive compressed in gzip,
+// convenient for moving data between machines.
+// Files are in the same layout as `Export`, and the manifest is the last file.
+// Modification times of files are the end of the request, so the same data makes the same archive.
+// The archive can be extracted by `ExtractArchive` or usual tools.
+func (r *RawRequest) ExportArchive(ctx context.Context, w io.Writer) (*ExportManifest, error) {
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	modTime := time.Unix(0, r.end)
+	write := func(name string, data []byte) error {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  modTime,
+		}
+		if serr := archive.WriteHeader(header); serr != nil {
+			return serr
+		}
+		_, serr := archive.Write(data)
+		return serr
+	}
+	manifest, serr := r.exportShards(ctx, func(entry *ExportShard, data []byte) error {
+		return write(entry.Path, data)
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	data, serr := json.MarshalIndent(manifest, "", "  ")
+	if serr != nil {
+		return nil, serr
+	}
+	if serr := write(exportManifestName, data); serr != nil {
+		return nil, serr
+	}
+	if serr := archive.Close(); serr != nil {
+		return nil, serr
+	}
+	if serr := compressed.Close(); serr != nil {
+		return nil, serr
+	}
+	return manifest, nil
+}
+
+// ExtractArchive extracts the archive written by `RawRequest.ExportArchive` into the directory,
+// and verifies it by `VerifyExport`.
+func ExtractArchive(r io.Reader, dir string) (*ExportManifest, error) {
+	compressed, serr := gzip.NewReader(r)
+	if serr != nil {
+		return nil, serr
+	}
+	archive := tar.NewReader(compressed)
+	for {
+		header, serr := archive.Next()
+		if serr != nil {
+			if serr == io.EOF {
+				break
+			}
+			return nil, serr
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%s: not a regular file", header.Name)
+		}
+		// Files must not be written outside of the directory
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("%s: path outside of the archive", header.Name)
+		}
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if serr := os.MkdirAll(filepath.Dir(name), 0755); serr != nil {
+			return nil, serr
+		}
+		file, serr := os.Create(name)
+		if serr != nil {
+			return nil, serr
+		}
+		_, serr = io.Copy(file, archive)
+		if cerr := file.Close(); serr == nil {
+			serr = cerr
+		}
+		if serr != nil {
+			return nil, fmt.Errorf("%s: %v", header.Name, serr)
+		}
+	}
+	return VerifyExport(dir)
+}
@@ -0,0 +1,52 @@
+package exdgo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestExportArchive(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	req := prepareFakeRawRequest(t, srv)
+	first := new(bytes.Buffer)
+	exported, serr := req.ExportArchive(context.Background(), first)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	second := new(bytes.Buffer)
+	if _, serr := req.ExportArchive(context.Background(), second); serr != nil {
+		t.Fatal(serr)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("archives of the same data differ")
+	}
+	extracted, serr := ExtractArchive(first, dir)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(extracted.Shards) != len(exported.Shards) || extracted.Shards[3] != exported.Shards[3] {
+		t.Fatal("manifest not extracted")
+	}
+
+	evil := new(bytes.Buffer)
+	compressed := gzip.NewWriter(evil)
+	archive := tar.NewWriter(compressed)
+	archive.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../evil", Mode: 0644, Size: 1})
+	archive.Write([]byte("x"))
+	archive.Close()
+	compressed.Close()
+	if _, serr := ExtractArchive(evil, dir); serr == nil {
+		t.Fatal("file outside of the directory extracted")
+	}
+}
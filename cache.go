@@ -0,0 +1,428 @@
+package exdgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Temporary and lock files older than this are considered to be left by crashed processes
+const cacheStaleTime = time.Hour
+
+// First bytes of gzip data
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Cache stores responses with their validators in a directory,
+// so unchanged responses are revalidated by conditional requests instead of downloaded again.
+// Responses without validators are stored only by `Client.Prefetch`, and used without revalidation.
+// Returned by `Client.Cache` if `CacheDir` was given. Safe for concurrent use.
+//
+// Entries are compressed in gzip at the fastest level rather than zstd, which would compress
+// better and faster but is not in the standard library, and this package has no dependencies.
+type Cache struct {
+	dir string
+	// Zero if unlimited
+	maxBytes int64
+	maxAge   time.Duration
+	mutex    sync.Mutex
+	// Size of entries stored, updated by `Prune` and when an entry is stored
+	size int64
+}
+
+// NotCachedError is the error reported when a client in offline mode is requested data not in the cache.
+type NotCachedError struct {
+	// Path of the request.
+	Path string
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("request %s not in cache", e.Path)
+}
+
+// cacheEntry is a response stored in the cache.
+type cacheEntry struct {
+	StatusCode   int    `json:"status"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// True if stored by `Prefetch` without validators, used as is without revalidation
+	Pinned bool `json:"pinned,omitempty"`
+	body   []byte
+}
+
+type cachePinKey struct{}
+
+// withCachePin returns the context whose responses are stored in the cache even without validators,
+// for `Prefetch` whose data must be served from the cache later.
+func withCachePin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cachePinKey{}, true)
+}
+
+// cachePinFrom reports whether responses in the context are stored without validators.
+func cachePinFrom(ctx context.Context) bool {
+	pin, _ := ctx.Value(cachePinKey{}).(bool)
+	return pin
+}
+
+// OpenCache opens the cache in the directory without a client, to manage it such as by `Prune`.
+// `maxBytes` and `maxAge` are limits applied by `Prune`, zero for unlimited.
+// Unlike the cache of a client, it is not pruned on opening.
+func OpenCache(dir string, maxBytes int64, maxAge time.Duration) (*Cache, error) {
+	if maxBytes < 0 {
+		return nil, errors.New("'maxBytes' must not be negative")
+	}
+	if maxAge < 0 {
+		return nil, errors.New("'maxAge' must not be negative")
+	}
+	if _, serr := os.Stat(dir); serr != nil {
+		return nil, serr
+	}
+	return &Cache{dir: dir, maxBytes: maxBytes, maxAge: maxAge}, nil
+}
+
+// newCache opens the cache in the directory, and prunes it if limits are given.
+func newCache(dir string, maxBytes int64, maxAge time.Duration) (*Cache, error) {
+	if serr := os.MkdirAll(dir, 0755); serr != nil {
+		return nil, fmt.Errorf("creating cache directory: %v", serr)
+	}
+	c := &Cache{dir: dir, maxBytes: maxBytes, maxAge: maxAge}
+	if maxBytes > 0 || maxAge > 0 {
+		if serr := c.Prune(); serr != nil {
+			return nil, fmt.Errorf("pruning cache: %v", serr)
+		}
+	}
+	return c, nil
+}
+
+// file returns the path of the entry for the request.
+func (c *Cache) file(path string, params url.Values) string {
+	// Encode sorts parameters by key, so the same request has the same key
+	sum := sha256.Sum256([]byte(path + "?" + params.Encode()))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get returns the entry for the request, or nil if it is not cached or can not be read.
+// An entry is stored as a line of JSON metadata followed by the body, compressed in gzip.
+// Entries not compressed are also read.
+func (c *Cache) get(path string, params url.Values) *cacheEntry {
+	name := c.file(path, params)
+	info, serr := os.Stat(name)
+	if serr != nil {
+		return nil
+	}
+	now := time.Now()
+	if c.maxAge > 0 && now.Sub(info.ModTime()) > c.maxAge {
+		os.Remove(name)
+		return nil
+	}
+	data, serr := ioutil.ReadFile(name)
+	if serr != nil {
+		return nil
+	}
+	// Modification time is the time of the last use for eviction
+	os.Chtimes(name, now, now)
+	entry, serr := parseCacheEntry(data)
+	if serr != nil {
+		return nil
+	}
+	return entry
+}
+
+// parseCacheEntry parses the content of the file of an entry.
+func parseCacheEntry(data []byte) (*cacheEntry, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, serr := gzip.NewReader(bytes.NewReader(data))
+		if serr != nil {
+			return nil, serr
+		}
+		data, serr = ioutil.ReadAll(reader)
+		if serr != nil {
+			return nil, serr
+		}
+	}
+	newline := bytes.IndexByte(data, '\n')
+	if newline == -1 {
+		return nil, errors.New("no metadata")
+	}
+	entry := new(cacheEntry)
+	if serr := json.Unmarshal(data[:newline], entry); serr != nil {
+		return nil, fmt.Errorf("metadata: %v", serr)
+	}
+	entry.body = data[newline+1:]
+	return entry, nil
+}
+
+// put stores the response.
+// A response without validators can not be revalidated, so it is stored only if `pin` is true,
+// and used without revalidation, otherwise an error is returned.
+// The entry is written into a temporary file and renamed, so a partially written entry is never read
+// even by other processes sharing the directory.
+func (c *Cache) put(path string, params url.Values, res *http.Response, body []byte, pin bool) error {
+	entry := cacheEntry{
+		StatusCode:   res.StatusCode,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		if !pin {
+			return fmt.Errorf("response of %s has neither ETag nor Last-Modified to revalidate it", path)
+		}
+		entry.Pinned = true
+	}
+	name := c.file(path, params)
+	if serr := os.MkdirAll(filepath.Dir(name), 0755); serr != nil {
+		return serr
+	}
+	tmp, serr := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if serr != nil {
+		return serr
+	}
+	defer os.Remove(tmp.Name())
+	meta, serr := json.Marshal(entry)
+	if serr != nil {
+		tmp.Close()
+		return serr
+	}
+	buffered := bufio.NewWriter(tmp)
+	// Speed matters more as responses are already compressed well by the fastest level
+	writer, serr := gzip.NewWriterLevel(buffered, gzip.BestSpeed)
+	if serr != nil {
+		tmp.Close()
+		return serr
+	}
+	writer.Write(meta)
+	writer.Write([]byte{'\n'})
+	writer.Write(body)
+	if serr := writer.Close(); serr != nil {
+		tmp.Close()
+		return serr
+	}
+	if serr := buffered.Flush(); serr != nil {
+		tmp.Close()
+		return serr
+	}
+	// The entry must be on the disk before it is visible to other processes by renaming
+	if serr := tmp.Sync(); serr != nil {
+		tmp.Close()
+		return serr
+	}
+	// Temporary files are only readable by the owner, but the cache can be shared by users
+	if serr := tmp.Chmod(0644); serr != nil {
+		tmp.Close()
+		return serr
+	}
+	info, serr := tmp.Stat()
+	if serr != nil {
+		tmp.Close()
+		return serr
+	}
+	if serr := tmp.Close(); serr != nil {
+		return serr
+	}
+	if serr := os.Rename(tmp.Name(), name); serr != nil {
+		return serr
+	}
+	c.mutex.Lock()
+	c.size += info.Size()
+	exceeded := c.maxBytes > 0 && c.size > c.maxBytes
+	c.mutex.Unlock()
+	if exceeded {
+		return c.Prune()
+	}
+	return nil
+}
+
+// Prune removes entries not used longer than the max age,
+// and then least recently used entries until the cache fits in the max size.
+// Called automatically when the cache exceeds the max size.
+//
+// Only one process prunes the directory at a time, others return immediately.
+// Temporary files left by crashed processes are also removed.
+func (c *Cache) Prune() error {
+	unlock, locked, serr := lockDir(c.dir)
+	if serr != nil {
+		return serr
+	}
+	if !locked {
+		return nil
+	}
+	defer unlock()
+	type file struct {
+		name string
+		size int64
+		used time.Time
+	}
+	files := make([]file, 0)
+	now := time.Now()
+	serr = filepath.Walk(c.dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Removed by others
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if strings.HasPrefix(info.Name(), ".tmp-") && now.Sub(info.ModTime()) > cacheStaleTime {
+				os.Remove(name)
+			}
+			return nil
+		}
+		files = append(files, file{name, info.Size(), info.ModTime()})
+		return nil
+	})
+	if serr != nil {
+		return serr
+	}
+	sort.Slice(files, func(a, b int) bool { return files[a].used.Before(files[b].used) })
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	for _, f := range files {
+		expired := c.maxAge > 0 && now.Sub(f.used) > c.maxAge
+		if !expired && (c.maxBytes <= 0 || total <= c.maxBytes) {
+			continue
+		}
+		if serr := os.Remove(f.name); serr != nil && !os.IsNotExist(serr) {
+			return serr
+		}
+		total -= f.size
+	}
+	c.mutex.Lock()
+	c.size = total
+	c.mutex.Unlock()
+	return nil
+}
+
+// CacheStats is the summary of entries in the cache.
+type CacheStats struct {
+	// Number of entries.
+	Entries int
+	// Total size of files of entries in bytes.
+	Bytes int64
+	// Time the least and the most recently used entries were used, zero if there is no entry.
+	Oldest time.Time
+	Newest time.Time
+}
+
+// walkEntries calls `fn` with files of entries, skipping temporary and lock files.
+func (c *Cache) walkEntries(fn func(name string, info os.FileInfo) error) error {
+	return filepath.Walk(c.dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Removed by others
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		return fn(name, info)
+	})
+}
+
+// Stats returns the summary of entries in the cache.
+func (c *Cache) Stats() (CacheStats, error) {
+	var stats CacheStats
+	serr := c.walkEntries(func(name string, info os.FileInfo) error {
+		stats.Entries++
+		stats.Bytes += info.Size()
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.Newest) {
+			stats.Newest = info.ModTime()
+		}
+		return nil
+	})
+	return stats, serr
+}
+
+// Verify reads all entries and returns paths of ones which can not be read, such as ones truncated
+// by a full disk. They are removed if `remove` is true.
+// Such entries are ignored by requests, which download data again.
+func (c *Cache) Verify(remove bool) ([]string, error) {
+	corrupted := make([]string, 0)
+	serr := c.walkEntries(func(name string, info os.FileInfo) error {
+		data, serr := ioutil.ReadFile(name)
+		if serr != nil {
+			if os.IsNotExist(serr) {
+				return nil
+			}
+			return serr
+		}
+		if _, serr := parseCacheEntry(data); serr == nil {
+			return nil
+		}
+		corrupted = append(corrupted, name)
+		if remove {
+			if serr := os.Remove(name); serr != nil && !os.IsNotExist(serr) {
+				return serr
+			}
+		}
+		return nil
+	})
+	return corrupted, serr
+}
+
+// setConditional adds headers to revalidate the entry.
+func (e *cacheEntry) setConditional(req *http.Request) {
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// lockDir takes the lock of the directory shared by processes, by creating a lock file exclusively.
+// `locked` is false if another process holds it.
+// This works on network file systems such as NFS, where `flock` is not reliable.
+func lockDir(dir string) (unlock func(), locked bool, err error) {
+	name := filepath.Join(dir, ".lock")
+	for {
+		file, serr := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if serr == nil {
+			file.Close()
+			return func() { os.Remove(name) }, true, nil
+		}
+		if !os.IsExist(serr) {
+			return nil, false, serr
+		}
+		info, serr := os.Stat(name)
+		if serr != nil {
+			if os.IsNotExist(serr) {
+				// Released just now
+				continue
+			}
+			return nil, false, serr
+		}
+		if time.Since(info.ModTime()) <= cacheStaleTime {
+			return nil, false, nil
+		}
+		// Left by a crashed process
+		if serr := os.Remove(name); serr != nil && !os.IsNotExist(serr) {
+			return nil, false, serr
+		}
+	}
+}
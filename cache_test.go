@@ -0,0 +1,350 @@
+package exdgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheRevalidates(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var full, notModified int64
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt64(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		}
+		atomic.AddInt64(&full, 1)
+		w.Header().Set("ETag", `"v1"`)
+		return true
+	}
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	cli := srv.client(t)
+	cli.cache, serr = newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	req.raw.cli = cli
+	first, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	downloaded := atomic.LoadInt64(&full)
+	second, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, first, second)
+	if atomic.LoadInt64(&full) != downloaded || atomic.LoadInt64(&notModified) != downloaded {
+		t.Fatalf("%d downloaded and %d revalidated after %d downloaded", full-downloaded, notModified, downloaded)
+	}
+}
+
+func TestCacheCompressed(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	cache, serr := newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	body := bytes.Repeat([]byte("msg\t1577836800000000000\ttrade\t{\"size\":1}\n"), 1000)
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": []string{`"v1"`}}}
+	params := url.Values{"channels": []string{"trade"}}
+	if serr := cache.put("filter/bitmex/1", params, res, body, false); serr != nil {
+		t.Fatal(serr)
+	}
+	stored, serr := ioutil.ReadFile(cache.file("filter/bitmex/1", params))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(stored) >= len(body)/10 {
+		t.Fatalf("%d bytes stored for %d bytes", len(stored), len(body))
+	}
+	entry := cache.get("filter/bitmex/1", params)
+	if entry == nil || !bytes.Equal(entry.body, body) || entry.ETag != `"v1"` || entry.Pinned {
+		t.Fatal("entry not read back")
+	}
+	// Responses without validators are only stored pinned
+	res = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	if serr := cache.put("filter/bitmex/2", params, res, body, false); serr == nil {
+		t.Fatal("response without validators stored")
+	}
+	if serr := cache.put("filter/bitmex/2", params, res, body, true); serr != nil {
+		t.Fatal(serr)
+	}
+	if entry := cache.get("filter/bitmex/2", params); entry == nil || !entry.Pinned || !bytes.Equal(entry.body, body) {
+		t.Fatal("pinned entry not read back")
+	}
+	// Entries stored without compression are still read
+	if serr := ioutil.WriteFile(cache.file("filter/bitmex/1", params), append([]byte("{\"status\":200,\"etag\":\"v2\"}\n"), body...), 0644); serr != nil {
+		t.Fatal(serr)
+	}
+	entry = cache.get("filter/bitmex/1", params)
+	if entry == nil || !bytes.Equal(entry.body, body) || entry.ETag != "v2" {
+		t.Fatal("uncompressed entry not read")
+	}
+}
+
+func TestCacheStatsAndVerify(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	cache, serr := OpenCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": []string{`"v1"`}}}
+	for _, path := range []string{"filter/bitmex/1", "filter/bitmex/2", "filter/bitmex/3"} {
+		if serr := cache.put(path, nil, res, []byte("msg\t1577836800000000000\ttrade\t{}\n"), false); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	stats, serr := cache.Stats()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if stats.Entries != 3 || stats.Bytes <= 0 || stats.Oldest.IsZero() || stats.Newest.Before(stats.Oldest) {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// Truncated by a full disk
+	name := cache.file("filter/bitmex/2", nil)
+	if serr := ioutil.WriteFile(name, gzipMagic, 0644); serr != nil {
+		t.Fatal(serr)
+	}
+	corrupted, serr := cache.Verify(false)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(corrupted) != 1 || corrupted[0] != name {
+		t.Fatalf("corrupted = %v", corrupted)
+	}
+	if _, serr := cache.Verify(true); serr != nil {
+		t.Fatal(serr)
+	}
+	if stats, _ := cache.Stats(); stats.Entries != 2 {
+		t.Fatalf("%d entries after removing corrupted ones", stats.Entries)
+	}
+	if _, serr := OpenCache(filepath.Join(dir, "missing"), 0, 0); serr == nil {
+		t.Fatal("missing directory opened")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	cache, serr := newCache(dir, 0, time.Hour)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": []string{`"v1"`}}}
+	put := func(path string) {
+		t.Helper()
+		if serr := cache.put(path, nil, res, bytes.Repeat([]byte(path), 100), false); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	age := func(path string, d time.Duration) {
+		t.Helper()
+		old := time.Now().Add(-d)
+		if serr := os.Chtimes(cache.file(path, nil), old, old); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	put("a")
+	put("b")
+	put("c")
+	age("a", 2*time.Hour)
+	age("b", 2*time.Minute)
+	age("c", time.Minute)
+	if serr := cache.Prune(); serr != nil {
+		t.Fatal(serr)
+	}
+	if cache.get("a", nil) != nil {
+		t.Fatal("expired entry kept")
+	}
+	// Reading makes it the most recently used
+	if cache.get("b", nil) == nil {
+		t.Fatal("entry removed")
+	}
+	info, serr := os.Stat(cache.file("b", nil))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// Only one entry fits
+	cache.maxBytes = info.Size()
+	if serr := cache.Prune(); serr != nil {
+		t.Fatal(serr)
+	}
+	if cache.get("c", nil) != nil || cache.get("b", nil) == nil {
+		t.Fatal("not the least recently used entry removed")
+	}
+	put("d")
+	if cache.get("b", nil) != nil || cache.get("d", nil) == nil {
+		t.Fatal("cache not pruned on exceeding the limit")
+	}
+}
+
+func TestCacheSharedByProcesses(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": []string{`"v1"`}}}
+	bodies := [][]byte{bytes.Repeat([]byte("a"), 100000), bytes.Repeat([]byte("b"), 200000)}
+	// Caches opened separately behave like ones in different processes
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		go func(w int) {
+			cache, serr := newCache(dir, 1<<20, 0)
+			if serr != nil {
+				errs <- serr
+				return
+			}
+			for i := 0; i < 20; i++ {
+				if serr := cache.put("filter/bitmex/1", nil, res, bodies[(w+i)%2], false); serr != nil {
+					errs <- serr
+					return
+				}
+				if entry := cache.get("filter/bitmex/1", nil); entry != nil && !bytes.Equal(entry.body, bodies[0]) && !bytes.Equal(entry.body, bodies[1]) {
+					errs <- errors.New("corrupted entry read")
+					return
+				}
+			}
+			errs <- nil
+		}(w)
+	}
+	for w := 0; w < 8; w++ {
+		if serr := <-errs; serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	stale := filepath.Join(dir, ".tmp-crashed")
+	if serr := ioutil.WriteFile(stale, []byte("partial"), 0644); serr != nil {
+		t.Fatal(serr)
+	}
+	old := time.Now().Add(-2 * cacheStaleTime)
+	os.Chtimes(stale, old, old)
+	cache, serr := newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := cache.Prune(); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := os.Stat(stale); !os.IsNotExist(serr) {
+		t.Fatal("stale temporary file kept")
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("ETag", `"v1"`)
+		return true
+	}
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	if _, serr := setupClient(ClientParam{Offline: true}); serr == nil {
+		t.Fatal("offline without cache accepted")
+	}
+	online := srv.client(t)
+	online.cache, serr = newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	req.raw.cli = online
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// API-key is not needed offline
+	offline, serr := setupClient(ClientParam{CacheDir: dir, Offline: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	offline.endpoint = srv.server.URL + "/"
+	sent := atomic.LoadInt64(&srv.requests)
+	req.raw.cli = &offline
+	downloaded, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, downloaded)
+	req.raw.end += int64(time.Minute)
+	_, serr = req.Download()
+	var nerr *NotCachedError
+	if !errors.As(serr, &nerr) {
+		t.Fatalf("expected NotCachedError, got %v", serr)
+	}
+	if atomic.LoadInt64(&srv.requests) != sent {
+		t.Fatal("request sent offline")
+	}
+}
+
+func TestCacheOfflineWithoutValidators(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	online := srv.client(t)
+	online.cache, serr = newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	req.raw.cli = online
+	// The fake server sends no ETag, so responses are stored only by prefetching
+	if serr := online.Prefetch(context.Background(), req.raw.filter, time.Unix(0, req.raw.start), time.Unix(0, req.raw.end)); serr != nil {
+		t.Fatal(serr)
+	}
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	offline, serr := setupClient(ClientParam{CacheDir: dir, Offline: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	sent := atomic.LoadInt64(&srv.requests)
+	req.raw.cli = &offline
+	downloaded, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, downloaded)
+	if atomic.LoadInt64(&srv.requests) != sent {
+		t.Fatal("request sent offline")
+	}
+}
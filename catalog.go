@@ -0,0 +1,83 @@
+package exdgo
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// DefinitionEntry is a definition of a channel observed, and the range of time it was valid in.
+type DefinitionEntry struct {
+	Exchange   string            `json:"exchange"`
+	Channel    string            `json:"channel"`
+	Definition map[string]string `json:"definition"`
+	// `End` is when another definition appeared, or the end of the request
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// DefinitionCatalog is definitions observed in a range, sorted by exchange, channel and time.
+// Encode it by `json.Marshal` to export a data dictionary.
+type DefinitionCatalog []DefinitionEntry
+
+// Definitions downloads the range and returns all definitions of channels observed,
+// with the range each of them was valid in.
+// Messages are not decoded, and lines are not kept.
+func (r *ReplayRequest) Definitions(ctx context.Context) (DefinitionCatalog, error) {
+	catalog := make(DefinitionCatalog, 0)
+	requests := r.segments
+	if len(requests) == 0 {
+		requests = []*ReplayRequest{r}
+	}
+	for _, req := range requests {
+		// Index of the entry currently valid in `catalog`, map[exchange]map[channel]index
+		current := make(map[string]map[string]int)
+		processor := newRawLineProcessor()
+		serr := req.raw.DownloadFunc(ctx, func(line *StringLine) error {
+			def, skip, serr := processor.track(line)
+			if serr != nil || !skip {
+				return serr
+			}
+			channels, ok := current[line.Exchange]
+			if !ok {
+				channels = make(map[string]int)
+				current[line.Exchange] = channels
+			}
+			at := time.Unix(0, line.Timestamp)
+			if k, ok := channels[*line.Channel]; ok {
+				if reflect.DeepEqual(catalog[k].Definition, def) {
+					// Sent again after a start line
+					return nil
+				}
+				catalog[k].End = at
+			}
+			channels[*line.Channel] = len(catalog)
+			catalog = append(catalog, DefinitionEntry{
+				Exchange:   line.Exchange,
+				Channel:    *line.Channel,
+				Definition: def,
+				Start:      at,
+			})
+			return nil
+		})
+		if serr != nil {
+			return nil, serr
+		}
+		for _, channels := range current {
+			for _, k := range channels {
+				catalog[k].End = req.End()
+			}
+		}
+	}
+	sort.SliceStable(catalog, func(a, b int) bool {
+		if catalog[a].Exchange != catalog[b].Exchange {
+			return catalog[a].Exchange < catalog[b].Exchange
+		}
+		if catalog[a].Channel != catalog[b].Channel {
+			return catalog[a].Channel < catalog[b].Channel
+		}
+		return catalog[a].Start.Before(catalog[b].Start)
+	})
+	return catalog, nil
+}
@@ -0,0 +1,50 @@
+package exdgo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefinitions(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	changed := start.Add(2 * time.Minute)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != fmt.Sprintf("/filter/bitmex/%d", changed.Unix()/60) {
+			return true
+		}
+		// Definition changes after reconnection
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "start\t%d\t\n", changed.UnixNano())
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano(), `{"price":"string"}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano()+1, `{"price":"1.5"}`)
+		return false
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	catalog, serr := req.Definitions(context.Background())
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	def := map[string]string{"price": "float", "size": "int", "timestamp": "timestamp"}
+	// Timestamps are shifted by the length of exchanges
+	expected := DefinitionCatalog{
+		{"bitfinex", "trades_tBTCUSD", def, start.Add(8), start.Add(10 * time.Minute)},
+		{"bitmex", "orderBookL2_XBTUSD", def, start.Add(6), changed},
+		{"bitmex", "orderBookL2_XBTUSD", map[string]string{"price": "string"}, changed, start.Add(10 * time.Minute)},
+	}
+	if len(catalog) != len(expected) {
+		t.Fatalf("catalog %v", catalog)
+	}
+	for k := range expected {
+		e := expected[k]
+		c := catalog[k]
+		if c.Exchange != e.Exchange || c.Channel != e.Channel || !reflect.DeepEqual(c.Definition, e.Definition) || !c.Start.Equal(e.Start) || !c.End.Equal(e.End) {
+			t.Fatalf("entry %d: %+v", k, c)
+		}
+	}
+}
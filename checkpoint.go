@@ -0,0 +1,121 @@
+package exdgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Identifies checkpoint files, written in the header
+const checkpointFormat = "exdgo-checkpoint"
+
+// Version of checkpoint files written, increased when the content changes incompatibly
+const checkpointVersion = 1
+
+// ErrCheckpointCorrupted is reported by `LoadCheckpoint` if the file is not a complete checkpoint.
+var ErrCheckpointCorrupted = errors.New("checkpoint corrupted")
+
+// Checkpoint is the progress of a long download such as a backfill, saved to resume it after a crash.
+// Minutes before `Next` of an exchange are done, so a resumed download neither skips nor repeats minutes.
+type Checkpoint struct {
+	// Request the progress is of, to check the checkpoint is for the same request when resumed.
+	Filter map[string][]string `json:"filter"`
+	// Range of the request in nanoseconds since the unix epoch, `End` is exclusive.
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Minute to do next for each exchange, in minutes since the unix epoch.
+	Next map[string]int64 `json:"next"`
+}
+
+// checkpointHeader is the first line of a checkpoint file, describing the content following.
+type checkpointHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// SHA-256 of the content in hex
+	SHA256 string `json:"sha256"`
+}
+
+// SaveCheckpoint writes the checkpoint into the file.
+// The checkpoint is written into a temporary file and renamed after it is on the disk,
+// so the file is either the old or the new checkpoint even after a power loss.
+func SaveCheckpoint(name string, checkpoint *Checkpoint) error {
+	content, serr := json.Marshal(checkpoint)
+	if serr != nil {
+		return serr
+	}
+	sum := sha256.Sum256(content)
+	header, serr := json.Marshal(checkpointHeader{
+		Format:  checkpointFormat,
+		Version: checkpointVersion,
+		SHA256:  hex.EncodeToString(sum[:]),
+	})
+	if serr != nil {
+		return serr
+	}
+	dir := filepath.Dir(name)
+	tmp, serr := ioutil.TempFile(dir, ".tmp-")
+	if serr != nil {
+		return serr
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(header)
+	tmp.Write([]byte{'\n'})
+	if _, serr := tmp.Write(content); serr != nil {
+		tmp.Close()
+		return serr
+	}
+	if serr := tmp.Sync(); serr != nil {
+		tmp.Close()
+		return serr
+	}
+	if serr := tmp.Close(); serr != nil {
+		return serr
+	}
+	if serr := os.Rename(tmp.Name(), name); serr != nil {
+		return serr
+	}
+	// Renaming is durable only after the directory is on the disk
+	if d, serr := os.Open(dir); serr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// LoadCheckpoint reads the checkpoint saved by `SaveCheckpoint`.
+// Reports an error satisfying `os.IsNotExist` if the file does not exist,
+// `ErrCheckpointCorrupted` if the file is incomplete or altered,
+// or an error if it is written by a newer version.
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	data, serr := ioutil.ReadFile(name)
+	if serr != nil {
+		return nil, serr
+	}
+	newline := bytes.IndexByte(data, '\n')
+	if newline == -1 {
+		return nil, fmt.Errorf("%s: %w: no header", name, ErrCheckpointCorrupted)
+	}
+	var header checkpointHeader
+	if serr := json.Unmarshal(data[:newline], &header); serr != nil || header.Format != checkpointFormat {
+		return nil, fmt.Errorf("%s: %w: not a checkpoint", name, ErrCheckpointCorrupted)
+	}
+	if header.Version != checkpointVersion {
+		return nil, fmt.Errorf("%s: checkpoint version %d not supported", name, header.Version)
+	}
+	content := data[newline+1:]
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != header.SHA256 {
+		return nil, fmt.Errorf("%s: %w: hash mismatch", name, ErrCheckpointCorrupted)
+	}
+	checkpoint := new(Checkpoint)
+	if serr := json.Unmarshal(content, checkpoint); serr != nil {
+		return nil, fmt.Errorf("%s: %w: %v", name, ErrCheckpointCorrupted, serr)
+	}
+	return checkpoint, nil
+}
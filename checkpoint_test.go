@@ -0,0 +1,74 @@
+package exdgo
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "checkpoint")
+	if _, serr := LoadCheckpoint(name); !os.IsNotExist(serr) {
+		t.Fatalf("expected not exist, got %v", serr)
+	}
+	checkpoint := &Checkpoint{
+		Filter: map[string][]string{"bitmex": {"trade"}},
+		Start:  1577836800000000000,
+		End:    1577837400000000000,
+		Next:   map[string]int64{"bitmex": 26297285},
+	}
+	if serr := SaveCheckpoint(name, checkpoint); serr != nil {
+		t.Fatal(serr)
+	}
+	checkpoint.Next["bitmex"]++
+	if serr := SaveCheckpoint(name, checkpoint); serr != nil {
+		t.Fatal(serr)
+	}
+	loaded, serr := LoadCheckpoint(name)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if !reflect.DeepEqual(loaded, checkpoint) {
+		t.Fatalf("loaded %+v, saved %+v", loaded, checkpoint)
+	}
+	files, serr := ioutil.ReadDir(dir)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d files left", len(files))
+	}
+	data, serr := ioutil.ReadFile(name)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// Torn and altered writes are detected
+	for _, corrupted := range []string{
+		string(data[:len(data)-3]),
+		strings.Replace(string(data), "26297286", "26297287", 1),
+		"",
+	} {
+		if serr := ioutil.WriteFile(name, []byte(corrupted), 0644); serr != nil {
+			t.Fatal(serr)
+		}
+		if _, serr := LoadCheckpoint(name); !errors.Is(serr, ErrCheckpointCorrupted) {
+			t.Fatalf("expected ErrCheckpointCorrupted for %q, got %v", corrupted, serr)
+		}
+	}
+	newer := strings.Replace(string(data), `"version":1`, `"version":2`, 1)
+	if serr := ioutil.WriteFile(name, []byte(newer), 0644); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := LoadCheckpoint(name); serr == nil || errors.Is(serr, ErrCheckpointCorrupted) {
+		t.Fatalf("newer version reported as %v", serr)
+	}
+}
@@ -0,0 +1,67 @@
+package exdgo
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// IntegrityError is the error reported when a response does not match the checksum sent by the server.
+// Such a request is retried.
+type IntegrityError struct {
+	// Path of the request.
+	Path string
+	// Algorithm of the checksum such as "sha-256".
+	Algorithm string
+	// Checksums in base64, sent by the server and calculated from the response.
+	Expected string
+	Actual   string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("request %s %s checksum mismatch: expected %s, got %s", e.Path, e.Algorithm, e.Expected, e.Actual)
+}
+
+// digestAlgorithms are algorithms of `Digest` header supported, in names of RFC 3230.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// verifyChecksum checks the body with checksums in `Digest` or `Content-MD5` header, if the server sent them.
+// Checksums are of the body as sent, so a body decompressed by the transport can not be verified.
+func verifyChecksum(path string, res *http.Response, body []byte) error {
+	if res.Uncompressed {
+		return nil
+	}
+	expected := make(map[string]string)
+	if md5sum := res.Header.Get("Content-MD5"); md5sum != "" {
+		expected["md5"] = md5sum
+	}
+	for _, digest := range strings.Split(res.Header.Get("Digest"), ",") {
+		eq := strings.IndexByte(digest, '=')
+		if eq == -1 {
+			continue
+		}
+		expected[strings.ToLower(strings.TrimSpace(digest[:eq]))] = strings.TrimSpace(digest[eq+1:])
+	}
+	for algorithm, sum := range expected {
+		newHash, ok := digestAlgorithms[algorithm]
+		if !ok {
+			continue
+		}
+		h := newHash()
+		h.Write(body)
+		actual := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		if actual != sum {
+			return &IntegrityError{Path: path, Algorithm: algorithm, Expected: sum, Actual: actual}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package exdgo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChecksumMismatch(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	body := "1577836800000000006\torderBookL2\t{}\n"
+	sum := sha256.Sum256([]byte(body))
+	var requests int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Content-Type", "text/plain")
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		} else {
+			w.Header().Set("Digest", "sha-256=invalid")
+		}
+		w.Write([]byte(body))
+		return false
+	}
+	cli := srv.client(t)
+	param := SnapshotParam{
+		Exchange: "bitmex",
+		Channels: []string{"orderBookL2"},
+		At:       time.Unix(1577836800, 0),
+	}
+	if _, serr := cli.HTTPSnapshot(param); serr != nil {
+		t.Fatal(serr)
+	}
+	_, serr := cli.HTTPSnapshot(param)
+	var ierr *IntegrityError
+	if !errors.As(serr, &ierr) || ierr.Algorithm != "sha-256" {
+		t.Fatalf("mismatch not reported: %v", serr)
+	}
+}
@@ -0,0 +1,177 @@
+package exdgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clickHouseTypes are column types for types of fields in definitions.
+var clickHouseTypes = map[string]string{
+	"timestamp": "DateTime64(9, 'UTC')",
+	"duration":  "Int64",
+	"int":       "Int64",
+	"float":     "Float64",
+	"string":    "String",
+	"boolean":   "UInt8",
+}
+
+// Escapes special characters in TabSeparated format
+var clickHouseEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\t", "\\t",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\x00", "\\0",
+)
+
+// clickHouseTable is the output of a channel.
+type clickHouseTable struct {
+	writer  *bufio.Writer
+	columns []string
+	types   []string
+}
+
+// ClickHouseWriter writes messages in TabSeparated format of ClickHouse, into an output for each channel.
+// Timestamps are written as DateTime64 in nanoseconds, so they can be inserted as is by
+// "INSERT INTO <table> FORMAT TabSeparated".
+// `Flush` must be called after writing all lines. Not safe for concurrent use.
+type ClickHouseWriter struct {
+	open   func(exchange string, channel string, schema string) (io.Writer, error)
+	tables map[string]*clickHouseTable
+}
+
+// NewClickHouseWriter creates a `ClickHouseWriter`.
+// `open` is called with the first message of each channel to get the output of the channel,
+// with the "CREATE TABLE" statement of the table for the channel derived from its definition.
+// Columns are the timestamp followed by fields in the definition sorted by name.
+func NewClickHouseWriter(open func(exchange string, channel string, schema string) (io.Writer, error)) *ClickHouseWriter {
+	return &ClickHouseWriter{open: open, tables: make(map[string]*clickHouseTable)}
+}
+
+// ClickHouseSchema returns the "CREATE TABLE" statement of the table for the channel,
+// named "<exchange>_<channel>" and ordered by the timestamp.
+func ClickHouseSchema(exchange string, channel string, definition map[string]string) string {
+	columns, types := clickHouseColumns(definition)
+	defs := make([]string, len(columns))
+	for k := range columns {
+		defs[k] = fmt.Sprintf("`%s` %s", columns[k], types[k])
+	}
+	name := regexSQLUnsafe.ReplaceAllString(exchange+"_"+channel, "_")
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) ENGINE = MergeTree ORDER BY `timestamp`", name, strings.Join(defs, ", "))
+}
+
+// clickHouseColumns returns columns and their types for the definition.
+func clickHouseColumns(definition map[string]string) ([]string, []string) {
+	columns := []string{"timestamp"}
+	types := []string{clickHouseTypes["timestamp"]}
+	fields := make([]string, 0, len(definition))
+	for field := range definition {
+		if field != "timestamp" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		typ, ok := clickHouseTypes[definition[field]]
+		if !ok {
+			// Written in JSON
+			typ = "String"
+		}
+		columns = append(columns, field)
+		types = append(types, typ)
+	}
+	return columns, types
+}
+
+// formatClickHouseValue formats the value for a column of the type.
+func formatClickHouseValue(typ string, val interface{}) (string, error) {
+	if val == nil {
+		return `\N`, nil
+	}
+	switch v := val.(type) {
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.000000000"), nil
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		if typ == clickHouseTypes["timestamp"] {
+			return time.Unix(0, v).UTC().Format("2006-01-02 15:04:05.000000000"), nil
+		}
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case string:
+		return clickHouseEscaper.Replace(v), nil
+	default:
+		encoded, serr := json.Marshal(v)
+		if serr != nil {
+			return "", serr
+		}
+		return clickHouseEscaper.Replace(string(encoded)), nil
+	}
+}
+
+// Write writes the line if it is a message, other lines are ignored.
+func (w *ClickHouseWriter) Write(line *StructLine) error {
+	if line.Type != LineTypeMessage || line.Channel == nil {
+		return nil
+	}
+	msg, ok := line.Message.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("message of %s is not an object", *line.Channel)
+	}
+	key := line.Exchange + "\x00" + *line.Channel
+	table, ok := w.tables[key]
+	if !ok {
+		out, serr := w.open(line.Exchange, *line.Channel, ClickHouseSchema(line.Exchange, *line.Channel, line.Definition))
+		if serr != nil {
+			return fmt.Errorf("opening output for %s %s: %v", line.Exchange, *line.Channel, serr)
+		}
+		if out == nil {
+			return errors.New("output is nil")
+		}
+		table = &clickHouseTable{writer: bufio.NewWriter(out)}
+		table.columns, table.types = clickHouseColumns(line.Definition)
+		w.tables[key] = table
+	}
+	for k, column := range table.columns {
+		if k > 0 {
+			table.writer.WriteByte('\t')
+		}
+		val := msg[column]
+		if k == 0 {
+			val = line.Timestamp
+		}
+		str, serr := formatClickHouseValue(table.types[k], val)
+		if serr != nil {
+			return fmt.Errorf("field %s: %v", column, serr)
+		}
+		table.writer.WriteString(str)
+	}
+	_, serr := table.writer.WriteString("\n")
+	return serr
+}
+
+// Flush writes all buffered data to outputs.
+func (w *ClickHouseWriter) Flush() error {
+	for _, table := range w.tables {
+		if serr := table.writer.Flush(); serr != nil {
+			return serr
+		}
+	}
+	return nil
+}
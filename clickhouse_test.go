@@ -0,0 +1,54 @@
+package exdgo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestClickHouseWriter(t *testing.T) {
+	outputs := make(map[string]*bytes.Buffer)
+	schemas := make(map[string]string)
+	writer := NewClickHouseWriter(func(exchange string, channel string, schema string) (io.Writer, error) {
+		buf := new(bytes.Buffer)
+		outputs[exchange+" "+channel] = buf
+		schemas[exchange+" "+channel] = schema
+		return buf, nil
+	})
+	trade := "trade"
+	quote := "quote"
+	tradeDef := map[string]string{"timestamp": "timestamp", "price": "float", "side": "string", "size": "int", "tags": "unknown"}
+	quoteDef := map[string]string{"timestamp": "timestamp", "bid": "float"}
+	lines := []StructLine{
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 0, Channel: &trade},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 1577836800000000001, Channel: &trade, Definition: tradeDef,
+			Message: map[string]interface{}{"price": 7200.5, "side": "Bu\ty", "size": int64(10), "tags": []interface{}{"a"}}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 1577836800000000002, Channel: &quote, Definition: quoteDef,
+			Message: map[string]interface{}{"timestamp": time.Unix(0, 1577836800000000002), "bid": 7200.0}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 1577836801000000000, Channel: &trade, Definition: tradeDef,
+			Message: map[string]interface{}{"price": 7201.0, "size": int64(1)}},
+	}
+	for k := range lines {
+		if serr := writer.Write(&lines[k]); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := writer.Flush(); serr != nil {
+		t.Fatal(serr)
+	}
+	expected := map[string]string{
+		"bitmex trade": "2020-01-01 00:00:00.000000001\t7200.5\tBu\\ty\t10\t[\"a\"]\n" +
+			"2020-01-01 00:00:01.000000000\t7201\t\\N\t1\t\\N\n",
+		"bitmex quote": "2020-01-01 00:00:00.000000002\t7200\n",
+	}
+	for key, e := range expected {
+		if outputs[key].String() != e {
+			t.Fatalf("%s: %q", key, outputs[key].String())
+		}
+	}
+	schema := "CREATE TABLE IF NOT EXISTS `bitmex_trade` (`timestamp` DateTime64(9, 'UTC'), `price` Float64, `side` String, `size` Int64, `tags` String) ENGINE = MergeTree ORDER BY `timestamp`"
+	if schemas["bitmex trade"] != schema {
+		t.Fatalf("schema %s", schemas["bitmex trade"])
+	}
+}
@@ -1,35 +1,194 @@
 package exdgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
 // ClientParam is the config for a client.
 type ClientParam struct {
 	// API-key used to access Exchangedataset API server.
+	// Optional, if empty, it is read from the environment variable `EXDG_APIKEY`,
+	// or "apikey" in the config file.
 	APIKey string
-	// Connection timeout.
+	// Path of the config file holding "apikey = <your API-key>", read when `APIKey` is not given.
+	// Optional, defaults to the environment variable `EXDG_CONFIG`,
+	// or "exdgo/config" under the user config directory such as "~/.config/exdgo/config".
+	ConfigFile string
+	// Provides the API-key for each request, in place of `APIKey`.
+	// Allows keys to be rotated without recreating the client.
+	// Optional, `APIKey` must be empty if given.
+	Credentials CredentialProvider
+	// Connection timeout for each HTTP request.
+	// Optional, defaults to 30 seconds.
 	Timeout *time.Duration
+	// How many times a failed request is retried, zero disables retrying.
+	// Optional, defaults to 3.
+	MaxRetries *int
+	// Wait before the first retry, doubled on every retry.
+	// Optional, defaults to 1 second.
+	RetryWait *time.Duration
+	// Number of concurrent downloads used by `Download()` of requests created from this client.
+	// Optional, defaults to 20.
+	Concurrency *int
+	// Buffer size used by `Stream()` of requests created from this client.
+	// Optional, defaults to 20.
+	BufferSize *int
+	// Maximum number of HTTP requests in flight at once across all requests created from this client,
+	// including concurrent streams and downloads.
+	// Optional, unlimited by default.
+	MaxConcurrentRequests *int
+	// Maximum download bandwidth in bytes per second, shared by all requests created from this client.
+	// Optional, unlimited by default.
+	MaxBytesPerSecond *int64
+	// HTTP client to send requests with.
+	// Optional, defaults to `http.DefaultClient`.
+	HTTPClient *http.Client
+	// User-Agent header sent with every request.
+	// Optional, the default of `net/http` is sent by default.
+	UserAgent string
+	// Additional headers sent with every request, such as ones required by a gateway.
+	// Authorization header can not be overridden.
+	// Optional.
+	Headers http.Header
+	// Base URLs of mirrors of the API.
+	// A request failed with an error worth retrying is sent to the next mirror,
+	// and mirrors failed recently are tried after healthy ones.
+	// The default endpoint is tried first while it is healthy.
+	// Optional.
+	Mirrors []string
+	// Called with every request just before it is sent, to modify it such as signing for a proxy.
+	// Returning an error fails the request.
+	// Optional.
+	RequestHook func(req *http.Request) error
+	// Directory to store responses in.
+	// Stored responses are revalidated with the server by conditional requests,
+	// and only downloaded again if they changed.
+	// Optional, responses are not stored by default.
+	CacheDir string
+	// Maximum size of the cache in bytes.
+	// Least recently used responses are removed when it is exceeded.
+	// Optional, unlimited by default.
+	CacheMaxBytes *int64
+	// Responses not used for longer than this are removed from the cache.
+	// Optional, kept forever by default.
+	CacheMaxAge *time.Duration
+	// If true, requests are served only from the cache and never sent to the server,
+	// and ones not in the cache fail with `NotCachedError`.
+	// Responses without `ETag` or `Last-Modified` are in the cache only if they were prefetched by `Client.Prefetch`.
+	// Requires `CacheDir`, and `APIKey` is not required.
+	Offline bool
+	// Function to dial connections with, such as to bind a source interface or to go through a tunnel.
+	// Can not be used with `HTTPClient`, set it in the transport of the client instead.
+	// Optional, `net.Dialer` is used by default.
+	DialContext DialFunc
+	// If set, addresses of hosts are resolved once and reused for this duration.
+	// Can not be used with `HTTPClient`.
+	// Optional, hosts are resolved for every connection by default.
+	DNSCacheTTL *time.Duration
+	// If set, iterators of streams are tracked for debugging, and this is called with the stack trace
+	// where an iterator was created when it was garbage collected without being closed.
+	// Tests can fail by panicking in this. Tracking has a cost, so leave it unset in production.
+	// See also `Client.Debug`.
+	// Optional.
+	OnIteratorLeak func(stack string)
+	// Logger to report retries and other events which do not fail requests.
+	// Optional, nothing is logged by default.
+	Logger Logger
+	// If true, the API-key is verified by a small request on creating the client,
+	// and an error matching `ErrUnauthorized` is returned if the server rejects it.
+	// Can not be used with `Offline`. See also `Client.VerifyAPIKey`.
+	VerifyAPIKey bool
+	// Request sent by `Client.VerifyAPIKey` to verify the API-key.
+	// Optional, defaults to a snapshot of "trade" of "bitmex" at 2020-01-01T00:00:00Z.
+	APIKeyProbe *APIKeyProbe
+}
+
+// Logger receives messages from a client, `*log.Logger` satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
 }
 
 // Client for accessing to Exchangedataset API.
+// It is safe for concurrent use by multiple goroutines.
 type Client struct {
-	apikey  string
-	timeout time.Duration
+	apikey string
+	// Provides the API-key in place of `apikey` if non-nil
+	credentials CredentialProvider
+	timeout     time.Duration
+	// Base URL of API, ends with slash
+	endpoint string
+	// How many times a failed request is retried
+	maxRetries int
+	// Wait before the first retry, doubled on every retry
+	retryWait time.Duration
+	// Default concurrency for `Download()`
+	concurrency int
+	// Default buffer size for `Stream()`
+	bufferSize int
+	// Semaphore limiting HTTP requests in flight, nil if unlimited
+	requestSlots chan struct{}
+	// Limits the download bandwidth, nil if unlimited
+	bandwidth  *bandwidthLimiter
+	httpClient *http.Client
+	// nil if nothing is logged
+	logger Logger
+	// nil if responses are not stored
+	cache *Cache
+	// Serves requests only from the cache if true
+	offline bool
+	// Request sent to verify the API-key
+	apiKeyProbe snapshotSetting
+	// Headers added to every request including User-Agent, nil if none
+	headers     http.Header
+	requestHook func(req *http.Request) error
+	// Endpoints to fail over including the default, nil if there is no mirror
+	mirrors *mirrorSet
+	// Iterators not yet closed, nil if not tracked
+	iterators      *iteratorRegistry
+	onIteratorLeak func(stack string)
+	// Number of background goroutines, shared by copies of the client
+	background *int64
+	// Memory used by streams, shared by copies of the client
+	memory *memoryStats
+	// Cancels requests in flight on `Close`, shared by copies of the client
+	closer *clientCloser
+	// Budgets and metrics of the session requests belong to, nil if none
+	session *sessionState
 }
 
 // setupClient finalize ClientParam and returns `Client`
 func setupClient(param ClientParam) (cli Client, err error) {
-	if param.APIKey == "" {
-		err = errors.New("empty parameter 'APIKey'")
-		return
-	}
-	if !regexAPIKey.MatchString(param.APIKey) {
-		err = errors.New("parameter 'APIKey' not a valid API-key")
-		return
+	if param.Credentials != nil {
+		if param.APIKey != "" {
+			err = errors.New("parameter 'APIKey' and 'Credentials' can not be given at the same time")
+			return
+		}
+		cli.credentials = param.Credentials
+	} else {
+		var apikey string
+		apikey, err = resolveAPIKey(param)
+		if err != nil {
+			return
+		}
+		if apikey == "" && !param.Offline {
+			err = errors.New("empty parameter 'APIKey'")
+			return
+		}
+		if apikey != "" && !regexAPIKey.MatchString(apikey) {
+			err = errors.New("parameter 'APIKey' not a valid API-key")
+			return
+		}
+		cli.apikey = apikey
 	}
-	cli.apikey = param.APIKey
+	cli.endpoint = urlAPI
 	if param.Timeout == nil {
 		// Set the default value
 		cli.timeout = clientDefaultTimeout
@@ -40,9 +199,193 @@ func setupClient(param ClientParam) (cli Client, err error) {
 		}
 		cli.timeout = *param.Timeout
 	}
+	if param.MaxRetries == nil {
+		cli.maxRetries = clientDefaultMaxRetries
+	} else {
+		if *param.MaxRetries < 0 {
+			err = errors.New("parameter 'MaxRetries' negative")
+			return
+		}
+		cli.maxRetries = *param.MaxRetries
+	}
+	if param.RetryWait == nil {
+		cli.retryWait = clientDefaultRetryWait
+	} else {
+		if *param.RetryWait < 0 {
+			err = errors.New("parameter 'RetryWait' negative")
+			return
+		}
+		cli.retryWait = *param.RetryWait
+	}
+	if param.Concurrency == nil {
+		cli.concurrency = downloadBatchSize
+	} else {
+		if *param.Concurrency < 1 {
+			err = errors.New("parameter 'Concurrency' must be positive")
+			return
+		}
+		cli.concurrency = *param.Concurrency
+	}
+	if param.BufferSize == nil {
+		cli.bufferSize = defaultBufferSize
+	} else {
+		if *param.BufferSize < 1 {
+			err = errors.New("parameter 'BufferSize' must be positive")
+			return
+		}
+		cli.bufferSize = *param.BufferSize
+	}
+	if param.MaxConcurrentRequests != nil {
+		if *param.MaxConcurrentRequests < 1 {
+			err = errors.New("parameter 'MaxConcurrentRequests' must be positive")
+			return
+		}
+		cli.requestSlots = make(chan struct{}, *param.MaxConcurrentRequests)
+	}
+	if param.MaxBytesPerSecond != nil {
+		if *param.MaxBytesPerSecond < 1 {
+			err = errors.New("parameter 'MaxBytesPerSecond' must be positive")
+			return
+		}
+		cli.bandwidth = newBandwidthLimiter(*param.MaxBytesPerSecond)
+	}
+	cli.httpClient = http.DefaultClient
+	if param.HTTPClient != nil {
+		if param.DialContext != nil || param.DNSCacheTTL != nil {
+			err = errors.New("parameter 'DialContext' and 'DNSCacheTTL' can not be used with 'HTTPClient'")
+			return
+		}
+		cli.httpClient = param.HTTPClient
+	}
+	if param.DialContext != nil || param.DNSCacheTTL != nil {
+		dial := param.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		}
+		if param.DNSCacheTTL != nil {
+			if *param.DNSCacheTTL <= 0 {
+				err = errors.New("parameter 'DNSCacheTTL' must be positive")
+				return
+			}
+			dial = newDNSCache(*param.DNSCacheTTL).dial(dial)
+		}
+		cli.httpClient = newDialingHTTPClient(dial)
+	}
+	cli.logger = param.Logger
+	cli.background = new(int64)
+	cli.memory = new(memoryStats)
+	cli.closer = newClientCloser()
+	if param.OnIteratorLeak != nil {
+		cli.iterators = newIteratorRegistry()
+		cli.onIteratorLeak = param.OnIteratorLeak
+	}
+	cli.requestHook = param.RequestHook
+	if len(param.Mirrors) > 0 {
+		endpoints := []string{cli.endpoint}
+		for _, mirror := range param.Mirrors {
+			endpoint, serr := normalizeEndpoint(mirror)
+			if serr != nil {
+				err = fmt.Errorf("'Mirrors': %v", serr)
+				return
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+		cli.mirrors = newMirrorSet(endpoints)
+	}
+	if len(param.Headers) > 0 || param.UserAgent != "" {
+		cli.headers = param.Headers.Clone()
+		if cli.headers == nil {
+			cli.headers = make(http.Header)
+		}
+		if param.UserAgent != "" {
+			cli.headers.Set("User-Agent", param.UserAgent)
+		}
+	}
+	if param.CacheDir != "" {
+		var maxBytes int64
+		var maxAge time.Duration
+		if param.CacheMaxBytes != nil {
+			if *param.CacheMaxBytes < 1 {
+				err = errors.New("parameter 'CacheMaxBytes' must be positive")
+				return
+			}
+			maxBytes = *param.CacheMaxBytes
+		}
+		if param.CacheMaxAge != nil {
+			if *param.CacheMaxAge <= 0 {
+				err = errors.New("parameter 'CacheMaxAge' must be positive")
+				return
+			}
+			maxAge = *param.CacheMaxAge
+		}
+		cli.cache, err = newCache(param.CacheDir, maxBytes, maxAge)
+		if err != nil {
+			return
+		}
+	}
+	if param.Offline {
+		if cli.cache == nil {
+			err = errors.New("parameter 'Offline' requires 'CacheDir'")
+			return
+		}
+		cli.offline = true
+	}
+	if param.VerifyAPIKey && cli.offline {
+		err = errors.New("parameter 'VerifyAPIKey' can not be used with 'Offline'")
+		return
+	}
+	cli.apiKeyProbe = defaultAPIKeyProbe
+	if param.APIKeyProbe != nil {
+		if param.APIKeyProbe.Exchange == "" || param.APIKeyProbe.Channel == "" {
+			err = errors.New("parameter 'APIKeyProbe' must have 'Exchange' and 'Channel'")
+			return
+		}
+		cli.apiKeyProbe = snapshotSetting{
+			exchange: param.APIKeyProbe.Exchange,
+			channels: []string{param.APIKeyProbe.Channel},
+			at:       param.APIKeyProbe.At.UnixNano(),
+		}
+	}
 	return
 }
 
+// normalizeEndpoint validates the base URL and makes it end with a slash.
+func normalizeEndpoint(endpoint string) (string, error) {
+	parsed, serr := url.Parse(endpoint)
+	if serr != nil {
+		return "", serr
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%q is not an absolute URL of http or https", endpoint)
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/", nil
+}
+
+// withEndpoint returns a copy of the client sending requests to the endpoint without mirrors.
+// Limits such as the request slots and the bandwidth are shared with the original.
+func (c *Client) withEndpoint(endpoint string) (*Client, error) {
+	normalized, serr := normalizeEndpoint(endpoint)
+	if serr != nil {
+		return nil, fmt.Errorf("'Endpoint': %v", serr)
+	}
+	copied := *c
+	copied.endpoint = normalized
+	copied.mirrors = nil
+	return &copied, nil
+}
+
+// Cache returns the cache of this client, nil if `CacheDir` was not given.
+func (c *Client) Cache() *Cache {
+	return c.cache
+}
+
+// logf logs the message if the client has a logger.
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
 // CreateClient creates new Client and returns pointer to it.
 func CreateClient(param ClientParam) (*Client, error) {
 	// Create new Client
@@ -50,5 +393,186 @@ func CreateClient(param ClientParam) (*Client, error) {
 	if serr != nil {
 		return nil, serr
 	}
+	if param.VerifyAPIKey {
+		if serr := client.VerifyAPIKey(context.Background()); serr != nil {
+			return nil, serr
+		}
+	}
 	return &client, nil
 }
+
+// APIKeyProbe is the request `Client.VerifyAPIKey` sends, a snapshot of a channel of an exchange at a time.
+// Choose one the API-key has access to, since a key without access to it is reported as rejected.
+type APIKeyProbe struct {
+	Exchange string
+	Channel  string
+	At       time.Time
+}
+
+// defaultAPIKeyProbe is the request sent to verify the API-key if `ClientParam.APIKeyProbe` is not given.
+var defaultAPIKeyProbe = snapshotSetting{
+	exchange: "bitmex",
+	channels: []string{"trade"},
+	// 2020-01-01T00:00:00Z
+	at: 1577836800000000000,
+}
+
+// VerifyAPIKey sends a small authenticated request to check the API-key is accepted by the server,
+// so an invalid key is reported before the first download.
+// The request is a snapshot of a single channel given by `ClientParam.APIKeyProbe`,
+// which is "trade" of "bitmex" at 2020-01-01T00:00:00Z by default.
+// Returns an error matching `ErrUnauthorized` if the server rejected the key,
+// or the error of the request if it failed otherwise.
+func (c *Client) VerifyAPIKey(ctx context.Context) error {
+	if c.offline {
+		return errors.New("API-key can not be verified offline")
+	}
+	serr := retry(ctx, c, func() error {
+		_, serr := httpSnapshot(ctx, c, c.apiKeyProbe)
+		return serr
+	})
+	if serr == nil {
+		return nil
+	}
+	if errors.Is(serr, ErrUnauthorized) {
+		return fmt.Errorf("verifying API-key: %w", serr)
+	}
+	return fmt.Errorf("verifying API-key: %v", serr)
+}
+
+// ErrClientClosed is the error returned by requests sent after `Client.Close` is called,
+// or the ones in flight cancelled by it.
+var ErrClientClosed = errors.New("client closed")
+
+// clientCloser cancels contexts of requests in flight when the client is closed,
+// and tracks streams and downloads in progress for `Shutdown`.
+type clientCloser struct {
+	mutex  sync.Mutex
+	closed bool
+	// Cancels contexts bound and not yet released
+	cancels map[int64]context.CancelFunc
+	nextID  int64
+	// Set by `Shutdown`, new streams and downloads are not started
+	draining bool
+	// Number of streams not yet closed and downloads not yet returned
+	active int
+	// Closed when nothing is active after draining started, nil before that
+	idle chan struct{}
+}
+
+func newClientCloser() *clientCloser {
+	return &clientCloser{cancels: make(map[int64]context.CancelFunc)}
+}
+
+// bind returns the context which is cancelled when the client is closed, and the function
+// to release it which must be called when the context is no longer used.
+// Returns `ErrClientClosed` if the client has already been closed.
+func (c *clientCloser) bind(ctx context.Context) (context.Context, func(), error) {
+	if c == nil {
+		return ctx, func() {}, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil, nil, ErrClientClosed
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	id := c.nextID
+	c.nextID++
+	c.cancels[id] = cancel
+	return ctx, func() {
+		c.mutex.Lock()
+		delete(c.cancels, id)
+		c.mutex.Unlock()
+		cancel()
+	}, nil
+}
+
+// begin counts a stream or a download in progress until the returned function is called.
+// Returns `ErrClientClosed` if the client has been closed or is shutting down.
+func (c *clientCloser) begin() (func(), error) {
+	if c == nil {
+		return func() {}, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed || c.draining {
+		return nil, ErrClientClosed
+	}
+	c.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			c.active--
+			// Nothing is started while draining, so this happens only once
+			if c.draining && c.active == 0 {
+				close(c.idle)
+			}
+		})
+	}, nil
+}
+
+// isClosed reports whether the client has been closed.
+func (c *clientCloser) isClosed() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// Close cancels all requests in flight and makes requests sent afterwards fail with `ErrClientClosed`,
+// including ones of requests with `Endpoint` given.
+// Iterators of streams still have to be closed, they return the error from `Next` once the
+// request for the shard is cancelled.
+// Calling it more than once has no effect.
+func (c *Client) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	c.closer.mutex.Lock()
+	if c.closer.closed {
+		c.closer.mutex.Unlock()
+		return nil
+	}
+	c.closer.closed = true
+	cancels := c.closer.cancels
+	c.closer.cancels = nil
+	c.closer.mutex.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// Shutdown stops starting new streams and downloads, waits for ones in progress to finish,
+// and closes the client as `Close` does.
+// A stream finishes when its iterator is closed.
+// If the context is done before they finish, the client is closed cancelling their requests,
+// and the error of the context is returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	if c.closer == nil {
+		return nil
+	}
+	c.closer.mutex.Lock()
+	if !c.closer.draining {
+		c.closer.draining = true
+		c.closer.idle = make(chan struct{})
+		if c.closer.active == 0 {
+			close(c.closer.idle)
+		}
+	}
+	idle := c.closer.idle
+	c.closer.mutex.Unlock()
+	select {
+	case <-idle:
+		return c.Close()
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,279 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetupClientDefaults(t *testing.T) {
+	cli, serr := setupClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.timeout != clientDefaultTimeout || cli.maxRetries != clientDefaultMaxRetries || cli.retryWait != clientDefaultRetryWait {
+		t.Fatalf("defaults not set: %+v", cli)
+	}
+	zero := 0
+	wait := 10 * time.Millisecond
+	cli, serr = setupClient(ClientParam{APIKey: "demo", MaxRetries: &zero, RetryWait: &wait})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.maxRetries != 0 || cli.retryWait != wait {
+		t.Fatalf("parameters not set: %+v", cli)
+	}
+	negative := -1
+	if _, serr := setupClient(ClientParam{APIKey: "demo", MaxRetries: &negative}); serr == nil {
+		t.Fatal("negative MaxRetries accepted")
+	}
+	negativeWait := -time.Second
+	if _, serr := setupClient(ClientParam{APIKey: "demo", RetryWait: &negativeWait}); serr == nil {
+		t.Fatal("negative RetryWait accepted")
+	}
+}
+
+func TestSetupClientConcurrencyAndBufferSize(t *testing.T) {
+	cli, serr := setupClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.concurrency != downloadBatchSize || cli.bufferSize != defaultBufferSize {
+		t.Fatalf("defaults not set: %+v", cli)
+	}
+	concurrency := 64
+	bufferSize := 2
+	cli, serr = setupClient(ClientParam{APIKey: "demo", Concurrency: &concurrency, BufferSize: &bufferSize})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.concurrency != concurrency || cli.bufferSize != bufferSize {
+		t.Fatalf("parameters not set: %+v", cli)
+	}
+	zero := 0
+	if _, serr := setupClient(ClientParam{APIKey: "demo", Concurrency: &zero}); serr == nil {
+		t.Fatal("zero Concurrency accepted")
+	}
+	if _, serr := setupClient(ClientParam{APIKey: "demo", BufferSize: &zero}); serr == nil {
+		t.Fatal("zero BufferSize accepted")
+	}
+}
+
+func TestClientConcurrentUse(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lines, serr := req.Download()
+			if serr == nil && len(lines) != 2+2*6*10 {
+				serr = fmt.Errorf("len(lines) = %d", len(lines))
+			}
+			errs <- serr
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for serr := range errs {
+		if serr != nil {
+			t.Fatal(serr)
+		}
+	}
+}
+
+func TestClientCloseCancelsRequests(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	arrived := make(chan struct{}, 100)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		arrived <- struct{}{}
+		// Hold the request until it is cancelled
+		<-r.Context().Done()
+		return false
+	}
+	req := prepareFakeRawRequest(t, srv)
+	done := make(chan error)
+	go func() {
+		_, serr := req.Download()
+		done <- serr
+	}()
+	<-arrived
+	if serr := req.cli.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	select {
+	case serr := <-done:
+		if !errors.Is(serr, ErrClientClosed) {
+			t.Fatalf("serr = %v", serr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not cancelled")
+	}
+	if _, serr := req.Download(); !errors.Is(serr, ErrClientClosed) {
+		t.Fatalf("serr = %v after close", serr)
+	}
+	if serr := req.cli.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+}
+
+func TestClientShutdownDrainsStreams(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	done := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- req.cli.Shutdown(ctx)
+	}()
+	// Wait for shutting down to start
+	for {
+		if _, serr := req.Download(); errors.Is(serr, ErrClientClosed) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	count := 1
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if count != 2+2*6*10 {
+		t.Fatalf("count = %d", count)
+	}
+	select {
+	case <-done:
+		t.Fatal("shut down before the stream was closed")
+	default:
+	}
+	itr.Close()
+	if serr := <-done; serr != nil {
+		t.Fatal(serr)
+	}
+}
+
+func TestClientShutdownDeadline(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var block int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if atomic.LoadInt32(&block) == 1 {
+			<-r.Context().Done()
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	itr, serr := req.StreamBufferSize(1)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	atomic.StoreInt32(&block, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if serr := req.cli.Shutdown(ctx); serr != context.DeadlineExceeded {
+		t.Fatalf("serr = %v", serr)
+	}
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr == nil {
+				t.Fatal("stream reached the end after the client was closed")
+			}
+			break
+		}
+	}
+}
+
+func TestClientVerifyAPIKey(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer demo" {
+			http.Error(w, `{"error":"invalid API-key"}`, http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	cli := srv.client(t)
+	if serr := cli.VerifyAPIKey(context.Background()); serr != nil {
+		t.Fatal(serr)
+	}
+	cli.apikey = "invalid"
+	serr := cli.VerifyAPIKey(context.Background())
+	if !errors.Is(serr, ErrUnauthorized) {
+		t.Fatalf("invalid API-key not reported: %v", serr)
+	}
+	if requests := atomic.LoadInt64(&srv.requests); requests != 2 {
+		t.Fatalf("%d requests sent, rejected key should not be retried", requests)
+	}
+	dir, serr := ioutil.TempDir("", "exdgo")
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer os.RemoveAll(dir)
+	if _, serr := NewClient("", WithCache(dir), WithOffline(), WithAPIKeyVerification()); serr == nil {
+		t.Fatal("verification accepted offline")
+	}
+}
+
+func TestClientVerifyAPIKeyProbe(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var path atomic.Value
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		path.Store(r.URL.Path + "?" + r.URL.RawQuery)
+		return true
+	}
+	at := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	retryWait := time.Millisecond
+	cli, serr := setupClient(ClientParam{
+		APIKey:      "demo",
+		RetryWait:   &retryWait,
+		APIKeyProbe: &APIKeyProbe{Exchange: "bitflyer", Channel: "lightning_board_FX_BTC_JPY", At: at},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cli.endpoint = srv.server.URL + "/"
+	if serr := cli.VerifyAPIKey(context.Background()); serr != nil {
+		t.Fatal(serr)
+	}
+	sent := path.Load().(string)
+	if !strings.HasPrefix(sent, fmt.Sprintf("/snapshot/bitflyer/%d?", at.UnixNano())) || !strings.Contains(sent, "channels=lightning_board_FX_BTC_JPY") {
+		t.Fatalf("unexpected request: %s", sent)
+	}
+	if _, serr := setupClient(ClientParam{APIKey: "demo", APIKeyProbe: &APIKeyProbe{Exchange: "bitmex"}}); serr == nil {
+		t.Fatal("probe without channel accepted")
+	}
+}
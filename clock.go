@@ -0,0 +1,174 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for features paced by time.
+// Use `RealClock` for playback in real time and `SimulatedClock` for tests
+// which run instantly and deterministically.
+type Clock interface {
+	// Now returns the current time of this clock.
+	Now() time.Time
+	// Sleep blocks until the duration had elapsed on this clock or the context is done.
+	// Returns the context error if the context is done.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// RealClock is `Clock` backed by the system time.
+type RealClock struct{}
+
+// Now returns `time.Now()`.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep sleeps for the duration or until the context is done.
+func (RealClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SimulatedClock is `Clock` whose time only advances by `Sleep` or `Advance`.
+// Sleep returns immediately after advancing the time.
+// Safe for concurrent use.
+type SimulatedClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewSimulatedClock creates new `SimulatedClock` starting at the given time.
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start}
+}
+
+// Now returns the current simulated time.
+func (c *SimulatedClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Sleep advances the simulated time by the duration without blocking.
+func (c *SimulatedClock) Sleep(ctx context.Context, d time.Duration) error {
+	if serr := ctx.Err(); serr != nil {
+		return serr
+	}
+	c.Advance(d)
+	return nil
+}
+
+// Advance advances the simulated time by the duration.
+// Negative duration is ignored.
+func (c *SimulatedClock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// PaceParam is the parameters for `Pace`.
+type PaceParam struct {
+	// Clock to pace lines with.
+	// Optional, defaults to `RealClock`.
+	Clock Clock
+	// Speed of playback relative to the real time, 2 plays twice as fast.
+	// Optional, defaults to 1.
+	Speed float64
+}
+
+type paceIterator struct {
+	itr   StructLineIterator
+	clock Clock
+	speed float64
+	// Timestamp of the first line and the time it was yielded
+	started   bool
+	firstLine int64
+	firstTime time.Time
+	// Cancelled by `Close` to stop waiting for a line to be due
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Held while `Next` runs, so the iterator given is not closed while being read
+	mutex  sync.Mutex
+	closed bool
+}
+
+// Pace returns an iterator which yields lines at the pace of their timestamps,
+// as if they were received in real time.
+// The first line is yielded immediately, and the following lines are yielded
+// when the time elapsed on the clock since then reaches the difference of their timestamps.
+//
+// Combined with `InjectLatency`, lines are yielded at the time they would arrive with the latency.
+//
+// The iterator given is closed when the returned iterator is closed.
+// `Close` can be called from another goroutine while `Next` waits for a line to be due,
+// which stops the wait and makes `Next` return `ErrIteratorClosed`.
+// The iterator given is closed after `Next` returned, so `Close` waits for its `Next` if it is running.
+func Pace(itr StructLineIterator, param PaceParam) (StructLineIterator, error) {
+	i := new(paceIterator)
+	i.itr = itr
+	i.clock = param.Clock
+	if i.clock == nil {
+		i.clock = RealClock{}
+	}
+	i.speed = param.Speed
+	if i.speed == 0 {
+		i.speed = 1
+	} else if i.speed < 0 {
+		return nil, errors.New("negative 'Speed'")
+	}
+	i.ctx, i.cancel = context.WithCancel(context.Background())
+	return i, nil
+}
+
+func (i *paceIterator) Next() (*StructLine, bool, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	line, ok, serr := i.itr.Next()
+	if !ok {
+		return nil, false, serr
+	}
+	if !i.started {
+		i.started = true
+		i.firstLine = line.Timestamp
+		i.firstTime = i.clock.Now()
+		return line, true, nil
+	}
+	due := i.firstTime.Add(time.Duration(float64(line.Timestamp-i.firstLine) / i.speed))
+	if serr := i.clock.Sleep(i.ctx, due.Sub(i.clock.Now())); serr != nil {
+		if i.ctx.Err() != nil {
+			return nil, false, ErrIteratorClosed
+		}
+		return nil, false, serr
+	}
+	return line, true, nil
+}
+
+func (i *paceIterator) Close() error {
+	// Stop the wait before taking the lock held by `Next`
+	i.cancel()
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.itr.Close()
+}
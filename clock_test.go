@@ -0,0 +1,114 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPaceSimulated(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewSimulatedClock(start)
+	lines := testLines(10, []string{"bitmex"}, []string{"trades"})
+	itr, serr := Pace(newSliceIterator(lines), PaceParam{Clock: clock, Speed: 2})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	for j := range lines {
+		line, ok, serr := itr.Next()
+		if !ok {
+			t.Fatalf("no line: %v", serr)
+		}
+		// Lines are a second apart, played twice as fast
+		if elapsed := clock.Now().Sub(start); elapsed != time.Duration(j)*500*time.Millisecond {
+			t.Fatalf("line %d yielded at %v", j, elapsed)
+		}
+		if line.Timestamp != lines[j].Timestamp {
+			t.Fatalf("line %d differ", j)
+		}
+	}
+}
+
+func TestPaceWithLatency(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewSimulatedClock(start)
+	lines := testLines(10, []string{"bitmex"}, []string{"trades"})
+	delayed, serr := InjectLatency(newSliceIterator(lines), LatencyParam{Latency: time.Second})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := Pace(delayed, PaceParam{Clock: clock})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	count := 0
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if count != 10 || clock.Now().Sub(start) != 9*time.Second {
+		t.Fatalf("count = %d, elapsed = %v", count, clock.Now().Sub(start))
+	}
+}
+
+func TestPaceCloseWhileWaiting(t *testing.T) {
+	lines := testLines(10, []string{"bitmex"}, []string{"trades"})
+	// Lines are a second apart, so the second line is due after hours
+	itr, serr := Pace(newSliceIterator(lines), PaceParam{Speed: 0.0001})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	returned := make(chan error)
+	go func() {
+		_, _, serr := itr.Next()
+		returned <- serr
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	select {
+	case serr := <-returned:
+		if serr != ErrIteratorClosed {
+			t.Fatalf("expected ErrIteratorClosed, got %v", serr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next kept waiting after Close")
+	}
+}
+
+func TestPaceCloseWhileReading(t *testing.T) {
+	lines := testLines(10, []string{"bitmex"}, []string{"trades"})
+	source := newSliceIterator(lines)
+	itr, serr := Pace(&slowIterator{StructLineIterator: source, delay: 100 * time.Millisecond}, PaceParam{Speed: 1000})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	returned := make(chan bool)
+	go func() {
+		_, ok, _ := itr.Next()
+		returned <- ok
+	}()
+	// Close while the source is being read
+	time.Sleep(20 * time.Millisecond)
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	<-returned
+	if !source.closed {
+		t.Fatal("source is not closed")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/exchangedataset/exdgo"
+)
+
+const cacheUsage = `Usage: exd cache <command> -dir <directory> [flags]
+
+Commands:
+  stats     show the number and the size of entries
+  prune     remove entries over the limits given by -max-bytes and -max-age
+  prefetch  download data of a range into the cache
+  verify    find entries which can not be read, and remove them with -remove
+`
+
+func runCache(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cacheUsage)
+		return errUsage
+	}
+	flags := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", "", "directory of the cache")
+	switch args[0] {
+	case "stats":
+		if serr := parseCacheFlags(flags, args[1:], dir, stderr); serr != nil {
+			return serr
+		}
+		cache, serr := exdgo.OpenCache(*dir, 0, 0)
+		if serr != nil {
+			return serr
+		}
+		return printCacheStats(cache, stdout)
+	case "prune":
+		maxBytes := flags.Int64("max-bytes", 0, "remove least recently used entries until the cache fits in the size")
+		maxAge := flags.Duration("max-age", 0, "remove entries not used longer than the duration")
+		if serr := parseCacheFlags(flags, args[1:], dir, stderr); serr != nil {
+			return serr
+		}
+		if *maxBytes == 0 && *maxAge == 0 {
+			return errors.New("-max-bytes or -max-age is required")
+		}
+		cache, serr := exdgo.OpenCache(*dir, *maxBytes, *maxAge)
+		if serr != nil {
+			return serr
+		}
+		if serr := cache.Prune(); serr != nil {
+			return serr
+		}
+		return printCacheStats(cache, stdout)
+	case "verify":
+		remove := flags.Bool("remove", false, "remove entries which can not be read")
+		if serr := parseCacheFlags(flags, args[1:], dir, stderr); serr != nil {
+			return serr
+		}
+		cache, serr := exdgo.OpenCache(*dir, 0, 0)
+		if serr != nil {
+			return serr
+		}
+		corrupted, serr := cache.Verify(*remove)
+		if serr != nil {
+			return serr
+		}
+		for _, name := range corrupted {
+			fmt.Fprintln(stdout, name)
+		}
+		if len(corrupted) > 0 && !*remove {
+			return fmt.Errorf("%d entries can not be read", len(corrupted))
+		}
+		return nil
+	case "prefetch":
+		request := addRequestFlags(flags)
+		if serr := request.parse(flags, args[1:], stderr); serr != nil {
+			return serr
+		}
+		if *dir == "" {
+			fmt.Fprintln(stderr, "-dir is required")
+			flags.Usage()
+			return errUsage
+		}
+		cli, serr := request.client(exdgo.WithCache(*dir))
+		if serr != nil {
+			return serr
+		}
+		defer cli.Close()
+		if serr := cli.Prefetch(ctx, request.filter, request.startTime, request.endTime); serr != nil {
+			return serr
+		}
+		return printCacheStats(cli.Cache(), stdout)
+	default:
+		fmt.Fprintf(stderr, "exd cache: unknown command %q\n%s", args[0], cacheUsage)
+		return errUsage
+	}
+}
+
+// parseCacheFlags parses arguments of commands which only work on the cache directory.
+func parseCacheFlags(flags *flag.FlagSet, args []string, dir *string, stderr io.Writer) error {
+	if serr := flags.Parse(args); serr != nil {
+		return errUsage
+	}
+	if flags.NArg() > 0 || *dir == "" {
+		fmt.Fprintln(stderr, "-dir is required and no argument is accepted")
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func printCacheStats(cache *exdgo.Cache, w io.Writer) error {
+	stats, serr := cache.Stats()
+	if serr != nil {
+		return serr
+	}
+	fmt.Fprintf(w, "entries: %d\nbytes: %d\n", stats.Entries, stats.Bytes)
+	if stats.Entries > 0 {
+		fmt.Fprintf(w, "oldest: %s\nnewest: %s\n", stats.Oldest.UTC().Format(time.RFC3339), stats.Newest.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
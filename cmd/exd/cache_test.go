@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCacheCommands(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exd")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"cache", "prefetch", "-sample", "-dir", dir,
+		"-filter", "bitmex:trade",
+		"-start", "2020-01-01T00:00:00Z",
+		"-end", "2020-01-01T00:03:00Z",
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("code = %d: %s", code, stderr.String())
+	}
+	// A snapshot and 3 minutes
+	if !strings.HasPrefix(stdout.String(), "entries: 4\n") {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+
+	// Break an entry
+	var broken string
+	filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && broken == "" {
+			broken = name
+		}
+		return nil
+	})
+	if serr := ioutil.WriteFile(broken, []byte("broken"), 0644); serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	stdout.Reset()
+	if code := run(context.Background(), []string{"cache", "verify", "-dir", dir}, &stdout, ioutil.Discard); code != 1 {
+		t.Fatalf("code = %d", code)
+	}
+	if stdout.String() != broken+"\n" {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+	if code := run(context.Background(), []string{"cache", "verify", "-remove", "-dir", dir}, ioutil.Discard, ioutil.Discard); code != 0 {
+		t.Fatalf("code = %d", code)
+	}
+
+	stdout.Reset()
+	if code := run(context.Background(), []string{"cache", "stats", "-dir", dir}, &stdout, ioutil.Discard); code != 0 {
+		t.Fatalf("code = %d", code)
+	}
+	if !strings.HasPrefix(stdout.String(), "entries: 3\n") {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+	stdout.Reset()
+	if code := run(context.Background(), []string{"cache", "prune", "-dir", dir, "-max-bytes", "1"}, &stdout, ioutil.Discard); code != 0 {
+		t.Fatalf("code = %d", code)
+	}
+	if stdout.String() != "entries: 0\nbytes: 0\n" {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+}
+
+func TestCacheUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"cache"},
+		{"cache", "clear"},
+		{"cache", "stats"},
+		{"cache", "prefetch", "-sample", "-filter", "bitmex:trade", "-start", "2020-01-01", "-end", "2020-01-02"},
+	} {
+		if code := run(context.Background(), args, ioutil.Discard, ioutil.Discard); code != 2 {
+			t.Errorf("code = %d for %q", code, args)
+		}
+	}
+}
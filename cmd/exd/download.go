@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/exchangedataset/exdgo"
+)
+
+// Output formats of the download command
+const (
+	// Raw lines separated by tabs, as the response of Filter HTTP Endpoint prefixed by the exchange
+	outputRaw = "raw"
+	// Decoded lines in JSON, one in a line
+	outputJSONL = "jsonl"
+	// Line protocol of InfluxDB
+	outputInflux = "influx"
+	// Binary encoding read by `exdgo.LineReader`
+	outputEXDL = "exdl"
+)
+
+func runDownload(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	request := addRequestFlags(flags)
+	output := flags.String("o", "", "file to write into, the standard output if not given")
+	outputFormat := flags.String("output-format", outputJSONL, "format of the output: raw, jsonl, influx or exdl")
+	if serr := request.parse(flags, args, stderr); serr != nil {
+		return serr
+	}
+	switch *outputFormat {
+	case outputRaw, outputJSONL, outputInflux, outputEXDL:
+	default:
+		return fmt.Errorf("unknown output format %q", *outputFormat)
+	}
+	cli, serr := request.client()
+	if serr != nil {
+		return serr
+	}
+	defer cli.Close()
+
+	var w io.Writer = stdout
+	var file *os.File
+	if *output != "" {
+		file, serr = os.Create(*output)
+		if serr != nil {
+			return serr
+		}
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+	serr = download(ctx, cli, exdgo.RawRequestParam{
+		Filter: request.filter,
+		Start:  request.startTime,
+		End:    request.endTime,
+	}, *outputFormat, buffered)
+	if serr == nil {
+		serr = buffered.Flush()
+	}
+	if file != nil {
+		if cerr := file.Close(); serr == nil {
+			serr = cerr
+		}
+		if serr != nil {
+			// Do not leave the incomplete file
+			os.Remove(*output)
+		}
+	}
+	return serr
+}
+
+// download streams lines of the range into `w` in the output format.
+func download(ctx context.Context, cli *exdgo.Client, param exdgo.RawRequestParam, outputFormat string, w io.Writer) error {
+	if outputFormat == outputRaw {
+		req, serr := cli.Raw(param)
+		if serr != nil {
+			return serr
+		}
+		return req.DownloadFunc(ctx, func(line *exdgo.StringLine) error {
+			return writeRawLine(w, line)
+		})
+	}
+	req, serr := cli.Replay(exdgo.ReplayRequestParam{
+		Filter: param.Filter,
+		Start:  param.Start,
+		End:    param.End,
+	})
+	if serr != nil {
+		return serr
+	}
+	var write func(line *exdgo.StructLine) error
+	var flush func() error
+	switch outputFormat {
+	case outputJSONL:
+		encoder := json.NewEncoder(w)
+		write = func(line *exdgo.StructLine) error { return encoder.Encode(newJSONLine(line)) }
+		flush = func() error { return nil }
+	case outputInflux:
+		writer := exdgo.NewInfluxWriter(w, exdgo.InfluxWriterParam{})
+		write = writer.Write
+		flush = writer.Flush
+	case outputEXDL:
+		writer := exdgo.NewLineWriter(w)
+		write = writer.Write
+		flush = writer.Flush
+	}
+	if serr := req.DownloadFunc(ctx, write); serr != nil {
+		return serr
+	}
+	return flush()
+}
+
+// writeRawLine writes the line as the response of Filter HTTP Endpoint does, prefixed by the exchange.
+func writeRawLine(w io.Writer, line *exdgo.StringLine) error {
+	fields := []string{line.Exchange, string(line.Type), strconv.FormatInt(line.Timestamp, 10)}
+	if line.Type != exdgo.LineTypeEnd {
+		if line.Channel != nil {
+			fields = append(fields, *line.Channel)
+		}
+		// Messages of start and error lines keep the newline of the response
+		fields = append(fields, strings.TrimSuffix(string(line.Message), "\n"))
+	}
+	_, serr := io.WriteString(w, strings.Join(fields, "\t")+"\n")
+	return serr
+}
+
+// jsonLine is a decoded line written in the jsonl output format.
+type jsonLine struct {
+	Exchange  string      `json:"exchange"`
+	Type      string      `json:"type"`
+	Timestamp int64       `json:"timestamp"`
+	Channel   *string     `json:"channel,omitempty"`
+	Message   interface{} `json:"message,omitempty"`
+}
+
+func newJSONLine(line *exdgo.StructLine) *jsonLine {
+	return &jsonLine{
+		Exchange:  line.Exchange,
+		Type:      string(line.Type),
+		Timestamp: line.Timestamp,
+		Channel:   line.Channel,
+		Message:   line.Message,
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadSample(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exd")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "out.jsonl")
+	var stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"download", "-sample",
+		"-filter", "bitmex:trade",
+		"-filter", "bitfinex:trade,quote",
+		"-start", "2020-01-01T00:00:00Z",
+		"-end", "2020-01-01T00:01:00Z",
+		"-o", name,
+	}, ioutil.Discard, &stderr)
+	if code != 0 {
+		t.Fatalf("code = %d: %s", code, stderr.String())
+	}
+	data, serr := ioutil.ReadFile(name)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 10 {
+		t.Fatalf("only %d lines", len(lines))
+	}
+	var last int64
+	for _, line := range lines {
+		var decoded jsonLine
+		if serr := json.Unmarshal([]byte(line), &decoded); serr != nil {
+			t.Fatalf("%v: %s", serr, line)
+		}
+		if decoded.Timestamp < last {
+			t.Fatalf("not in order: %s", line)
+		}
+		last = decoded.Timestamp
+	}
+}
+
+func TestDownloadRawOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"download", "-sample",
+		"-filter", "bitmex:trade",
+		"-start", "2020-01-01",
+		"-end", "1577836860",
+		"-output-format", "raw",
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("code = %d: %s", code, stderr.String())
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "bitmex\t") {
+			t.Fatalf("line = %q", line)
+		}
+	}
+}
+
+func TestDownloadUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"upload"},
+		{"download", "-start", "2020-01-01", "-end", "2020-01-02"},
+		{"download", "-filter", "bitmex", "-start", "2020-01-01", "-end", "2020-01-02"},
+	} {
+		if code := run(context.Background(), args, ioutil.Discard, ioutil.Discard); code != 2 {
+			t.Errorf("code = %d for %q", code, args)
+		}
+	}
+	code := run(context.Background(), []string{
+		"download", "-sample", "-filter", "bitmex:trade", "-start", "2020-01-01", "-end", "2020-01-02", "-output-format", "csv",
+	}, ioutil.Discard, ioutil.Discard)
+	if code != 1 {
+		t.Fatalf("code = %d for unknown output format", code)
+	}
+}
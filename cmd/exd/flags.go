@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/exchangedataset/exdgo"
+)
+
+// filterFlag collects `-filter exchange:channel,...` flags into a filter.
+type filterFlag map[string][]string
+
+func (f filterFlag) String() string {
+	parts := make([]string, 0, len(f))
+	for exchange, channels := range f {
+		parts = append(parts, exchange+":"+strings.Join(channels, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f filterFlag) Set(value string) error {
+	colon := strings.IndexByte(value, ':')
+	if colon <= 0 || colon == len(value)-1 {
+		return fmt.Errorf("%q is not in the form of exchange:channel,...", value)
+	}
+	exchange := value[:colon]
+	for _, channel := range strings.Split(value[colon+1:], ",") {
+		if channel == "" {
+			return fmt.Errorf("%q has an empty channel", value)
+		}
+		f[exchange] = append(f[exchange], channel)
+	}
+	return nil
+}
+
+// requestFlags are flags shared by commands to make a request.
+type requestFlags struct {
+	filter filterFlag
+	start  string
+	end    string
+	apikey string
+	sample bool
+	// Set by `parse`
+	startTime time.Time
+	endTime   time.Time
+}
+
+func addRequestFlags(flags *flag.FlagSet) *requestFlags {
+	f := &requestFlags{filter: make(filterFlag)}
+	flags.Var(f.filter, "filter", "exchange and channels in `exchange:channel,...`, can be repeated")
+	flags.StringVar(&f.start, "start", "", "start of the range, in RFC3339, a date or unix time")
+	flags.StringVar(&f.end, "end", "", "end of the range, exclusive, in the same form as -start")
+	flags.StringVar(&f.apikey, "apikey", "", "API-key, read from the environment variable or the config file if not given")
+	flags.BoolVar(&f.sample, "sample", false, "use the sample data served without the network, to try the command")
+	return f
+}
+
+// parse parses arguments into the flags and checks ones required are given.
+// Returns `errUsage` if arguments are wrong.
+func (f *requestFlags) parse(flags *flag.FlagSet, args []string, stderr io.Writer) error {
+	if serr := flags.Parse(args); serr != nil {
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return errUsage
+	}
+	if len(f.filter) == 0 || f.start == "" || f.end == "" {
+		fmt.Fprintln(stderr, "-filter, -start and -end are required")
+		flags.Usage()
+		return errUsage
+	}
+	var serr error
+	f.startTime, serr = exdgo.ParseTimeParam(f.start)
+	if serr != nil {
+		return fmt.Errorf("-start: %v", serr)
+	}
+	f.endTime, serr = exdgo.ParseTimeParam(f.end)
+	if serr != nil {
+		return fmt.Errorf("-end: %v", serr)
+	}
+	return nil
+}
+
+// client creates the client to send requests with.
+func (f *requestFlags) client(opts ...exdgo.Option) (*exdgo.Client, error) {
+	var cli *exdgo.Client
+	var serr error
+	if f.sample {
+		cli, serr = exdgo.NewSampleClient(opts...)
+	} else {
+		cli, serr = exdgo.NewClient(f.apikey, opts...)
+	}
+	if serr != nil {
+		return nil, fmt.Errorf("client: %v", serr)
+	}
+	return cli, nil
+}
@@ -0,0 +1,77 @@
+// Command exd pulls market data from Exchangedataset API without writing a Go program.
+//
+// Usage:
+//
+//	exd download -filter bitmex:orderBookL2,trade -start 2020-01-01T00:00:00Z -end 2020-01-01T00:10:00Z -o out.jsonl
+//
+// The API-key is read from the environment variable `EXDG_APIKEY` or the config file
+// unless `-apikey` is given. Run `exd <command> -h` for all flags.
+//
+// Lines can be piped into other tools as they are replayed at the pace of their timestamps:
+//
+//	exd stream -filter bitmex:trade -start 2020-01-01 -end 2020-01-02 -speed 1 | jq .message.price
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// errUsage is returned when arguments are wrong, the usage has already been printed.
+var errUsage = errors.New("usage")
+
+const usage = `Usage: exd <command> [flags]
+
+Commands:
+  download  download data of a range into a file or the standard output
+  stream    stream decoded lines of a range to the standard output in NDJSON
+  cache     manage the local cache of responses
+`
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		// Second interrupt kills the process as usual
+		signal.Stop(interrupt)
+		cancel()
+	}()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command and returns the exit code.
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var serr error
+	switch args[0] {
+	case "download":
+		serr = runDownload(ctx, args[1:], stdout, stderr)
+	case "stream":
+		serr = runStream(ctx, args[1:], stdout, stderr)
+	case "cache":
+		serr = runCache(ctx, args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "exd: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	if serr == errUsage {
+		return 2
+	}
+	if serr != nil {
+		fmt.Fprintf(stderr, "exd: %v\n", serr)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+
+	"github.com/exchangedataset/exdgo"
+)
+
+// Shards downloaded ahead while streaming, same as the default of the library
+const streamBufferSize = 20
+
+func runStream(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("stream", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	request := addRequestFlags(flags)
+	filterOut := make(filterFlag)
+	flags.Var(filterOut, "filter-out", "exchange and channels to drop in `exchange:channel,...`, can be repeated")
+	speed := flags.Float64("speed", 0, "pace lines at their timestamps, relative to the real time, 0 yields them as fast as possible")
+	messages := flags.Bool("messages", false, "emit only message lines")
+	if serr := request.parse(flags, args, stderr); serr != nil {
+		return serr
+	}
+	if *speed < 0 {
+		return errors.New("-speed must not be negative")
+	}
+	cli, serr := request.client()
+	if serr != nil {
+		return serr
+	}
+	defer cli.Close()
+	param := exdgo.ReplayRequestParam{
+		Filter: request.filter,
+		Start:  request.startTime,
+		End:    request.endTime,
+	}
+	if len(filterOut) > 0 {
+		param.FilterOut = filterOut
+	}
+	req, serr := cli.Replay(param)
+	if serr != nil {
+		return serr
+	}
+	itr, serr := req.StreamWithContext(ctx, streamBufferSize)
+	if serr != nil {
+		return serr
+	}
+	if *speed > 0 {
+		// Never fails with a positive speed
+		itr, _ = exdgo.Pace(itr, exdgo.PaceParam{Speed: *speed})
+	}
+	buffered := bufio.NewWriter(stdout)
+	encoder := json.NewEncoder(buffered)
+	// Closes the iterator
+	serr = exdgo.ForEach(itr, func(line *exdgo.StructLine) error {
+		if *messages && line.Type != exdgo.LineTypeMessage {
+			return nil
+		}
+		if serr := encoder.Encode(newJSONLine(line)); serr != nil {
+			return serr
+		}
+		if *speed > 0 {
+			// Lines are read as they come
+			return buffered.Flush()
+		}
+		return nil
+	})
+	if serr != nil {
+		return serr
+	}
+	return buffered.Flush()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestStreamSample(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"stream", "-sample",
+		"-filter", "bitmex:trade,quote",
+		"-filter-out", "bitmex:quote",
+		"-start", "2020-01-01T00:00:00Z",
+		"-end", "2020-01-01T00:00:30Z",
+		"-speed", "1000",
+		"-messages",
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("code = %d: %s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("only %d lines", len(lines))
+	}
+	for _, line := range lines {
+		var decoded jsonLine
+		if serr := json.Unmarshal([]byte(line), &decoded); serr != nil {
+			t.Fatalf("%v: %s", serr, line)
+		}
+		if decoded.Type != "msg" || decoded.Channel == nil || *decoded.Channel != "trade" {
+			t.Fatalf("line = %s", line)
+		}
+	}
+}
+
+func TestStreamNegativeSpeed(t *testing.T) {
+	code := run(context.Background(), []string{
+		"stream", "-sample", "-filter", "bitmex:trade", "-start", "2020-01-01", "-end", "2020-01-02", "-speed", "-1",
+	}, ioutil.Discard, ioutil.Discard)
+	if code != 1 {
+		t.Fatalf("code = %d", code)
+	}
+}
@@ -1,9 +1,14 @@
 package exdgo
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -11,11 +16,26 @@ var (
 	regexAPIKey = regexp.MustCompile("^[A-Za-z0-9\\-_]+$")
 )
 
+// ErrIteratorClosed is returned by `Next` of an iterator after `Close` was called.
+var ErrIteratorClosed = errors.New("iterator already closed")
+
+// ErrTruncated is reported when a response ended before all of it was received.
+// Such a request is retried.
+var ErrTruncated = errors.New("response truncated")
+
+// ErrStalled is returned by `Next` of a stream when no shard was downloaded within the stall timeout.
+var ErrStalled = errors.New("stream stalled")
+
 const (
 	urlAPI                  = "https://api.exchangedataset.cc/v1/"
 	defaultBufferSize       = 20
 	downloadBatchSize       = 20
+	decodeBatchSize         = 512
+	demuxBufferSize         = 1024
+	mergeBufferSize         = 256
 	clientDefaultTimeout    = 30 * time.Second
+	clientDefaultMaxRetries = 3
+	clientDefaultRetryWait  = time.Second
 	snapshotTopicSubscribed = "!subscribed"
 )
 
@@ -49,8 +69,69 @@ const (
 	// Message of line of this type contains an error during recording.
 	// Used in both server-side (exchanges' server) error and client-side (our clients which receive WebSocket data) error.
 	LineTypeError LineType = "err"
+	// LineTypeUndecoded is a one of the LineTypes.
+	//
+	// Message of line of this type is the whole response for a shard in a format this client does not know.
+	// Such a response is returned without decoding, one line per a shard.
+	// Timestamp is the beginning of the shard and channel is not present.
+	LineTypeUndecoded LineType = "undecoded"
+	// LineTypeDefinitionChange is a one of the LineTypes.
+	//
+	// Line of this type is not in data but yielded by this client when enabled by `DefinitionChanges`,
+	// before the first message of a channel whose definition changed.
+	// Message is `*DefinitionChange`, and the timestamp is of the first message.
+	LineTypeDefinitionChange LineType = "defchange"
+	// LineTypeOrderFlow is a one of the LineTypes.
+	//
+	// Line of this type is not in data but yielded by `DeriveOrderFlow` after each trade.
+	// Message is `*OrderFlow`, and the timestamp and the channel are of the trade.
+	LineTypeOrderFlow LineType = "orderflow"
 )
 
+// Formats this client can decode responses in
+var knownFormats = map[string]bool{
+	"raw":  true,
+	"json": true,
+}
+
+// isKnownFormat reports whether responses in the format can be decoded into lines.
+// nil is the default format of the server.
+func isKnownFormat(format *string) bool {
+	return format == nil || knownFormats[*format]
+}
+
+// ParseLineType returns `LineType` of the given name such as "msg" or "start".
+// Returns error if the name is not of any `LineType`.
+func ParseLineType(name string) (LineType, error) {
+	switch typ := LineType(name); typ {
+	case LineTypeMessage, LineTypeSend, LineTypeStart, LineTypeEnd, LineTypeError, LineTypeUndecoded,
+		LineTypeDefinitionChange, LineTypeOrderFlow:
+		return typ, nil
+	}
+	return "", fmt.Errorf("unknown line type: %s", name)
+}
+
+// String returns the name of the line type such as "msg" or "start".
+func (t LineType) String() string {
+	return string(t)
+}
+
+// MarshalText implements `encoding.TextMarshaler`, line type is encoded as its name.
+func (t LineType) MarshalText() ([]byte, error) {
+	return []byte(t), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+// Returns error if the text is not a name of any `LineType`.
+func (t *LineType) UnmarshalText(text []byte) error {
+	typ, serr := ParseLineType(string(text))
+	if serr != nil {
+		return serr
+	}
+	*t = typ
+	return nil
+}
+
 // StructLine is the line which have a struct as a message.
 // See `StringLine`.
 type StructLine struct {
@@ -59,6 +140,10 @@ type StructLine struct {
 	Timestamp int64
 	Channel   *string
 	Message   interface{}
+	// Original JSON bytes of message.
+	// Only set for message lines when `KeepRaw` was specified.
+	// Do not modify.
+	Raw json.RawMessage
 	// Definition of message.
 	// Could be nil.
 	// Do not modify.
@@ -107,26 +192,82 @@ type StringLine struct {
 	Message []byte
 }
 
-func copyFilter(filter map[string][]string) (map[string][]string, error) {
-	// Copy filter map and validate content at the same time
+// validateChannel checks the channel name can be sent to the server.
+// Channel names are sent URL-encoded, so spaces, slashes and unicode are allowed,
+// but control characters including tabs and newlines are not, as they separate fields in responses.
+func validateChannel(channel string) error {
+	if channel == "" {
+		return errors.New("empty channel")
+	}
+	if !utf8.ValidString(channel) {
+		return fmt.Errorf("channel %q is not valid UTF-8", channel)
+	}
+	for _, r := range channel {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("control character in channel %q", channel)
+		}
+	}
+	return nil
+}
+
+// ParamErrors is the error returned when parameters have problems, reporting all of them at once.
+type ParamErrors []error
+
+func (e ParamErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, serr := range e {
+		msgs[i] = serr.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add appends the error, or all of errors in it if it is `ParamErrors`.
+// nil is ignored.
+func (e *ParamErrors) add(err error) {
+	if err == nil {
+		return
+	}
+	if errs, ok := err.(ParamErrors); ok {
+		*e = append(*e, errs...)
+		return
+	}
+	*e = append(*e, err)
+}
+
+// err returns nil if there was no error, so that nil of `ParamErrors` is not returned as a non-nil error.
+func (e ParamErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// copyFilter copies the filter map validating its content at the same time.
+// `name` is the name of the parameter used in errors, all of problems are reported in `ParamErrors`.
+func copyFilter(name string, filter map[string][]string) (map[string][]string, error) {
+	var errs ParamErrors
 	filterCopied := make(map[string][]string)
 	for exc, chs := range filter {
 		// Validate exchange name
 		if !regexName.MatchString(exc) {
-			return nil, errors.New("invalid characters in exchange in 'Filter'")
+			errs.add(fmt.Errorf("invalid characters in exchange %q in '%s'", exc, name))
+		}
+		if len(chs) == 0 {
+			errs.add(fmt.Errorf("empty channels for exchange %q in '%s'", exc, name))
 		}
 		// Validate channel names
 		for _, ch := range chs {
-			if !regexName.MatchString(ch) {
-				return nil, errors.New("invalid characters in channel in 'Filter'")
+			if serr := validateChannel(ch); serr != nil {
+				errs.add(fmt.Errorf("invalid channel in '%s': %v", name, serr))
 			}
 		}
 		// Perform slice copy
 		chsCopied := make([]string, len(chs))
-		if copied := copy(chsCopied, chs); copied != len(chs) {
-			return nil, errors.New("copy of slice failed")
-		}
+		copy(chsCopied, chs)
 		filterCopied[exc] = chsCopied
 	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	return filterCopied, nil
 }
@@ -0,0 +1,90 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLineTypeText(t *testing.T) {
+	encoded, serr := json.Marshal(map[LineType][]LineType{
+		LineTypeStart: []LineType{LineTypeMessage, LineTypeError},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if string(encoded) != `{"start":["msg","err"]}` {
+		t.Fatalf("encoded = %s", encoded)
+	}
+	var decoded map[LineType][]LineType
+	if serr := json.Unmarshal(encoded, &decoded); serr != nil {
+		t.Fatal(serr)
+	}
+	if decoded[LineTypeStart][1] != LineTypeError {
+		t.Fatalf("decoded = %v", decoded)
+	}
+	var typ LineType
+	for _, expected := range []LineType{
+		LineTypeMessage, LineTypeSend, LineTypeStart, LineTypeEnd, LineTypeError, LineTypeUndecoded,
+		LineTypeDefinitionChange, LineTypeOrderFlow,
+	} {
+		text, serr := expected.MarshalText()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if serr := typ.UnmarshalText(text); serr != nil || typ != expected {
+			t.Fatalf("%s: decoded %s: %v", expected, typ, serr)
+		}
+	}
+	if serr := typ.UnmarshalText([]byte("message")); serr == nil {
+		t.Fatal("expected error for unknown line type")
+	}
+	if LineTypeEnd.String() != "end" {
+		t.Fatal("String() differ")
+	}
+}
+
+func TestValidateChannel(t *testing.T) {
+	for _, ch := range []string{"trades", "BTC/USD", "order book", "ティッカー", "a-b.c:d"} {
+		if serr := validateChannel(ch); serr != nil {
+			t.Fatalf("%q: %v", ch, serr)
+		}
+	}
+	for _, ch := range []string{"", "a\tb", "a\nb", "\xff"} {
+		if serr := validateChannel(ch); serr == nil {
+			t.Fatalf("%q accepted", ch)
+		}
+	}
+}
+
+func TestParamErrors(t *testing.T) {
+	cli, serr := setupClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	negative := -1
+	start := time.Unix(1577836800, 0)
+	_, serr = setupReplayRequest(&cli, ReplayRequestParam{
+		Filter: map[string][]string{
+			"bit-mex":  []string{"orderBookL2"},
+			"bitflyer": []string{},
+		},
+		Start:         start,
+		End:           start.Add(-time.Minute),
+		DecodeWorkers: &negative,
+	})
+	errs, ok := serr.(ParamErrors)
+	if !ok {
+		t.Fatalf("not ParamErrors: %v", serr)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("%d errors reported: %v", len(errs), errs)
+	}
+	if _, serr := setupReplayRequest(&cli, ReplayRequestParam{
+		Filter: map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:  start,
+		End:    start.Add(time.Minute),
+	}); serr != nil {
+		t.Fatal(serr)
+	}
+}
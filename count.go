@@ -0,0 +1,72 @@
+package exdgo
+
+import "context"
+
+// LineCounts is the number of message lines for each exchange and channel, `map[exchange]map[channel]count`.
+type LineCounts map[string]map[string]int64
+
+func (c LineCounts) add(exchange string, channel string) {
+	channels, ok := c[exchange]
+	if !ok {
+		channels = make(map[string]int64)
+		c[exchange] = channels
+	}
+	channels[channel]++
+}
+
+// Total returns the number of lines of all exchanges and channels.
+func (c LineCounts) Total() int64 {
+	var total int64
+	for _, channels := range c {
+		for _, n := range channels {
+			total += n
+		}
+	}
+	return total
+}
+
+// Count downloads the range and counts message lines for each exchange and channel,
+// including ones in snapshots.
+// Lines are not kept, so it uses memory only for shards being downloaded.
+func (r *RawRequest) Count(ctx context.Context) (LineCounts, error) {
+	counts := make(LineCounts)
+	serr := r.DownloadFunc(ctx, func(line *StringLine) error {
+		if line.Type == LineTypeMessage {
+			counts.add(line.Exchange, *line.Channel)
+		}
+		return nil
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	return counts, nil
+}
+
+// Count counts message lines for each exchange and channel as they would be yielded by `Download`,
+// without decoding messages into `StructLine`, for a quick check before replaying.
+// Lines are not kept, so it uses memory only for shards being downloaded.
+func (r *ReplayRequest) Count(ctx context.Context) (LineCounts, error) {
+	counts := make(LineCounts)
+	requests := r.segments
+	if len(requests) == 0 {
+		requests = []*ReplayRequest{r}
+	}
+	for _, req := range requests {
+		// Definitions are tracked to skip them, but messages are not decoded
+		processor := newRawLineProcessor()
+		serr := req.raw.DownloadFunc(ctx, func(line *StringLine) error {
+			_, skip, serr := processor.track(line)
+			if serr != nil {
+				return serr
+			}
+			if !skip && line.Type == LineTypeMessage {
+				counts.add(line.Exchange, *line.Channel)
+			}
+			return nil
+		})
+		if serr != nil {
+			return nil, serr
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,37 @@
+package exdgo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReplayCount(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	counts, serr := req.Count(context.Background())
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	expected := make(LineCounts)
+	for _, line := range lines {
+		if line.Type == LineTypeMessage {
+			expected.add(line.Exchange, *line.Channel)
+		}
+	}
+	if counts.Total() != int64(len(lines)) || len(counts) != len(expected) {
+		t.Fatalf("counted %v, expected %v", counts, expected)
+	}
+	for exchange, channels := range expected {
+		for channel, n := range channels {
+			if counts[exchange][channel] != n {
+				t.Fatalf("%s %s: counted %d, expected %d", exchange, channel, counts[exchange][channel], n)
+			}
+		}
+	}
+	checkGoroutineLeak(t)
+}
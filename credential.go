@@ -0,0 +1,118 @@
+package exdgo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Environment variable read for the API-key when `ClientParam.APIKey` is empty
+	envAPIKey = "EXDG_APIKEY"
+	// Environment variable overriding the path of the config file
+	envConfigFile = "EXDG_CONFIG"
+	// Key of the API-key in the config file
+	configKeyAPIKey = "apikey"
+)
+
+// CredentialProvider supplies the API-key, such as from a secret manager.
+// It must be safe for concurrent use.
+type CredentialProvider interface {
+	// APIKey returns the API-key to send a request with.
+	// Called for every request, so it should return a cached key quickly.
+	APIKey(ctx context.Context) (string, error)
+	// Refresh is called when the server rejected the key returned by `APIKey`,
+	// so that `APIKey` returns a new one afterwards.
+	// The request is sent once again with the new key.
+	Refresh(ctx context.Context) error
+}
+
+// currentAPIKey returns the API-key to send a request with.
+func (c *Client) currentAPIKey(ctx context.Context) (string, error) {
+	if c.credentials == nil {
+		return c.apikey, nil
+	}
+	apikey, serr := c.credentials.APIKey(ctx)
+	if serr != nil {
+		return "", fmt.Errorf("credentials: %v", serr)
+	}
+	if !regexAPIKey.MatchString(apikey) {
+		return "", errors.New("credentials: not a valid API-key")
+	}
+	return apikey, nil
+}
+
+// defaultConfigFile returns the path of the config file used when neither
+// `ClientParam.ConfigFile` nor the environment variable is set.
+// Returns empty string if the user config directory is unknown.
+func defaultConfigFile() string {
+	dir, serr := os.UserConfigDir()
+	if serr != nil {
+		return ""
+	}
+	return filepath.Join(dir, "exdgo", "config")
+}
+
+// readConfigFile reads "key = value" pairs from the file.
+// Empty lines and lines starting with '#' are ignored.
+func readConfigFile(path string) (map[string]string, error) {
+	file, serr := os.Open(path)
+	if serr != nil {
+		return nil, serr
+	}
+	defer file.Close()
+	config := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq == -1 {
+			return nil, fmt.Errorf("%s:%d: expected 'key = value'", path, n)
+		}
+		config[strings.TrimSpace(line[:eq])] = strings.TrimSpace(line[eq+1:])
+	}
+	if serr := scanner.Err(); serr != nil {
+		return nil, serr
+	}
+	return config, nil
+}
+
+// resolveAPIKey returns the API-key from the parameter, the environment variable or the config file,
+// in this order.
+// Returns empty string if none of them has one.
+func resolveAPIKey(param ClientParam) (string, error) {
+	if param.APIKey != "" {
+		return param.APIKey, nil
+	}
+	if apikey := os.Getenv(envAPIKey); apikey != "" {
+		return apikey, nil
+	}
+	path := param.ConfigFile
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(envConfigFile)
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultConfigFile()
+	}
+	if path == "" {
+		return "", nil
+	}
+	config, serr := readConfigFile(path)
+	if serr != nil {
+		if os.IsNotExist(serr) && !explicit {
+			// The default config file is optional
+			return "", nil
+		}
+		return "", fmt.Errorf("config file: %v", serr)
+	}
+	return config[configKeyAPIKey], nil
+}
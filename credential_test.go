@@ -0,0 +1,132 @@
+package exdgo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setenv sets the environment variable and returns a function restoring it.
+func setenv(t *testing.T, key string, value string) func() {
+	t.Helper()
+	old, existed := os.LookupEnv(key)
+	if serr := os.Setenv(key, value); serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	return func() {
+		if existed {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestResolveAPIKey(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config")
+	if serr := ioutil.WriteFile(config, []byte("# comment\n\napikey = from-file\n"), 0600); serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer setenv(t, envConfigFile, filepath.Join(dir, "absent"))()
+	defer setenv(t, envAPIKey, "")()
+
+	if _, serr := setupClient(ClientParam{}); serr == nil {
+		t.Fatal("missing config file given by the environment variable accepted")
+	}
+	cli, serr := setupClient(ClientParam{ConfigFile: config})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.apikey != "from-file" {
+		t.Fatalf("apikey = %s", cli.apikey)
+	}
+	defer setenv(t, envAPIKey, "from-env")()
+	cli, serr = setupClient(ClientParam{ConfigFile: config})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.apikey != "from-env" {
+		t.Fatalf("apikey = %s", cli.apikey)
+	}
+	cli, serr = setupClient(ClientParam{APIKey: "from-param"})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.apikey != "from-param" {
+		t.Fatalf("apikey = %s", cli.apikey)
+	}
+
+	if serr := ioutil.WriteFile(config, []byte("apikey\n"), 0600); serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer setenv(t, envAPIKey, "")()
+	if _, serr := setupClient(ClientParam{ConfigFile: config}); serr == nil {
+		t.Fatal("malformed config file accepted")
+	}
+}
+
+// rotatingCredentials returns "old" until refreshed.
+type rotatingCredentials struct {
+	mutex     sync.Mutex
+	key       string
+	refreshed int
+}
+
+func (c *rotatingCredentials) APIKey(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.key, nil
+}
+
+func (c *rotatingCredentials) Refresh(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.key = "new"
+	c.refreshed++
+	return nil
+}
+
+func TestCredentialProvider(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			http.Error(w, `{"error":"invalid API-key"}`, http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	creds := &rotatingCredentials{key: "old"}
+	cli, serr := setupClient(ClientParam{Credentials: creds})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cli.endpoint = srv.server.URL + "/"
+	snapshots, serr := cli.HTTPSnapshot(SnapshotParam{
+		Exchange: "bitmex",
+		Channels: []string{"orderBookL2"},
+		At:       time.Unix(1577836800, 0),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("len(snapshots) = %d", len(snapshots))
+	}
+	if creds.refreshed != 1 {
+		t.Fatalf("refreshed %d times", creds.refreshed)
+	}
+	if _, serr := setupClient(ClientParam{APIKey: "demo", Credentials: creds}); serr == nil {
+		t.Fatal("both APIKey and Credentials accepted")
+	}
+}
@@ -0,0 +1,125 @@
+package exdgo
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DebugInfo is the state of a client for finding resource leaks. See `Client.Debug`.
+type DebugInfo struct {
+	// Stack traces where iterators not yet closed were created.
+	// Only available if `OnIteratorLeak` of `ClientParam` is set.
+	OpenIterators []string
+	// Number of goroutines downloading in background for requests of the client.
+	BackgroundGoroutines int64
+}
+
+// iteratorRegistry holds iterators not yet closed.
+type iteratorRegistry struct {
+	mutex sync.Mutex
+	open  map[*iteratorRecord]struct{}
+}
+
+// iteratorRecord is an iterator tracked.
+type iteratorRecord struct {
+	stack  string
+	closed bool
+}
+
+func newIteratorRegistry() *iteratorRegistry {
+	return &iteratorRegistry{open: make(map[*iteratorRecord]struct{})}
+}
+
+// register records an iterator being created by the caller.
+func (r *iteratorRegistry) register() *iteratorRecord {
+	buf := make([]byte, 4096)
+	buf = buf[:runtime.Stack(buf, false)]
+	record := &iteratorRecord{stack: string(buf)}
+	r.mutex.Lock()
+	r.open[record] = struct{}{}
+	r.mutex.Unlock()
+	return record
+}
+
+// close records the iterator was closed, and returns false if it had been closed.
+func (r *iteratorRegistry) close(record *iteratorRecord) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if record.closed {
+		return false
+	}
+	record.closed = true
+	delete(r.open, record)
+	return true
+}
+
+// Debug returns the state of the client to find resource leaks, such as iterators not closed.
+func (c *Client) Debug() DebugInfo {
+	info := DebugInfo{BackgroundGoroutines: atomic.LoadInt64(c.background)}
+	if c.iterators != nil {
+		c.iterators.mutex.Lock()
+		for record := range c.iterators.open {
+			info.OpenIterators = append(info.OpenIterators, record.stack)
+		}
+		c.iterators.mutex.Unlock()
+		sort.Strings(info.OpenIterators)
+	}
+	return info
+}
+
+// startBackground counts a background goroutine until the returned function is called.
+func (c *Client) startBackground() func() {
+	atomic.AddInt64(c.background, 1)
+	return func() { atomic.AddInt64(c.background, -1) }
+}
+
+// trackStructIterator returns the iterator tracked by the client if `OnIteratorLeak` is set.
+func (c *Client) trackStructIterator(itr StructLineIterator) StructLineIterator {
+	if c.iterators == nil {
+		return itr
+	}
+	tracked := &trackedStructIterator{StructLineIterator: itr, cli: c, record: c.iterators.register()}
+	runtime.SetFinalizer(tracked, func(t *trackedStructIterator) { c.leaked(t.record) })
+	return tracked
+}
+
+// trackStringIterator is `trackStructIterator` for `StringLineIterator`.
+func (c *Client) trackStringIterator(itr StringLineIterator) StringLineIterator {
+	if c.iterators == nil {
+		return itr
+	}
+	tracked := &trackedStringIterator{StringLineIterator: itr, cli: c, record: c.iterators.register()}
+	runtime.SetFinalizer(tracked, func(t *trackedStringIterator) { c.leaked(t.record) })
+	return tracked
+}
+
+// leaked reports the iterator if it was garbage collected without being closed.
+func (c *Client) leaked(record *iteratorRecord) {
+	if c.iterators.close(record) {
+		c.onIteratorLeak(record.stack)
+	}
+}
+
+type trackedStructIterator struct {
+	StructLineIterator
+	cli    *Client
+	record *iteratorRecord
+}
+
+func (t *trackedStructIterator) Close() error {
+	t.cli.iterators.close(t.record)
+	return t.StructLineIterator.Close()
+}
+
+type trackedStringIterator struct {
+	StringLineIterator
+	cli    *Client
+	record *iteratorRecord
+}
+
+func (t *trackedStringIterator) Close() error {
+	t.cli.iterators.close(t.record)
+	return t.StringLineIterator.Close()
+}
@@ -0,0 +1,69 @@
+package exdgo
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIteratorLeak(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	leaks := make(chan string, 1)
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	cli := req.raw.cli
+	cli.iterators = newIteratorRegistry()
+	cli.onIteratorLeak = func(stack string) { leaks <- stack }
+
+	closed, serr := req.Stream()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if info := cli.Debug(); len(info.OpenIterators) != 1 || !strings.Contains(info.OpenIterators[0], "TestIteratorLeak") {
+		t.Fatalf("open iterators %v", info.OpenIterators)
+	}
+	if atomic.LoadInt64(cli.background) == 0 {
+		t.Fatal("background goroutines not counted")
+	}
+	closed.Close()
+	if info := cli.Debug(); len(info.OpenIterators) != 0 {
+		t.Fatalf("open iterators %v", info.OpenIterators)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	func() {
+		itr, serr := req.StreamWithContext(ctx, 2)
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if _, ok, serr := itr.Next(); !ok {
+			t.Fatalf("no line: %v", serr)
+		}
+		// Dropped without Close
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case stack := <-leaks:
+			if !strings.Contains(stack, "TestIteratorLeak") {
+				t.Fatalf("stack of leak %s", stack)
+			}
+			// Stops background goroutines of the leaked iterator
+			cancel()
+			checkGoroutineLeak(t)
+			if n := cli.Debug().BackgroundGoroutines; n != 0 {
+				t.Fatalf("%d background goroutines", n)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("leak not reported")
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// decodeBatch is a group of lines decoded by one of decode workers.
+type decodeBatch struct {
+	lines []*StringLine
+	// Definition for each line returned by `track`
+	defs    []map[string]string
+	setting *decodeSetting
+	// Counts decoded lines, can be nil
+	memory *memoryStats
+	// Decoded lines, available after `done` is closed
+	result []StructLine
+	// Error from reading or decoding lines, lines in `result` precede this error
+	err error
+	// Closed when decoding is done
+	done chan struct{}
+}
+
+func newDecodeBatch(setting *decodeSetting, memory *memoryStats) *decodeBatch {
+	b := new(decodeBatch)
+	b.setting = setting
+	b.memory = memory
+	b.lines = make([]*StringLine, 0, decodeBatchSize)
+	b.defs = make([]map[string]string, 0, decodeBatchSize)
+	b.done = make(chan struct{})
+	return b
+}
+
+func (b *decodeBatch) decode() {
+	defer close(b.done)
+	// Counted before `done` is closed, so the reader always finds it counted
+	defer func() { b.memory.addDecoded(int64(len(b.result))) }()
+	b.result = make([]StructLine, len(b.lines))
+	for j, line := range b.lines {
+		var serr error
+		b.result[j], serr = convertRawLine(line, b.defs[j], b.setting)
+		if serr != nil {
+			// Decode error precedes read error since it happened on an earlier line
+			b.err = serr
+			b.result = b.result[:j]
+			return
+		}
+	}
+}
+
+// decodeWorker decodes batches until `jobs` is closed.
+func decodeWorker(jobs chan *decodeBatch, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for b := range jobs {
+		b.decode()
+	}
+}
+
+// replayParallelStreamIterator decodes lines on a pool of worker goroutines while preserving order.
+//
+// A reader goroutine owns the raw iterator, it tracks definitions sequentially and
+// groups lines into batches which are decoded by workers.
+// Batches are passed to the consumer in the order they were read.
+type replayParallelStreamIterator struct {
+	req    *ReplayRequest
+	rawItr StringLineIterator
+	// Cancels context the raw iterator runs on
+	cancelRaw context.CancelFunc
+	// Batches in the order of lines
+	batches chan *decodeBatch
+	// Closed to stop the reader
+	stop chan struct{}
+	// Reader and workers
+	wg       sync.WaitGroup
+	current  *decodeBatch
+	position int
+	closed   bool
+	// nil if calls can not be cancelled
+	call *callContext
+	// Batch received but the call was cancelled before it was decoded
+	pending *decodeBatch
+}
+
+func newReplayParallelStreamIterator(ctx context.Context, req *ReplayRequest, bufferSize int) (*replayParallelStreamIterator, error) {
+	i := new(replayParallelStreamIterator)
+	i.req = req
+	var rawCtx context.Context
+	rawCtx, i.cancelRaw = context.WithCancel(ctx)
+	// Read by the reader goroutine, the consumer follows contexts of calls instead
+	i.call = callContextFrom(ctx)
+	itr, serr := req.raw.stream(withoutCallContext(rawCtx), bufferSize, false)
+	if serr != nil {
+		i.cancelRaw()
+		return nil, serr
+	}
+	i.rawItr = itr
+	i.batches = make(chan *decodeBatch, 2*req.decodeWorkers)
+	i.stop = make(chan struct{})
+	jobs := make(chan *decodeBatch, req.decodeWorkers)
+	for j := 0; j < req.decodeWorkers; j++ {
+		i.wg.Add(1)
+		go decodeWorker(jobs, &i.wg)
+	}
+	i.wg.Add(1)
+	go i.read(jobs)
+	return i, nil
+}
+
+// read reads lines from the raw iterator and sends batches to both workers and the consumer.
+func (i *replayParallelStreamIterator) read(jobs chan *decodeBatch) {
+	defer i.wg.Done()
+	defer close(jobs)
+	defer close(i.batches)
+	processor := newRawLineProcessor()
+	for {
+		batch := newDecodeBatch(&i.req.decode, i.req.raw.cli.memory)
+		last := false
+		for len(batch.lines) < decodeBatchSize {
+			line, ok, serr := i.rawItr.Next()
+			if !ok {
+				batch.err = serr
+				last = true
+				break
+			}
+			def, skip, serr := processor.track(line)
+			if serr != nil {
+				batch.err = serr
+				last = true
+				break
+			}
+			if skip {
+				continue
+			}
+			batch.lines = append(batch.lines, line)
+			batch.defs = append(batch.defs, def)
+		}
+		select {
+		case i.batches <- batch:
+		case <-i.stop:
+			return
+		}
+		select {
+		case jobs <- batch:
+		case <-i.stop:
+			return
+		}
+		if last {
+			return
+		}
+	}
+}
+
+func (i *replayParallelStreamIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		if i.current != nil {
+			if i.position < len(i.current.result) {
+				line := &i.current.result[i.position]
+				i.position++
+				return line, true, nil
+			}
+			if i.current.err != nil {
+				// Keep reporting the same error
+				return nil, false, i.current.err
+			}
+		}
+		batch := i.pending
+		if batch == nil {
+			select {
+			case batch = <-i.batches:
+			case <-i.call.done():
+				return nil, false, i.call.err()
+			}
+			if batch == nil {
+				// No more lines
+				return nil, false, nil
+			}
+		}
+		select {
+		case <-batch.done:
+		case <-i.call.done():
+			// Waits for the batch again in the next call
+			i.pending = batch
+			return nil, false, i.call.err()
+		}
+		i.pending = nil
+		i.release()
+		i.current = batch
+		i.position = 0
+	}
+}
+
+// release stops counting lines in the current batch as decoded.
+func (i *replayParallelStreamIterator) release() {
+	if i.current != nil {
+		i.current.memory.addDecoded(-int64(len(i.current.result)))
+	}
+}
+
+func (i *replayParallelStreamIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	close(i.stop)
+	// Unblock the reader waiting for the raw iterator
+	i.cancelRaw()
+	i.wg.Wait()
+	i.release()
+	i.current = nil
+	if i.pending != nil {
+		i.pending.memory.addDecoded(-int64(len(i.pending.result)))
+	}
+	// Workers are stopped, batches never decoded are empty
+	for batch := range i.batches {
+		batch.memory.addDecoded(-int64(len(batch.result)))
+	}
+	serr := i.rawItr.Close()
+	if serr != nil && !errors.Is(serr, context.Canceled) {
+		return serr
+	}
+	return nil
+}
+
+// decodeParallel decodes lines on `workers` goroutines and returns them in the original order.
+func decodeParallel(lines []StringLine, workers int, setting *decodeSetting) ([]StructLine, error) {
+	processor := newRawLineProcessor()
+	defs := make([]map[string]string, len(lines))
+	skips := make([]bool, len(lines))
+	for j := range lines {
+		var serr error
+		defs[j], skips[j], serr = processor.track(&lines[j])
+		if serr != nil {
+			return nil, serr
+		}
+	}
+	decoded := make([]StructLine, len(lines))
+	// Error of each chunk, the first one in line order is reported
+	errs := make([]error, workers)
+	chunkSize := (len(lines) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from := w * chunkSize
+		to := from + chunkSize
+		if to > len(lines) {
+			to = len(lines)
+		}
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			for j := from; j < to; j++ {
+				if skips[j] {
+					continue
+				}
+				var serr error
+				decoded[j], serr = convertRawLine(&lines[j], defs[j], setting)
+				if serr != nil {
+					errs[w] = serr
+					return
+				}
+			}
+		}(w, from, to)
+	}
+	wg.Wait()
+	for _, serr := range errs {
+		if serr != nil {
+			return nil, serr
+		}
+	}
+	result := make([]StructLine, 0, len(lines))
+	for j := range decoded {
+		if !skips[j] {
+			result = append(result, decoded[j])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+)
+
+// DecoderRegistry maps channels to Go struct types messages of them are decoded into by `ReplayRequest`,
+// set by `ReplayRequestParam.Decoders`.
+// Structs generated by `DefinitionGoStruct` can be registered as they are.
+// It is safe for concurrent use.
+type DecoderRegistry struct {
+	mutex   sync.Mutex
+	entries []decoderEntry
+	// Types already looked up, nil if no entry matched
+	// map[exchange]map[channel]type
+	resolved map[string]map[string]reflect.Type
+}
+
+type decoderEntry struct {
+	exchange string
+	pattern  *regexp.Regexp
+	typ      reflect.Type
+}
+
+// NewDecoderRegistry returns an empty registry.
+func NewDecoderRegistry() *DecoderRegistry {
+	return &DecoderRegistry{resolved: make(map[string]map[string]reflect.Type)}
+}
+
+// Register decodes messages of the exchange in channels matching the regular expression into
+// the type of `prototype`, which is a struct or a pointer to a struct, such as `Trade{}`.
+// Messages are decoded by `json.Unmarshal` into a new value, and `StructLine.Message` is the pointer to it.
+// If a channel matches more than one entry, the one registered first is used.
+func (d *DecoderRegistry) Register(exchange string, channelPattern string, prototype interface{}) error {
+	if !regexName.MatchString(exchange) {
+		return errors.New("invalid characters in 'exchange'")
+	}
+	pattern, serr := regexp.Compile(channelPattern)
+	if serr != nil {
+		return fmt.Errorf("'channelPattern': %v", serr)
+	}
+	typ := reflect.TypeOf(prototype)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fmt.Errorf("'prototype' must be a struct or a pointer to a struct, got %T", prototype)
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.entries = append(d.entries, decoderEntry{exchange, pattern, typ})
+	// Channels without a type may match the new entry
+	d.resolved = make(map[string]map[string]reflect.Type)
+	return nil
+}
+
+// lookup returns the struct type messages of the channel are decoded into, nil if none.
+func (d *DecoderRegistry) lookup(exchange string, channel string) reflect.Type {
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if typ, ok := d.resolved[exchange][channel]; ok {
+		return typ
+	}
+	var found reflect.Type
+	for _, entry := range d.entries {
+		if entry.exchange == exchange && entry.pattern.MatchString(channel) {
+			found = entry.typ
+			break
+		}
+	}
+	if _, ok := d.resolved[exchange]; !ok {
+		d.resolved[exchange] = make(map[string]reflect.Type)
+	}
+	d.resolved[exchange][channel] = found
+	return found
+}
+
+// decodeRegistered decodes the message of the line into a new value of the type,
+// and returns the pointer to it.
+func decodeRegistered(line *StringLine, typ reflect.Type) (interface{}, error) {
+	ptr := reflect.New(typ)
+	if serr := json.Unmarshal(line.Message, ptr.Interface()); serr != nil {
+		return nil, fmt.Errorf("message unmarshal into %v: %v", typ, serr)
+	}
+	return ptr.Interface(), nil
+}
+
+// Struct copies the message decoded by a type registered in `DecoderRegistry` into `dst`,
+// which is a pointer to the same struct type, and reports whether it was copied.
+// It is false for lines other than messages and messages of other types.
+// The message can also be taken by a type assertion, such as `line.Message.(*Trade)`.
+func (l *StructLine) Struct(dst interface{}) bool {
+	src := reflect.ValueOf(l.Message)
+	target := reflect.ValueOf(dst)
+	if src.Kind() != reflect.Ptr || src.IsNil() || target.Kind() != reflect.Ptr || target.IsNil() {
+		return false
+	}
+	if src.Type() != target.Type() {
+		return false
+	}
+	target.Elem().Set(src.Elem())
+	return true
+}
@@ -0,0 +1,58 @@
+package exdgo
+
+import (
+	"testing"
+)
+
+type testOrderBook struct {
+	Price     float64 `json:"price"`
+	Size      int64   `json:"size"`
+	Timestamp int64   `json:"timestamp,string"`
+}
+
+func TestReplayDecoders(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	decoders := NewDecoderRegistry()
+	if serr := decoders.Register("bitmex", "^orderBookL2_", &testOrderBook{}); serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := decoders.Register("bitmex", "(", testOrderBook{}); serr == nil {
+		t.Fatal("invalid pattern accepted")
+	}
+	if serr := decoders.Register("bitmex", "", 1); serr == nil {
+		t.Fatal("non-struct prototype accepted")
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{Decoders: decoders})
+	itr, serr := req.Stream()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	structs := 0
+	for _, line := range readAllStructLines(t, itr) {
+		if line.Type != LineTypeMessage {
+			continue
+		}
+		var book testOrderBook
+		ok := line.Struct(&book)
+		if line.Exchange == "bitfinex" {
+			if ok {
+				t.Fatal("message of channel not registered decoded into struct")
+			}
+			if _, isMap := line.Message.(map[string]interface{}); !isMap {
+				t.Fatalf("message not decoded into map: %T", line.Message)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("message not decoded into struct: %T", line.Message)
+		}
+		if book.Price != 1.5 || book.Timestamp != line.Timestamp {
+			t.Fatalf("message decoded wrong: %+v", book)
+		}
+		structs++
+	}
+	if structs != 6*10 {
+		t.Fatalf("%d messages decoded into struct, expected %d", structs, 6*10)
+	}
+}
@@ -0,0 +1,77 @@
+package exdgo
+
+import "reflect"
+
+// DefinitionChange is the message of a line of `LineTypeDefinitionChange`.
+type DefinitionChange struct {
+	// Definition of messages of the channel yielded before
+	Old map[string]string
+	// Definition of messages yielded after
+	New map[string]string
+}
+
+// DetectDefinitionChanges returns an iterator yielding lines from `itr`, and a line of
+// `LineTypeDefinitionChange` before the first message of a channel whose definition differs from
+// the one of its previous message, such as after reconnection of the recorder.
+// Consumers can react to changes, for example to add columns to a database, instead of missing them.
+// Definitions sent again without a change are not reported.
+func DetectDefinitionChanges(itr StructLineIterator) StructLineIterator {
+	return &defChangeIterator{source: itr, defs: make(map[string]map[string]map[string]string)}
+}
+
+type defChangeIterator struct {
+	source StructLineIterator
+	// The last definition of each channel, map[exchange]map[channel]definition
+	defs map[string]map[string]map[string]string
+	// Message line to yield after the change
+	pending *StructLine
+	change  StructLine
+	closed  bool
+}
+
+func (i *defChangeIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	if i.pending != nil {
+		line := i.pending
+		i.pending = nil
+		return line, true, nil
+	}
+	line, ok, serr := i.source.Next()
+	if !ok || line.Type != LineTypeMessage || line.Definition == nil {
+		return line, ok, serr
+	}
+	channels, ok := i.defs[line.Exchange]
+	if !ok {
+		channels = make(map[string]map[string]string)
+		i.defs[line.Exchange] = channels
+	}
+	old, ok := channels[*line.Channel]
+	if ok && reflect.ValueOf(old).Pointer() == reflect.ValueOf(line.Definition).Pointer() {
+		// Same definition is shared by messages until it is sent again
+		return line, true, nil
+	}
+	channels[*line.Channel] = line.Definition
+	if !ok || reflect.DeepEqual(old, line.Definition) {
+		return line, true, nil
+	}
+	i.pending = line
+	i.change = StructLine{
+		Exchange:   line.Exchange,
+		Type:       LineTypeDefinitionChange,
+		Timestamp:  line.Timestamp,
+		Channel:    line.Channel,
+		Message:    &DefinitionChange{Old: old, New: line.Definition},
+		Definition: line.Definition,
+	}
+	return &i.change, true, nil
+}
+
+func (i *defChangeIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
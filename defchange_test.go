@@ -0,0 +1,51 @@
+package exdgo
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectDefinitionChanges(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	changed := start.Add(2 * time.Minute)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != fmt.Sprintf("/filter/bitmex/%d", changed.Unix()/60) {
+			return true
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "start\t%d\t\n", changed.UnixNano())
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano(), `{"price":"string"}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano()+1, `{"price":"1.5"}`)
+		fmt.Fprintf(w, "start\t%d\t\n", changed.UnixNano()+2)
+		// Sent again without a change
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano()+2, `{"price":"string"}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano()+3, `{"price":"1.6"}`)
+		return false
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{DefinitionChanges: true})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	changes := make([]StructLine, 0)
+	for k, line := range lines {
+		if line.Type == LineTypeDefinitionChange {
+			changes = append(changes, line)
+			if lines[k+1].Timestamp != changed.UnixNano()+1 {
+				t.Fatalf("change yielded before %+v", lines[k+1])
+			}
+		}
+	}
+	if len(changes) != 1 {
+		t.Fatalf("%d changes", len(changes))
+	}
+	change := changes[0].Message.(*DefinitionChange)
+	if change.Old["price"] != "float" || !reflect.DeepEqual(change.New, map[string]string{"price": "string"}) {
+		t.Fatalf("change %+v", change)
+	}
+}
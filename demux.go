@@ -0,0 +1,336 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// demuxer reads lines from an iterator on a background goroutine and
+// dispatches them into sub-iterators by the key of a line.
+//
+// Background goroutine blocks when the buffer of a sub-iterator is full,
+// so all sub-iterators must be consumed concurrently.
+type demuxer struct {
+	itr StructLineIterator
+	// Returns false if the line has no key, such as lines without channel
+	keyOf func(line *StructLine) (string, bool)
+	subs  map[string]*demuxIterator
+	// Receives lines of other keys or without key if non-nil
+	fallback *demuxIterator
+	// Error from the underlying iterator, set before sub-iterators are notified
+	err   error
+	mutex sync.Mutex
+	// Number of sub-iterators not yet closed
+	open int
+	// Closed to stop background when all sub-iterators are closed
+	stop chan struct{}
+	// Closed when background had stopped and the underlying iterator is closed
+	stopped chan struct{}
+	// Error from closing the underlying iterator
+	closeErr error
+}
+
+// demuxIterator is a sub-iterator yielding lines of a key.
+type demuxIterator struct {
+	demuxer *demuxer
+	lines   chan StructLine
+	// Closed when this iterator is closed so background can stop sending lines
+	done   chan struct{}
+	line   StructLine
+	closed bool
+}
+
+// newDemuxer starts dispatching lines to sub-iterators of the keys.
+// If `fallback` is true, the sub-iterator receiving the rest of lines is also returned, otherwise they are dropped.
+func newDemuxer(itr StructLineIterator, keys []string, fallback bool, keyOf func(line *StructLine) (string, bool)) (map[string]StructLineIterator, *demuxIterator) {
+	d := new(demuxer)
+	d.itr = itr
+	d.keyOf = keyOf
+	d.subs = make(map[string]*demuxIterator)
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+	ret := make(map[string]StructLineIterator)
+	for _, key := range keys {
+		if _, ok := d.subs[key]; ok {
+			continue
+		}
+		sub := d.newSub()
+		d.subs[key] = sub
+		ret[key] = sub
+	}
+	d.open = len(d.subs)
+	if fallback {
+		d.fallback = d.newSub()
+		d.open++
+	}
+	if d.open == 0 {
+		// No one will read lines
+		itr.Close()
+		return ret, nil
+	}
+	go d.background()
+	return ret, d.fallback
+}
+
+func (d *demuxer) newSub() *demuxIterator {
+	return &demuxIterator{
+		demuxer: d,
+		lines:   make(chan StructLine, demuxBufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+func (d *demuxer) background() {
+	defer close(d.stopped)
+	defer func() {
+		// Sub-iterators see the end of lines after err is set
+		for _, sub := range d.subs {
+			close(sub.lines)
+		}
+		if d.fallback != nil {
+			close(d.fallback.lines)
+		}
+		d.closeErr = d.itr.Close()
+	}()
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		line, ok, serr := d.itr.Next()
+		if !ok {
+			d.err = serr
+			return
+		}
+		var sub *demuxIterator
+		if key, ok := d.keyOf(line); ok {
+			sub = d.subs[key]
+		}
+		if sub == nil {
+			sub = d.fallback
+		}
+		if sub == nil {
+			// Nobody is interested in
+			continue
+		}
+		select {
+		case sub.lines <- *line:
+		case <-sub.done:
+			// Closed sub-iterator drops lines
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (i *demuxIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	line, ok := <-i.lines
+	if !ok {
+		// err is set before lines is closed
+		return nil, false, i.demuxer.err
+	}
+	i.line = line
+	return &i.line, true, nil
+}
+
+// Close detaches this sub-iterator.
+// The underlying iterator is closed when all sub-iterators are closed,
+// in that case, this waits for the background to stop and returns the error from closing it.
+func (i *demuxIterator) Close() error {
+	if !i.detach() {
+		return nil
+	}
+	d := i.demuxer
+	<-d.stopped
+	return d.closeErr
+}
+
+// detach detaches this sub-iterator without waiting for the background to stop.
+// Returns true if this was the last sub-iterator, which made the background stop
+// and close the underlying iterator after its `Next` returned.
+func (i *demuxIterator) detach() bool {
+	if i.closed {
+		return false
+	}
+	i.closed = true
+	close(i.done)
+	d := i.demuxer
+	d.mutex.Lock()
+	d.open--
+	last := d.open == 0
+	d.mutex.Unlock()
+	if last {
+		close(d.stop)
+	}
+	return last
+}
+
+// Demux splits lines from an iterator into sub-iterators, one for each exchange given.
+// This lets processing for each exchange run on its own goroutine while sharing a single download.
+//
+// Lines of exchanges not given are dropped.
+// The iterator given is read on a background goroutine, which blocks when a sub-iterator
+// have not read its buffered lines, thus all sub-iterators must be consumed concurrently
+// or be closed.
+// An error from the iterator given is reported to all sub-iterators after their buffered lines.
+//
+// The iterator given is closed after all sub-iterators are closed, and must not be used after calling this.
+func Demux(itr StructLineIterator, exchanges []string) map[string]StructLineIterator {
+	subs, _ := newDemuxer(itr, exchanges, false, func(line *StructLine) (string, bool) {
+		return line.Exchange, true
+	})
+	return subs
+}
+
+// Router dispatches lines from a stream to handlers registered for each channel.
+// Each handler is called on its own goroutine, so e.g. trades and order book updates
+// can be processed concurrently.
+//
+// Lines are buffered for each handler, a slow handler will make the stream wait
+// but never makes lines dropped.
+//
+// A router runs only once, handlers must be registered before `Run` and make a new router to run again.
+type Router struct {
+	handlers map[string]func(line *StructLine) error
+	// Handler of lines of other channels, nil if not registered
+	defaultHandler func(line *StructLine) error
+	chans          []chan StructLine
+	// Closed when `Run` is stopping
+	done <-chan struct{}
+	// True if `Run` was called
+	ran bool
+}
+
+// NewRouter creates new `Router` without any handler.
+func NewRouter() *Router {
+	r := new(Router)
+	r.handlers = make(map[string]func(line *StructLine) error)
+	return r
+}
+
+// Handle registers a handler to be called with lines of the given channel.
+// Lines passed to the handler are valid only while it is called.
+// Returning an error from a handler stops `Run` and is returned from it.
+func (r *Router) Handle(channel string, handler func(line *StructLine) error) {
+	r.handlers[channel] = handler
+}
+
+// HandleDefault registers a handler to be called with lines of channels without handler,
+// including lines without channel such as start and end lines.
+// Those lines are dropped if this is not registered.
+func (r *Router) HandleDefault(handler func(line *StructLine) error) {
+	r.defaultHandler = handler
+}
+
+// Chan returns a Go channel receiving lines of the given channel.
+// This is an alternative to `Handle`, lines are sent in order and the channel is closed when `Run` returns.
+// `Run` waits for lines to be received, unless it is stopping by the context or an error.
+func (r *Router) Chan(channel string) <-chan StructLine {
+	ch := make(chan StructLine)
+	r.chans = append(r.chans, ch)
+	r.handlers[channel] = func(line *StructLine) error {
+		select {
+		case ch <- *line:
+			return nil
+		case <-r.done:
+			return context.Canceled
+		}
+	}
+	return ch
+}
+
+// Run reads all lines from the iterator and dispatches them to handlers.
+// The iterator is closed before return if all lines are handled.
+//
+// Returns when all lines are handled, the context is done, or a handler returned an error.
+// Returns the first error from handlers, the iterator or the context.
+// If stopped by the context or an error, this returns without waiting for `Next` of the iterator
+// running at that time, and the iterator is closed in background after it returned,
+// so cancel the context the iterator was made with to stop its downloads.
+//
+// Returns an error without reading the iterator if called more than once.
+func (r *Router) Run(ctx context.Context, itr StructLineIterator) error {
+	if r.ran {
+		return errors.New("router has already run")
+	}
+	r.ran = true
+	defer func() {
+		for _, ch := range r.chans {
+			close(ch)
+		}
+		r.chans = nil
+	}()
+	keys := make([]string, 0, len(r.handlers))
+	for key := range r.handlers {
+		keys = append(keys, key)
+	}
+	subs, fallback := newDemuxer(itr, keys, r.defaultHandler != nil, func(line *StructLine) (string, bool) {
+		if line.Channel == nil {
+			return "", false
+		}
+		return *line.Channel, true
+	})
+	if len(subs) == 0 && fallback == nil {
+		return nil
+	}
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.done = childCtx.Done()
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+	report := func(serr error) {
+		once.Do(func() {
+			first = serr
+			// Stop other handlers
+			cancel()
+		})
+	}
+	run := func(handler func(line *StructLine) error, sub *demuxIterator) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if childCtx.Err() != nil {
+					// Stopping, not to wait for the iterator blocking in `Next`
+					sub.detach()
+					return
+				}
+				if serr := sub.Close(); serr != nil {
+					report(serr)
+				}
+			}()
+			for {
+				select {
+				case line, ok := <-sub.lines:
+					if !ok {
+						if sub.demuxer.err != nil {
+							report(sub.demuxer.err)
+						}
+						return
+					}
+					if serr := handler(&line); serr != nil {
+						report(serr)
+						return
+					}
+				case <-childCtx.Done():
+					report(childCtx.Err())
+					return
+				}
+			}
+		}()
+	}
+	for key, sub := range subs {
+		run(r.handlers[key], sub.(*demuxIterator))
+	}
+	if fallback != nil {
+		run(r.defaultHandler, fallback)
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,203 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDemux(t *testing.T) {
+	source := newSliceIterator(testLines(3000, []string{"bitmex", "bitfinex", "binance"}, []string{"trades"}))
+	source.err = errors.New("source error")
+	subs := Demux(source, []string{"bitmex", "bitfinex"})
+	if len(subs) != 2 {
+		t.Fatalf("len(subs) = %d", len(subs))
+	}
+	var wg sync.WaitGroup
+	counts := make(map[string]int)
+	errs := make(map[string]error)
+	var mutex sync.Mutex
+	for exchange, sub := range subs {
+		wg.Add(1)
+		go func(exchange string, sub StructLineIterator) {
+			defer wg.Done()
+			count := 0
+			var serr error
+			for {
+				line, ok, err := sub.Next()
+				if !ok {
+					serr = err
+					break
+				}
+				if line.Exchange != exchange {
+					serr = errors.New("wrong exchange")
+					break
+				}
+				count++
+			}
+			mutex.Lock()
+			counts[exchange] = count
+			errs[exchange] = serr
+			mutex.Unlock()
+		}(exchange, sub)
+	}
+	wg.Wait()
+	for exchange, sub := range subs {
+		if counts[exchange] != 1000 {
+			t.Fatalf("%s: count = %d", exchange, counts[exchange])
+		}
+		if errs[exchange] != source.err {
+			t.Fatalf("%s: err = %v", exchange, errs[exchange])
+		}
+		if serr := sub.Close(); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if !source.closed {
+		t.Fatal("source is not closed")
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestDemuxCloseEarly(t *testing.T) {
+	source := newSliceIterator(testLines(10*demuxBufferSize, []string{"bitmex", "bitfinex"}, []string{"trades"}))
+	subs := Demux(source, []string{"bitmex", "bitfinex"})
+	// Closing one sub-iterator must not block the other
+	subs["bitfinex"].Close()
+	count := 0
+	for {
+		_, ok, serr := subs["bitmex"].Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if count != 5*demuxBufferSize {
+		t.Fatalf("count = %d", count)
+	}
+	subs["bitmex"].Close()
+	checkGoroutineLeak(t)
+}
+
+func TestRouter(t *testing.T) {
+	source := newSliceIterator(testLines(900, []string{"bitmex"}, []string{"trades", "orderBookL2", "funding"}))
+	router := NewRouter()
+	trades := 0
+	router.Handle("trades", func(line *StructLine) error {
+		if *line.Channel != "trades" {
+			return errors.New("wrong channel")
+		}
+		trades++
+		return nil
+	})
+	others := 0
+	router.HandleDefault(func(line *StructLine) error {
+		others++
+		return nil
+	})
+	books := router.Chan("orderBookL2")
+	bookCount := make(chan int)
+	go func() {
+		count := 0
+		for range books {
+			count++
+		}
+		bookCount <- count
+	}()
+	if serr := router.Run(context.Background(), source); serr != nil {
+		t.Fatal(serr)
+	}
+	if trades != 300 || others != 300 || <-bookCount != 300 {
+		t.Fatalf("trades = %d, others = %d", trades, others)
+	}
+	if !source.closed {
+		t.Fatal("source is not closed")
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestRouterChannelLikeDefault(t *testing.T) {
+	// Channel names can contain slashes
+	source := newSliceIterator(testLines(20, []string{"bitmex"}, []string{"/default", "trades"}))
+	router := NewRouter()
+	channel, others := 0, 0
+	router.Handle("/default", func(line *StructLine) error {
+		channel++
+		return nil
+	})
+	router.HandleDefault(func(line *StructLine) error {
+		if *line.Channel != "trades" {
+			return errors.New("wrong channel")
+		}
+		others++
+		return nil
+	})
+	if serr := router.Run(context.Background(), source); serr != nil {
+		t.Fatal(serr)
+	}
+	if channel != 10 || others != 10 {
+		t.Fatalf("channel = %d, others = %d", channel, others)
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestRouterHandlerError(t *testing.T) {
+	source := newSliceIterator(testLines(10*demuxBufferSize, []string{"bitmex"}, []string{"trades", "orderBookL2"}))
+	router := NewRouter()
+	failure := errors.New("handler failure")
+	router.Handle("trades", func(line *StructLine) error {
+		return failure
+	})
+	// Never read, Run must not be blocked by this
+	router.Chan("orderBookL2")
+	if serr := router.Run(context.Background(), source); serr != failure {
+		t.Fatalf("err = %v", serr)
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestRouterRunTwice(t *testing.T) {
+	router := NewRouter()
+	lines := router.Chan("trades")
+	go func() {
+		for range lines {
+		}
+	}()
+	if serr := router.Run(context.Background(), newSliceIterator(testLines(10, []string{"bitmex"}, []string{"trades"}))); serr != nil {
+		t.Fatal(serr)
+	}
+	source := newSliceIterator(testLines(10, []string{"bitmex"}, []string{"trades"}))
+	if serr := router.Run(context.Background(), source); serr == nil {
+		t.Fatal("second run succeeded")
+	}
+}
+
+func TestRouterCancelWhileReading(t *testing.T) {
+	blockCtx, unblock := context.WithCancel(context.Background())
+	defer unblock()
+	router := NewRouter()
+	router.Handle("trades", func(line *StructLine) error {
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan error)
+	go func() { returned <- router.Run(ctx, &blockingIterator{ctx: blockCtx}) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case serr := <-returned:
+		if serr != context.Canceled {
+			t.Fatalf("unexpected error: %v", serr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return")
+	}
+	// The iterator is closed after Next returned
+	unblock()
+	checkGoroutineLeak(t)
+}
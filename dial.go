@@ -0,0 +1,80 @@
+package exdgo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DialFunc dials a connection, same as `net.Dialer.DialContext`.
+type DialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// dnsCache caches resolved addresses of hosts for a while.
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+	mutex    sync.Mutex
+	entries  map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, resolver: net.DefaultResolver, entries: make(map[string]dnsEntry)}
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mutex.Lock()
+	entry, ok := c.entries[host]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+	addrs, serr := c.resolver.LookupHost(ctx, host)
+	if serr != nil {
+		return nil, serr
+	}
+	c.mutex.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mutex.Unlock()
+	return addrs, nil
+}
+
+// dial resolves the host with the cache and dials addresses in order until one succeeds.
+func (c *dnsCache) dial(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, port, serr := net.SplitHostPort(addr)
+		if serr != nil {
+			return nil, serr
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, serr := c.lookup(ctx, host)
+		if serr != nil {
+			return nil, serr
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, serr := dial(ctx, network, net.JoinHostPort(ip, port))
+			if serr == nil {
+				return conn, nil
+			}
+			lastErr = serr
+		}
+		return nil, lastErr
+	}
+}
+
+// newDialingHTTPClient returns an HTTP client dialing with the function,
+// with the same settings as the default transport otherwise.
+func newDialingHTTPClient(dial DialFunc) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	return &http.Client{Transport: transport}
+}
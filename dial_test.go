@@ -0,0 +1,66 @@
+package exdgo
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientDialContext(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	target, serr := url.Parse(srv.server.URL)
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	var dials int32
+	var dialer net.Dialer
+	cli, serr := NewClient("demo", WithDialContext(func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		// Every host is tunneled to the fake server
+		return dialer.DialContext(ctx, network, target.Host)
+	}))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cli.endpoint = "http://api.exchangedataset.invalid/"
+	if _, serr := cli.HTTPSnapshot(SnapshotParam{
+		Exchange: "bitmex",
+		Channels: []string{"orderBookL2"},
+		At:       time.Unix(1577836800, 0),
+	}); serr != nil {
+		t.Fatal(serr)
+	}
+	if atomic.LoadInt32(&dials) == 0 {
+		t.Fatal("dial function not used")
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	target, serr := url.Parse(srv.server.URL)
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	_, port, _ := net.SplitHostPort(target.Host)
+	cache := newDNSCache(time.Minute)
+	var dialer net.Dialer
+	dial := cache.dial(dialer.DialContext)
+	for i := 0; i < 2; i++ {
+		conn, serr := dial(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		conn.Close()
+	}
+	if _, ok := cache.entries["localhost"]; !ok {
+		t.Fatal("address not cached")
+	}
+	if _, serr := setupClient(ClientParam{APIKey: "demo", HTTPClient: newDialingHTTPClient(dialer.DialContext), DialContext: dialer.DialContext}); serr == nil {
+		t.Fatal("DialContext with HTTPClient accepted")
+	}
+}
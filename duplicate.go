@@ -0,0 +1,124 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DuplicateParam is the parameters for `DetectDuplicates`.
+type DuplicateParam struct {
+	// If true, duplicates are not yielded.
+	Drop bool
+	// Called with each duplicate found, before it is yielded.
+	// Optional.
+	OnDuplicate func(line *StructLine)
+}
+
+// duplicateChannel is messages of a channel at the latest timestamp.
+type duplicateChannel struct {
+	timestamp int64
+	payloads  map[string]bool
+}
+
+// DuplicateIterator yields lines and counts duplicated messages. See `DetectDuplicates`.
+type DuplicateIterator struct {
+	source StructLineIterator
+	param  DuplicateParam
+	// map[exchange]map[channel]messages
+	channels   map[string]map[string]*duplicateChannel
+	messages   LineCounts
+	duplicates LineCounts
+	closed     bool
+}
+
+// DetectDuplicates returns an iterator which finds messages exactly same as an earlier one,
+// of the same exchange, channel, timestamp and payload, which are artifacts of capturing
+// and bias statistics such as trade volume.
+// Frequency of them can be read by `Duplicates` and `Messages` of the iterator while or after reading.
+//
+// Payloads are compared by `StructLine.Raw` if it is kept by `KeepRaw`, otherwise by messages encoded in JSON.
+// Lines must be in order of timestamp, only messages at the latest timestamp of each channel are remembered.
+//
+// The iterator given is closed when the returned iterator is closed.
+func DetectDuplicates(itr StructLineIterator, param DuplicateParam) *DuplicateIterator {
+	return &DuplicateIterator{
+		source:     itr,
+		param:      param,
+		channels:   make(map[string]map[string]*duplicateChannel),
+		messages:   make(LineCounts),
+		duplicates: make(LineCounts),
+	}
+}
+
+func (i *DuplicateIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		line, ok, serr := i.source.Next()
+		if !ok || line.Type != LineTypeMessage || line.Channel == nil {
+			return line, ok, serr
+		}
+		duplicate, serr := i.check(line)
+		if serr != nil {
+			return nil, false, serr
+		}
+		if !duplicate {
+			return line, true, nil
+		}
+		if i.param.OnDuplicate != nil {
+			i.param.OnDuplicate(line)
+		}
+		if !i.param.Drop {
+			return line, true, nil
+		}
+	}
+}
+
+// check counts the message and reports whether it is a duplicate.
+func (i *DuplicateIterator) check(line *StructLine) (bool, error) {
+	payload := []byte(line.Raw)
+	if payload == nil {
+		var serr error
+		payload, serr = json.Marshal(line.Message)
+		if serr != nil {
+			return false, fmt.Errorf("encoding message to compare: %v", serr)
+		}
+	}
+	i.messages.add(line.Exchange, *line.Channel)
+	channels, ok := i.channels[line.Exchange]
+	if !ok {
+		channels = make(map[string]*duplicateChannel)
+		i.channels[line.Exchange] = channels
+	}
+	channel, ok := channels[*line.Channel]
+	if !ok || channel.timestamp != line.Timestamp {
+		channel = &duplicateChannel{timestamp: line.Timestamp, payloads: make(map[string]bool)}
+		channels[*line.Channel] = channel
+	}
+	if channel.payloads[string(payload)] {
+		i.duplicates.add(line.Exchange, *line.Channel)
+		return true, nil
+	}
+	channel.payloads[string(payload)] = true
+	return false, nil
+}
+
+// Duplicates returns the number of duplicated messages found so far for each exchange and channel,
+// not counting the first one of them.
+func (i *DuplicateIterator) Duplicates() LineCounts {
+	return i.duplicates
+}
+
+// Messages returns the number of messages read so far for each exchange and channel, including duplicates.
+func (i *DuplicateIterator) Messages() LineCounts {
+	return i.messages
+}
+
+func (i *DuplicateIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
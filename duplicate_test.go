@@ -0,0 +1,55 @@
+package exdgo
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDetectDuplicates(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	duplicated := start.Add(2 * time.Minute)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != fmt.Sprintf("/filter/bitmex/%d", duplicated.Unix()/60) {
+			return true
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		ts := duplicated.UnixNano()
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", ts, `{"price":1.5,"size":1}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", ts, `{"price":1.5,"size":1}`)
+		// Same payload at another timestamp, or another payload at the same timestamp
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", ts, `{"price":1.5,"size":2}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", ts+1, `{"price":1.5,"size":1}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", ts+1, `{"price":1.5,"size":1}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", ts+1, `{"price":1.5,"size":1}`)
+		return false
+	}
+	for _, keepRaw := range []bool{false, true} {
+		req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{KeepRaw: keepRaw})
+		itr, serr := req.Stream()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		flagged := 0
+		detector := DetectDuplicates(itr, DuplicateParam{Drop: true, OnDuplicate: func(line *StructLine) { flagged++ }})
+		lines := readAllStructLines(t, detector)
+		if flagged != 3 || detector.Duplicates()["bitmex"]["orderBookL2_XBTUSD"] != 3 || detector.Duplicates().Total() != 3 {
+			t.Fatalf("KeepRaw %v: %d flagged, duplicates %v", keepRaw, flagged, detector.Duplicates())
+		}
+		if messages := detector.Messages()["bitmex"]["orderBookL2_XBTUSD"]; messages != 6*9+6 {
+			t.Fatalf("%d messages counted", messages)
+		}
+		yielded := 0
+		for _, line := range lines {
+			if line.Exchange == "bitmex" && line.Type == LineTypeMessage {
+				yielded++
+			}
+		}
+		if yielded != 6*9+3 {
+			t.Fatalf("%d messages yielded, duplicates not dropped", yielded)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package exdgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Written at the beginning of a stream of encoded lines, the last byte is the version.
+var lineEncodingHeader = []byte("EXDL\x01")
+
+// Tags for values in an encoded message
+const (
+	valueNil byte = iota
+	valueFalse
+	valueTrue
+	valueFloat64
+	valueInt64
+	valueInt
+	valueString
+	valueNumber
+	valueTime
+	valueDuration
+	valueRawMessage
+	valueSlice
+	valueMap
+	valueRawMap
+	valueBytes
+	valueNilBytes
+	valueDefinitionChange
+	valueOrderFlow
+)
+
+// LineWriter encodes `StructLine`s into a compact binary stream.
+// Unlike JSON, types of values in messages such as `int64`, `time.Time` and `json.Number`
+// are kept when read back by `LineReader`.
+//
+// `Flush` must be called after writing all lines.
+type LineWriter struct {
+	writer      *bufio.Writer
+	wroteHeader bool
+	buf         [binary.MaxVarintLen64]byte
+}
+
+// NewLineWriter returns a `LineWriter` writing into `w`.
+func NewLineWriter(w io.Writer) *LineWriter {
+	return &LineWriter{writer: bufio.NewWriter(w)}
+}
+
+func (w *LineWriter) writeUvarint(x uint64) {
+	n := binary.PutUvarint(w.buf[:], x)
+	w.writer.Write(w.buf[:n])
+}
+
+func (w *LineWriter) writeVarint(x int64) {
+	n := binary.PutVarint(w.buf[:], x)
+	w.writer.Write(w.buf[:n])
+}
+
+func (w *LineWriter) writeString(s string) {
+	w.writeUvarint(uint64(len(s)))
+	w.writer.WriteString(s)
+}
+
+func (w *LineWriter) writeBytes(b []byte) {
+	w.writeUvarint(uint64(len(b)))
+	w.writer.Write(b)
+}
+
+// writeNilableStringMap writes a map such as definitions, keeping whether it is nil.
+func (w *LineWriter) writeNilableStringMap(m map[string]string) {
+	if m == nil {
+		w.writer.WriteByte(0)
+		return
+	}
+	w.writer.WriteByte(1)
+	w.writeUvarint(uint64(len(m)))
+	for key, elem := range m {
+		w.writeString(key)
+		w.writeString(elem)
+	}
+}
+
+func (w *LineWriter) writeValue(val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		w.writer.WriteByte(valueNil)
+	case bool:
+		if v {
+			w.writer.WriteByte(valueTrue)
+		} else {
+			w.writer.WriteByte(valueFalse)
+		}
+	case float64:
+		w.writer.WriteByte(valueFloat64)
+		w.writeUvarint(math.Float64bits(v))
+	case int64:
+		w.writer.WriteByte(valueInt64)
+		w.writeVarint(v)
+	case int:
+		w.writer.WriteByte(valueInt)
+		w.writeVarint(int64(v))
+	case string:
+		w.writer.WriteByte(valueString)
+		w.writeString(v)
+	case json.Number:
+		w.writer.WriteByte(valueNumber)
+		w.writeString(string(v))
+	case time.Time:
+		w.writer.WriteByte(valueTime)
+		w.writeVarint(v.UnixNano())
+	case time.Duration:
+		w.writer.WriteByte(valueDuration)
+		w.writeVarint(int64(v))
+	case json.RawMessage:
+		w.writer.WriteByte(valueRawMessage)
+		w.writeBytes(v)
+	case []interface{}:
+		w.writer.WriteByte(valueSlice)
+		w.writeUvarint(uint64(len(v)))
+		for _, elem := range v {
+			if serr := w.writeValue(elem); serr != nil {
+				return serr
+			}
+		}
+	case map[string]interface{}:
+		w.writer.WriteByte(valueMap)
+		w.writeUvarint(uint64(len(v)))
+		for key, elem := range v {
+			w.writeString(key)
+			if serr := w.writeValue(elem); serr != nil {
+				return fmt.Errorf("%s: %v", key, serr)
+			}
+		}
+	case map[string]json.RawMessage:
+		w.writer.WriteByte(valueRawMap)
+		w.writeUvarint(uint64(len(v)))
+		for key, elem := range v {
+			w.writeString(key)
+			w.writeBytes(elem)
+		}
+	case []byte:
+		// Messages of control lines, nil for most of them
+		if v == nil {
+			w.writer.WriteByte(valueNilBytes)
+		} else {
+			w.writer.WriteByte(valueBytes)
+			w.writeBytes(v)
+		}
+	case *DefinitionChange:
+		w.writer.WriteByte(valueDefinitionChange)
+		w.writeNilableStringMap(v.Old)
+		w.writeNilableStringMap(v.New)
+	case *OrderFlow:
+		w.writer.WriteByte(valueOrderFlow)
+		w.writeString(v.Key)
+		for _, x := range []float64{v.Delta, v.CVD, v.BuyVolume, v.SellVolume} {
+			w.writeUvarint(math.Float64bits(x))
+		}
+		w.writeVarint(v.BuyTrades)
+		w.writeVarint(v.SellTrades)
+	default:
+		return fmt.Errorf("unsupported type %T", val)
+	}
+	return nil
+}
+
+// Write encodes the line.
+func (w *LineWriter) Write(line *StructLine) error {
+	if !w.wroteHeader {
+		if _, serr := w.writer.Write(lineEncodingHeader); serr != nil {
+			return serr
+		}
+		w.wroteHeader = true
+	}
+	w.writeString(line.Exchange)
+	w.writeString(string(line.Type))
+	w.writeVarint(line.Timestamp)
+	if line.Channel == nil {
+		w.writer.WriteByte(0)
+	} else {
+		w.writer.WriteByte(1)
+		w.writeString(*line.Channel)
+	}
+	if serr := w.writeValue(line.Message); serr != nil {
+		return fmt.Errorf("message: %v", serr)
+	}
+	if line.Raw == nil {
+		w.writer.WriteByte(0)
+	} else {
+		w.writer.WriteByte(1)
+		w.writeBytes(line.Raw)
+	}
+	w.writeNilableStringMap(line.Definition)
+	// Errors are sticky in bufio.Writer
+	_, serr := w.writer.Write(nil)
+	return serr
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *LineWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// LineReader reads lines encoded by `LineWriter`.
+// It is also a `StructLineIterator`, so encoded lines can be used where lines from the server are.
+type LineReader struct {
+	source     io.Reader
+	reader     *bufio.Reader
+	readHeader bool
+	line       StructLine
+	closed     bool
+}
+
+// NewLineReader returns a `LineReader` reading from `r`.
+// `r` is closed on `Close` if it is an `io.Closer`.
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{source: r, reader: bufio.NewReader(r)}
+}
+
+// errUnexpectedEOF converts EOF in the middle of a line.
+func errUnexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (r *LineReader) readString() (string, error) {
+	b, serr := r.readBytes()
+	return string(b), serr
+}
+
+func (r *LineReader) readBytes() ([]byte, error) {
+	n, serr := binary.ReadUvarint(r.reader)
+	if serr != nil {
+		return nil, errUnexpectedEOF(serr)
+	}
+	if n > math.MaxInt32 {
+		return nil, errors.New("too long")
+	}
+	b := make([]byte, n)
+	if _, serr := io.ReadFull(r.reader, b); serr != nil {
+		return nil, errUnexpectedEOF(serr)
+	}
+	return b, nil
+}
+
+func (r *LineReader) readVarint() (int64, error) {
+	x, serr := binary.ReadVarint(r.reader)
+	return x, errUnexpectedEOF(serr)
+}
+
+func (r *LineReader) readFloat64() (float64, error) {
+	bits, serr := binary.ReadUvarint(r.reader)
+	return math.Float64frombits(bits), errUnexpectedEOF(serr)
+}
+
+// readNilableStringMap reads a map written by `writeNilableStringMap`.
+func (r *LineReader) readNilableStringMap() (map[string]string, error) {
+	flag, serr := r.reader.ReadByte()
+	if serr != nil {
+		return nil, errUnexpectedEOF(serr)
+	}
+	if flag == 0 {
+		return nil, nil
+	}
+	n, serr := binary.ReadUvarint(r.reader)
+	if serr != nil {
+		return nil, errUnexpectedEOF(serr)
+	}
+	m := make(map[string]string, n)
+	for j := uint64(0); j < n; j++ {
+		key, serr := r.readString()
+		if serr != nil {
+			return nil, serr
+		}
+		if m[key], serr = r.readString(); serr != nil {
+			return nil, serr
+		}
+	}
+	return m, nil
+}
+
+func (r *LineReader) readValue() (interface{}, error) {
+	tag, serr := r.reader.ReadByte()
+	if serr != nil {
+		return nil, errUnexpectedEOF(serr)
+	}
+	switch tag {
+	case valueNil:
+		return nil, nil
+	case valueFalse:
+		return false, nil
+	case valueTrue:
+		return true, nil
+	case valueFloat64:
+		return r.readFloat64()
+	case valueInt64:
+		return r.readVarint()
+	case valueInt:
+		x, serr := r.readVarint()
+		return int(x), serr
+	case valueString:
+		return r.readString()
+	case valueNumber:
+		s, serr := r.readString()
+		return json.Number(s), serr
+	case valueTime:
+		x, serr := r.readVarint()
+		return time.Unix(0, x).UTC(), serr
+	case valueDuration:
+		x, serr := r.readVarint()
+		return time.Duration(x), serr
+	case valueRawMessage:
+		b, serr := r.readBytes()
+		return json.RawMessage(b), serr
+	case valueSlice:
+		n, serr := binary.ReadUvarint(r.reader)
+		if serr != nil {
+			return nil, errUnexpectedEOF(serr)
+		}
+		slice := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			elem, serr := r.readValue()
+			if serr != nil {
+				return nil, serr
+			}
+			slice = append(slice, elem)
+		}
+		return slice, nil
+	case valueMap:
+		n, serr := binary.ReadUvarint(r.reader)
+		if serr != nil {
+			return nil, errUnexpectedEOF(serr)
+		}
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, serr := r.readString()
+			if serr != nil {
+				return nil, serr
+			}
+			m[key], serr = r.readValue()
+			if serr != nil {
+				return nil, serr
+			}
+		}
+		return m, nil
+	case valueRawMap:
+		n, serr := binary.ReadUvarint(r.reader)
+		if serr != nil {
+			return nil, errUnexpectedEOF(serr)
+		}
+		m := make(map[string]json.RawMessage, n)
+		for i := uint64(0); i < n; i++ {
+			key, serr := r.readString()
+			if serr != nil {
+				return nil, serr
+			}
+			b, serr := r.readBytes()
+			if serr != nil {
+				return nil, serr
+			}
+			m[key] = b
+		}
+		return m, nil
+	case valueBytes:
+		return r.readBytes()
+	case valueNilBytes:
+		return []byte(nil), nil
+	case valueDefinitionChange:
+		change := &DefinitionChange{}
+		if change.Old, serr = r.readNilableStringMap(); serr != nil {
+			return nil, serr
+		}
+		change.New, serr = r.readNilableStringMap()
+		return change, serr
+	case valueOrderFlow:
+		flow := &OrderFlow{}
+		if flow.Key, serr = r.readString(); serr != nil {
+			return nil, serr
+		}
+		for _, x := range []*float64{&flow.Delta, &flow.CVD, &flow.BuyVolume, &flow.SellVolume} {
+			if *x, serr = r.readFloat64(); serr != nil {
+				return nil, serr
+			}
+		}
+		if flow.BuyTrades, serr = r.readVarint(); serr != nil {
+			return nil, serr
+		}
+		flow.SellTrades, serr = r.readVarint()
+		return flow, serr
+	}
+	return nil, fmt.Errorf("unknown value tag %d", tag)
+}
+
+// Read decodes the next line.
+// Returns `io.EOF` if there are no more lines.
+func (r *LineReader) Read() (line StructLine, err error) {
+	if !r.readHeader {
+		header := make([]byte, len(lineEncodingHeader))
+		if _, serr := io.ReadFull(r.reader, header); serr != nil {
+			err = serr
+			return
+		}
+		if string(header) != string(lineEncodingHeader) {
+			err = errors.New("not encoded lines or unsupported version")
+			return
+		}
+		r.readHeader = true
+	}
+	if _, serr := r.reader.Peek(1); serr != nil {
+		// EOF at the beginning of a line
+		err = serr
+		return
+	}
+	if line.Exchange, err = r.readString(); err != nil {
+		return
+	}
+	var typ string
+	if typ, err = r.readString(); err != nil {
+		return
+	}
+	line.Type = LineType(typ)
+	if line.Timestamp, err = r.readVarint(); err != nil {
+		return
+	}
+	flag, serr := r.reader.ReadByte()
+	if serr != nil {
+		err = errUnexpectedEOF(serr)
+		return
+	}
+	if flag == 1 {
+		var channel string
+		if channel, err = r.readString(); err != nil {
+			return
+		}
+		line.Channel = &channel
+	}
+	if line.Message, err = r.readValue(); err != nil {
+		return
+	}
+	flag, serr = r.reader.ReadByte()
+	if serr != nil {
+		err = errUnexpectedEOF(serr)
+		return
+	}
+	if flag == 1 {
+		var raw []byte
+		if raw, err = r.readBytes(); err != nil {
+			return
+		}
+		line.Raw = raw
+	}
+	line.Definition, err = r.readNilableStringMap()
+	return
+}
+
+// Next returns the next line, for using `LineReader` as a `StructLineIterator`.
+func (r *LineReader) Next() (*StructLine, bool, error) {
+	if r.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	line, serr := r.Read()
+	if serr != nil {
+		if serr == io.EOF {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("reading encoded line: %v", serr)
+	}
+	r.line = line
+	return &r.line, true, nil
+}
+
+// Close closes the underlying reader if it is an `io.Closer`.
+func (r *LineReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if closer, ok := r.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
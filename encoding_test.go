@@ -0,0 +1,127 @@
+package exdgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLineEncodingRoundTrip(t *testing.T) {
+	channel := "trades"
+	lines := []StructLine{
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 1, Message: nil},
+		{
+			Exchange:  "bitmex",
+			Type:      LineTypeMessage,
+			Timestamp: -2,
+			Channel:   &channel,
+			Message: map[string]interface{}{
+				"price":     1.5,
+				"size":      int64(3),
+				"index":     7,
+				"side":      "buy",
+				"open":      true,
+				"closed":    false,
+				"missing":   nil,
+				"number":    json.Number("0.10000000000000000001"),
+				"timestamp": time.Unix(0, 1577836800000000001).UTC(),
+				"duration":  time.Minute,
+				"nested":    []interface{}{map[string]interface{}{"a": "b"}, nil, 1.0},
+				"raw":       json.RawMessage(`{"x":1}`),
+			},
+			Raw:        json.RawMessage(`{"original":true}`),
+			Definition: map[string]string{"price": "float", "timestamp": "timestamp"},
+		},
+		{
+			Exchange:  "bitfinex",
+			Type:      LineTypeMessage,
+			Timestamp: 3,
+			Channel:   &channel,
+			Message:   map[string]json.RawMessage{"price": json.RawMessage(`1.5`)},
+		},
+		{Exchange: "bitfinex", Type: LineTypeEnd, Timestamp: 4},
+		// Control lines from the server have messages in bytes
+		{Exchange: "bitmex", Type: LineTypeError, Timestamp: 5, Message: []byte(`{"reason":"disconnected"}`)},
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 6, Message: []byte("wss://www.bitmex.com/realtime")},
+		{Exchange: "bitmex", Type: LineTypeEnd, Timestamp: 7, Message: []byte(nil)},
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 8, Message: []byte{}},
+		{
+			Exchange:  "bitmex",
+			Type:      LineTypeDefinitionChange,
+			Timestamp: 9,
+			Channel:   &channel,
+			Message:   &DefinitionChange{Old: map[string]string{"price": "float"}, New: map[string]string{"price": "string"}},
+		},
+		{
+			Exchange:  "bitmex",
+			Type:      LineTypeDefinitionChange,
+			Timestamp: 9,
+			Channel:   &channel,
+			Message:   &DefinitionChange{New: map[string]string{}},
+		},
+		{
+			Exchange:  "bitmex",
+			Type:      LineTypeOrderFlow,
+			Timestamp: 10,
+			Channel:   &channel,
+			Message: &OrderFlow{
+				Key:        "XBTUSD",
+				Delta:      -2.5,
+				CVD:        1.25,
+				BuyVolume:  3.75,
+				BuyTrades:  2,
+				SellVolume: 2.5,
+				SellTrades: 1,
+			},
+		},
+	}
+	var buf bytes.Buffer
+	w := NewLineWriter(&buf)
+	for i := range lines {
+		if serr := w.Write(&lines[i]); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := w.Flush(); serr != nil {
+		t.Fatal(serr)
+	}
+	r := NewLineReader(&buf)
+	for i := range lines {
+		line, serr := r.Read()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if !reflect.DeepEqual(line, lines[i]) {
+			t.Fatalf("line %d differ:\n%#v\n%#v", i, line, lines[i])
+		}
+	}
+	if _, serr := r.Read(); serr != io.EOF {
+		t.Fatalf("expected EOF: %v", serr)
+	}
+}
+
+func TestLineEncodingErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewLineWriter(&buf)
+	if serr := w.Write(&StructLine{Message: struct{}{}}); serr == nil {
+		t.Fatal("unsupported type accepted")
+	}
+	if _, serr := NewLineReader(bytes.NewReader([]byte("not encoded"))).Read(); serr == nil {
+		t.Fatal("invalid header accepted")
+	}
+	buf.Reset()
+	w = NewLineWriter(&buf)
+	w.Write(&StructLine{Exchange: "bitmex", Type: LineTypeMessage, Message: "truncated"})
+	w.Flush()
+	truncated := buf.Bytes()[:buf.Len()-3]
+	if _, serr := NewLineReader(bytes.NewReader(truncated)).Read(); serr != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated line: %v", serr)
+	}
+	// Empty stream has no lines
+	if _, ok, serr := NewLineReader(bytes.NewReader(nil)).Next(); ok || serr != nil {
+		t.Fatalf("empty stream: %v", serr)
+	}
+}
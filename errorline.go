@@ -0,0 +1,111 @@
+package exdgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrorLine is the parsed message of a line of `LineTypeError`.
+//
+// Error message is recorded in the format each exchange or our client reported,
+// so fields are filled on best-effort basis.
+type ErrorLine struct {
+	Exchange  string
+	Timestamp int64
+	// Human readable reason of the error.
+	// Whole message is set if the message is not structured.
+	Reason string
+	// Channel affected by the error.
+	// nil if the error is not specific to a channel or it is unknown.
+	Channel *string
+	// Error code reported by the server.
+	// nil if absent.
+	Code *string
+	// Original message.
+	Raw []byte
+}
+
+// Names of fields which could contain each information in a structured error message
+var (
+	errorLineReasonFields  = []string{"reason", "message", "msg", "error", "err"}
+	errorLineChannelFields = []string{"channel", "topic", "subscribe", "arg"}
+	errorLineCodeFields    = []string{"code", "status", "errorCode", "error_code"}
+)
+
+// parseErrorMessage parses the message of an error line.
+func parseErrorMessage(exchange string, timestamp int64, message []byte) *ErrorLine {
+	parsed := &ErrorLine{
+		Exchange:  exchange,
+		Timestamp: timestamp,
+		Raw:       message,
+	}
+	trimmed := bytes.TrimSpace(message)
+	var obj map[string]interface{}
+	if len(trimmed) == 0 || trimmed[0] != '{' || json.Unmarshal(trimmed, &obj) != nil {
+		// Not structured
+		parsed.Reason = string(trimmed)
+		return parsed
+	}
+	if reason, ok := findErrorLineField(obj, errorLineReasonFields); ok {
+		parsed.Reason = reason
+	} else {
+		parsed.Reason = string(trimmed)
+	}
+	if channel, ok := findErrorLineField(obj, errorLineChannelFields); ok {
+		parsed.Channel = &channel
+	}
+	if code, ok := findErrorLineField(obj, errorLineCodeFields); ok {
+		parsed.Code = &code
+	}
+	return parsed
+}
+
+// findErrorLineField returns the first field found in names as a string.
+// Nested object named "error" is also searched.
+func findErrorLineField(obj map[string]interface{}, names []string) (string, bool) {
+	for _, name := range names {
+		switch val := obj[name].(type) {
+		case string:
+			if val != "" {
+				return val, true
+			}
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), true
+		case bool, nil, map[string]interface{}:
+		default:
+			encoded, serr := json.Marshal(val)
+			if serr == nil {
+				return string(encoded), true
+			}
+		}
+	}
+	if nested, ok := obj["error"].(map[string]interface{}); ok {
+		return findErrorLineField(nested, names)
+	}
+	return "", false
+}
+
+// ParseErrorLine parses the message of a line of `LineTypeError` into `ErrorLine`.
+// Returns error if the line is not an error line.
+func ParseErrorLine(line *StringLine) (*ErrorLine, error) {
+	if line.Type != LineTypeError {
+		return nil, fmt.Errorf("not an error line: %s", line.Type)
+	}
+	return parseErrorMessage(line.Exchange, line.Timestamp, line.Message), nil
+}
+
+// ParseStructErrorLine parses the message of a line of `LineTypeError` into `ErrorLine`.
+// Returns error if the line is not an error line.
+func ParseStructErrorLine(line *StructLine) (*ErrorLine, error) {
+	if line.Type != LineTypeError {
+		return nil, fmt.Errorf("not an error line: %s", line.Type)
+	}
+	message, ok := line.Message.([]byte)
+	if !ok {
+		return nil, errors.New("message of error line is not bytes")
+	}
+	return parseErrorMessage(line.Exchange, line.Timestamp, message), nil
+}
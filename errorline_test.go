@@ -0,0 +1,42 @@
+package exdgo
+
+import (
+	"testing"
+)
+
+func TestParseErrorLine(t *testing.T) {
+	cases := []struct {
+		message string
+		reason  string
+		channel string
+		code    string
+	}{
+		{"connection reset by peer", "connection reset by peer", "", ""},
+		{`{"status":400,"error":"Unknown table: orderBookL2_XXX","request":{"op":"subscribe"}}`, "Unknown table: orderBookL2_XXX", "", "400"},
+		{`{"event":"error","msg":"subscribe: dup","code":10301,"channel":"trades"}`, "subscribe: dup", "trades", "10301"},
+		{`{"error":{"message":"rate limited","code":"E429"}}`, "rate limited", "", "E429"},
+	}
+	for _, c := range cases {
+		parsed, serr := ParseErrorLine(&StringLine{
+			Exchange:  "bitmex",
+			Type:      LineTypeError,
+			Timestamp: 10,
+			Message:   []byte(c.message),
+		})
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if parsed.Reason != c.reason {
+			t.Fatalf("%s: reason = %s", c.message, parsed.Reason)
+		}
+		if (parsed.Channel == nil) != (c.channel == "") || (parsed.Channel != nil && *parsed.Channel != c.channel) {
+			t.Fatalf("%s: channel = %v", c.message, parsed.Channel)
+		}
+		if (parsed.Code == nil) != (c.code == "") || (parsed.Code != nil && *parsed.Code != c.code) {
+			t.Fatalf("%s: code = %v", c.message, parsed.Code)
+		}
+	}
+	if _, serr := ParseErrorLine(&StringLine{Type: LineTypeMessage}); serr == nil {
+		t.Fatal("expected error for message line")
+	}
+}
@@ -0,0 +1,98 @@
+package exdgo
+
+import (
+	"bytes"
+	"errors"
+)
+
+// StreamEvent is an event of the lifecycle of a recording, parsed from a control line.
+// It is one of `*StreamStarted`, `*StreamEnded` and `*StreamError`.
+type StreamEvent interface {
+	streamEvent()
+}
+
+// StreamStarted is the event of a start line, the recording of an exchange started or reconnected.
+type StreamStarted struct {
+	Exchange  string
+	Timestamp int64
+	// Message of the start line, such as the URL connected to
+	Message string
+}
+
+// StreamEnded is the event of an end line, the recording of an exchange ended.
+type StreamEnded struct {
+	Exchange  string
+	Timestamp int64
+}
+
+// StreamError is the event of an error line, with the error parsed.
+type StreamError struct {
+	*ErrorLine
+}
+
+func (*StreamStarted) streamEvent() {}
+func (*StreamEnded) streamEvent()   {}
+func (*StreamError) streamEvent()   {}
+
+// ParseStreamEvent parses a start, end or error line into `StreamEvent`.
+// Returns nil for other lines.
+func ParseStreamEvent(line *StructLine) (StreamEvent, error) {
+	switch line.Type {
+	case LineTypeStart:
+		message, ok := line.Message.([]byte)
+		if !ok && line.Message != nil {
+			return nil, errors.New("message of start line is not bytes")
+		}
+		return &StreamStarted{Exchange: line.Exchange, Timestamp: line.Timestamp, Message: string(bytes.TrimSpace(message))}, nil
+	case LineTypeEnd:
+		return &StreamEnded{Exchange: line.Exchange, Timestamp: line.Timestamp}, nil
+	case LineTypeError:
+		parsed, serr := ParseStructErrorLine(line)
+		if serr != nil {
+			return nil, serr
+		}
+		return &StreamError{parsed}, nil
+	}
+	return nil, nil
+}
+
+// NotifyStreamEvents returns an iterator yielding lines from `itr` as is,
+// calling `fn` with the event of each start, end and error line in order before it is yielded.
+// Returning an error from `fn` stops reading and the error is returned from `Next`.
+func NotifyStreamEvents(itr StructLineIterator, fn func(event StreamEvent) error) StructLineIterator {
+	return &streamEventIterator{source: itr, fn: fn}
+}
+
+type streamEventIterator struct {
+	source StructLineIterator
+	fn     func(event StreamEvent) error
+	closed bool
+}
+
+func (i *streamEventIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	line, ok, serr := i.source.Next()
+	if !ok {
+		return nil, false, serr
+	}
+	event, serr := ParseStreamEvent(line)
+	if serr != nil {
+		return nil, false, serr
+	}
+	if event != nil {
+		if serr := i.fn(event); serr != nil {
+			return nil, false, serr
+		}
+	}
+	return line, true, nil
+}
+
+func (i *streamEventIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
@@ -0,0 +1,60 @@
+package exdgo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStreamEvents(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	minute := time.Unix(1577836800, 0).Add(2 * time.Minute)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != fmt.Sprintf("/filter/bitmex/%d", minute.Unix()/60) {
+			return true
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "err\t%d\t%s\n", minute.UnixNano(), `{"error":"Rate limited","status":429}`)
+		fmt.Fprintf(w, "start\t%d\twss://www.bitmex.com/realtime\n", minute.UnixNano()+1)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", minute.UnixNano()+1, `{"price":"float","size":"int","timestamp":"timestamp"}`)
+		fmt.Fprintf(w, "end\t%d\n", minute.UnixNano()+2)
+		return false
+	}
+	events := make([]StreamEvent, 0)
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{OnEvent: func(event StreamEvent) error {
+		events = append(events, event)
+		return nil
+	}})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	controls := 0
+	for _, line := range lines {
+		if line.Type != LineTypeMessage {
+			controls++
+		}
+	}
+	if len(events) != 3 || controls != 3 {
+		t.Fatalf("%d events for %d lines", len(events), controls)
+	}
+	if e, ok := events[0].(*StreamError); !ok || e.Reason != "Rate limited" || *e.Code != "429" {
+		t.Fatalf("event 0: %+v", events[0])
+	}
+	if e, ok := events[1].(*StreamStarted); !ok || e.Message != "wss://www.bitmex.com/realtime" || e.Exchange != "bitmex" {
+		t.Fatalf("event 1: %+v", events[1])
+	}
+	if e, ok := events[2].(*StreamEnded); !ok || e.Timestamp != minute.UnixNano()+2 {
+		t.Fatalf("event 2: %+v", events[2])
+	}
+
+	stop := errors.New("stop")
+	req = prepareFakeReplayRequest(t, srv, ReplayRequestParam{OnEvent: func(event StreamEvent) error { return stop }})
+	if _, serr := req.Download(); !errors.Is(serr, stop) {
+		t.Fatalf("expected stop, got %v", serr)
+	}
+	checkGoroutineLeak(t)
+}
@@ -0,0 +1,133 @@
+package exdgo
+
+import (
+	"container/heap"
+	"time"
+)
+
+// EventScheduler runs callbacks registered in event time, which is advanced by timestamps of lines
+// rather than the wall clock, such as to act 5 seconds after a trade in a backtest.
+// Wrap a stream with `Drive` to advance it with lines.
+// Not safe for concurrent use, callbacks are run on the goroutine advancing the time.
+type EventScheduler struct {
+	now    int64
+	timers eventTimerHeap
+	// Order registered, to fire timers with the same deadline in FIFO order
+	seq int64
+}
+
+// EventTimer is a callback registered to an `EventScheduler`.
+type EventTimer struct {
+	at    int64
+	seq   int64
+	fn    func(now time.Time)
+	index int
+	// nil after fired or stopped
+	scheduler *EventScheduler
+}
+
+// eventTimerHeap is a heap of timers ordered by the deadline, the earliest first.
+type eventTimerHeap []*EventTimer
+
+func (h eventTimerHeap) Len() int { return len(h) }
+func (h eventTimerHeap) Less(a, b int) bool {
+	if h[a].at != h[b].at {
+		return h[a].at < h[b].at
+	}
+	return h[a].seq < h[b].seq
+}
+func (h eventTimerHeap) Swap(a, b int) {
+	h[a], h[b] = h[b], h[a]
+	h[a].index = a
+	h[b].index = b
+}
+func (h *eventTimerHeap) Push(x interface{}) {
+	timer := x.(*EventTimer)
+	timer.index = len(*h)
+	*h = append(*h, timer)
+}
+func (h *eventTimerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// NewEventScheduler creates new `EventScheduler` starting at the given time.
+func NewEventScheduler(start time.Time) *EventScheduler {
+	return &EventScheduler{now: start.UnixNano()}
+}
+
+// Now returns the current event time.
+func (s *EventScheduler) Now() time.Time {
+	return time.Unix(0, s.now)
+}
+
+// At registers `fn` to be called when the event time reaches `at`.
+// If `at` is not after the current time, it is called on the next advance.
+func (s *EventScheduler) At(at time.Time, fn func(now time.Time)) *EventTimer {
+	timer := &EventTimer{at: at.UnixNano(), seq: s.seq, fn: fn, scheduler: s}
+	s.seq++
+	heap.Push(&s.timers, timer)
+	return timer
+}
+
+// After registers `fn` to be called when the duration had elapsed in event time.
+func (s *EventScheduler) After(d time.Duration, fn func(now time.Time)) *EventTimer {
+	return s.At(time.Unix(0, s.now+int64(d)), fn)
+}
+
+// Stop cancels the timer.
+// Returns false if it had already fired or been stopped.
+func (t *EventTimer) Stop() bool {
+	if t.scheduler == nil {
+		return false
+	}
+	heap.Remove(&t.scheduler.timers, t.index)
+	t.scheduler = nil
+	return true
+}
+
+// Advance moves the event time to `to`, firing timers due by then in the order of their deadlines.
+// The current time is the deadline of each timer while its callback runs,
+// and timers registered by callbacks also fire if they are due.
+// Time never goes backward, `to` earlier than the current time only fires timers already due.
+func (s *EventScheduler) Advance(to time.Time) {
+	target := to.UnixNano()
+	for len(s.timers) > 0 && s.timers[0].at <= target {
+		timer := heap.Pop(&s.timers).(*EventTimer)
+		timer.scheduler = nil
+		if timer.at > s.now {
+			s.now = timer.at
+		}
+		timer.fn(time.Unix(0, s.now))
+	}
+	if target > s.now {
+		s.now = target
+	}
+}
+
+// Drive returns an iterator yielding lines from `itr`, which advances the event time
+// to the timestamp of each line before yielding it.
+// Thus timers due at or before a line fire before the line is yielded.
+func (s *EventScheduler) Drive(itr StructLineIterator) StructLineIterator {
+	return &eventDrivenIterator{source: itr, scheduler: s}
+}
+
+type eventDrivenIterator struct {
+	source    StructLineIterator
+	scheduler *EventScheduler
+}
+
+func (i *eventDrivenIterator) Next() (*StructLine, bool, error) {
+	line, ok, serr := i.source.Next()
+	if ok {
+		i.scheduler.Advance(time.Unix(0, line.Timestamp))
+	}
+	return line, ok, serr
+}
+
+func (i *eventDrivenIterator) Close() error {
+	return i.source.Close()
+}
@@ -0,0 +1,59 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventScheduler(t *testing.T) {
+	lines := testLines(10, []string{"bitmex"}, []string{"trade"})
+	sched := NewEventScheduler(time.Unix(0, 0))
+	events := make([]string, 0)
+	fired := make([]time.Time, 0)
+	itr := sched.Drive(newSliceIterator(lines))
+	defer itr.Close()
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if !sched.Now().Equal(time.Unix(0, line.Timestamp)) {
+			t.Fatalf("now %v at line %d", sched.Now(), line.Timestamp)
+		}
+		index := line.Message.(map[string]interface{})["index"].(int)
+		events = append(events, "line")
+		switch index {
+		case 1:
+			// Fires before line 4
+			sched.After(2500*time.Millisecond, func(now time.Time) {
+				fired = append(fired, now)
+				events = append(events, "timer")
+				// Registered by a callback and due at line 5
+				sched.After(1500*time.Millisecond, func(now time.Time) {
+					fired = append(fired, now)
+					events = append(events, "chained")
+				})
+			})
+		case 2:
+			timer := sched.After(time.Second, func(now time.Time) { t.Fatal("stopped timer fired") })
+			if !timer.Stop() || timer.Stop() {
+				t.Fatal("Stop reported wrongly")
+			}
+		}
+	}
+	expected := []string{"line", "line", "line", "line", "timer", "line", "chained", "line", "line", "line", "line", "line"}
+	if len(events) != len(expected) {
+		t.Fatalf("events %v", events)
+	}
+	for k := range expected {
+		if events[k] != expected[k] {
+			t.Fatalf("events %v", events)
+		}
+	}
+	if !fired[0].Equal(time.Unix(3, 500000000)) || !fired[1].Equal(time.Unix(5, 0)) {
+		t.Fatalf("fired at %v", fired)
+	}
+}
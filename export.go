@@ -0,0 +1,217 @@
+package exdgo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Name of the manifest file in the directory written by `Export`
+const exportManifestName = "manifest.json"
+
+// ErrManifestMismatch is reported by `VerifyExport` if a shard differs from the manifest.
+var ErrManifestMismatch = errors.New("shard does not match the manifest")
+
+// ExportManifest describes data written by `RawRequest.Export`.
+type ExportManifest struct {
+	Filter map[string][]string `json:"filter"`
+	// Empty if the default format
+	Format string `json:"format,omitempty"`
+	// Range of the request in nanoseconds since the unix epoch, `End` is exclusive.
+	Start  int64         `json:"start"`
+	End    int64         `json:"end"`
+	Shards []ExportShard `json:"shards"`
+}
+
+// ExportShard is a shard written by `RawRequest.Export`.
+type ExportShard struct {
+	// Path of the file relative to the directory, separated by slashes.
+	Path     string `json:"path"`
+	Exchange string `json:"exchange"`
+	// Range of time the shard covers in nanoseconds since the unix epoch, `End` is exclusive.
+	// Both are the start of the request for the snapshot.
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Number of lines.
+	Lines int `json:"lines"`
+	// Size of the file in bytes.
+	Size int64 `json:"size"`
+	// SHA-256 of the file in hex.
+	SHA256 string `json:"sha256"`
+}
+
+// writeStringLine writes the line in the form of the response of Filter HTTP Endpoint.
+func writeStringLine(w *bufio.Writer, line *StringLine) {
+	if line.Type == LineTypeUndecoded {
+		// The whole response
+		w.Write(line.Message)
+		return
+	}
+	w.WriteString(string(line.Type))
+	w.WriteByte('\t')
+	w.WriteString(strconv.FormatInt(line.Timestamp, 10))
+	if line.Type != LineTypeEnd {
+		if line.Channel != nil {
+			w.WriteByte('\t')
+			w.WriteString(*line.Channel)
+		}
+		w.WriteByte('\t')
+		// Messages of start and error lines keep the newline of the response
+		w.Write(bytes.TrimSuffix(line.Message, []byte{'\n'}))
+	}
+	w.WriteByte('\n')
+}
+
+// Export downloads data of the request into the directory, a file for each shard,
+// and writes the manifest with sizes, hashes and time ranges of them, so the copy can be checked by `VerifyExport`.
+// A shard is written in "<exchange>/snapshot.txt" or "<exchange>/<minute>.txt"
+// in the form of the response of Filter HTTP Endpoint.
+// The manifest is written at last, so the directory without it is incomplete.
+func (r *RawRequest) Export(ctx context.Context, dir string) (*ExportManifest, error) {
+	manifest, serr := r.exportShards(ctx, func(entry *ExportShard, data []byte) error {
+		name := filepath.Join(dir, filepath.FromSlash(entry.Path))
+		if serr := os.MkdirAll(filepath.Dir(name), 0755); serr != nil {
+			return serr
+		}
+		return ioutil.WriteFile(name, data, 0644)
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	data, serr := json.MarshalIndent(manifest, "", "  ")
+	if serr != nil {
+		return nil, serr
+	}
+	if serr := ioutil.WriteFile(filepath.Join(dir, exportManifestName), data, 0644); serr != nil {
+		return nil, serr
+	}
+	return manifest, nil
+}
+
+// exportShards downloads shards of exchanges in order of their names, and calls `fn` with each of them encoded.
+// Returns the manifest of shards, which is not yet written.
+func (r *RawRequest) exportShards(ctx context.Context, fn func(entry *ExportShard, data []byte) error) (*ExportManifest, error) {
+	manifest := &ExportManifest{
+		Filter: r.filter,
+		Start:  r.start,
+		End:    r.end,
+		Shards: make([]ExportShard, 0),
+	}
+	if r.format != nil {
+		manifest.Format = *r.format
+	}
+	exchanges := make([]string, 0, len(r.filter))
+	for exchange := range r.filter {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	startMinute := r.start / int64(time.Minute)
+	for _, exchange := range exchanges {
+		itr := newRawExchangeStreamShardIterator(ctx, r, exchange, r.cli.concurrency, shardOrder{})
+		for index := 0; ; index++ {
+			shard, serr := itr.next()
+			if serr != nil {
+				itr.close()
+				return nil, serr
+			}
+			if shard == nil {
+				break
+			}
+			entry := ExportShard{Exchange: exchange, Start: r.start, End: r.start, Lines: len(shard)}
+			if index == 0 {
+				entry.Path = exchange + "/snapshot.txt"
+			} else {
+				minute := startMinute + int64(index-1)
+				entry.Path = fmt.Sprintf("%s/%d.txt", exchange, minute)
+				entry.Start = minute * int64(time.Minute)
+				if entry.Start < r.start {
+					entry.Start = r.start
+				}
+				entry.End = (minute + 1) * int64(time.Minute)
+				if entry.End > r.end {
+					entry.End = r.end
+				}
+			}
+			data := encodeExportShard(shard)
+			r.cli.memory.addBuffered(-shardBytes(shard))
+			sum := sha256.Sum256(data)
+			entry.Size = int64(len(data))
+			entry.SHA256 = hex.EncodeToString(sum[:])
+			if serr := fn(&entry, data); serr != nil {
+				itr.close()
+				return nil, serr
+			}
+			manifest.Shards = append(manifest.Shards, entry)
+		}
+		if serr := itr.close(); serr != nil {
+			return nil, serr
+		}
+	}
+	return manifest, nil
+}
+
+// encodeExportShard returns lines in the form of the response of Filter HTTP Endpoint.
+func encodeExportShard(shard []StringLine) []byte {
+	buf := new(bytes.Buffer)
+	writer := bufio.NewWriter(buf)
+	for j := range shard {
+		writeStringLine(writer, &shard[j])
+	}
+	// Never fails on a buffer
+	writer.Flush()
+	return buf.Bytes()
+}
+
+// ReadExportManifest reads the manifest in the directory written by `RawRequest.Export`.
+func ReadExportManifest(dir string) (*ExportManifest, error) {
+	data, serr := ioutil.ReadFile(filepath.Join(dir, exportManifestName))
+	if serr != nil {
+		return nil, serr
+	}
+	manifest := new(ExportManifest)
+	if serr := json.Unmarshal(data, manifest); serr != nil {
+		return nil, fmt.Errorf("manifest unmarshal: %v", serr)
+	}
+	return manifest, nil
+}
+
+// VerifyExport checks sizes and hashes of shards in the directory written by `RawRequest.Export`
+// against its manifest, and returns the manifest.
+// Reports `ErrManifestMismatch` for the first shard differs, or an error if a shard is missing.
+func VerifyExport(dir string) (*ExportManifest, error) {
+	manifest, serr := ReadExportManifest(dir)
+	if serr != nil {
+		return nil, serr
+	}
+	for _, entry := range manifest.Shards {
+		file, serr := os.Open(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		if serr != nil {
+			return nil, serr
+		}
+		hash := sha256.New()
+		size, serr := io.Copy(hash, file)
+		file.Close()
+		if serr != nil {
+			return nil, fmt.Errorf("%s: %v", entry.Path, serr)
+		}
+		if size != entry.Size {
+			return nil, fmt.Errorf("%s: %w: size %d, expected %d", entry.Path, ErrManifestMismatch, size, entry.Size)
+		}
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != entry.SHA256 {
+			return nil, fmt.Errorf("%s: %w: hash %s, expected %s", entry.Path, ErrManifestMismatch, sum, entry.SHA256)
+		}
+	}
+	return manifest, nil
+}
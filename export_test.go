@@ -0,0 +1,68 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	req := prepareFakeRawRequest(t, srv)
+	manifest, serr := req.Export(context.Background(), dir)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// A snapshot and 10 minutes for each exchange
+	if len(manifest.Shards) != 2*11 {
+		t.Fatalf("%d shards exported", len(manifest.Shards))
+	}
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines := 0
+	for _, entry := range manifest.Shards {
+		lines += entry.Lines
+		if entry.Start < req.start || entry.End > req.end || entry.Start > entry.End {
+			t.Fatalf("shard %s covers %d to %d", entry.Path, entry.Start, entry.End)
+		}
+	}
+	if lines != len(expected) {
+		t.Fatalf("%d lines exported, expected %d", lines, len(expected))
+	}
+	data, serr := ioutil.ReadFile(filepath.Join(dir, "bitmex", "26297281.txt"))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if !strings.HasPrefix(string(data), "msg\t1577836860000000006\torderBookL2\t") {
+		t.Fatalf("unexpected shard %q", data)
+	}
+	if _, serr := VerifyExport(dir); serr != nil {
+		t.Fatal(serr)
+	}
+	// Same size, but different content
+	data[len(data)-2] = 'x'
+	if serr := ioutil.WriteFile(filepath.Join(dir, "bitmex", "26297281.txt"), data, 0644); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := VerifyExport(dir); !errors.Is(serr, ErrManifestMismatch) {
+		t.Fatalf("expected ErrManifestMismatch, got %v", serr)
+	}
+	if serr := os.Remove(filepath.Join(dir, "bitmex", "26297281.txt")); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := VerifyExport(dir); serr == nil || errors.Is(serr, ErrManifestMismatch) {
+		t.Fatalf("missing shard reported as %v", serr)
+	}
+}
@@ -0,0 +1,156 @@
+package exdgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// ExternalSortParam is the parameters for `ExternalSort`.
+type ExternalSortParam struct {
+	// Lines to sort, they do not have to be in order.
+	// All of them are read to the end and closed.
+	Inputs []StructLineIterator
+	// Sorted lines are written in the encoding of `LineWriter`, thus can be read by `LineReader`.
+	Output io.Writer
+	// Lines are sorted in memory until their encoded size exceeds this in bytes,
+	// then they are written to a temporary file and merged at last.
+	MemoryLimit int64
+	// Directory to create temporary files in.
+	// Optional, defaults to the default directory for temporary files.
+	Dir string
+}
+
+// countingWriter counts bytes written and discards them.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// sortRuns writes sorted runs into temporary files.
+type sortRuns struct {
+	dir   string
+	files []*os.File
+}
+
+// write sorts the lines and writes them into a new run.
+func (s *sortRuns) write(lines []StructLine) error {
+	file, serr := ioutil.TempFile(s.dir, "exdgo-sort-")
+	if serr != nil {
+		return fmt.Errorf("creating sort file: %v", serr)
+	}
+	s.files = append(s.files, file)
+	if serr := writeSortedLines(file, lines); serr != nil {
+		return serr
+	}
+	_, serr = file.Seek(0, io.SeekStart)
+	return serr
+}
+
+func (s *sortRuns) remove() {
+	for _, file := range s.files {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+// writeSortedLines sorts lines by timestamp keeping the original order for the same timestamp,
+// and writes them.
+func writeSortedLines(w io.Writer, lines []StructLine) error {
+	sort.SliceStable(lines, func(a, b int) bool {
+		return lines[a].Timestamp < lines[b].Timestamp
+	})
+	writer := NewLineWriter(w)
+	for i := range lines {
+		if serr := writer.Write(&lines[i]); serr != nil {
+			return serr
+		}
+	}
+	return writer.Flush()
+}
+
+// ExternalSort reads lines from all inputs and writes them ordered by timestamp,
+// using temporary files when lines do not fit in the memory limit.
+// This allows combining datasets larger than memory, such as ones written by `LineWriter`.
+// Lines with the same timestamp are kept in the order they were read.
+func ExternalSort(param ExternalSortParam) (err error) {
+	if param.Output == nil {
+		return errors.New("parameter 'Output' is nil")
+	}
+	if param.MemoryLimit < 0 {
+		return errors.New("parameter 'MemoryLimit' negative")
+	}
+	runs := &sortRuns{dir: param.Dir}
+	defer runs.remove()
+	defer func() {
+		for _, itr := range param.Inputs {
+			if serr := itr.Close(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}()
+	// Encoded size of lines in memory is measured to compare with the limit
+	size := new(countingWriter)
+	sizer := NewLineWriter(size)
+	chunk := make([]StructLine, 0)
+	for _, itr := range param.Inputs {
+		for {
+			line, ok, serr := itr.Next()
+			if !ok {
+				if serr != nil {
+					return serr
+				}
+				break
+			}
+			if serr := sizer.Write(line); serr != nil {
+				return serr
+			}
+			sizer.Flush()
+			chunk = append(chunk, *line)
+			if size.n > param.MemoryLimit {
+				if serr := runs.write(chunk); serr != nil {
+					return serr
+				}
+				chunk = make([]StructLine, 0)
+				size.n = 0
+			}
+		}
+	}
+	if len(runs.files) == 0 {
+		// Everything fit in memory
+		return writeSortedLines(param.Output, chunk)
+	}
+	if len(chunk) > 0 {
+		if serr := runs.write(chunk); serr != nil {
+			return serr
+		}
+	}
+	readers := make([]StructLineIterator, len(runs.files))
+	for i, file := range runs.files {
+		readers[i] = NewLineReader(file)
+	}
+	// Barrier is required to strictly order lines across runs
+	merged := Merge(MergeParam{Iterators: readers, Barrier: true})
+	defer merged.Close()
+	writer := NewLineWriter(param.Output)
+	for {
+		line, ok, serr := merged.Next()
+		if !ok {
+			if serr != nil {
+				return serr
+			}
+			break
+		}
+		if serr := writer.Write(line); serr != nil {
+			return serr
+		}
+	}
+	return writer.Flush()
+}
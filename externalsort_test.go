@@ -0,0 +1,85 @@
+package exdgo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestExternalSort(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer os.RemoveAll(dir)
+	for _, limit := range []int64{1 << 30, 200} {
+		a := testLines(100, []string{"bitmex"}, []string{"trades"})
+		b := testLines(100, []string{"bitfinex"}, []string{"trades"})
+		// Reverse the order of a, and make timestamps of b the same as a
+		for j := range a {
+			a[j].Timestamp = int64(len(a) - j)
+			b[j].Timestamp = int64(j + 1)
+		}
+		var out bytes.Buffer
+		serr := ExternalSort(ExternalSortParam{
+			Inputs:      []StructLineIterator{newSliceIterator(a), newSliceIterator(b)},
+			Output:      &out,
+			MemoryLimit: limit,
+			Dir:         dir,
+		})
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		lines := readAllStructLines(t, NewLineReader(&out))
+		if len(lines) != 200 {
+			t.Fatalf("len(lines) = %d", len(lines))
+		}
+		for j := range lines {
+			if lines[j].Timestamp != int64(j/2+1) {
+				t.Fatalf("limit %d: line %d has timestamp %d", limit, j, lines[j].Timestamp)
+			}
+			// Lines from the first input come first for the same timestamp
+			if expected := []string{"bitmex", "bitfinex"}[j%2]; lines[j].Exchange != expected {
+				t.Fatalf("limit %d: line %d is from %s", limit, j, lines[j].Exchange)
+			}
+		}
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != 0 {
+			t.Fatal("temporary files not removed")
+		}
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestExternalSortControlLines(t *testing.T) {
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer os.RemoveAll(dir)
+	channel := "trades"
+	a := []StructLine{
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 1, Message: []byte("wss://www.bitmex.com/realtime")},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 2, Channel: &channel, Message: map[string]interface{}{"price": 1.5}},
+		{Exchange: "bitmex", Type: LineTypeEnd, Timestamp: 3, Message: []byte(nil)},
+	}
+	b := []StructLine{
+		{Exchange: "bitfinex", Type: LineTypeError, Timestamp: 2, Message: []byte(`{"reason":"disconnected"}`)},
+	}
+	expected := []StructLine{a[0], a[1], b[0], a[2]}
+	// Lines are spilled one by one with the small limit
+	for _, limit := range []int64{1 << 30, 1} {
+		var out bytes.Buffer
+		serr := ExternalSort(ExternalSortParam{
+			Inputs:      []StructLineIterator{newSliceIterator(a), newSliceIterator(b)},
+			Output:      &out,
+			MemoryLimit: limit,
+			Dir:         dir,
+		})
+		if serr != nil {
+			t.Fatalf("limit %d: %v", limit, serr)
+		}
+		compareStructLines(t, expected, readAllStructLines(t, NewLineReader(&out)))
+	}
+}
@@ -0,0 +1,137 @@
+package exdgo
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault is a failure injected into a request by `FaultTransport`.
+// Fields can be combined, such as latency before an error response.
+type Fault struct {
+	// Only requests whose URL path contains this are affected.
+	// Optional, any request is affected by default.
+	Path string
+	// Delays the request.
+	Latency time.Duration
+	// Blocks the request until it is cancelled, as a server not responding does.
+	Hang bool
+	// Responds with this status code without sending the request, such as 503 or 429.
+	StatusCode int
+	// Cuts the body of the response after this many bytes, as a broken connection does.
+	// Ignored if `StatusCode` is set.
+	TruncateAt *int
+}
+
+// FaultTransport is `http.RoundTripper` injecting faults into requests according to a scenario,
+// to test how retries, stall detection and failover behave deterministically.
+// Set it to the transport of `HTTPClient` of `ClientParam`.
+// Safe for concurrent use.
+type FaultTransport struct {
+	base  http.RoundTripper
+	mutex sync.Mutex
+	// Faults not yet injected, in order
+	faults []Fault
+}
+
+// NewFaultTransport creates a `FaultTransport` sending requests with `base`.
+// Each request gets the first fault remaining in the scenario which matches it,
+// and requests without a fault are sent as is.
+// `base` defaults to `http.DefaultTransport` if nil.
+func NewFaultTransport(base http.RoundTripper, scenario ...Fault) *FaultTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	faults := make([]Fault, len(scenario))
+	copy(faults, scenario)
+	return &FaultTransport{base: base, faults: faults}
+}
+
+// Remaining returns the number of faults not yet injected.
+func (t *FaultTransport) Remaining() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.faults)
+}
+
+// next takes the fault for the request, or returns nil if there is none.
+func (t *FaultTransport) next(req *http.Request) *Fault {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for k := range t.faults {
+		if strings.Contains(req.URL.Path, t.faults[k].Path) {
+			fault := t.faults[k]
+			t.faults = append(t.faults[:k], t.faults[k+1:]...)
+			return &fault
+		}
+	}
+	return nil
+}
+
+// RoundTrip sends the request with the fault injected.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.next(req)
+	if fault == nil {
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	if fault.Hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if fault.StatusCode != 0 {
+		body := http.StatusText(fault.StatusCode)
+		return &http.Response{
+			Status:        http.StatusText(fault.StatusCode),
+			StatusCode:    fault.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"text/plain"}},
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	res, serr := t.base.RoundTrip(req)
+	if serr != nil || fault.TruncateAt == nil {
+		return res, serr
+	}
+	res.Body = &truncatedBody{body: res.Body, left: *fault.TruncateAt}
+	return res, nil
+}
+
+// truncatedBody fails with `io.ErrUnexpectedEOF` after `left` bytes were read.
+type truncatedBody struct {
+	body io.ReadCloser
+	left int
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > b.left {
+		p = p[:b.left]
+	}
+	n, serr := b.body.Read(p)
+	b.left -= n
+	return n, serr
+}
+
+func (b *truncatedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,46 @@
+package exdgo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFaultTransport(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	truncateAt := 10
+	transport := NewFaultTransport(nil,
+		Fault{Path: "/filter/bitmex/", StatusCode: http.StatusServiceUnavailable},
+		Fault{Path: "/filter/bitmex/", StatusCode: http.StatusTooManyRequests, Latency: 10 * time.Millisecond},
+		Fault{Path: "/filter/bitfinex/", TruncateAt: &truncateAt},
+		Fault{Path: "/snapshot/", Hang: true},
+	)
+	cli := req.raw.cli
+	cli.httpClient = &http.Client{Transport: transport}
+	cli.timeout = 100 * time.Millisecond
+	downloaded, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, downloaded)
+	if n := transport.Remaining(); n != 0 {
+		t.Fatalf("%d faults not injected", n)
+	}
+
+	// Faults beyond retries fail the request
+	transport = NewFaultTransport(nil,
+		Fault{Path: "/filter/bitmex/26297280", StatusCode: http.StatusBadGateway},
+		Fault{Path: "/filter/bitmex/26297280", StatusCode: http.StatusBadGateway},
+	)
+	cli.httpClient = &http.Client{Transport: transport}
+	cli.maxRetries = 1
+	if _, serr := req.Download(); serr == nil {
+		t.Fatal("faults beyond retries did not fail")
+	}
+}
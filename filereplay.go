@@ -0,0 +1,160 @@
+package exdgo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileReplayParam is the parameters for `FileReplay` and `ArchiveReplay`.
+type FileReplayParam struct {
+	// Map of exchanges and its channels to replay.
+	// Optional, all of data exported are replayed if nil.
+	// All channels of an exchange are replayed if its channels are empty.
+	Filter map[string][]string
+	// Settings for decoding messages, same as `ReplayRequestParam`.
+	Decode LineDecoderParam
+}
+
+// FileReplay returns an iterator yielding lines from data exported by `RawRequest.Export` into the directory,
+// in the same way as `ReplayRequest.Stream`, so the same code runs both online and offline.
+// Data must be exported in json format, shards are read one by one as lines are yielded.
+func FileReplay(dir string, param FileReplayParam) (StructLineIterator, error) {
+	manifest, serr := ReadExportManifest(dir)
+	if serr != nil {
+		return nil, serr
+	}
+	if manifest.Format != "json" {
+		return nil, fmt.Errorf("data exported in format %q, not json", manifest.Format)
+	}
+	channels := make(map[string]map[string]bool)
+	for exchange := range manifest.Filter {
+		chs, ok := param.Filter[exchange]
+		if param.Filter != nil && !ok {
+			continue
+		}
+		channels[exchange] = make(map[string]bool)
+		for _, ch := range chs {
+			channels[exchange][ch] = true
+		}
+	}
+	for exchange := range param.Filter {
+		if _, ok := manifest.Filter[exchange]; !ok {
+			return nil, fmt.Errorf("exchange %s not exported", exchange)
+		}
+	}
+	if _, serr := NewLineDecoderWithParam(param.Decode); serr != nil {
+		return nil, serr
+	}
+	exchanges := make([]string, 0, len(channels))
+	for exchange := range channels {
+		exchanges = append(exchanges, exchange)
+	}
+	// Lines at the same timestamp are yielded in the order of exchanges
+	sort.Strings(exchanges)
+	iterators := make([]StructLineIterator, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		itr := &fileExchangeIterator{dir: dir, channels: channels[exchange]}
+		itr.decoder, _ = NewLineDecoderWithParam(param.Decode)
+		for _, shard := range manifest.Shards {
+			if shard.Exchange == exchange {
+				itr.shards = append(itr.shards, shard)
+			}
+		}
+		iterators = append(iterators, itr)
+	}
+	// Barrier keeps the order of timestamp across exchanges
+	return Merge(MergeParam{Iterators: iterators, Barrier: true}), nil
+}
+
+// fileExchangeIterator yields lines of an exchange from exported shards.
+type fileExchangeIterator struct {
+	dir    string
+	shards []ExportShard
+	// Channels to yield, all channels if empty
+	channels map[string]bool
+	decoder  *LineDecoder
+	lines    []StringLine
+	position int
+	closed   bool
+}
+
+func (i *fileExchangeIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		for i.position >= len(i.lines) {
+			if len(i.shards) == 0 {
+				return nil, false, nil
+			}
+			data, serr := ioutil.ReadFile(filepath.Join(i.dir, filepath.FromSlash(i.shards[0].Path)))
+			if serr != nil {
+				return nil, false, serr
+			}
+			i.lines, serr = parseFilterBody(i.shards[0].Exchange, data)
+			if serr != nil {
+				return nil, false, fmt.Errorf("%s: %v", i.shards[0].Path, serr)
+			}
+			i.shards = i.shards[1:]
+			i.position = 0
+		}
+		line := &i.lines[i.position]
+		i.position++
+		if len(i.channels) > 0 && line.Channel != nil && !i.channels[*line.Channel] {
+			continue
+		}
+		decoded, serr := i.decoder.Decode(line)
+		if serr != nil {
+			return nil, false, serr
+		}
+		if decoded == nil {
+			// Definition
+			continue
+		}
+		return decoded, true, nil
+	}
+}
+
+func (i *fileExchangeIterator) Close() error {
+	i.closed = true
+	i.lines = nil
+	return nil
+}
+
+// ArchiveReplay is same as `FileReplay`, but reads the archive written by `RawRequest.ExportArchive`.
+// The archive is extracted into a temporary directory in `tempDir`, or the default directory for
+// temporary files if empty, and removed when the iterator is closed.
+func ArchiveReplay(r io.Reader, tempDir string, param FileReplayParam) (StructLineIterator, error) {
+	dir, serr := ioutil.TempDir(tempDir, "exdgo-archive-")
+	if serr != nil {
+		return nil, serr
+	}
+	if _, serr := ExtractArchive(r, dir); serr != nil {
+		os.RemoveAll(dir)
+		return nil, serr
+	}
+	itr, serr := FileReplay(dir, param)
+	if serr != nil {
+		os.RemoveAll(dir)
+		return nil, serr
+	}
+	return &archiveReplayIterator{StructLineIterator: itr, dir: dir}, nil
+}
+
+// archiveReplayIterator removes the extracted archive when closed.
+type archiveReplayIterator struct {
+	StructLineIterator
+	dir string
+}
+
+func (i *archiveReplayIterator) Close() error {
+	serr := i.StructLineIterator.Close()
+	if rerr := os.RemoveAll(i.dir); rerr != nil && serr == nil {
+		serr = rerr
+	}
+	return serr
+}
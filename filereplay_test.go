@@ -0,0 +1,64 @@
+package exdgo
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileReplay(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := req.raw.Export(context.Background(), dir); serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := FileReplay(dir, FileReplayParam{})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+
+	itr, serr = FileReplay(dir, FileReplayParam{Filter: map[string][]string{"bitmex": nil}})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	for _, line := range readAllStructLines(t, itr) {
+		if line.Exchange != "bitmex" {
+			t.Fatalf("line of %s replayed", line.Exchange)
+		}
+	}
+	if _, serr := FileReplay(dir, FileReplayParam{Filter: map[string][]string{"binance": nil}}); serr == nil {
+		t.Fatal("exchange not exported accepted")
+	}
+
+	archive := new(bytes.Buffer)
+	if _, serr := req.raw.ExportArchive(context.Background(), archive); serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr = ArchiveReplay(archive, dir, FileReplayParam{})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	files, serr := ioutil.ReadDir(dir)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	for _, file := range files {
+		if file.IsDir() && file.Name() != "bitmex" && file.Name() != "bitfinex" {
+			t.Fatalf("extracted archive %s not removed", file.Name())
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package exdgo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	fixtureDefaultTradesPerSecond = 1
+	fixtureDefaultInitialPrice    = 10000
+	fixtureDefaultVolatility      = 0.0005
+	// Price is rounded to this tick
+	fixtureTickSize = 0.5
+)
+
+// Definitions of channels in fixtures
+var fixtureDefinitions = map[string]string{
+	"trade": `{"timestamp":"timestamp","price":"float","size":"int","side":"string"}`,
+	"quote": `{"timestamp":"timestamp","bidPrice":"float","askPrice":"float"}`,
+}
+
+// FixtureParam is the parameters for `GenerateFixture`.
+type FixtureParam struct {
+	// Seed of the random numbers, the same seed generates the same lines.
+	Seed int64
+	// Exchanges to generate lines for, each has an independent market.
+	Exchanges []string
+	// Range to generate lines in, `End` is exclusive.
+	Start time.Time
+	End   time.Time
+	// Average number of trades per second of each exchange.
+	// Optional, defaults to 1.
+	TradesPerSecond *float64
+	// Price at the start.
+	// Optional, defaults to 10000.
+	InitialPrice *float64
+	// Standard deviation of the log return of a trade.
+	// Optional, defaults to 0.0005.
+	Volatility *float64
+}
+
+// GenerateFixture generates synthetic lines in json format, as ones from `RawRequest` with `Format` of "json",
+// so tests have reproducible inputs without real market data.
+// For each exchange, a start line and definitions of "trade" and "quote" channels are followed by
+// trades arriving randomly, each followed by the quote after it, and an end line at the end.
+// Prices follow a random walk. Decode lines by `LineDecoder` to get `StructLine`.
+func GenerateFixture(param FixtureParam) ([]StringLine, error) {
+	var errs ParamErrors
+	if len(param.Exchanges) == 0 {
+		errs.add(errors.New("'Exchanges' is empty"))
+	}
+	if !param.Start.Before(param.End) {
+		errs.add(errors.New("'Start' must be before 'End'"))
+	}
+	rate := float64(fixtureDefaultTradesPerSecond)
+	if param.TradesPerSecond != nil {
+		if *param.TradesPerSecond <= 0 {
+			errs.add(errors.New("'TradesPerSecond' must be positive"))
+		}
+		rate = *param.TradesPerSecond
+	}
+	price := float64(fixtureDefaultInitialPrice)
+	if param.InitialPrice != nil {
+		if *param.InitialPrice <= 0 {
+			errs.add(errors.New("'InitialPrice' must be positive"))
+		}
+		price = *param.InitialPrice
+	}
+	volatility := fixtureDefaultVolatility
+	if param.Volatility != nil {
+		if *param.Volatility < 0 {
+			errs.add(errors.New("'Volatility' negative"))
+		}
+		volatility = *param.Volatility
+	}
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
+	start := param.Start.UnixNano()
+	end := param.End.UnixNano()
+	lines := make([]StringLine, 0)
+	for k, exchange := range param.Exchanges {
+		// Each exchange has its own source so adding an exchange does not change others
+		rnd := rand.New(rand.NewSource(param.Seed + int64(k)))
+		lines = append(lines, StringLine{Exchange: exchange, Type: LineTypeStart, Timestamp: start, Message: []byte("fixture")})
+		for _, channel := range []string{"quote", "trade"} {
+			channel := channel
+			lines = append(lines, StringLine{
+				Exchange:  exchange,
+				Type:      LineTypeMessage,
+				Timestamp: start,
+				Channel:   &channel,
+				Message:   []byte(fixtureDefinitions[channel]),
+			})
+		}
+		last := price
+		for ts := start; ; {
+			// Poisson arrivals
+			ts += int64(rnd.ExpFloat64() / rate * float64(time.Second))
+			if ts >= end {
+				break
+			}
+			last *= math.Exp(rnd.NormFloat64() * volatility)
+			traded := math.Max(fixtureTickSize, math.Round(last/fixtureTickSize)*fixtureTickSize)
+			side := "Buy"
+			bid := traded - fixtureTickSize
+			if rnd.Intn(2) == 0 {
+				side = "Sell"
+				bid = traded
+			}
+			size := 1 + rnd.Intn(100)
+			trade := "trade"
+			quote := "quote"
+			lines = append(lines, StringLine{
+				Exchange:  exchange,
+				Type:      LineTypeMessage,
+				Timestamp: ts,
+				Channel:   &trade,
+				Message:   []byte(fmt.Sprintf(`{"timestamp":"%d","price":%v,"size":%d,"side":"%s"}`, ts, traded, size, side)),
+			}, StringLine{
+				Exchange:  exchange,
+				Type:      LineTypeMessage,
+				Timestamp: ts,
+				Channel:   &quote,
+				Message:   []byte(fmt.Sprintf(`{"timestamp":"%d","bidPrice":%v,"askPrice":%v}`, ts, bid, bid+fixtureTickSize)),
+			})
+		}
+		lines = append(lines, StringLine{Exchange: exchange, Type: LineTypeEnd, Timestamp: end - 1})
+	}
+	// Lines of an exchange are already in order
+	sort.SliceStable(lines, func(a, b int) bool { return lines[a].Timestamp < lines[b].Timestamp })
+	return lines, nil
+}
@@ -0,0 +1,58 @@
+package exdgo
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestGenerateFixture(t *testing.T) {
+	param := FixtureParam{
+		Seed:      42,
+		Exchanges: []string{"bitmex", "bitfinex"},
+		Start:     time.Unix(1577836800, 0),
+		End:       time.Unix(1577836800, 0).Add(time.Minute),
+	}
+	lines, serr := GenerateFixture(param)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	again, serr := GenerateFixture(param)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) != len(again) {
+		t.Fatalf("%d lines generated, then %d", len(lines), len(again))
+	}
+	for k := range lines {
+		if lines[k].Timestamp != again[k].Timestamp || !bytes.Equal(lines[k].Message, again[k].Message) {
+			t.Fatalf("line %d differs", k)
+		}
+	}
+	decoder, serr := NewLineDecoderWithParam(LineDecoderParam{Strict: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	counts := make(map[LineType]int)
+	var last int64
+	for k := range lines {
+		if lines[k].Timestamp < last {
+			t.Fatalf("line %d out of order", k)
+		}
+		last = lines[k].Timestamp
+		decoded, serr := decoder.Decode(&lines[k])
+		if serr != nil {
+			t.Fatalf("line %d: %v", k, serr)
+		}
+		if decoded != nil {
+			counts[decoded.Type]++
+		}
+	}
+	// About a trade and a quote every second
+	if counts[LineTypeStart] != 2 || counts[LineTypeEnd] != 2 || counts[LineTypeMessage] < 2*2*30 || counts[LineTypeMessage] > 2*2*90 {
+		t.Fatalf("counts %v", counts)
+	}
+	if _, serr := GenerateFixture(FixtureParam{}); serr == nil {
+		t.Fatal("empty parameter accepted")
+	}
+}
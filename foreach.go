@@ -0,0 +1,51 @@
+package exdgo
+
+import "errors"
+
+// ErrStop can be returned from the callback of `ForEach` to stop iterating without an error.
+var ErrStop = errors.New("stop iteration")
+
+// ForEach calls `fn` with each line from the iterator until it reaches the end, and closes it.
+// If `fn` returns an error, iterating stops and the error is returned,
+// except for `ErrStop`, which stops iterating and nil is returned.
+// The line given to `fn` is only valid during the call.
+func ForEach(itr StructLineIterator, fn func(line *StructLine) error) (err error) {
+	defer func() {
+		if serr := itr.Close(); serr != nil && err == nil {
+			err = serr
+		}
+	}()
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			return serr
+		}
+		if serr := fn(line); serr != nil {
+			if serr == ErrStop {
+				return nil
+			}
+			return serr
+		}
+	}
+}
+
+// ForEachString is same as `ForEach` for `StringLineIterator`.
+func ForEachString(itr StringLineIterator, fn func(line *StringLine) error) (err error) {
+	defer func() {
+		if serr := itr.Close(); serr != nil && err == nil {
+			err = serr
+		}
+	}()
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			return serr
+		}
+		if serr := fn(line); serr != nil {
+			if serr == ErrStop {
+				return nil
+			}
+			return serr
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package exdgo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	lines := testLines(10, []string{"bitmex"}, []string{"trade"})
+	count := 0
+	serr := ForEach(newSliceIterator(lines), func(line *StructLine) error {
+		count++
+		return nil
+	})
+	if serr != nil || count != len(lines) {
+		t.Fatalf("visited %d lines: %v", count, serr)
+	}
+	count = 0
+	itr := newSliceIterator(lines)
+	serr = ForEach(itr, func(line *StructLine) error {
+		count++
+		if count == 3 {
+			return ErrStop
+		}
+		return nil
+	})
+	if serr != nil || count != 3 {
+		t.Fatalf("visited %d lines: %v", count, serr)
+	}
+	if _, _, serr := itr.Next(); !errors.Is(serr, ErrIteratorClosed) {
+		t.Fatal("iterator not closed")
+	}
+	failure := errors.New("failure")
+	serr = ForEach(newSliceIterator(lines), func(line *StructLine) error {
+		return failure
+	})
+	if serr != failure {
+		t.Fatalf("callback error not returned: %v", serr)
+	}
+}
@@ -0,0 +1,241 @@
+//go:build go1.16
+// +build go1.16
+
+package exdgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rawFS is the file system returned by `RawRequest.FS`.
+type rawFS struct {
+	ctx       context.Context
+	req       *RawRequest
+	exchanges []string
+	// Minutes having a file for each exchange, in order
+	minutes map[string][]int64
+}
+
+// FS returns data of the request as a read-only file system of NDJSON files,
+// so tools working on files such as `fs.WalkDir` can read it as if it were local.
+//
+// There is a directory for each exchange, which has "snapshot.ndjson" for snapshots at the start,
+// and "<minute>.ndjson" for each minute in the range, named by minutes since the unix epoch as `Export` does.
+// Each line of a file is a JSON object with "exchange", "type", "timestamp", "channel" and "message",
+// where "message" is the message as is if it is JSON, otherwise a string.
+//
+// Data of a file is downloaded when it is opened, and every open downloads it again.
+// Sizes of files in directory listings are zero since they are not known before downloading,
+// `Stat` of an opened file reports the actual size.
+// `FilterOut`, `ChannelRegex`, `SkipMinutes` and `ClockOffsets` are applied.
+// Downloads are done within the context.
+func (r *RawRequest) FS(ctx context.Context) fs.FS {
+	f := &rawFS{
+		ctx:     r.withRetryBudget(ctx),
+		req:     r,
+		minutes: make(map[string][]int64, len(r.filter)),
+	}
+	for exchange := range r.filter {
+		f.exchanges = append(f.exchanges, exchange)
+	}
+	sort.Strings(f.exchanges)
+	startMinute := r.start / int64(time.Minute)
+	// End is exclusive
+	endMinute := (r.end - 1) / int64(time.Minute)
+	for _, exchange := range f.exchanges {
+		for minute := startMinute; minute <= endMinute; minute++ {
+			if !r.skip[exchange][minute] {
+				f.minutes[exchange] = append(f.minutes[exchange], minute)
+			}
+		}
+	}
+	return f
+}
+
+const fsSnapshotName = "snapshot.ndjson"
+
+func (f *rawFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		entries := make([]fs.DirEntry, len(f.exchanges))
+		for j, exchange := range f.exchanges {
+			entries[j] = &fsInfo{name: exchange, dir: true}
+		}
+		return &fsDir{info: fsInfo{name: ".", dir: true}, entries: entries}, nil
+	}
+	parts := strings.Split(name, "/")
+	if _, ok := f.req.filter[parts[0]]; !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	exchange := parts[0]
+	if len(parts) == 1 {
+		entries := []fs.DirEntry{&fsInfo{name: fsSnapshotName, modTime: time.Unix(0, f.req.start)}}
+		for _, minute := range f.minutes[exchange] {
+			entries = append(entries, &fsInfo{name: fsMinuteName(minute), modTime: time.Unix(0, minute*int64(time.Minute))})
+		}
+		return &fsDir{info: fsInfo{name: exchange, dir: true}, entries: entries}, nil
+	}
+	if len(parts) > 2 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	file, serr := f.openShard(exchange, parts[1])
+	if serr != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: serr}
+	}
+	return file, nil
+}
+
+// fsMinuteName returns the name of the file of the minute.
+func fsMinuteName(minute int64) string {
+	return strconv.FormatInt(minute, 10) + ".ndjson"
+}
+
+// openShard downloads the shard of the file and returns it encoded.
+func (f *rawFS) openShard(exchange string, name string) (*fsFile, error) {
+	shards := &rawExchangeStreamShardIterator{request: f.req, exchange: exchange}
+	var download func(ctx context.Context) ([]StringLine, error)
+	var modTime time.Time
+	if name == fsSnapshotName {
+		download = shards.downloadSnapshot
+		modTime = time.Unix(0, f.req.start)
+	} else {
+		minute, serr := strconv.ParseInt(strings.TrimSuffix(name, ".ndjson"), 10, 64)
+		minutes := f.minutes[exchange]
+		k := sort.Search(len(minutes), func(k int) bool { return minutes[k] >= minute })
+		if serr != nil || k == len(minutes) || fsMinuteName(minutes[k]) != name {
+			return nil, fs.ErrNotExist
+		}
+		download = func(ctx context.Context) ([]StringLine, error) { return shards.downloadFilter(ctx, minute) }
+		modTime = time.Unix(0, minute*int64(time.Minute))
+	}
+	end, serr := f.req.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	defer end()
+	var shard []StringLine
+	serr = retry(f.ctx, f.req.cli, func() error {
+		var serr error
+		shard, serr = download(f.ctx)
+		return serr
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	shard = f.req.dropExcluded(exchange, shard)
+	f.req.correctClock(exchange, shard)
+	data, serr := encodeNDJSONShard(shard)
+	if serr != nil {
+		return nil, serr
+	}
+	return &fsFile{
+		info:   fsInfo{name: name, size: int64(len(data)), modTime: modTime},
+		Reader: bytes.NewReader(data),
+	}, nil
+}
+
+// encodeNDJSONShard encodes lines into NDJSON.
+func encodeNDJSONShard(shard []StringLine) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for j := range shard {
+		line := &shard[j]
+		encoded := jsonLine{Exchange: line.Exchange, Type: line.Type, Timestamp: line.Timestamp, Channel: line.Channel}
+		if len(line.Message) > 0 {
+			// Messages of start and error lines keep the newline of the response
+			message := bytes.TrimSuffix(line.Message, []byte{'\n'})
+			if json.Valid(message) {
+				encoded.Message = json.RawMessage(message)
+			} else {
+				encoded.Message = string(message)
+			}
+		}
+		if serr := encoder.Encode(&encoded); serr != nil {
+			return nil, fmt.Errorf("line at %d: %v", line.Timestamp, serr)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// fsInfo describes a file or a directory of `rawFS`.
+type fsInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i *fsInfo) Name() string { return i.name }
+
+func (i *fsInfo) Size() int64 { return i.size }
+
+func (i *fsInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fsInfo) ModTime() time.Time { return i.modTime }
+
+func (i *fsInfo) IsDir() bool { return i.dir }
+
+func (i *fsInfo) Sys() interface{} { return nil }
+
+func (i *fsInfo) Type() fs.FileMode { return i.Mode().Type() }
+
+func (i *fsInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// fsFile is an opened file of `rawFS` holding the downloaded data.
+type fsFile struct {
+	info fsInfo
+	*bytes.Reader
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return &f.info, nil }
+
+func (f *fsFile) Close() error { return nil }
+
+// fsDir is an opened directory of `rawFS`.
+type fsDir struct {
+	info    fsInfo
+	entries []fs.DirEntry
+	// Number of entries already read by `ReadDir`
+	offset int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return &d.info, nil }
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) Close() error { return nil }
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,61 @@
+//go:build go1.16
+// +build go1.16
+
+package exdgo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestRawFS(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	fsys := req.FS(context.Background())
+	lines := 0
+	files := 0
+	serr := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, serr error) error {
+		if serr != nil || entry.IsDir() {
+			return serr
+		}
+		file, serr := fsys.Open(name)
+		if serr != nil {
+			return serr
+		}
+		defer file.Close()
+		files++
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var line jsonLine
+			if serr := json.Unmarshal(scanner.Bytes(), &line); serr != nil {
+				return serr
+			}
+			if !strings.HasPrefix(name, line.Exchange+"/") {
+				t.Fatalf("line of %s in %s", line.Exchange, name)
+			}
+			if _, ok := line.Message.(map[string]interface{}); !ok {
+				t.Fatalf("message not in JSON: %v", line.Message)
+			}
+			lines++
+		}
+		return scanner.Err()
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if files != 2*11 {
+		t.Fatalf("%d files, expected %d", files, 2*11)
+	}
+	if lines != 2+2*6*10 {
+		t.Fatalf("%d lines, expected %d", lines, 2+2*6*10)
+	}
+	if _, serr := fsys.Open("bitmex/26297290.ndjson"); !errors.Is(serr, fs.ErrNotExist) {
+		t.Fatalf("minute out of the range opened: %v", serr)
+	}
+}
@@ -0,0 +1,224 @@
+package exdgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayHandlerParam is the parameters to make a handler by `ReplayHandler`.
+type ReplayHandlerParam struct {
+	// Client to send requests with.
+	Client *Client
+	// Longest range a request can ask for.
+	// Optional, unlimited by default.
+	MaxRange *time.Duration
+	// Called with the parameters parsed from the query before the request is made,
+	// to restrict them or set options such as `Decoders`.
+	// Returning an error responds with 400 Bad Request and the message of it.
+	// Optional.
+	Prepare func(r *http.Request, param *ReplayRequestParam) error
+	// Buffer size of the stream.
+	// Optional, defaults to the buffer size of the client.
+	BufferSize *int
+}
+
+// replayHandler serves replay streams, see `ReplayHandler`.
+type replayHandler struct {
+	cli        *Client
+	maxRange   *time.Duration
+	prepare    func(r *http.Request, param *ReplayRequestParam) error
+	bufferSize int
+}
+
+// ReplayHandler returns a handler serving a replay stream as Server-Sent Events or NDJSON,
+// so browsers and dashboards can play historical data back through a service.
+//
+// The request is made from query parameters:
+// - `filter`: exchange and channels in `exchange:channel,...`, can be repeated.
+// - `start` and `end`: range to replay, in the forms `ParseTimeParam` accepts such as RFC3339.
+// - `speed`: if given, lines are sent at the pace of their timestamps multiplied by it, see `Pace`.
+// - `format`: "sse" or "ndjson", defaults to "sse" if the Accept header has "text/event-stream", otherwise "ndjson".
+//
+// Each line is sent as a JSON object with "exchange", "type", "timestamp", "channel" and "message".
+// In SSE, lines are "message" events, and the stream ends with an "end" event.
+// An error after the stream started is sent as an "error" event in SSE,
+// or an object with "error" in NDJSON, since the status code was already sent.
+// The stream stops when the client disconnects.
+func ReplayHandler(param ReplayHandlerParam) (http.Handler, error) {
+	var errs ParamErrors
+	if param.Client == nil {
+		errs.add(errors.New("'Client' must be given"))
+	}
+	h := &replayHandler{
+		cli:      param.Client,
+		maxRange: param.MaxRange,
+		prepare:  param.Prepare,
+	}
+	if param.MaxRange != nil && *param.MaxRange <= 0 {
+		errs.add(errors.New("'MaxRange' must be positive"))
+	}
+	if param.BufferSize != nil {
+		if *param.BufferSize < 1 {
+			errs.add(errors.New("'BufferSize' must be positive"))
+		}
+		h.bufferSize = *param.BufferSize
+	} else if param.Client != nil {
+		h.bufferSize = param.Client.bufferSize
+	}
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
+	return h, nil
+}
+
+// jsonLine is a line encoded in JSON by `ReplayHandler` and `RawRequest.FS`.
+type jsonLine struct {
+	Exchange  string      `json:"exchange"`
+	Type      LineType    `json:"type"`
+	Timestamp int64       `json:"timestamp"`
+	Channel   *string     `json:"channel,omitempty"`
+	Message   interface{} `json:"message,omitempty"`
+}
+
+// parseQuery makes the parameters of a replay request and the speed from the query.
+// Speed is zero if lines are sent as fast as possible.
+func (h *replayHandler) parseQuery(r *http.Request) (param ReplayRequestParam, speed float64, err error) {
+	query := r.URL.Query()
+	var errs ParamErrors
+	param.Filter = make(map[string][]string)
+	for _, value := range query["filter"] {
+		colon := strings.IndexByte(value, ':')
+		if colon <= 0 || colon == len(value)-1 {
+			errs.add(fmt.Errorf("'filter' %q is not in the form of exchange:channel,...", value))
+			continue
+		}
+		exchange := value[:colon]
+		param.Filter[exchange] = append(param.Filter[exchange], strings.Split(value[colon+1:], ",")...)
+	}
+	if len(param.Filter) == 0 {
+		errs.add(errors.New("'filter' must be given"))
+	}
+	var serr error
+	if param.Start, serr = ParseTimeParam(query.Get("start")); serr != nil {
+		errs.add(fmt.Errorf("'start': %v", serr))
+	}
+	if param.End, serr = ParseTimeParam(query.Get("end")); serr != nil {
+		errs.add(fmt.Errorf("'end': %v", serr))
+	}
+	if h.maxRange != nil && param.End.Sub(param.Start) > *h.maxRange {
+		errs.add(fmt.Errorf("range must not be longer than %v", *h.maxRange))
+	}
+	if value := query.Get("speed"); value != "" {
+		if speed, serr = strconv.ParseFloat(value, 64); serr != nil || speed <= 0 {
+			errs.add(errors.New("'speed' must be a positive number"))
+		}
+	}
+	if err = errs.err(); err != nil {
+		return
+	}
+	if h.prepare != nil {
+		err = h.prepare(r, &param)
+	}
+	return
+}
+
+// wantsSSE reports whether the stream is sent as Server-Sent Events.
+func wantsSSE(r *http.Request) (bool, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "sse":
+		return true, nil
+	case "ndjson":
+		return false, nil
+	case "":
+		return strings.Contains(r.Header.Get("Accept"), "text/event-stream"), nil
+	default:
+		return false, fmt.Errorf("unknown 'format' %q", format)
+	}
+}
+
+func (h *replayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sse, serr := wantsSSE(r)
+	if serr != nil {
+		http.Error(w, serr.Error(), http.StatusBadRequest)
+		return
+	}
+	param, speed, serr := h.parseQuery(r)
+	if serr != nil {
+		http.Error(w, serr.Error(), http.StatusBadRequest)
+		return
+	}
+	req, serr := h.cli.Replay(param)
+	if serr != nil {
+		http.Error(w, serr.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	itr, serr := req.StreamWithContext(ctx, h.bufferSize)
+	if serr != nil {
+		http.Error(w, serr.Error(), http.StatusBadGateway)
+		return
+	}
+	defer itr.Close()
+	if speed > 0 {
+		// Never fails with a positive speed
+		itr, _ = Pace(itr, PaceParam{Speed: speed})
+	}
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	buffered := bufio.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	// send writes a JSON value as an event, and sends it to the client immediately
+	send := func(event string, v interface{}) error {
+		encoded, serr := json.Marshal(v)
+		if serr != nil {
+			return serr
+		}
+		if sse {
+			fmt.Fprintf(buffered, "event: %s\ndata: %s\n\n", event, encoded)
+		} else {
+			buffered.Write(encoded)
+			buffered.WriteByte('\n')
+		}
+		if serr := buffered.Flush(); serr != nil {
+			return serr
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	for {
+		line, ok, serr := itr.Next()
+		if serr != nil {
+			if ctx.Err() == nil {
+				send("error", map[string]string{"error": serr.Error()})
+			}
+			return
+		}
+		if !ok {
+			break
+		}
+		if serr := send("message", &jsonLine{line.Exchange, line.Type, line.Timestamp, line.Channel, line.Message}); serr != nil {
+			// The client disconnected, or the message can not be encoded
+			if ctx.Err() == nil {
+				send("error", map[string]string{"error": serr.Error()})
+			}
+			return
+		}
+	}
+	if sse {
+		send("end", struct{}{})
+	}
+}
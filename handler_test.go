@@ -0,0 +1,80 @@
+package exdgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayHandler(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	maxRange := time.Hour
+	handler, serr := ReplayHandler(ReplayHandlerParam{Client: srv.client(t), MaxRange: &maxRange})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	expected, serr := prepareFakeReplayRequest(t, srv, ReplayRequestParam{}).Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	query := "?filter=bitmex:orderBookL2_XBTUSD&filter=bitfinex:trades_tBTCUSD" +
+		"&start=2020-01-01T00:00:00Z&end=2020-01-01T00:10:00Z"
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/"+query, nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected response: %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	scanner := bufio.NewScanner(recorder.Body)
+	count := 0
+	for scanner.Scan() {
+		var line jsonLine
+		if serr := json.Unmarshal(scanner.Bytes(), &line); serr != nil {
+			t.Fatal(serr)
+		}
+		if line.Exchange != expected[count].Exchange || line.Timestamp != expected[count].Timestamp {
+			t.Fatalf("line %d differs: %+v", count, line)
+		}
+		count++
+	}
+	if count != len(expected) {
+		t.Fatalf("%d lines sent, expected %d", count, len(expected))
+	}
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/"+query, nil)
+	request.Header.Set("Accept", "text/event-stream")
+	handler.ServeHTTP(recorder, request)
+	body := recorder.Body.String()
+	if recorder.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("not sent as SSE: %s", recorder.Header().Get("Content-Type"))
+	}
+	if events := strings.Count(body, "event: message\ndata: "); events != len(expected) {
+		t.Fatalf("%d events sent, expected %d", events, len(expected))
+	}
+	if !strings.HasSuffix(body, "event: end\ndata: {}\n\n") {
+		t.Fatal("stream not ended with an end event")
+	}
+
+	for _, bad := range []string{
+		"?start=2020-01-01&end=2020-01-02",
+		"?filter=bitmex&start=2020-01-01&end=2020-01-02",
+		"?filter=bitmex:trade&start=2020-01-01&end=2020-01-03",
+		query + "&speed=-1",
+		query + "&format=xml",
+	} {
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/"+bad, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: responded with %d", bad, recorder.Code)
+		}
+	}
+	if _, serr := ReplayHandler(ReplayHandlerParam{}); serr == nil {
+		t.Fatal("handler without a client made")
+	}
+}
@@ -0,0 +1,71 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+)
+
+// Shards are downloaded one by one by `Head` and `Tail`,
+// as only a few shards are usually needed
+const headBufferSize = 1
+
+// takeMessages returns at most `n` message lines from the iterator, and closes it.
+func takeMessages(itr StructLineIterator, n int) ([]StructLine, error) {
+	lines := make([]StructLine, 0, n)
+	serr := ForEach(itr, func(line *StructLine) error {
+		if line.Type != LineTypeMessage {
+			return nil
+		}
+		lines = append(lines, *line)
+		if len(lines) >= n {
+			return ErrStop
+		}
+		return nil
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	return lines, nil
+}
+
+// Head returns the first `n` message lines of the range, downloading only shards needed.
+// Lines other than messages such as start lines are not included.
+func (r *ReplayRequest) Head(n int) ([]StructLine, error) {
+	return r.HeadWithContext(context.Background(), n)
+}
+
+// HeadWithContext is same as `Head` but a context can be given.
+func (r *ReplayRequest) HeadWithContext(ctx context.Context, n int) ([]StructLine, error) {
+	if n < 1 {
+		return nil, errors.New("'n' must be positive")
+	}
+	itr, serr := r.StreamWithContext(ctx, headBufferSize)
+	if serr != nil {
+		return nil, serr
+	}
+	return takeMessages(itr, n)
+}
+
+// Tail returns the last `n` message lines of the range in order of time,
+// downloading only shards needed from the end of the range.
+// Lines other than messages such as start lines are not included.
+func (r *ReplayRequest) Tail(n int) ([]StructLine, error) {
+	return r.TailWithContext(context.Background(), n)
+}
+
+// TailWithContext is same as `Tail` but a context can be given.
+func (r *ReplayRequest) TailWithContext(ctx context.Context, n int) ([]StructLine, error) {
+	if n < 1 {
+		return nil, errors.New("'n' must be positive")
+	}
+	itr, serr := r.StreamReverseWithContext(ctx, headBufferSize)
+	if serr != nil {
+		return nil, serr
+	}
+	lines, serr := takeMessages(itr, n)
+	if serr != nil {
+		return nil, serr
+	}
+	reverseStructLines(lines)
+	return lines, nil
+}
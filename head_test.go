@@ -0,0 +1,35 @@
+package exdgo
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplayHeadTail(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	requests := atomic.LoadInt64(&srv.requests)
+	head, serr := req.Head(5)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, lines[:5], head)
+	tail, serr := req.Tail(5)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, lines[len(lines)-5:], tail)
+	// Downloading the whole range takes a request for each minute
+	if n := atomic.LoadInt64(&srv.requests) - requests; n >= requests {
+		t.Fatalf("%d requests sent for head and tail", n)
+	}
+	if _, serr := req.Head(0); serr == nil {
+		t.Fatal("zero accepted")
+	}
+	checkGoroutineLeak(t)
+}
@@ -0,0 +1,113 @@
+package exdgo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of latest latencies kept to calculate the percentile
+	hedgeWindowSize = 100
+	// Requests are not hedged until this many latencies are observed
+	hedgeMinSamples = 5
+)
+
+// hedger sends a duplicate request for a shard taking longer than the percentile
+// of latencies observed so far, and takes whichever finishes first.
+type hedger struct {
+	percentile float64
+	mutex      sync.Mutex
+	// Ring buffer of latest latencies
+	latencies []time.Duration
+	next      int
+}
+
+func newHedger(percentile float64) *hedger {
+	return &hedger{percentile: percentile}
+}
+
+func (h *hedger) observe(latency time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.latencies) < hedgeWindowSize {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeWindowSize
+}
+
+// threshold returns the latency after which a request is hedged.
+// `ok` is false if not enough latencies are observed.
+func (h *hedger) threshold() (threshold time.Duration, ok bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.latencies) < hedgeMinSamples {
+		return 0, false
+	}
+	sorted := make([]time.Duration, len(h.latencies))
+	copy(sorted, h.latencies)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	index := int(h.percentile * float64(len(sorted)-1))
+	return sorted[index], true
+}
+
+type hedgeResult struct {
+	shard []StringLine
+	err   error
+}
+
+// do calls `fetch`, and calls it again concurrently if it takes longer than the threshold.
+// The result of the one finished first without an error is returned,
+// and the other is cancelled and waited for.
+// `fetch` is simply called if the hedger is nil.
+func (h *hedger) do(ctx context.Context, fetch func(ctx context.Context) ([]StringLine, error)) ([]StringLine, error) {
+	if h == nil {
+		return fetch(ctx)
+	}
+	// Waits after cancelling the other request
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Buffered so that the loser does not block
+	results := make(chan hedgeResult, 2)
+	start := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started := time.Now()
+			shard, serr := fetch(childCtx)
+			if serr == nil {
+				h.observe(time.Since(started))
+			}
+			results <- hedgeResult{shard, serr}
+		}()
+	}
+	start()
+	running := 1
+	// Receiving from nil channel blocks forever, so it never hedges without threshold
+	var hedge <-chan time.Time
+	if threshold, ok := h.threshold(); ok {
+		timer := time.NewTimer(threshold)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+	for {
+		select {
+		case <-hedge:
+			hedge = nil
+			start()
+			running++
+		case res := <-results:
+			running--
+			if res.err == nil || running == 0 {
+				// Cancel the other one by deferred functions
+				return res.shard, res.err
+			}
+			// Wait for the other
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package exdgo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgerDuplicatesSlowRequest(t *testing.T) {
+	h := newHedger(0.9)
+	for j := 0; j < hedgeMinSamples; j++ {
+		h.observe(10 * time.Millisecond)
+	}
+	var calls, cancelled int32
+	start := time.Now()
+	shard, serr := h.do(context.Background(), func(ctx context.Context) ([]StringLine, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The first request is wedged
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+			return nil, ctx.Err()
+		}
+		return []StringLine{{Exchange: "bitmex"}}, nil
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(shard) != 1 || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("shard = %v, calls = %d", shard, calls)
+	}
+	// The wedged request was cancelled and waited for
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Fatal("slow request was not cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took %v", elapsed)
+	}
+}
+
+func TestHedgerWithoutSamples(t *testing.T) {
+	h := newHedger(0.9)
+	var calls int32
+	_, serr := h.do(context.Background(), func(ctx context.Context) ([]StringLine, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return nil, nil
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d", calls)
+	}
+	var nilHedger *hedger
+	if _, serr := nilHedger.do(context.Background(), func(ctx context.Context) ([]StringLine, error) { return nil, nil }); serr != nil {
+		t.Fatal(serr)
+	}
+}
@@ -12,27 +12,122 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// acquireRequestSlot waits until the client allows another HTTP request to be sent.
+// Returned function must be called to release the slot.
+func acquireRequestSlot(ctx context.Context, cli *Client) (func(), error) {
+	if cli.requestSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case cli.requestSlots <- struct{}{}:
+		return func() { <-cli.requestSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // httpDownload will send HTTP GET request to HTTP Endpoint with timeout.
 // clientSetting's Timeout duration is used.
+// Waiting for the client to allow sending request is not included in the timeout.
+// If the client has mirrors, the request is sent to the next mirror when it failed with a retryable error.
 // Response is nil if and only if error is non-nil.
 func httpDownloadWithTimeout(ctx context.Context, cli *Client, path string, params url.Values) (statusCode int, body []byte, err error) {
+	var cached *cacheEntry
+	if cli.cache != nil {
+		cached = cli.cache.get(path, params)
+		// Pinned entries can not be revalidated
+		if cached != nil && (cli.offline || cached.Pinned) {
+			summaryCollectorFrom(ctx).addCacheHit()
+			cli.session.addCacheHit()
+			return cached.StatusCode, cached.body, nil
+		}
+	}
+	if cli.offline {
+		err = &NotCachedError{Path: path}
+		return
+	}
+	if cli.mirrors == nil {
+		return httpDownloadAuthorized(ctx, cli, cli.endpoint, path, params, cached)
+	}
+	for _, m := range cli.mirrors.ranked() {
+		statusCode, body, err = httpDownloadAuthorized(ctx, cli, m.endpoint, path, params, cached)
+		if err == nil || !isRetryable(err) || ctx.Err() != nil {
+			if err == nil {
+				cli.mirrors.succeeded(m)
+			}
+			return
+		}
+		cli.mirrors.failed(m)
+		cli.logf("exdgo: mirror %s failed: %v", m.endpoint, err)
+	}
+	return
+}
+
+// httpDownloadAuthorized sends the request to the endpoint.
+// If the client has `CredentialProvider` and the key was rejected, it is refreshed and the request is sent again.
+func httpDownloadAuthorized(ctx context.Context, cli *Client, endpoint string, path string, params url.Values, cached *cacheEntry) (statusCode int, body []byte, err error) {
+	statusCode, body, err = httpDownloadOnce(ctx, cli, endpoint, path, params, cached)
+	var serr *StatusError
+	if cli.credentials == nil || !errors.As(err, &serr) || serr.StatusCode != http.StatusUnauthorized {
+		return
+	}
+	if rerr := cli.credentials.Refresh(ctx); rerr != nil {
+		err = fmt.Errorf("refreshing credentials: %v, after: %v", rerr, err)
+		return
+	}
+	return httpDownloadOnce(ctx, cli, endpoint, path, params, cached)
+}
+
+// httpDownloadOnce sends the request once without refreshing credentials.
+// The request is conditional if the response is in the cache, `cached` is nil if not.
+func httpDownloadOnce(ctx context.Context, cli *Client, endpoint string, path string, params url.Values, cached *cacheEntry) (statusCode int, body []byte, err error) {
+	apikey, serr := cli.currentAPIKey(ctx)
+	if serr != nil {
+		err = serr
+		return
+	}
+	release, serr := acquireRequestSlot(ctx, cli)
+	if serr != nil {
+		err = fmt.Errorf("waiting for request %s: %v", path, serr)
+		return
+	}
+	defer release()
+	releaseSession, serr := cli.session.acquire(ctx)
+	if serr != nil {
+		err = fmt.Errorf("request %s: %w", path, serr)
+		return
+	}
+	defer releaseSession()
+	ctx, unbind, serr := cli.closer.bind(ctx)
+	if serr != nil {
+		err = fmt.Errorf("request %s: %w", path, serr)
+		return
+	}
+	defer unbind()
 	childCtx, cancel := context.WithTimeout(ctx, cli.timeout)
 	// Free resources anyway
 	defer cancel()
 
-	req, serr := http.NewRequestWithContext(childCtx, http.MethodGet, urlAPI+path, nil)
+	req, serr := http.NewRequestWithContext(childCtx, http.MethodGet, endpoint+path, nil)
 	if serr != nil {
 		err = fmt.Errorf("creating request %s: %v", path, serr)
 		return
 	}
 	// Set query parameter
 	req.URL.RawQuery = params.Encode()
+	for key, values := range cli.headers {
+		req.Header[key] = values
+	}
 	// Set authorization header
-	req.Header.Add("Authorization", "Bearer "+cli.apikey)
-	res, serr := http.DefaultClient.Do(req)
+	req.Header.Set("Authorization", "Bearer "+apikey)
+	if cached != nil {
+		cached.setConditional(req)
+	}
+	res, serr := cli.do(req)
 	if serr != nil {
 		err = fmt.Errorf("request %s: %v", path, serr)
 		return
@@ -49,23 +144,54 @@ func httpDownloadWithTimeout(ctx context.Context, cli *Client, path string, para
 		}
 	}()
 	// Read all response and store it on byte slice.
-	body, serr = ioutil.ReadAll(res.Body)
+	var reader io.Reader = res.Body
+	if cli.bandwidth != nil {
+		reader = &throttledReader{ctx: childCtx, reader: reader, limiter: cli.bandwidth}
+	}
+	if cli.session != nil && cli.session.bandwidth != nil {
+		reader = &throttledReader{ctx: childCtx, reader: reader, limiter: cli.session.bandwidth}
+	}
+	body, serr = ioutil.ReadAll(reader)
+	if serr != nil && len(body) > 0 && canResume(res) {
+		body, serr = resumeBody(ctx, cli, req, res, body, serr)
+	}
+	summaryCollectorFrom(ctx).addBytes(int64(len(body)))
+	cli.session.addBytes(int64(len(body)))
+	if serr == io.ErrUnexpectedEOF {
+		serr = ErrTruncated
+	}
 	if serr != nil {
-		err = fmt.Errorf("body read: %v", serr)
+		err = fmt.Errorf("request %s body read: %w", path, serr)
+		return
+	}
+	if res.ContentLength >= 0 && !res.Uncompressed && int64(len(body)) != res.ContentLength && res.StatusCode != http.StatusNotModified {
+		err = fmt.Errorf("request %s: %w: %d bytes of %d", path, ErrTruncated, len(body), res.ContentLength)
+		return
+	}
+	if serr := verifyChecksum(path, res, body); serr != nil {
+		err = serr
 		return
 	}
 	// Check status-code.
 	statusCode = res.StatusCode
+	if statusCode == http.StatusNotModified && cached != nil {
+		// Cached response is still valid
+		summaryCollectorFrom(ctx).addCacheHit()
+		cli.session.addCacheHit()
+		statusCode = cached.StatusCode
+		body = cached.body
+		return
+	}
 	if statusCode != http.StatusOK && statusCode != http.StatusNotFound {
 		// An error has returned from server
 		// This struct is used to marshal an error message from server.
-		var obj *struct {
+		var obj struct {
 			Error   *string `json:"error"`
 			Message *string `json:"message,Message"`
 		}
 		var errMsg string
 		// This error is used to determine if bytes are in correct JSON format.
-		ierr := json.Unmarshal(body, obj)
+		ierr := json.Unmarshal(body, &obj)
 		if ierr == nil {
 			if obj.Error != nil {
 				errMsg = *obj.Error
@@ -78,7 +204,11 @@ func httpDownloadWithTimeout(ctx context.Context, cli *Client, path string, para
 		} else {
 			errMsg = string(body)
 		}
-		err = fmt.Errorf("request %s bad status code %d: %s", path, statusCode, errMsg)
+		err = &StatusError{
+			Path:       path,
+			StatusCode: statusCode,
+			Message:    errMsg,
+		}
 		return
 	}
 
@@ -91,9 +221,101 @@ func httpDownloadWithTimeout(ctx context.Context, cli *Client, path string, para
 
 	// Compression is automatically processed by http library.
 
+	if cli.cache != nil {
+		if serr := cli.cache.put(path, params, res, body, cachePinFrom(ctx)); serr != nil {
+			// The response is still valid
+			cli.logf("exdgo: storing response in cache: %v", serr)
+		}
+	}
 	return
 }
 
+// do sends the request after calling the request hook.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.requestHook != nil {
+		if serr := c.requestHook(req); serr != nil {
+			return nil, fmt.Errorf("request hook: %v", serr)
+		}
+	}
+	return c.httpClient.Do(req)
+}
+
+// canResume reports whether the rest of the response body can be requested by a range request.
+func canResume(res *http.Response) bool {
+	// Offsets do not match if the body was decompressed by the transport
+	return res.StatusCode == http.StatusOK && res.Header.Get("Accept-Ranges") == "bytes" && !res.Uncompressed
+}
+
+// resumeBody requests the rest of the body interrupted by `readErr` with range requests,
+// up to `cli.maxRetries` times, instead of downloading the whole body again.
+// `If-Range` makes the server return the whole body if it changed, which is treated as a failure.
+// Returns the last error if the body could not be completed.
+func resumeBody(ctx context.Context, cli *Client, orig *http.Request, first *http.Response, body []byte, readErr error) ([]byte, error) {
+	validator := first.Header.Get("ETag")
+	if validator == "" {
+		validator = first.Header.Get("Last-Modified")
+	}
+	for trial := 0; trial < cli.maxRetries; trial++ {
+		cli.logf("exdgo: resuming %s from byte %d: %v", orig.URL.Path, len(body), readErr)
+		childCtx, cancel := context.WithTimeout(ctx, cli.timeout)
+		req := orig.Clone(childCtx)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(body)))
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+		res, serr := cli.do(req)
+		if serr != nil {
+			cancel()
+			readErr = serr
+			continue
+		}
+		if res.StatusCode != http.StatusPartialContent || !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", len(body))) {
+			res.Body.Close()
+			cancel()
+			// The server can not resume, the whole body has to be requested again
+			return body, readErr
+		}
+		var reader io.Reader = res.Body
+		if cli.bandwidth != nil {
+			reader = &throttledReader{ctx: childCtx, reader: reader, limiter: cli.bandwidth}
+		}
+		if cli.session != nil && cli.session.bandwidth != nil {
+			reader = &throttledReader{ctx: childCtx, reader: reader, limiter: cli.session.bandwidth}
+		}
+		rest, serr := ioutil.ReadAll(reader)
+		res.Body.Close()
+		cancel()
+		body = append(body, rest...)
+		if serr == nil {
+			return body, nil
+		}
+		readErr = serr
+	}
+	return body, readErr
+}
+
+// StatusError is the error returned when the API server responded with an unexpected status code.
+type StatusError struct {
+	// Path of the request.
+	Path string
+	// HTTP status code of the response.
+	StatusCode int
+	// Error message from the server.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request %s bad status code %d: %s", e.Path, e.StatusCode, e.Message)
+}
+
+// Is reports whether the error matches `ErrUnauthorized` by its status code.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrUnauthorized && e.StatusCode == http.StatusUnauthorized
+}
+
+// ErrUnauthorized matches errors of requests rejected by the server because of the API-key.
+var ErrUnauthorized = errors.New("unauthorized")
+
 // Snapshot holds a line from Snapshot HTTP Endpoint.
 type Snapshot struct {
 	// Channel name.
@@ -114,6 +336,8 @@ type SnapshotParam struct {
 	// Date-time to take snapshot at.
 	At time.Time
 	// What format to get response in.
+	// For formats this client does not know, the whole response is returned as one `Snapshot`
+	// without a channel.
 	Format *string
 }
 
@@ -131,8 +355,8 @@ func setupSnapshotSetting(param SnapshotParam) (setting snapshotSetting, err err
 	}
 	setting.exchange = param.Exchange
 	for _, ch := range param.Channels {
-		if !regexName.MatchString(ch) {
-			err = errors.New("invalid characters in 'Channels'")
+		if serr := validateChannel(ch); serr != nil {
+			err = fmt.Errorf("invalid channel in 'Channels': %v", serr)
 			return
 		}
 	}
@@ -170,6 +394,17 @@ func httpSnapshot(ctx context.Context, cli *Client, setting snapshotSetting) ([]
 		// 404, return empty slice
 		return make([]Snapshot, 0), nil
 	}
+	if !isKnownFormat(setting.format) {
+		if len(body) == 0 {
+			return make([]Snapshot, 0), nil
+		}
+		// Return the response as is
+		return []Snapshot{{Timestamp: setting.at, Snapshot: body}}, nil
+	}
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		// Every line ends with a newline
+		return nil, fmt.Errorf("request %s: %w: no newline at the end", path, ErrTruncated)
+	}
 	// Conversion to line structs
 	// Construct buffered reader from byte slice
 	reader := bytes.NewReader(body)
@@ -261,6 +496,7 @@ type FilterParam struct {
 	// End date-time.
 	End *time.Time
 	// What format to get response in.
+	// For formats this client does not know, the whole response is returned as one line of `LineTypeUndecoded`.
 	// Optional.
 	Format *string
 }
@@ -281,8 +517,8 @@ func setupFilterSetting(param FilterParam) (setting filterSetting, err error) {
 	}
 	setting.exchange = param.Exchange
 	for _, ch := range param.Channels {
-		if !regexName.MatchString(ch) {
-			err = errors.New("invalid characters in 'Channels'")
+		if serr := validateChannel(ch); serr != nil {
+			err = fmt.Errorf("invalid channel in 'Channels': %v", serr)
 			return
 		}
 	}
@@ -318,6 +554,28 @@ func setupFilterSetting(param FilterParam) (setting filterSetting, err error) {
 // using settings for both client and filter.
 // Returns nil as a slice of `StringLine` if and only if error was not nil.
 func httpFilter(ctx context.Context, cli *Client, setting filterSetting) ([]StringLine, error) {
+	body, serr := httpFilterBody(ctx, cli, setting)
+	if serr != nil {
+		return nil, serr
+	}
+	if len(body) == 0 {
+		return make([]StringLine, 0), nil
+	}
+	if !isKnownFormat(setting.format) {
+		// Return the response as is
+		return []StringLine{{
+			Exchange:  setting.exchange,
+			Type:      LineTypeUndecoded,
+			Timestamp: setting.minute * int64(time.Minute),
+			Message:   body,
+		}}, nil
+	}
+	return parseFilterBody(setting.exchange, body)
+}
+
+// httpFilterBody calls Filter HTTP Endpoint and returns the response as is, empty if data were not recorded.
+// The response in a known format is checked to end with a newline.
+func httpFilterBody(ctx context.Context, cli *Client, setting filterSetting) ([]byte, error) {
 	path := fmt.Sprintf("filter/%s/%d", setting.exchange, setting.minute)
 	params := make(url.Values)
 	// Don't have to copy, this slice is supposed read-only
@@ -338,9 +596,19 @@ func httpFilter(ctx context.Context, cli *Client, setting filterSetting) ([]Stri
 		return nil, serr
 	}
 	if statusCode == http.StatusNotFound {
-		// Return empty slice if data were not recorded
-		return make([]StringLine, 0), nil
+		// Data were not recorded
+		return nil, nil
+	}
+	if isKnownFormat(setting.format) && len(body) > 0 && body[len(body)-1] != '\n' {
+		// Every line ends with a newline
+		return nil, fmt.Errorf("request %s: %w: no newline at the end", path, ErrTruncated)
 	}
+	return body, nil
+}
+
+// parseFilterBody converts the response of Filter HTTP Endpoint into lines of the exchange.
+// Lines after an end line are ignored.
+func parseFilterBody(exchange string, body []byte) ([]StringLine, error) {
 	// Conversion to line structs
 	// Construct buffered reader from byte slice
 	reader := bytes.NewReader(body)
@@ -372,7 +640,7 @@ func httpFilter(ctx context.Context, cli *Client, setting filterSetting) ([]Stri
 				return nil, fmt.Errorf("timestamp conversion: %v", serr)
 			}
 			lines = append(lines, StringLine{
-				Exchange:  setting.exchange,
+				Exchange:  exchange,
 				Type:      lineType,
 				Timestamp: timestamp,
 				Channel:   nil,
@@ -402,7 +670,7 @@ func httpFilter(ctx context.Context, cli *Client, setting filterSetting) ([]Stri
 			}
 			message = message[:len(message)-1]
 			lines = append(lines, StringLine{
-				Exchange:  setting.exchange,
+				Exchange:  exchange,
 				Type:      lineType,
 				Timestamp: timestamp,
 				Channel:   &channel,
@@ -415,7 +683,7 @@ func httpFilter(ctx context.Context, cli *Client, setting filterSetting) ([]Stri
 			}
 			message = message[:len(message)]
 			lines = append(lines, StringLine{
-				Exchange:  setting.exchange,
+				Exchange:  exchange,
 				Type:      lineType,
 				Timestamp: timestamp,
 				Channel:   nil,
@@ -1,6 +1,14 @@
 package exdgo
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -44,3 +52,222 @@ func TestHTTPSnapshot(t *testing.T) {
 		t.Fatal("len(ss) == 0")
 	}
 }
+
+// fakeServer serves Snapshot and Filter HTTP Endpoint with generated lines
+// so tests can run without accessing the real API server.
+//
+// Filter Endpoint returns a line every 10 seconds for each channel.
+// If format is json, Snapshot Endpoint returns definitions of channels.
+type fakeServer struct {
+	server *httptest.Server
+	// Called before a response is written if non-nil.
+	// Returning false prevents the default response to be written.
+	hook func(w http.ResponseWriter, r *http.Request) bool
+	// Number of requests received
+	requests int64
+}
+
+func newFakeServer() *fakeServer {
+	s := new(fakeServer)
+	s.server = httptest.NewServer(s)
+	return s
+}
+
+func (s *fakeServer) close() {
+	s.server.Close()
+}
+
+// client returns a client which sends requests to this server.
+func (s *fakeServer) client(t *testing.T) *Client {
+	// Do not let tests wait for retries
+	retryWait := time.Millisecond
+	cli, serr := setupClient(ClientParam{APIKey: "demo", RetryWait: &retryWait})
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	cli.endpoint = s.server.URL + "/"
+	return &cli
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+	if s.hook != nil && !s.hook(w, r) {
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	exchange := parts[1]
+	num, serr := strconv.ParseInt(parts[2], 10, 64)
+	if serr != nil {
+		http.Error(w, serr.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	channels := query["channels"]
+	json := query.Get("format") == "json"
+	// Shift timestamps by exchange so lines do not collide between exchanges
+	offset := int64(len(exchange))
+	w.Header().Set("Content-Type", "text/plain")
+	switch parts[0] {
+	case "snapshot":
+		for _, ch := range channels {
+			if json {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", num+offset, ch, `{"price":"float","size":"int","timestamp":"timestamp"}`)
+			} else {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", num+offset, ch, `{"snapshot":true}`)
+			}
+		}
+	case "filter":
+		start, _ := strconv.ParseInt(query.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(query.Get("end"), 10, 64)
+		for sec := int64(0); sec < 60; sec += 10 {
+			ts := num*int64(time.Minute) + sec*int64(time.Second) + offset
+			if ts < start || ts >= end {
+				continue
+			}
+			for _, ch := range channels {
+				fmt.Fprintf(w, "msg\t%d\t%s\t{\"price\":1.5,\"size\":%d,\"timestamp\":\"%d\"}\n", ts, ch, sec, ts)
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// checkGoroutineLeak fails the test if goroutines of this package are still running after a while.
+func checkGoroutineLeak(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		leaked := make([]string, 0)
+		for _, g := range strings.Split(string(buf), "\n\n") {
+			if !strings.Contains(g, "exdgo.") {
+				continue
+			}
+			if strings.Contains(g, "exdgo.Test") || strings.Contains(g, "exdgo.checkGoroutineLeak") || strings.Contains(g, "exdgo.(*fakeServer)") {
+				continue
+			}
+			leaked = append(leaked, g)
+		}
+		if len(leaked) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutine leaked:\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientMaxConcurrentRequests(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var inFlight, peak int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		return true
+	}
+	cli := srv.client(t)
+	cli.requestSlots = make(chan struct{}, 2)
+	req := prepareFakeRawRequest(t, srv)
+	req.cli = cli
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, serr := req.DownloadConcurrency(8)
+			errs <- serr
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if serr := <-errs; serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Fatalf("%d requests were in flight", p)
+	}
+}
+
+func TestHTTPResumesInterruptedBody(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var body strings.Builder
+	minute := int64(26297280)
+	for sec := int64(0); sec < 60; sec++ {
+		ts := minute*int64(time.Minute) + sec*int64(time.Second)
+		fmt.Fprintf(&body, "msg\t%d\ttrade\t{\"size\":%d}\n", ts, sec)
+	}
+	full := body.String()
+	var ranges int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", `"v1"`)
+		var offset int
+		if _, serr := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset); serr == nil {
+			atomic.AddInt32(&ranges, 1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(full)-1, len(full)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(full[offset:]))
+			return false
+		}
+		// Interrupt in the middle of the body
+		w.Header().Set("Content-Length", strconv.Itoa(len(full)))
+		w.Write([]byte(full[:len(full)/2]))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	cli := srv.client(t)
+	lines, serr := cli.HTTPFilter(FilterParam{
+		Exchange: "bitmex",
+		Channels: []string{"trade"},
+		Minute:   time.Unix(0, minute*int64(time.Minute)),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) != 60 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	if atomic.LoadInt32(&ranges) != 1 {
+		t.Fatalf("%d range requests", ranges)
+	}
+}
+
+func TestHTTPDetectsTruncation(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		// Connection is closed without length, and the last line is cut
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Connection", "close")
+		w.(http.Flusher).Flush()
+		fmt.Fprintf(w, "msg\t1577836800000000000\ttrade\t{\"size\":1}\nmsg\t1577836801000000000\ttra")
+		return false
+	}
+	cli := srv.client(t)
+	_, serr := cli.HTTPFilter(FilterParam{
+		Exchange: "bitmex",
+		Channels: []string{"trade"},
+		Minute:   time.Unix(1577836800, 0),
+	})
+	if !errors.Is(serr, ErrTruncated) {
+		t.Fatalf("truncation not detected: %v", serr)
+	}
+	if !isRetryable(serr) {
+		t.Fatal("truncation not retryable")
+	}
+}
@@ -0,0 +1,139 @@
+package exdgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Escapes measurements in line protocol
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	// Escapes tag keys, tag values and field keys in line protocol
+	influxKeyEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	// Escapes string field values in line protocol
+	influxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// InfluxWriterParam is the parameters for `NewInfluxWriter`.
+type InfluxWriterParam struct {
+	// Fields of messages written as tags instead of fields, such as "symbol".
+	// Optional, only the exchange is written as a tag by default.
+	Tags []string
+}
+
+// InfluxWriter writes messages in the line protocol of InfluxDB, which VictoriaMetrics also accepts.
+// The measurement is the channel, tagged with the exchange and fields in `Tags`,
+// and the timestamp is in nanoseconds.
+// `Flush` must be called after writing all lines. Not safe for concurrent use.
+type InfluxWriter struct {
+	writer *bufio.Writer
+	tags   map[string]bool
+	// Sorted
+	tagKeys []string
+}
+
+// NewInfluxWriter creates an `InfluxWriter` writing into `w`.
+func NewInfluxWriter(w io.Writer, param InfluxWriterParam) *InfluxWriter {
+	iw := &InfluxWriter{writer: bufio.NewWriter(w), tags: make(map[string]bool)}
+	for _, tag := range param.Tags {
+		if !iw.tags[tag] {
+			iw.tags[tag] = true
+			iw.tagKeys = append(iw.tagKeys, tag)
+		}
+	}
+	sort.Strings(iw.tagKeys)
+	return iw
+}
+
+// formatInfluxField formats the value of a field, or returns false if it can not be written.
+func formatInfluxField(val interface{}) (string, bool, error) {
+	switch v := val.(type) {
+	case nil:
+		return "", false, nil
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true, nil
+	case int:
+		return strconv.Itoa(v) + "i", true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true, nil
+	case json.Number:
+		return v.String(), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	case time.Time:
+		return strconv.FormatInt(v.UnixNano(), 10) + "i", true, nil
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10) + "i", true, nil
+	case string:
+		return `"` + influxStringEscaper.Replace(v) + `"`, true, nil
+	default:
+		encoded, serr := json.Marshal(v)
+		if serr != nil {
+			return "", false, serr
+		}
+		return `"` + influxStringEscaper.Replace(string(encoded)) + `"`, true, nil
+	}
+}
+
+// Write writes the line if it is a message, other lines and messages without fields are ignored.
+func (w *InfluxWriter) Write(line *StructLine) error {
+	if line.Type != LineTypeMessage || line.Channel == nil {
+		return nil
+	}
+	msg, ok := line.Message.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("message of %s is not an object", *line.Channel)
+	}
+	keys := make([]string, 0, len(msg))
+	for key := range msg {
+		if !w.tags[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		val, ok, serr := formatInfluxField(msg[key])
+		if serr != nil {
+			return fmt.Errorf("field %s: %v", key, serr)
+		}
+		if ok {
+			fields = append(fields, influxKeyEscaper.Replace(key)+"="+val)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	w.writer.WriteString(influxMeasurementEscaper.Replace(*line.Channel))
+	w.writer.WriteString(",exchange=" + influxKeyEscaper.Replace(line.Exchange))
+	for _, tag := range w.tagKeys {
+		val, ok := msg[tag]
+		if !ok || val == nil {
+			continue
+		}
+		str, isString := val.(string)
+		if !isString {
+			str = fmt.Sprint(val)
+		}
+		if str == "" {
+			// Empty tag values are not allowed
+			continue
+		}
+		w.writer.WriteString("," + influxKeyEscaper.Replace(tag) + "=" + influxKeyEscaper.Replace(str))
+	}
+	w.writer.WriteByte(' ')
+	w.writer.WriteString(strings.Join(fields, ","))
+	_, serr := w.writer.WriteString(" " + strconv.FormatInt(line.Timestamp, 10) + "\n")
+	return serr
+}
+
+// Flush writes all buffered data to the underlying writer.
+func (w *InfluxWriter) Flush() error {
+	return w.writer.Flush()
+}
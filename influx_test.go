@@ -0,0 +1,31 @@
+package exdgo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInfluxWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writer := NewInfluxWriter(buf, InfluxWriterParam{Tags: []string{"symbol"}})
+	channel := "trade XBT,USD"
+	lines := []StructLine{
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 1, Channel: &channel},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 2, Channel: &channel,
+			Message: map[string]interface{}{"symbol": "XBT USD", "price": 7200.5, "size": int64(10), "side": `"Buy"`, "liq": false, "none": nil}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 3, Channel: &channel,
+			Message: map[string]interface{}{"symbol": "XBTUSD"}},
+	}
+	for k := range lines {
+		if serr := writer.Write(&lines[k]); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := writer.Flush(); serr != nil {
+		t.Fatal(serr)
+	}
+	expected := `trade\ XBT\,USD,exchange=bitmex,symbol=XBT\ USD liq=false,price=7200.5,side="\"Buy\"",size=10i 2` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("written %q", buf.String())
+	}
+}
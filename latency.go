@@ -0,0 +1,136 @@
+package exdgo
+
+import (
+	"container/heap"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// LatencyParam is the parameters for `InjectLatency`.
+type LatencyParam struct {
+	// Latency applied to lines of all exchanges.
+	Latency time.Duration
+	// Latency for each exchange, overrides `Latency`.
+	// Optional.
+	Exchanges map[string]time.Duration
+	// Maximum random latency added on top of the latency.
+	// Random latency is uniformly distributed in [0, Jitter).
+	// Optional.
+	Jitter time.Duration
+	// Seed for the random latency, the same seed produces the same latencies.
+	Seed int64
+}
+
+// timedLine is a line with the time it is to be released.
+type timedLine struct {
+	line StructLine
+	at   int64
+	// Order the line was pushed, to keep the original order for lines with the same time
+	seq int64
+}
+
+// timedLineHeap is a min-heap of lines ordered by time to be released.
+type timedLineHeap []timedLine
+
+func (h timedLineHeap) Len() int { return len(h) }
+func (h timedLineHeap) Less(a, b int) bool {
+	if h[a].at != h[b].at {
+		return h[a].at < h[b].at
+	}
+	return h[a].seq < h[b].seq
+}
+func (h timedLineHeap) Swap(a, b int)       { h[a], h[b] = h[b], h[a] }
+func (h *timedLineHeap) Push(x interface{}) { *h = append(*h, x.(timedLine)) }
+func (h *timedLineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+type latencyIterator struct {
+	itr       StructLineIterator
+	param     LatencyParam
+	rand      *rand.Rand
+	minimum   int64
+	pending   timedLineHeap
+	seq       int64
+	lastInput int64
+	ended     bool
+	line      StructLine
+}
+
+// InjectLatency returns an iterator which delays each line by the latency,
+// simulating a feed received with delay in backtests.
+//
+// Lines are yielded in the order of the time they arrive, with `Timestamp` replaced by it.
+// As latency differs by exchange or by jitter, lines could be reordered from the original.
+// Lines given must be in the order of timestamp.
+//
+// The iterator given is closed when the returned iterator is closed.
+func InjectLatency(itr StructLineIterator, param LatencyParam) (StructLineIterator, error) {
+	if param.Latency < 0 || param.Jitter < 0 {
+		return nil, errors.New("negative latency")
+	}
+	i := new(latencyIterator)
+	i.itr = itr
+	i.param = param
+	i.rand = rand.New(rand.NewSource(param.Seed))
+	i.minimum = int64(param.Latency)
+	for _, latency := range param.Exchanges {
+		if latency < 0 {
+			return nil, errors.New("negative latency in 'Exchanges'")
+		}
+		if int64(latency) < i.minimum {
+			i.minimum = int64(latency)
+		}
+	}
+	return i, nil
+}
+
+func (i *latencyIterator) latency(exchange string) int64 {
+	latency, ok := i.param.Exchanges[exchange]
+	if !ok {
+		latency = i.param.Latency
+	}
+	if i.param.Jitter > 0 {
+		return int64(latency) + i.rand.Int63n(int64(i.param.Jitter))
+	}
+	return int64(latency)
+}
+
+func (i *latencyIterator) Next() (*StructLine, bool, error) {
+	for {
+		// No line read afterward can arrive earlier than the last input with the minimum latency
+		if len(i.pending) > 0 && (i.ended || i.pending[0].at <= i.lastInput+i.minimum) {
+			next := heap.Pop(&i.pending).(timedLine)
+			i.line = next.line
+			i.line.Timestamp = next.at
+			return &i.line, true, nil
+		}
+		if i.ended {
+			return nil, false, nil
+		}
+		line, ok, serr := i.itr.Next()
+		if !ok {
+			if serr != nil {
+				return nil, false, serr
+			}
+			i.ended = true
+			continue
+		}
+		i.lastInput = line.Timestamp
+		heap.Push(&i.pending, timedLine{
+			line: *line,
+			at:   line.Timestamp + i.latency(line.Exchange),
+			seq:  i.seq,
+		})
+		i.seq++
+	}
+}
+
+func (i *latencyIterator) Close() error {
+	return i.itr.Close()
+}
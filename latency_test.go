@@ -0,0 +1,50 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInjectLatency(t *testing.T) {
+	lines := testLines(1000, []string{"bitmex", "bitfinex"}, []string{"trades"})
+	itr, serr := InjectLatency(newSliceIterator(lines), LatencyParam{
+		Latency: 3 * time.Second,
+		Exchanges: map[string]time.Duration{
+			"bitfinex": 500 * time.Millisecond,
+		},
+		Jitter: 2 * time.Second,
+		Seed:   1,
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	var last int64
+	count := 0
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if line.Timestamp < last {
+			t.Fatalf("line %d arrived earlier than the previous", count)
+		}
+		last = line.Timestamp
+		index := line.Message.(map[string]interface{})["index"].(int)
+		delay := time.Duration(line.Timestamp - lines[index].Timestamp)
+		minimum, maximum := 3*time.Second, 5*time.Second
+		if line.Exchange == "bitfinex" {
+			minimum, maximum = 500*time.Millisecond, 2500*time.Millisecond
+		}
+		if delay < minimum || delay >= maximum {
+			t.Fatalf("delay of line %d out of range: %v", index, delay)
+		}
+		count++
+	}
+	if count != len(lines) {
+		t.Fatalf("count = %d", count)
+	}
+}
@@ -0,0 +1,93 @@
+package exdgo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LiveSource provides lines continuing from historical data in real time,
+// such as ones received from a websocket of an exchange.
+type LiveSource interface {
+	// Open starts yielding lines from the time given, which is the end of historical data.
+	// Lines earlier than the time are skipped, so the source may start a little earlier to avoid gaps.
+	// The iterator is closed when the continued iterator is closed.
+	Open(ctx context.Context, from time.Time) (StructLineIterator, error)
+}
+
+// ContinueLive returns an iterator yielding lines from `history` until it reaches the end,
+// and then lines from the live source opened at `end`.
+// `history` is closed when it reached the end.
+func ContinueLive(ctx context.Context, history StructLineIterator, end time.Time, live LiveSource) StructLineIterator {
+	return &liveIterator{ctx: ctx, history: history, end: end, source: live}
+}
+
+// StreamLive is same as `StreamWithContext`, but continues with lines from the live source
+// after the end of the request. See `ContinueLive`.
+func (r *ReplayRequest) StreamLive(ctx context.Context, bufferSize int, live LiveSource) (StructLineIterator, error) {
+	itr, serr := r.StreamWithContext(ctx, bufferSize)
+	if serr != nil {
+		return nil, serr
+	}
+	return ContinueLive(ctx, itr, r.End(), live), nil
+}
+
+type liveIterator struct {
+	ctx     context.Context
+	history StructLineIterator
+	end     time.Time
+	source  LiveSource
+	// nil until history reached the end
+	live   StructLineIterator
+	closed bool
+}
+
+func (i *liveIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	if i.history != nil {
+		line, ok, serr := i.history.Next()
+		if ok || serr != nil {
+			return line, ok, serr
+		}
+		serr = i.history.Close()
+		i.history = nil
+		if serr != nil {
+			return nil, false, serr
+		}
+	}
+	if i.live == nil {
+		live, serr := i.source.Open(i.ctx, i.end)
+		if serr != nil {
+			return nil, false, fmt.Errorf("opening live source: %v", serr)
+		}
+		i.live = live
+	}
+	from := i.end.UnixNano()
+	for {
+		line, ok, serr := i.live.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		if line.Timestamp >= from {
+			return line, true, nil
+		}
+	}
+}
+
+func (i *liveIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	if i.history != nil {
+		if serr := i.history.Close(); serr != nil {
+			return serr
+		}
+	}
+	if i.live != nil {
+		return i.live.Close()
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package exdgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeLiveSource struct {
+	lines  []StructLine
+	opened time.Time
+}
+
+func (s *fakeLiveSource) Open(ctx context.Context, from time.Time) (StructLineIterator, error) {
+	s.opened = from
+	return newSliceIterator(s.lines), nil
+}
+
+func TestContinueLive(t *testing.T) {
+	lines := testLines(10, []string{"bitmex"}, []string{"trade"})
+	end := time.Unix(0, lines[5].Timestamp)
+	// Live source starts a little earlier than the end
+	source := &fakeLiveSource{lines: lines[3:]}
+	itr := ContinueLive(context.Background(), newSliceIterator(lines[:5]), end, source)
+	compareStructLines(t, lines, readAllStructLines(t, itr))
+	if !source.opened.Equal(end) {
+		t.Fatalf("live source opened at %v", source.opened)
+	}
+	if _, _, serr := itr.Next(); serr != ErrIteratorClosed {
+		t.Fatalf("expected ErrIteratorClosed, got %v", serr)
+	}
+}
@@ -0,0 +1,196 @@
+package exdgo
+
+import (
+	"sync"
+)
+
+// MergeParam is the parameters for `Merge`.
+type MergeParam struct {
+	// Iterators to merge.
+	// They are read on background goroutines and closed when the merged iterator is closed,
+	// thus must not be used after passed.
+	// Closing waits for `Next` of iterators running at that time to return before closing them,
+	// so an iterator blocking in `Next` must be stopped by `Cancel`, otherwise closing blocks as long.
+	Iterators []StructLineIterator
+	// Called when the merged iterator is closed before waiting for `Next` of iterators,
+	// such as the cancel function of the context iterators were made with.
+	// Optional.
+	Cancel func()
+	// If true, a line is released only after all iterators have progressed up to its timestamp,
+	// that is, every iterator which have not reached the end has a line buffered.
+	// This prevents a fast iterator from racing ahead of a slow one and guarantees
+	// lines are yielded in the order of timestamp across iterators.
+	//
+	// If false, the earliest line among lines already buffered is released without waiting
+	// for slower iterators.
+	Barrier bool
+}
+
+// mergeItem is a line or the end of an iterator sent from readers.
+type mergeItem struct {
+	source int
+	line   StructLine
+	// True if the iterator reached the end or returned an error
+	end bool
+	err error
+}
+
+type mergeIterator struct {
+	sources []StructLineIterator
+	barrier bool
+	items   chan mergeItem
+	// Credits to read lines, returned when a line is consumed
+	credits []chan struct{}
+	// Lines received from each source
+	queues   [][]StructLine
+	finished []bool
+	// Closed to stop readers
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	line   StructLine
+	err    error
+	closed bool
+	// Index of the source `line` came from
+	lineSource int
+	// Stops readers blocked in `Next`, nil if not given
+	cancel func()
+}
+
+// Merge merges lines from multiple iterators into one iterator ordered by timestamp.
+// Lines with the same timestamp are yielded in the order of iterators given.
+// Each iterator is read on its own goroutine, so slow iterators are read concurrently.
+//
+// The first error from any of iterators is returned from `Next` of the merged iterator.
+func Merge(param MergeParam) StructLineIterator {
+	return newMergeIterator(param)
+}
+
+func newMergeIterator(param MergeParam) *mergeIterator {
+	i := new(mergeIterator)
+	i.sources = param.Iterators
+	i.barrier = param.Barrier
+	i.items = make(chan mergeItem, len(i.sources))
+	i.credits = make([]chan struct{}, len(i.sources))
+	i.queues = make([][]StructLine, len(i.sources))
+	i.finished = make([]bool, len(i.sources))
+	i.cancel = param.Cancel
+	i.stop = make(chan struct{})
+	for j := range i.sources {
+		i.credits[j] = make(chan struct{}, mergeBufferSize)
+		for k := 0; k < mergeBufferSize; k++ {
+			i.credits[j] <- struct{}{}
+		}
+		i.wg.Add(1)
+		go i.read(j)
+	}
+	return i
+}
+
+// read reads lines from a source as long as it has credits.
+func (i *mergeIterator) read(source int) {
+	defer i.wg.Done()
+	for {
+		select {
+		case <-i.credits[source]:
+		case <-i.stop:
+			return
+		}
+		item := mergeItem{source: source}
+		line, ok, serr := i.sources[source].Next()
+		if ok {
+			item.line = *line
+		} else {
+			item.end = true
+			item.err = serr
+		}
+		select {
+		case i.items <- item:
+		case <-i.stop:
+			return
+		}
+		if item.end {
+			return
+		}
+	}
+}
+
+func (i *mergeIterator) receive(item mergeItem) {
+	if item.end {
+		i.finished[item.source] = true
+		if item.err != nil && i.err == nil {
+			i.err = item.err
+		}
+		return
+	}
+	i.queues[item.source] = append(i.queues[item.source], item.line)
+}
+
+func (i *mergeIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		if i.err != nil {
+			return nil, false, i.err
+		}
+		// Take all lines already available
+	drain:
+		for {
+			select {
+			case item := <-i.items:
+				i.receive(item)
+			default:
+				break drain
+			}
+		}
+		if i.err != nil {
+			return nil, false, i.err
+		}
+		argmin := -1
+		waiting := false
+		for j, queue := range i.queues {
+			if len(queue) == 0 {
+				if !i.finished[j] {
+					waiting = true
+				}
+				continue
+			}
+			if argmin < 0 || queue[0].Timestamp < i.queues[argmin][0].Timestamp {
+				argmin = j
+			}
+		}
+		if argmin >= 0 && (!i.barrier || !waiting) {
+			i.line = i.queues[argmin][0]
+			i.lineSource = argmin
+			i.queues[argmin] = i.queues[argmin][1:]
+			// Let the reader read another line
+			i.credits[argmin] <- struct{}{}
+			return &i.line, true, nil
+		}
+		if !waiting {
+			// All iterators reached the end
+			return nil, false, nil
+		}
+		i.receive(<-i.items)
+	}
+}
+
+func (i *mergeIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	close(i.stop)
+	if i.cancel != nil {
+		i.cancel()
+	}
+	// Sources are not safe for concurrent use, so they are closed after readers left `Next`
+	i.wg.Wait()
+	var serr error
+	for _, source := range i.sources {
+		if err := source.Close(); err != nil && serr == nil {
+			serr = err
+		}
+	}
+	return serr
+}
@@ -0,0 +1,130 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowIterator delays every `Next` of the iterator.
+type slowIterator struct {
+	StructLineIterator
+	delay time.Duration
+}
+
+func (i *slowIterator) Next() (*StructLine, bool, error) {
+	time.Sleep(i.delay)
+	return i.StructLineIterator.Next()
+}
+
+func TestMergeBarrier(t *testing.T) {
+	fast := testLines(100, []string{"bitmex"}, []string{"trades"})
+	slow := testLines(100, []string{"bitfinex"}, []string{"trades"})
+	for j := range slow {
+		// Interleave with lines of the fast iterator
+		slow[j].Timestamp += int64(time.Millisecond)
+	}
+	itr := Merge(MergeParam{
+		Iterators: []StructLineIterator{
+			newSliceIterator(fast),
+			&slowIterator{StructLineIterator: newSliceIterator(slow), delay: time.Millisecond},
+		},
+		Barrier: true,
+	})
+	defer itr.Close()
+	var last int64
+	count := 0
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if line.Timestamp < last {
+			t.Fatalf("line %d is earlier than the previous", count)
+		}
+		last = line.Timestamp
+		count++
+	}
+	if count != 200 {
+		t.Fatalf("count = %d", count)
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	failing := newSliceIterator(testLines(10, []string{"bitfinex"}, []string{"trades"}))
+	failing.err = errors.New("source error")
+	itr := Merge(MergeParam{
+		Iterators: []StructLineIterator{
+			newSliceIterator(testLines(1000, []string{"bitmex"}, []string{"trades"})),
+			failing,
+		},
+	})
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr != failing.err {
+				t.Fatalf("err = %v", serr)
+			}
+			break
+		}
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	if !failing.closed {
+		t.Fatal("source is not closed")
+	}
+	checkGoroutineLeak(t)
+}
+
+// blockingIterator blocks `Next` until the context is done.
+type blockingIterator struct {
+	ctx    context.Context
+	closed bool
+}
+
+func (i *blockingIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	<-i.ctx.Done()
+	return nil, false, i.ctx.Err()
+}
+
+func (i *blockingIterator) Close() error {
+	i.closed = true
+	return nil
+}
+
+func TestMergeCloseBlockedSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	blocking := &blockingIterator{ctx: ctx}
+	itr := Merge(MergeParam{
+		Iterators: []StructLineIterator{
+			newSliceIterator(testLines(10, []string{"bitmex"}, []string{"trades"})),
+			blocking,
+		},
+		Cancel: cancel,
+	})
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatal(serr)
+	}
+	closed := make(chan error)
+	go func() { closed <- itr.Close() }()
+	select {
+	case serr := <-closed:
+		if serr != nil {
+			t.Fatal(serr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close waited for the blocked source")
+	}
+	if !blocking.closed {
+		t.Fatal("blocked source is not closed")
+	}
+	checkGoroutineLeak(t)
+}
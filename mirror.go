@@ -0,0 +1,72 @@
+package exdgo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A mirror which failed is tried after healthy mirrors until this passes since the last failure
+const mirrorCooldown = time.Minute
+
+// mirror is an endpoint serving the same API.
+type mirror struct {
+	endpoint string
+	// Index in the order mirrors were given
+	index int
+	// Number of failures in a row
+	failures    int
+	lastFailure time.Time
+}
+
+// mirrorSet ranks endpoints by their health, safe for concurrent use.
+type mirrorSet struct {
+	mutex   sync.Mutex
+	mirrors []*mirror
+}
+
+func newMirrorSet(endpoints []string) *mirrorSet {
+	s := new(mirrorSet)
+	for i, endpoint := range endpoints {
+		s.mirrors = append(s.mirrors, &mirror{endpoint: endpoint, index: i})
+	}
+	return s
+}
+
+// ranked returns mirrors to try in order.
+// Healthy mirrors come first in the order they were given,
+// then ones failed recently with fewer failures first.
+func (s *mirrorSet) ranked() []*mirror {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	ranked := make([]*mirror, len(s.mirrors))
+	copy(ranked, s.mirrors)
+	unhealthy := func(m *mirror) int {
+		if m.failures == 0 || now.Sub(m.lastFailure) >= mirrorCooldown {
+			return 0
+		}
+		return m.failures
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		ua, ub := unhealthy(ranked[a]), unhealthy(ranked[b])
+		if ua != ub {
+			return ua < ub
+		}
+		return ranked[a].index < ranked[b].index
+	})
+	return ranked
+}
+
+func (s *mirrorSet) succeeded(m *mirror) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m.failures = 0
+}
+
+func (s *mirrorSet) failed(m *mirror) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m.failures++
+	m.lastFailure = time.Now()
+}
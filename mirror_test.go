@@ -0,0 +1,45 @@
+package exdgo
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMirrorFailover(t *testing.T) {
+	primary := newFakeServer()
+	defer primary.close()
+	primary.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	mirror := newFakeServer()
+	defer mirror.close()
+	cli := primary.client(t)
+	cli.mirrors = newMirrorSet([]string{primary.server.URL + "/", mirror.server.URL + "/"})
+	param := SnapshotParam{
+		Exchange: "bitmex",
+		Channels: []string{"orderBookL2"},
+		At:       time.Unix(1577836800, 0),
+	}
+	for i := 0; i < 3; i++ {
+		snapshots, serr := cli.HTTPSnapshot(param)
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if len(snapshots) != 1 {
+			t.Fatalf("len(snapshots) = %d", len(snapshots))
+		}
+	}
+	// The primary is not tried again while it is unhealthy
+	if n := atomic.LoadInt64(&primary.requests); n != 1 {
+		t.Fatalf("%d requests sent to the failing primary", n)
+	}
+	if n := atomic.LoadInt64(&mirror.requests); n != 3 {
+		t.Fatalf("%d requests sent to the mirror", n)
+	}
+	if _, serr := setupClient(ClientParam{APIKey: "demo", Mirrors: []string{"not a url"}}); serr == nil {
+		t.Fatal("invalid mirror accepted")
+	}
+}
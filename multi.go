@@ -0,0 +1,140 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ReplayMultiRequest replays multiple requests merged into one stream ordered by timestamp.
+// See `Client.ReplayMulti`.
+type ReplayMultiRequest struct {
+	cli      *Client
+	requests []*ReplayRequest
+}
+
+// ReplayMulti creates a request which runs all of requests with the given parameters,
+// and merges lines from them into one stream ordered by timestamp.
+// Lines returned by more than one request, such as of the same channel in overlapping time ranges,
+// are yielded only once, while identical lines returned by a request, such as trades of the same price
+// at the same time, are all kept.
+//
+// Requests share the limits of this client, such as `MaxConcurrentRequests`.
+func (c *Client) ReplayMulti(params []ReplayRequestParam) (*ReplayMultiRequest, error) {
+	if len(params) == 0 {
+		return nil, errors.New("empty 'params'")
+	}
+	req := &ReplayMultiRequest{cli: c}
+	for k, param := range params {
+		r, serr := setupReplayRequest(c, param)
+		if serr != nil {
+			return nil, fmt.Errorf("params[%d]: %v", k, serr)
+		}
+		req.requests = append(req.requests, r)
+	}
+	return req, nil
+}
+
+// Stream returns an iterator yielding merged lines.
+func (r *ReplayMultiRequest) Stream() (StructLineIterator, error) {
+	return r.StreamWithContext(context.Background(), r.cli.bufferSize)
+}
+
+// StreamWithContext is same as `Stream` but a context and a buffer size for each request can be given.
+func (r *ReplayMultiRequest) StreamWithContext(ctx context.Context, bufferSize int) (StructLineIterator, error) {
+	// Cancelled on closing to stop streams waiting for shards
+	ctx, cancel := context.WithCancel(ctx)
+	itrs := make([]StructLineIterator, 0, len(r.requests))
+	for _, req := range r.requests {
+		itr, serr := req.StreamWithContext(ctx, bufferSize)
+		if serr != nil {
+			cancel()
+			for _, opened := range itrs {
+				opened.Close()
+			}
+			return nil, serr
+		}
+		itrs = append(itrs, itr)
+	}
+	// Barrier is required to strictly order lines across requests
+	merged := newMergeIterator(MergeParam{Iterators: itrs, Barrier: true, Cancel: cancel})
+	return &dedupIterator{itr: merged}, nil
+}
+
+// Download downloads all merged lines in a slice.
+func (r *ReplayMultiRequest) Download() ([]StructLine, error) {
+	return r.DownloadWithContext(context.Background())
+}
+
+// DownloadWithContext is same as `Download` but a context can be given.
+func (r *ReplayMultiRequest) DownloadWithContext(ctx context.Context) ([]StructLine, error) {
+	itr, serr := r.StreamWithContext(ctx, r.cli.bufferSize)
+	if serr != nil {
+		return nil, serr
+	}
+	return collectStructLines(itr)
+}
+
+// dedupIterator drops lines which are the same as a line from another source with the same timestamp.
+// Lines repeated in a source are kept, so a line is yielded as many times as the source having it the most.
+// Lines given must be in the order of timestamp.
+type dedupIterator struct {
+	itr *mergeIterator
+	// Lines yielded with the timestamp of the last line
+	seen []dedupEntry
+}
+
+// dedupEntry is a line yielded and the number of times each source had it.
+type dedupEntry struct {
+	line StructLine
+	// map[source]count
+	counts map[int]int
+	// Number of times the line was yielded, the largest of counts
+	yielded int
+}
+
+func sameLine(a *StructLine, b *StructLine) bool {
+	if a.Exchange != b.Exchange || a.Type != b.Type || a.Timestamp != b.Timestamp {
+		return false
+	}
+	if (a.Channel == nil) != (b.Channel == nil) || (a.Channel != nil && *a.Channel != *b.Channel) {
+		return false
+	}
+	return reflect.DeepEqual(a.Message, b.Message)
+}
+
+func (i *dedupIterator) Next() (*StructLine, bool, error) {
+	for {
+		line, ok, serr := i.itr.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		source := i.itr.lineSource
+		if len(i.seen) > 0 && i.seen[0].line.Timestamp != line.Timestamp {
+			i.seen = i.seen[:0]
+		}
+		var entry *dedupEntry
+		for j := range i.seen {
+			if sameLine(&i.seen[j].line, line) {
+				entry = &i.seen[j]
+				break
+			}
+		}
+		if entry == nil {
+			i.seen = append(i.seen, dedupEntry{line: *line, counts: map[int]int{source: 1}, yielded: 1})
+			return line, true, nil
+		}
+		entry.counts[source]++
+		if entry.counts[source] <= entry.yielded {
+			// Another source had the same line
+			continue
+		}
+		entry.yielded++
+		return line, true, nil
+	}
+}
+
+func (i *dedupIterator) Close() error {
+	return i.itr.Close()
+}
@@ -0,0 +1,119 @@
+package exdgo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayMulti(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	cli := srv.client(t)
+	params := []ReplayRequestParam{
+		{
+			Filter: map[string][]string{"bitmex": []string{"orderBookL2_XBTUSD"}},
+			Start:  start,
+			End:    start.Add(3 * time.Minute),
+		},
+		{
+			Filter: map[string][]string{"bitmex": []string{"orderBookL2_XBTUSD", "orderBookL2_ETHUSD"}},
+			Start:  start.Add(2 * time.Minute),
+			End:    start.Add(4 * time.Minute),
+		},
+	}
+	req, serr := cli.ReplayMulti(params)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// orderBookL2 for 4 minutes without duplicates, and the other channel for 2 minutes
+	if len(lines) != 6*4+6*2 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	for j := 1; j < len(lines); j++ {
+		if lines[j].Timestamp < lines[j-1].Timestamp {
+			t.Fatalf("line %d out of order", j)
+		}
+	}
+	checkGoroutineLeak(t)
+	if _, serr := cli.ReplayMulti(nil); serr == nil {
+		t.Fatal("empty params accepted")
+	}
+}
+
+func TestDedupIteratorSameSource(t *testing.T) {
+	channel := "trades"
+	line := func(timestamp int64, price float64) StructLine {
+		return StructLine{
+			Exchange:  "bitmex",
+			Type:      LineTypeMessage,
+			Timestamp: timestamp,
+			Channel:   &channel,
+			Message:   map[string]interface{}{"price": price},
+		}
+	}
+	// Two trades of the same price at the same time are both real
+	a := []StructLine{line(1, 1.5), line(1, 1.5), line(2, 2.5)}
+	b := []StructLine{line(1, 1.5), line(2, 2.5), line(2, 2.5), line(3, 3.5)}
+	itr := &dedupIterator{itr: newMergeIterator(MergeParam{
+		Iterators: []StructLineIterator{newSliceIterator(a), newSliceIterator(b)},
+		Barrier:   true,
+	})}
+	lines := readAllStructLines(t, itr)
+	expected := []StructLine{line(1, 1.5), line(1, 1.5), line(2, 2.5), line(2, 2.5), line(3, 3.5)}
+	compareStructLines(t, expected, lines)
+}
+
+func TestReplayMultiCloseWhileFetching(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	release := make(chan struct{})
+	defer close(release)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		// Shards of bitfinex after snapshots do not come until the test ends
+		if strings.HasSuffix(r.URL.Path, "/bitfinex/26297280") {
+			<-release
+		}
+		return true
+	}
+	start := time.Unix(1577836800, 0)
+	req, serr := srv.client(t).ReplayMulti([]ReplayRequestParam{
+		{
+			Filter: map[string][]string{"bitmex": []string{"orderBookL2_XBTUSD"}},
+			Start:  start,
+			End:    start.Add(2 * time.Minute),
+		},
+		{
+			Filter: map[string][]string{"bitfinex": []string{"trades_tBTCUSD"}},
+			Start:  start,
+			End:    start.Add(2 * time.Minute),
+		},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := req.StreamWithContext(context.Background(), 2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// Let readers wait for shards
+	time.Sleep(50 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		itr.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close waited for the slow request")
+	}
+	checkGoroutineLeak(t)
+}
@@ -0,0 +1,174 @@
+package exdgo
+
+import "sync"
+
+// Operators below wrap an iterator into another, so they can be chained into a pipeline
+// together with `Merge` and others taking iterators.
+// Closing the returned iterator closes the wrapped one.
+
+// Map returns an iterator yielding lines converted by `fn`.
+// An error from `fn` is returned from `Next`.
+func Map(itr StructLineIterator, fn func(line StructLine) (StructLine, error)) StructLineIterator {
+	return FlatMap(itr, func(line StructLine) ([]StructLine, error) {
+		mapped, serr := fn(line)
+		if serr != nil {
+			return nil, serr
+		}
+		return []StructLine{mapped}, nil
+	})
+}
+
+// Filter returns an iterator yielding only lines `fn` returned true for.
+func Filter(itr StructLineIterator, fn func(line *StructLine) bool) StructLineIterator {
+	return &filterIterator{source: itr, fn: fn}
+}
+
+type filterIterator struct {
+	source StructLineIterator
+	fn     func(line *StructLine) bool
+	closed bool
+}
+
+func (i *filterIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		line, ok, serr := i.source.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		if i.fn(line) {
+			return line, true, nil
+		}
+	}
+}
+
+func (i *filterIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
+
+// FlatMap returns an iterator yielding all lines `fn` returned for each line, in order.
+// An error from `fn` is returned from `Next`.
+func FlatMap(itr StructLineIterator, fn func(line StructLine) ([]StructLine, error)) StructLineIterator {
+	return &flatMapIterator{source: itr, fn: fn}
+}
+
+type flatMapIterator struct {
+	source StructLineIterator
+	fn     func(line StructLine) ([]StructLine, error)
+	// Lines returned by `fn` not yet yielded
+	pending []StructLine
+	line    StructLine
+	closed  bool
+}
+
+func (i *flatMapIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for len(i.pending) == 0 {
+		line, ok, serr := i.source.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		i.pending, serr = i.fn(*line)
+		if serr != nil {
+			return nil, false, serr
+		}
+	}
+	i.line = i.pending[0]
+	i.pending = i.pending[1:]
+	return &i.line, true, nil
+}
+
+func (i *flatMapIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
+
+// Buffer returns an iterator reading up to `size` lines ahead from `itr` on a background goroutine,
+// so a slow stage of a pipeline does not stall the stages before it.
+// `itr` must not be used after passed.
+func Buffer(itr StructLineIterator, size int) StructLineIterator {
+	if size < 1 {
+		size = 1
+	}
+	i := &bufferIterator{
+		source: itr,
+		items:  make(chan mergeItem, size),
+		stop:   make(chan struct{}),
+	}
+	i.wg.Add(1)
+	go i.read()
+	return i
+}
+
+type bufferIterator struct {
+	source StructLineIterator
+	items  chan mergeItem
+	// Closed to stop reading
+	stop chan struct{}
+	wg   sync.WaitGroup
+	line StructLine
+	// Set when the source reached the end
+	end    bool
+	err    error
+	closed bool
+}
+
+func (i *bufferIterator) read() {
+	defer i.wg.Done()
+	for {
+		var item mergeItem
+		line, ok, serr := i.source.Next()
+		if ok {
+			item.line = *line
+		} else {
+			item.end = true
+			item.err = serr
+		}
+		select {
+		case i.items <- item:
+		case <-i.stop:
+			return
+		}
+		if item.end {
+			return
+		}
+	}
+}
+
+func (i *bufferIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	if i.end {
+		return nil, false, i.err
+	}
+	item := <-i.items
+	if item.end {
+		i.end = true
+		i.err = item.err
+		return nil, false, i.err
+	}
+	i.line = item.line
+	return &i.line, true, nil
+}
+
+func (i *bufferIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	close(i.stop)
+	i.wg.Wait()
+	return i.source.Close()
+}
@@ -0,0 +1,61 @@
+package exdgo
+
+import (
+	"errors"
+	"testing"
+)
+
+// closeCounter counts calls to `Close` of the iterator.
+type closeCounter struct {
+	StructLineIterator
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return c.StructLineIterator.Close()
+}
+
+func TestOperators(t *testing.T) {
+	lines := testLines(20, []string{"bitmex", "bitfinex"}, []string{"trade"})
+	source := &closeCounter{StructLineIterator: newSliceIterator(lines)}
+	itr := Buffer(Map(
+		FlatMap(
+			Filter(source, func(line *StructLine) bool { return line.Exchange == "bitmex" }),
+			func(line StructLine) ([]StructLine, error) { return []StructLine{line, line}, nil },
+		),
+		func(line StructLine) (StructLine, error) {
+			line.Exchange = "mapped"
+			return line, nil
+		},
+	), 3)
+	expected := make([]StructLine, 0)
+	for _, line := range lines {
+		if line.Exchange == "bitmex" {
+			line.Exchange = "mapped"
+			expected = append(expected, line, line)
+		}
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	if source.closed != 1 {
+		t.Fatalf("source closed %d times", source.closed)
+	}
+	if serr := itr.Close(); serr != nil || source.closed != 1 {
+		t.Fatalf("closing again: %v, source closed %d times", serr, source.closed)
+	}
+
+	failure := errors.New("failure")
+	itr = Map(newSliceIterator(lines), func(line StructLine) (StructLine, error) { return line, failure })
+	if _, _, serr := itr.Next(); serr != failure {
+		t.Fatalf("expected failure, got %v", serr)
+	}
+	itr.Close()
+
+	// Closing before reading all lines stops the background goroutine
+	itr = Buffer(newSliceIterator(lines), 1)
+	if _, ok, _ := itr.Next(); !ok {
+		t.Fatal("no line")
+	}
+	itr.Close()
+	checkGoroutineLeak(t)
+}
@@ -0,0 +1,146 @@
+package exdgo
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a client created by `NewClient`.
+// Each option sets the field of the same name in `ClientParam`.
+type Option func(param *ClientParam)
+
+// NewClient creates new Client with the API-key and options.
+// The API-key can be empty to read it from the environment variable or the config file,
+// as `ClientParam.APIKey`.
+func NewClient(apiKey string, opts ...Option) (*Client, error) {
+	param := ClientParam{APIKey: apiKey}
+	for _, opt := range opts {
+		opt(&param)
+	}
+	return CreateClient(param)
+}
+
+// WithConfigFile sets `ClientParam.ConfigFile`.
+func WithConfigFile(path string) Option {
+	return func(param *ClientParam) { param.ConfigFile = path }
+}
+
+// WithCredentials sets `ClientParam.Credentials`.
+func WithCredentials(provider CredentialProvider) Option {
+	return func(param *ClientParam) { param.Credentials = provider }
+}
+
+// WithTimeout sets `ClientParam.Timeout`.
+func WithTimeout(timeout time.Duration) Option {
+	return func(param *ClientParam) { param.Timeout = &timeout }
+}
+
+// WithMaxRetries sets `ClientParam.MaxRetries`.
+func WithMaxRetries(retries int) Option {
+	return func(param *ClientParam) { param.MaxRetries = &retries }
+}
+
+// WithRetryWait sets `ClientParam.RetryWait`.
+func WithRetryWait(wait time.Duration) Option {
+	return func(param *ClientParam) { param.RetryWait = &wait }
+}
+
+// WithConcurrency sets `ClientParam.Concurrency`.
+func WithConcurrency(concurrency int) Option {
+	return func(param *ClientParam) { param.Concurrency = &concurrency }
+}
+
+// WithBufferSize sets `ClientParam.BufferSize`.
+func WithBufferSize(size int) Option {
+	return func(param *ClientParam) { param.BufferSize = &size }
+}
+
+// WithMaxConcurrentRequests sets `ClientParam.MaxConcurrentRequests`.
+func WithMaxConcurrentRequests(requests int) Option {
+	return func(param *ClientParam) { param.MaxConcurrentRequests = &requests }
+}
+
+// WithMaxBytesPerSecond sets `ClientParam.MaxBytesPerSecond`.
+func WithMaxBytesPerSecond(bytes int64) Option {
+	return func(param *ClientParam) { param.MaxBytesPerSecond = &bytes }
+}
+
+// WithHTTPClient sets `ClientParam.HTTPClient`.
+func WithHTTPClient(client *http.Client) Option {
+	return func(param *ClientParam) { param.HTTPClient = client }
+}
+
+// WithLogger sets `ClientParam.Logger`.
+func WithLogger(logger Logger) Option {
+	return func(param *ClientParam) { param.Logger = logger }
+}
+
+// WithCache sets `ClientParam.CacheDir`.
+func WithCache(dir string) Option {
+	return func(param *ClientParam) { param.CacheDir = dir }
+}
+
+// WithUserAgent sets `ClientParam.UserAgent`.
+func WithUserAgent(userAgent string) Option {
+	return func(param *ClientParam) { param.UserAgent = userAgent }
+}
+
+// WithHeader adds the header to `ClientParam.Headers`.
+func WithHeader(key string, value string) Option {
+	return func(param *ClientParam) {
+		if param.Headers == nil {
+			param.Headers = make(http.Header)
+		}
+		param.Headers.Add(key, value)
+	}
+}
+
+// WithRequestHook sets `ClientParam.RequestHook`.
+func WithRequestHook(hook func(req *http.Request) error) Option {
+	return func(param *ClientParam) { param.RequestHook = hook }
+}
+
+// WithMirrors sets `ClientParam.Mirrors`.
+func WithMirrors(endpoints ...string) Option {
+	return func(param *ClientParam) { param.Mirrors = endpoints }
+}
+
+// WithDialContext sets `ClientParam.DialContext`.
+func WithDialContext(dial DialFunc) Option {
+	return func(param *ClientParam) { param.DialContext = dial }
+}
+
+// WithDNSCache sets `ClientParam.DNSCacheTTL`.
+func WithDNSCache(ttl time.Duration) Option {
+	return func(param *ClientParam) { param.DNSCacheTTL = &ttl }
+}
+
+// WithCacheMaxBytes sets `ClientParam.CacheMaxBytes`.
+func WithCacheMaxBytes(bytes int64) Option {
+	return func(param *ClientParam) { param.CacheMaxBytes = &bytes }
+}
+
+// WithOffline sets `ClientParam.Offline`.
+func WithOffline() Option {
+	return func(param *ClientParam) { param.Offline = true }
+}
+
+// WithCacheMaxAge sets `ClientParam.CacheMaxAge`.
+func WithCacheMaxAge(age time.Duration) Option {
+	return func(param *ClientParam) { param.CacheMaxAge = &age }
+}
+
+// WithIteratorLeakHandler sets `ClientParam.OnIteratorLeak`.
+func WithIteratorLeakHandler(fn func(stack string)) Option {
+	return func(param *ClientParam) { param.OnIteratorLeak = fn }
+}
+
+// WithAPIKeyVerification sets `ClientParam.VerifyAPIKey`.
+func WithAPIKeyVerification() Option {
+	return func(param *ClientParam) { param.VerifyAPIKey = true }
+}
+
+// WithAPIKeyProbe sets `ClientParam.APIKeyProbe`.
+func WithAPIKeyProbe(probe APIKeyProbe) Option {
+	return func(param *ClientParam) { param.APIKeyProbe = &probe }
+}
@@ -0,0 +1,131 @@
+package exdgo
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	httpClient := &http.Client{}
+	cli, serr := NewClient("demo",
+		WithTimeout(time.Minute),
+		WithMaxRetries(1),
+		WithRetryWait(time.Millisecond),
+		WithConcurrency(4),
+		WithHTTPClient(httpClient),
+	)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.timeout != time.Minute || cli.maxRetries != 1 || cli.retryWait != time.Millisecond || cli.concurrency != 4 || cli.httpClient != httpClient {
+		t.Fatalf("options not applied: %+v", cli)
+	}
+	if _, serr := NewClient("demo", WithConcurrency(0)); serr == nil {
+		t.Fatal("invalid option accepted")
+	}
+}
+
+func TestClientLogsRetries(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var failed int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	}
+	var buf bytes.Buffer
+	cli, serr := NewClient("demo", WithRetryWait(time.Millisecond), WithLogger(log.New(&buf, "", 0)))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cli.endpoint = srv.server.URL + "/"
+	start := time.Unix(1577836800, 0)
+	req, serr := cli.Raw(RawRequestParam{
+		Filter: map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:  start,
+		End:    start.Add(time.Minute),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	if !strings.Contains(buf.String(), "retrying") {
+		t.Fatalf("retry not logged: %q", buf.String())
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var userAgent, team, auth atomic.Value
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		team.Store(r.Header.Get("X-Team"))
+		auth.Store(r.Header.Get("Authorization"))
+		return true
+	}
+	cli, serr := NewClient("demo",
+		WithUserAgent("research-bot/1.0"),
+		WithHeader("X-Team", "quant"),
+		WithHeader("Authorization", "Bearer other"),
+	)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cli.endpoint = srv.server.URL + "/"
+	if _, serr := cli.HTTPSnapshot(SnapshotParam{
+		Exchange: "bitmex",
+		Channels: []string{"orderBookL2"},
+		At:       time.Unix(1577836800, 0),
+	}); serr != nil {
+		t.Fatal(serr)
+	}
+	if userAgent.Load() != "research-bot/1.0" || team.Load() != "quant" || auth.Load() != "Bearer demo" {
+		t.Fatalf("headers sent: %v %v %v", userAgent.Load(), team.Load(), auth.Load())
+	}
+}
+
+func TestClientRequestHook(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("X-Signature") != "signed "+r.URL.Path {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	cli, serr := NewClient("demo", WithRequestHook(func(req *http.Request) error {
+		req.Header.Set("X-Signature", "signed "+req.URL.Path)
+		return nil
+	}))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cli.endpoint = srv.server.URL + "/"
+	param := SnapshotParam{
+		Exchange: "bitmex",
+		Channels: []string{"orderBookL2"},
+		At:       time.Unix(1577836800, 0),
+	}
+	if _, serr := cli.HTTPSnapshot(param); serr != nil {
+		t.Fatal(serr)
+	}
+	cli.requestHook = func(req *http.Request) error {
+		return errors.New("no signing key")
+	}
+	if _, serr := cli.HTTPSnapshot(param); serr == nil {
+		t.Fatal("hook error ignored")
+	}
+}
@@ -0,0 +1,60 @@
+package exdgo
+
+import "fmt"
+
+// OrderError is returned by `Next` of an iterator verifying the order of lines,
+// when a line is out of order of timestamp.
+type OrderError struct {
+	// Number of lines yielded before the line
+	Index int64
+	// The last line yielded
+	Previous StructLine
+	// The line out of order, which is not yielded
+	Line StructLine
+}
+
+func (e *OrderError) Error() string {
+	var channel string
+	if e.Line.Channel != nil {
+		channel = *e.Line.Channel
+	}
+	return fmt.Sprintf("line %d (%s %s %s) at %d is out of order after line of %s at %d",
+		e.Index, e.Line.Exchange, e.Line.Type, channel, e.Line.Timestamp, e.Previous.Exchange, e.Previous.Timestamp)
+}
+
+type orderVerifyIterator struct {
+	itr     StructLineIterator
+	reverse bool
+	count   int64
+	// The last line yielded
+	previous StructLine
+}
+
+// VerifyOrder returns an iterator which checks lines from the given iterator are
+// in non-decreasing order of timestamp.
+// If a line is older than the line before, `Next` returns `*OrderError` instead of the line,
+// and the following calls continue comparing with the last line yielded.
+//
+// The iterator given is closed when the returned iterator is closed.
+func VerifyOrder(itr StructLineIterator) StructLineIterator {
+	return &orderVerifyIterator{itr: itr}
+}
+
+func (i *orderVerifyIterator) Next() (*StructLine, bool, error) {
+	line, ok, serr := i.itr.Next()
+	if !ok {
+		return nil, false, serr
+	}
+	if i.count > 0 {
+		if (!i.reverse && line.Timestamp < i.previous.Timestamp) || (i.reverse && line.Timestamp > i.previous.Timestamp) {
+			return nil, false, &OrderError{Index: i.count, Previous: i.previous, Line: *line}
+		}
+	}
+	i.previous = *line
+	i.count++
+	return line, true, nil
+}
+
+func (i *orderVerifyIterator) Close() error {
+	return i.itr.Close()
+}
@@ -0,0 +1,43 @@
+package exdgo
+
+import (
+	"testing"
+)
+
+func TestVerifyOrder(t *testing.T) {
+	lines := testLines(5, []string{"bitmex", "bitfinex"}, []string{"trades"})
+	lines[3].Timestamp = lines[1].Timestamp - 1
+	itr := VerifyOrder(newSliceIterator(lines))
+	defer itr.Close()
+	for j := 0; j < 3; j++ {
+		if _, ok, serr := itr.Next(); !ok {
+			t.Fatalf("line %d: %v", j, serr)
+		}
+	}
+	_, ok, serr := itr.Next()
+	oerr, isOrderError := serr.(*OrderError)
+	if ok || !isOrderError {
+		t.Fatalf("expected OrderError: %v", serr)
+	}
+	if oerr.Index != 3 || oerr.Line.Exchange != "bitfinex" || oerr.Previous.Timestamp != lines[2].Timestamp {
+		t.Fatalf("unexpected error: %+v", oerr)
+	}
+	// Continues from the last line yielded
+	if line, ok, serr := itr.Next(); !ok || line.Timestamp != lines[4].Timestamp {
+		t.Fatalf("line 4: %v", serr)
+	}
+}
+
+func TestReplayVerifyOrder(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{VerifyOrder: true})
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := req.StreamReverse()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	readAllStructLines(t, itr)
+}
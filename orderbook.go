@@ -0,0 +1,314 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrOrderNotFound is returned for an order id not in `OrderBookL3`.
+var ErrOrderNotFound = errors.New("order not found")
+
+// Side is the side of an order.
+type Side int
+
+const (
+	// Bid is the side of buy orders.
+	Bid Side = iota
+	// Ask is the side of sell orders.
+	Ask
+)
+
+// L3Order is an order in `OrderBookL3`.
+type L3Order struct {
+	ID    string
+	Side  Side
+	Price float64
+	Size  float64
+}
+
+// l3Level is the queue of orders at a price, the earliest first.
+type l3Level struct {
+	orders []*L3Order
+}
+
+// OrderBookL3 is an order book tracking individual orders, for channels carrying order-level data.
+// Orders at the same price are queued in time priority, so the position of an order in the queue can be queried.
+// Events of a channel are applied by `Add`, `Modify` and `Cancel` after decoding them from the messages.
+// The state can be saved and restored by `MarshalBinary` and `UnmarshalBinary`.
+// See `NewOrderBookL3Depth` to keep only the best levels.
+// Not safe for concurrent use.
+type OrderBookL3 struct {
+	orders map[string]*L3Order
+	levels [2]map[float64]*l3Level
+	// Prices of levels in the order of priority, the best first
+	prices [2][]float64
+	// Number of levels kept for each side, zero if unlimited
+	depth int
+}
+
+// NewOrderBookL3 creates an empty `OrderBookL3`.
+func NewOrderBookL3() *OrderBookL3 {
+	b := &OrderBookL3{orders: make(map[string]*L3Order)}
+	b.levels[Bid] = make(map[float64]*l3Level)
+	b.levels[Ask] = make(map[float64]*l3Level)
+	return b
+}
+
+// NewOrderBookL3Depth creates an empty `OrderBookL3` keeping only the best `depth` levels of each side,
+// which takes far less memory and CPU on deep books and is enough for studies of spreads and the best prices.
+// Orders at worse prices are dropped when added or when better levels push their level out,
+// and are not restored when the price comes back into the depth, so levels near the depth can be
+// incomplete after better levels are removed. Keep a margin beyond the levels used.
+// `Modify` and `Cancel` of dropped orders return `ErrOrderNotFound`, which can be ignored.
+func NewOrderBookL3Depth(depth int) (*OrderBookL3, error) {
+	if depth < 1 {
+		return nil, errors.New("'depth' must be positive")
+	}
+	b := NewOrderBookL3()
+	b.depth = depth
+	return b, nil
+}
+
+// better reports whether price `a` has priority over `b` on the side.
+func better(side Side, a float64, b float64) bool {
+	if side == Bid {
+		return a > b
+	}
+	return a < b
+}
+
+// Add adds a new order at the end of the queue of its price.
+func (b *OrderBookL3) Add(id string, side Side, price float64, size float64) error {
+	if side != Bid && side != Ask {
+		return fmt.Errorf("invalid side %d", side)
+	}
+	if _, ok := b.orders[id]; ok {
+		return fmt.Errorf("order %s already exists", id)
+	}
+	if !b.within(side, price) {
+		// Dropped
+		return nil
+	}
+	order := &L3Order{ID: id, Side: side, Price: price, Size: size}
+	b.orders[id] = order
+	b.enqueue(order)
+	return nil
+}
+
+// within reports whether an order at the price is kept within the depth.
+func (b *OrderBookL3) within(side Side, price float64) bool {
+	if b.depth == 0 || len(b.prices[side]) < b.depth {
+		return true
+	}
+	if _, ok := b.levels[side][price]; ok {
+		return true
+	}
+	return better(side, price, b.prices[side][b.depth-1])
+}
+
+// enqueue appends the order to the queue of its price, creating the level if needed.
+// The worst level is dropped if the new level exceeds the depth.
+func (b *OrderBookL3) enqueue(order *L3Order) {
+	level, ok := b.levels[order.Side][order.Price]
+	if !ok {
+		level = new(l3Level)
+		b.levels[order.Side][order.Price] = level
+		prices := b.prices[order.Side]
+		k := sort.Search(len(prices), func(k int) bool { return !better(order.Side, prices[k], order.Price) })
+		prices = append(prices, 0)
+		copy(prices[k+1:], prices[k:])
+		prices[k] = order.Price
+		b.prices[order.Side] = prices
+		if b.depth > 0 && len(prices) > b.depth {
+			b.dropWorst(order.Side)
+		}
+	}
+	level.orders = append(level.orders, order)
+}
+
+// dropWorst removes the worst level of the side and its orders.
+func (b *OrderBookL3) dropWorst(side Side) {
+	prices := b.prices[side]
+	worst := prices[len(prices)-1]
+	for _, o := range b.levels[side][worst].orders {
+		delete(b.orders, o.ID)
+	}
+	delete(b.levels[side], worst)
+	b.prices[side] = prices[:len(prices)-1]
+}
+
+// dequeue removes the order from the queue of its price, removing the level if it became empty.
+func (b *OrderBookL3) dequeue(order *L3Order) {
+	level := b.levels[order.Side][order.Price]
+	for k, o := range level.orders {
+		if o == order {
+			level.orders = append(level.orders[:k], level.orders[k+1:]...)
+			break
+		}
+	}
+	if len(level.orders) > 0 {
+		return
+	}
+	delete(b.levels[order.Side], order.Price)
+	prices := b.prices[order.Side]
+	k := sort.Search(len(prices), func(k int) bool { return !better(order.Side, prices[k], order.Price) })
+	b.prices[order.Side] = append(prices[:k], prices[k+1:]...)
+}
+
+// Modify changes the price and the size of the order.
+// The order keeps its position in the queue if only its size is reduced,
+// otherwise it loses the priority and moves to the end of the queue, as most exchanges do.
+func (b *OrderBookL3) Modify(id string, price float64, size float64) error {
+	order, ok := b.orders[id]
+	if !ok {
+		return fmt.Errorf("order %s: %w", id, ErrOrderNotFound)
+	}
+	if price == order.Price && size <= order.Size {
+		order.Size = size
+		return nil
+	}
+	b.dequeue(order)
+	order.Price = price
+	order.Size = size
+	if !b.within(order.Side, price) {
+		// Moved out of the depth
+		delete(b.orders, id)
+		return nil
+	}
+	b.enqueue(order)
+	return nil
+}
+
+// Cancel removes the order, also used for orders fully filled.
+func (b *OrderBookL3) Cancel(id string) error {
+	order, ok := b.orders[id]
+	if !ok {
+		return fmt.Errorf("order %s: %w", id, ErrOrderNotFound)
+	}
+	b.dequeue(order)
+	delete(b.orders, id)
+	return nil
+}
+
+// Order returns the order, or false if it is not in the book.
+func (b *OrderBookL3) Order(id string) (L3Order, bool) {
+	order, ok := b.orders[id]
+	if !ok {
+		return L3Order{}, false
+	}
+	return *order, true
+}
+
+// QueuePosition returns the number of orders and their total size ahead of the order at its price.
+func (b *OrderBookL3) QueuePosition(id string) (orders int, size float64, err error) {
+	order, ok := b.orders[id]
+	if !ok {
+		return 0, 0, fmt.Errorf("order %s: %w", id, ErrOrderNotFound)
+	}
+	for _, o := range b.levels[order.Side][order.Price].orders {
+		if o == order {
+			break
+		}
+		orders++
+		size += o.Size
+	}
+	return orders, size, nil
+}
+
+// Best returns the best price of the side and the total size at it, or false if the side is empty.
+func (b *OrderBookL3) Best(side Side) (price float64, size float64, ok bool) {
+	if len(b.prices[side]) == 0 {
+		return 0, 0, false
+	}
+	price = b.prices[side][0]
+	for _, o := range b.levels[side][price].orders {
+		size += o.Size
+	}
+	return price, size, true
+}
+
+// Level returns copies of orders at the price of the side in the order of priority.
+func (b *OrderBookL3) Level(side Side, price float64) []L3Order {
+	level, ok := b.levels[side][price]
+	if !ok {
+		return nil
+	}
+	orders := make([]L3Order, len(level.orders))
+	for k, o := range level.orders {
+		orders[k] = *o
+	}
+	return orders
+}
+
+// Prices returns prices of levels of the side, the best first.
+func (b *OrderBookL3) Prices(side Side) []float64 {
+	prices := make([]float64, len(b.prices[side]))
+	copy(prices, b.prices[side])
+	return prices
+}
+
+// orderBookL3State is the state of `OrderBookL3` serialized by `MarshalBinary`.
+type orderBookL3State struct {
+	Version int `json:"version"`
+	// Zero if unlimited
+	Depth int `json:"depth,omitempty"`
+	// Orders of bids and then asks, each in the order of priority
+	Orders []l3OrderState `json:"orders"`
+}
+
+type l3OrderState struct {
+	ID    string  `json:"id"`
+	Side  Side    `json:"side"`
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// Version of the serialized state, incremented on incompatible changes
+const orderBookL3StateVersion = 1
+
+// MarshalBinary serializes the full state of the book including positions of orders in queues,
+// so a long backtest can save it with the position of the stream such as `Checkpoint`,
+// and resume from there by `UnmarshalBinary` instead of replaying from the last snapshot.
+func (b *OrderBookL3) MarshalBinary() ([]byte, error) {
+	state := orderBookL3State{
+		Version: orderBookL3StateVersion,
+		Depth:   b.depth,
+		Orders:  make([]l3OrderState, 0, len(b.orders)),
+	}
+	for _, side := range []Side{Bid, Ask} {
+		for _, price := range b.prices[side] {
+			for _, o := range b.levels[side][price].orders {
+				state.Orders = append(state.Orders, l3OrderState{ID: o.ID, Side: o.Side, Price: o.Price, Size: o.Size})
+			}
+		}
+	}
+	return json.Marshal(state)
+}
+
+// UnmarshalBinary restores the state serialized by `MarshalBinary`, replacing the current state of the book
+// including its depth.
+// The book is left unchanged if an error is returned.
+func (b *OrderBookL3) UnmarshalBinary(data []byte) error {
+	var state orderBookL3State
+	if serr := json.Unmarshal(data, &state); serr != nil {
+		return fmt.Errorf("order book state: %v", serr)
+	}
+	if state.Version != orderBookL3StateVersion {
+		return fmt.Errorf("order book state: unsupported version %d", state.Version)
+	}
+	if state.Depth < 0 {
+		return fmt.Errorf("order book state: invalid depth %d", state.Depth)
+	}
+	restored := NewOrderBookL3()
+	restored.depth = state.Depth
+	for _, o := range state.Orders {
+		// Adding in the order of priority restores queues as they were
+		if serr := restored.Add(o.ID, o.Side, o.Price, o.Size); serr != nil {
+			return fmt.Errorf("order book state: %v", serr)
+		}
+	}
+	*b = *restored
+	return nil
+}
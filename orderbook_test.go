@@ -0,0 +1,196 @@
+package exdgo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOrderBookL3(t *testing.T) {
+	book := NewOrderBookL3()
+	adds := []L3Order{
+		{"a", Bid, 100, 1},
+		{"b", Bid, 101, 2},
+		{"c", Bid, 100, 3},
+		{"d", Bid, 100, 4},
+		{"e", Ask, 103, 1},
+		{"f", Ask, 102, 5},
+	}
+	for _, o := range adds {
+		if serr := book.Add(o.ID, o.Side, o.Price, o.Size); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := book.Add("a", Ask, 1, 1); serr == nil {
+		t.Fatal("duplicated order added")
+	}
+	if price, size, ok := book.Best(Bid); !ok || price != 101 || size != 2 {
+		t.Fatalf("best bid %v %v %v", price, size, ok)
+	}
+	if price, _, _ := book.Best(Ask); price != 102 {
+		t.Fatalf("best ask %v", price)
+	}
+	if orders, size, serr := book.QueuePosition("d"); serr != nil || orders != 2 || size != 4 {
+		t.Fatalf("position of d %d %v %v", orders, size, serr)
+	}
+	// Reducing size keeps the priority
+	if serr := book.Modify("a", 100, 0.5); serr != nil {
+		t.Fatal(serr)
+	}
+	if orders, size, _ := book.QueuePosition("d"); orders != 2 || size != 3.5 {
+		t.Fatalf("position of d %d %v", orders, size)
+	}
+	// Increasing size loses the priority
+	if serr := book.Modify("c", 100, 10); serr != nil {
+		t.Fatal(serr)
+	}
+	if orders, _, _ := book.QueuePosition("c"); orders != 2 {
+		t.Fatalf("position of c %d", orders)
+	}
+	if serr := book.Cancel("b"); serr != nil {
+		t.Fatal(serr)
+	}
+	if price, size, _ := book.Best(Bid); price != 100 || size != 14.5 {
+		t.Fatalf("best bid %v %v", price, size)
+	}
+	if prices := book.Prices(Ask); len(prices) != 2 || prices[0] != 102 || prices[1] != 103 {
+		t.Fatalf("ask prices %v", prices)
+	}
+	// Moving to another price
+	if serr := book.Modify("f", 104, 5); serr != nil {
+		t.Fatal(serr)
+	}
+	if prices := book.Prices(Ask); len(prices) != 2 || prices[0] != 103 || prices[1] != 104 {
+		t.Fatalf("ask prices %v", prices)
+	}
+	level := book.Level(Bid, 100)
+	if len(level) != 3 || level[0].ID != "a" || level[1].ID != "d" || level[2].ID != "c" {
+		t.Fatalf("level %v", level)
+	}
+	if serr := book.Cancel("x"); !errors.Is(serr, ErrOrderNotFound) {
+		t.Fatalf("expected ErrOrderNotFound, got %v", serr)
+	}
+}
+
+func TestOrderBookL3MarshalBinary(t *testing.T) {
+	book := NewOrderBookL3()
+	adds := []L3Order{
+		{"a", Bid, 100, 1},
+		{"b", Bid, 101, 2},
+		{"c", Bid, 100, 3},
+		{"d", Ask, 102.5, 0.1},
+	}
+	for _, o := range adds {
+		if serr := book.Add(o.ID, o.Side, o.Price, o.Size); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	// Moves a behind c
+	if serr := book.Modify("a", 100, 5); serr != nil {
+		t.Fatal(serr)
+	}
+	data, serr := book.MarshalBinary()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	restored := NewOrderBookL3()
+	if serr := restored.UnmarshalBinary(data); serr != nil {
+		t.Fatal(serr)
+	}
+	for _, side := range []Side{Bid, Ask} {
+		prices := restored.Prices(side)
+		if !reflect.DeepEqual(prices, book.Prices(side)) {
+			t.Fatalf("prices %v", prices)
+		}
+		for _, price := range prices {
+			if level := restored.Level(side, price); !reflect.DeepEqual(level, book.Level(side, price)) {
+				t.Fatalf("level %v", level)
+			}
+		}
+	}
+	if orders, size, serr := restored.QueuePosition("a"); serr != nil || orders != 1 || size != 3 {
+		t.Fatalf("position of a %d %v %v", orders, size, serr)
+	}
+	// Restored book is independent of the original
+	if serr := restored.Cancel("b"); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok := book.Order("b"); !ok {
+		t.Fatal("original book changed")
+	}
+	if serr := restored.UnmarshalBinary([]byte(`{"version":1,"orders":[{"id":"x","side":0},{"id":"x","side":1}]}`)); serr == nil {
+		t.Fatal("duplicated order restored")
+	}
+	if _, ok := restored.Order("a"); !ok {
+		t.Fatal("book changed by failed restore")
+	}
+}
+
+func TestOrderBookL3Depth(t *testing.T) {
+	if _, serr := NewOrderBookL3Depth(0); serr == nil {
+		t.Fatal("zero depth accepted")
+	}
+	book, serr := NewOrderBookL3Depth(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	adds := []L3Order{
+		{"a", Bid, 100, 1},
+		{"b", Bid, 99, 1},
+		// Dropped as it is out of the depth
+		{"c", Bid, 98, 1},
+		// Joins a level kept
+		{"d", Bid, 99, 2},
+		// Pushes the level of b and d out
+		{"e", Bid, 101, 1},
+		{"f", Ask, 102, 1},
+	}
+	for _, o := range adds {
+		if serr := book.Add(o.ID, o.Side, o.Price, o.Size); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if prices := book.Prices(Bid); !reflect.DeepEqual(prices, []float64{101, 100}) {
+		t.Fatalf("bid prices %v", prices)
+	}
+	for _, id := range []string{"b", "c", "d"} {
+		if serr := book.Cancel(id); !errors.Is(serr, ErrOrderNotFound) {
+			t.Fatalf("order %s was not dropped: %v", id, serr)
+		}
+	}
+	// Moving out of the depth drops the order
+	if serr := book.Add("g", Bid, 100, 1); serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := book.Modify("a", 97, 1); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok := book.Order("a"); ok {
+		t.Fatal("order moved out of the depth was kept")
+	}
+	// The level of the price comes back into the depth
+	if serr := book.Cancel("g"); serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := book.Add("h", Bid, 97, 1); serr != nil {
+		t.Fatal(serr)
+	}
+	if prices := book.Prices(Bid); !reflect.DeepEqual(prices, []float64{101, 97}) {
+		t.Fatalf("bid prices %v", prices)
+	}
+	// Depth is kept by serialization
+	data, serr := book.MarshalBinary()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	restored := NewOrderBookL3()
+	if serr := restored.UnmarshalBinary(data); serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := restored.Add("i", Bid, 96, 1); serr != nil {
+		t.Fatal(serr)
+	}
+	if prices := restored.Prices(Bid); !reflect.DeepEqual(prices, []float64{101, 97}) {
+		t.Fatalf("restored bid prices %v", prices)
+	}
+}
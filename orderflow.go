@@ -0,0 +1,111 @@
+package exdgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OrderFlowParam is the parameters for `DeriveOrderFlow`.
+type OrderFlowParam struct {
+	// Returns the size and the side of the aggressor if the line is a trade,
+	// `Bid` if the taker bought and `Ask` if the taker sold.
+	Trade func(line *StructLine) (size float64, aggressor Side, ok bool)
+	// Returns the key to accumulate flow separately for, such as the symbol of the instrument.
+	// Optional, the exchange of the line by default.
+	Key func(line *StructLine) string
+}
+
+// OrderFlow is the message of a line of `LineTypeOrderFlow`, the flow of a key after a trade.
+type OrderFlow struct {
+	Key string
+	// Size of the trade, positive if the aggressor bought and negative if sold.
+	Delta float64
+	// Cumulative volume delta, the sum of `Delta` of trades of the key so far.
+	CVD float64
+	// Volume and number of trades the aggressor bought so far.
+	BuyVolume float64
+	BuyTrades int64
+	// Volume and number of trades the aggressor sold so far.
+	SellVolume float64
+	SellTrades int64
+}
+
+type orderFlowIterator struct {
+	source StructLineIterator
+	param  OrderFlowParam
+	// Flow so far of each key
+	flows map[string]OrderFlow
+	// Line of the flow to yield after the trade, nil if none
+	pending *StructLine
+	flow    StructLine
+	closed  bool
+}
+
+// DeriveOrderFlow returns an iterator yielding lines from `itr`, each trade followed by a line of `LineTypeOrderFlow`
+// with the cumulative volume delta and the volume of each aggressor side of its key, as inputs for
+// flow-based research.
+// Flows are accumulated from the start of the stream.
+//
+// The iterator given is closed when the returned iterator is closed.
+func DeriveOrderFlow(itr StructLineIterator, param OrderFlowParam) (StructLineIterator, error) {
+	if param.Trade == nil {
+		return nil, errors.New("'Trade' is required")
+	}
+	if param.Key == nil {
+		param.Key = func(line *StructLine) string { return line.Exchange }
+	}
+	return &orderFlowIterator{source: itr, param: param, flows: make(map[string]OrderFlow)}, nil
+}
+
+func (i *orderFlowIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	if i.pending != nil {
+		line := i.pending
+		i.pending = nil
+		return line, true, nil
+	}
+	line, ok, serr := i.source.Next()
+	if !ok || line.Type != LineTypeMessage {
+		return line, ok, serr
+	}
+	size, aggressor, isTrade := i.param.Trade(line)
+	if !isTrade {
+		return line, true, nil
+	}
+	key := i.param.Key(line)
+	flow := i.flows[key]
+	flow.Key = key
+	switch aggressor {
+	case Bid:
+		flow.Delta = size
+		flow.BuyVolume += size
+		flow.BuyTrades++
+	case Ask:
+		flow.Delta = -size
+		flow.SellVolume += size
+		flow.SellTrades++
+	default:
+		return nil, false, fmt.Errorf("invalid side %d of the aggressor", aggressor)
+	}
+	flow.CVD += flow.Delta
+	i.flows[key] = flow
+	i.flow = StructLine{
+		Exchange:  line.Exchange,
+		Type:      LineTypeOrderFlow,
+		Timestamp: line.Timestamp,
+		Channel:   line.Channel,
+		Message:   &flow,
+	}
+	i.pending = &i.flow
+	return line, true, nil
+}
+
+func (i *orderFlowIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
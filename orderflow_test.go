@@ -0,0 +1,75 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeriveOrderFlow(t *testing.T) {
+	trade := "trade"
+	quote := "quote"
+	symbols := []string{"XBTUSD", "ETHUSD", "XBTUSD", "XBTUSD"}
+	sides := []string{"Buy", "Sell", "Sell", "Buy"}
+	sizes := []float64{3, 2, 5, 1}
+	lines := make([]StructLine, 0)
+	for k := range symbols {
+		lines = append(lines, StructLine{
+			Exchange:  "bitmex",
+			Type:      LineTypeMessage,
+			Timestamp: int64(k) * int64(time.Second),
+			Channel:   &trade,
+			Message:   map[string]interface{}{"symbol": symbols[k], "side": sides[k], "size": sizes[k]},
+		}, StructLine{
+			Exchange:  "bitmex",
+			Type:      LineTypeMessage,
+			Timestamp: int64(k) * int64(time.Second),
+			Channel:   &quote,
+			Message:   map[string]interface{}{"symbol": symbols[k]},
+		})
+	}
+	itr, serr := DeriveOrderFlow(newSliceIterator(lines), OrderFlowParam{
+		Trade: func(line *StructLine) (float64, Side, bool) {
+			if *line.Channel != "trade" {
+				return 0, 0, false
+			}
+			msg := line.Message.(map[string]interface{})
+			side := Bid
+			if msg["side"] == "Sell" {
+				side = Ask
+			}
+			return msg["size"].(float64), side, true
+		},
+		Key: func(line *StructLine) string { return line.Message.(map[string]interface{})["symbol"].(string) },
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	yielded := readAllStructLines(t, itr)
+	if len(yielded) != len(lines)+len(symbols) {
+		t.Fatalf("%d lines yielded", len(yielded))
+	}
+	var flows []*OrderFlow
+	for k, line := range yielded {
+		if line.Type != LineTypeOrderFlow {
+			continue
+		}
+		if *yielded[k-1].Channel != "trade" || yielded[k-1].Timestamp != line.Timestamp {
+			t.Fatalf("flow yielded after %+v", yielded[k-1])
+		}
+		flows = append(flows, line.Message.(*OrderFlow))
+	}
+	expected := []OrderFlow{
+		{Key: "XBTUSD", Delta: 3, CVD: 3, BuyVolume: 3, BuyTrades: 1},
+		{Key: "ETHUSD", Delta: -2, CVD: -2, SellVolume: 2, SellTrades: 1},
+		{Key: "XBTUSD", Delta: -5, CVD: -2, BuyVolume: 3, BuyTrades: 1, SellVolume: 5, SellTrades: 1},
+		{Key: "XBTUSD", Delta: 1, CVD: -1, BuyVolume: 4, BuyTrades: 2, SellVolume: 5, SellTrades: 1},
+	}
+	for k, e := range expected {
+		if *flows[k] != e {
+			t.Fatalf("flow %d: %+v, expected %+v", k, *flows[k], e)
+		}
+	}
+	if _, serr := DeriveOrderFlow(newSliceIterator(lines), OrderFlowParam{}); serr == nil {
+		t.Fatal("missing 'Trade' accepted")
+	}
+}
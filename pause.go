@@ -0,0 +1,87 @@
+package exdgo
+
+import (
+	"context"
+	"sync"
+)
+
+// Pausable is implemented by iterators returned by `Stream` methods of requests.
+// Use a type assertion to get it, as iterators wrapping them do not implement it.
+// Safe to call from goroutines other than the one reading the iterator.
+type Pausable interface {
+	// Pause stops starting downloads of shards in background, keeping the position of the iterator.
+	// Downloads in flight are completed, and lines already downloaded are still yielded.
+	// When the reader needs a shard not yet downloaded, only that shard is downloaded.
+	Pause()
+	// Resume restarts downloads stopped by `Pause`.
+	Resume()
+}
+
+// pauseControl is shared by shard iterators of a stream to pause them at once.
+type pauseControl struct {
+	mutex  sync.Mutex
+	paused bool
+	// Wakes background goroutines of shard iterators
+	wakes map[chan struct{}]struct{}
+}
+
+type pauseControlKey struct{}
+
+// withPauseControl returns the context carrying a new control, which shard iterators created on it follow.
+func withPauseControl(ctx context.Context) (context.Context, *pauseControl) {
+	control := &pauseControl{wakes: make(map[chan struct{}]struct{})}
+	return context.WithValue(ctx, pauseControlKey{}, control), control
+}
+
+// pauseControlFrom returns the control in the context, or nil if none.
+func pauseControlFrom(ctx context.Context) *pauseControl {
+	control, _ := ctx.Value(pauseControlKey{}).(*pauseControl)
+	return control
+}
+
+// register returns the channel notified when the stream is resumed.
+func (c *pauseControl) register() chan struct{} {
+	wake := make(chan struct{}, 1)
+	c.mutex.Lock()
+	c.wakes[wake] = struct{}{}
+	c.mutex.Unlock()
+	return wake
+}
+
+func (c *pauseControl) unregister(wake chan struct{}) {
+	c.mutex.Lock()
+	delete(c.wakes, wake)
+	c.mutex.Unlock()
+}
+
+func (c *pauseControl) isPaused() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.paused
+}
+
+func (c *pauseControl) Pause() {
+	c.mutex.Lock()
+	c.paused = true
+	c.mutex.Unlock()
+}
+
+func (c *pauseControl) Resume() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paused = false
+	for wake := range c.wakes {
+		notify(wake)
+	}
+}
+
+// notify sends to the channel buffering one notification without blocking.
+func notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
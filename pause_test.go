@@ -0,0 +1,74 @@
+package exdgo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamPause(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// Waits for requests in flight to complete
+	settle := func() int64 {
+		last := atomic.LoadInt64(&srv.requests)
+		for {
+			time.Sleep(20 * time.Millisecond)
+			now := atomic.LoadInt64(&srv.requests)
+			if now == last {
+				return now
+			}
+			last = now
+		}
+	}
+	before := settle()
+	itr, serr := req.StreamWithContext(context.Background(), 2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	pausable, ok := itr.(Pausable)
+	if !ok {
+		t.Fatal("stream not pausable")
+	}
+	pausable.Pause()
+	paused := settle()
+	// A snapshot and 10 minutes for each exchange
+	if paused-before >= 2*11 {
+		t.Fatal("all shards downloaded")
+	}
+	if settle() != paused {
+		t.Fatal("downloaded while paused")
+	}
+	// Shards needed are downloaded while paused
+	read := 0
+	for read < len(expected)/2 {
+		if _, _, serr := itr.Next(); serr != nil {
+			t.Fatal(serr)
+		}
+		read++
+	}
+	pausable.Resume()
+	for {
+		_, ok, serr := itr.Next()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if !ok {
+			break
+		}
+		read++
+	}
+	if read != len(expected) {
+		t.Fatalf("%d lines read, expected %d", read, len(expected))
+	}
+	if downloaded := settle() - before; downloaded != 2*11 {
+		t.Fatalf("%d shards downloaded", downloaded)
+	}
+}
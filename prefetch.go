@@ -0,0 +1,31 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Prefetch downloads data of the filter from `start` to `end` into the cache without yielding lines,
+// so later requests of `Replay` for them are served from the cache.
+// Data is downloaded in json format, which `Replay` uses.
+// Responses the server sent without `ETag` or `Last-Modified` are also stored, and used without revalidation.
+// It requires `CacheDir` of `ClientParam`, and blocks until all of data is stored;
+// call it in another goroutine to prefetch in background.
+func (c *Client) Prefetch(ctx context.Context, filter map[string][]string, start time.Time, end time.Time) error {
+	if c.cache == nil {
+		return errors.New("'CacheDir' is required to prefetch")
+	}
+	format := "json"
+	req, serr := setupRawRequest(c, RawRequestParam{
+		Filter: filter,
+		Start:  start,
+		End:    end,
+		Format: &format,
+	})
+	if serr != nil {
+		return serr
+	}
+	// Shards are streamed instead of downloaded at once, so they are not kept in memory
+	return req.DownloadFunc(withCachePin(ctx), func(line *StringLine) error { return nil })
+}
@@ -0,0 +1,84 @@
+package exdgo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var full int64
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		}
+		atomic.AddInt64(&full, 1)
+		w.Header().Set("ETag", `"v1"`)
+		return true
+	}
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	start := time.Unix(1577836800, 0)
+	cli := req.raw.cli
+	if serr := cli.Prefetch(context.Background(), req.raw.filter, start, start.Add(10*time.Minute)); serr == nil {
+		t.Fatal("prefetched without cache")
+	}
+	cli.cache, serr = newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := cli.Prefetch(context.Background(), req.raw.filter, start, start.Add(10*time.Minute)); serr != nil {
+		t.Fatal(serr)
+	}
+	prefetched := atomic.LoadInt64(&full)
+	if prefetched == 0 {
+		t.Fatal("nothing downloaded")
+	}
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	if downloaded := atomic.LoadInt64(&full) - prefetched; downloaded != 0 {
+		t.Fatalf("%d responses downloaded again", downloaded)
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestPrefetchWithoutValidators(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	defer os.RemoveAll(dir)
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	start := time.Unix(1577836800, 0)
+	cli := req.raw.cli
+	cli.cache, serr = newCache(dir, 0, 0)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// The fake server sends neither ETag nor Last-Modified
+	if serr := cli.Prefetch(context.Background(), req.raw.filter, start, start.Add(10*time.Minute)); serr != nil {
+		t.Fatal(serr)
+	}
+	sent := atomic.LoadInt64(&srv.requests)
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	if requests := atomic.LoadInt64(&srv.requests) - sent; requests != 0 {
+		t.Fatalf("%d requests sent for prefetched data", requests)
+	}
+	checkGoroutineLeak(t)
+}
@@ -0,0 +1,94 @@
+package exdgo
+
+import "time"
+
+// Progress is a snapshot of the progress of a download, with the estimation of the rest.
+type Progress struct {
+	// Number of shards downloaded, a shard is a snapshot or a minute of an exchange.
+	CompletedShards int
+	TotalShards     int
+	// Size of messages downloaded so far.
+	Bytes int64
+	// Time since the download started.
+	Elapsed time.Duration
+	// Estimated time until all shards are downloaded, based on the throughput observed so far.
+	// Zero if no shard is downloaded yet.
+	Remaining time.Duration
+	// Estimated size of messages yet to be downloaded, based on the average size of downloaded shards.
+	RemainingBytes int64
+}
+
+// progressTracker accumulates the progress of a download and reports it.
+// Not safe for concurrent use.
+type progressTracker struct {
+	callback func(p Progress)
+	started  time.Time
+	progress Progress
+}
+
+func newProgressTracker(total int, callback func(p Progress)) *progressTracker {
+	t := new(progressTracker)
+	t.callback = callback
+	t.started = time.Now()
+	t.progress.TotalShards = total
+	return t
+}
+
+// shardDone records a downloaded shard and reports the progress.
+func (t *progressTracker) shardDone(lines []StringLine) {
+	if t == nil {
+		return
+	}
+	p := &t.progress
+	p.CompletedShards++
+	for i := range lines {
+		p.Bytes += int64(len(lines[i].Message))
+	}
+	p.Elapsed = time.Since(t.started)
+	rest := int64(p.TotalShards - p.CompletedShards)
+	p.Remaining = time.Duration(int64(p.Elapsed) / int64(p.CompletedShards) * rest)
+	p.RemainingBytes = p.Bytes / int64(p.CompletedShards) * rest
+	t.callback(*p)
+}
+
+// shardNotifier reports shards read through by the reader of a stream to `OnShard`.
+// A shard is reported at the next call of `Next` after its last line was yielded,
+// so lines of the shard are all handled when it is reported. Methods do nothing on nil.
+type shardNotifier struct {
+	fn      func(exchange string, minute time.Time, lines, bytes int)
+	pending []shardReport
+}
+
+// shardReport is a shard waiting to be reported.
+type shardReport struct {
+	exchange string
+	minute   int64
+	lines    int
+	bytes    int
+}
+
+func newShardNotifier(fn func(exchange string, minute time.Time, lines, bytes int)) *shardNotifier {
+	if fn == nil {
+		return nil
+	}
+	return &shardNotifier{fn: fn}
+}
+
+// add records the shard of the minute, in minutes since the unix epoch, read through.
+func (n *shardNotifier) add(exchange string, minute int64, lines int, bytes int64) {
+	if n == nil {
+		return
+	}
+	n.pending = append(n.pending, shardReport{exchange, minute, lines, int(bytes)})
+}
+
+// flush reports shards recorded.
+func (n *shardNotifier) flush() {
+	if n == nil {
+		return
+	}
+	for _, report := range n.pending {
+		n.fn(report.exchange, time.Unix(report.minute*60, 0).UTC(), report.lines, report.bytes)
+	}
+	n.pending = n.pending[:0]
+}
@@ -0,0 +1,135 @@
+package exdgo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadProgress(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	reports := make([]Progress, 0)
+	req.onProgress = func(p Progress) {
+		reports = append(reports, p)
+	}
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	// 2 exchanges * (snapshot + 10 minutes)
+	if len(reports) != 2*11 {
+		t.Fatalf("len(reports) = %d", len(reports))
+	}
+	for i, p := range reports {
+		if p.CompletedShards != i+1 || p.TotalShards != 2*11 {
+			t.Fatalf("report %d: %+v", i, p)
+		}
+		if i > 0 && p.Bytes < reports[i-1].Bytes {
+			t.Fatalf("bytes decreased: %+v", p)
+		}
+	}
+	last := reports[len(reports)-1]
+	if last.Remaining != 0 || last.RemainingBytes != 0 || last.Bytes == 0 {
+		t.Fatalf("last report: %+v", last)
+	}
+}
+
+func TestProgressTrackerEstimation(t *testing.T) {
+	var last Progress
+	tracker := newProgressTracker(4, func(p Progress) { last = p })
+	tracker.shardDone([]StringLine{{Message: make([]byte, 100)}})
+	if last.RemainingBytes != 300 {
+		t.Fatalf("RemainingBytes = %d", last.RemainingBytes)
+	}
+	if last.Remaining != last.Elapsed*3 {
+		t.Fatalf("Remaining = %v, Elapsed = %v", last.Remaining, last.Elapsed)
+	}
+}
+
+func TestOnShard(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	type report struct {
+		exchange string
+		minute   time.Time
+		lines    int
+		// Lines of the minute yielded when reported
+		yielded int
+	}
+	var reports []report
+	yielded := make(map[string]int)
+	key := func(exchange string, minute time.Time) string {
+		return exchange + minute.Format(time.RFC3339)
+	}
+	onShard := func(exchange string, minute time.Time, lines, bytes int) {
+		if bytes <= 0 && lines > 0 {
+			t.Fatalf("%d bytes reported for %d lines", bytes, lines)
+		}
+		reports = append(reports, report{exchange, minute, lines, yielded[key(exchange, minute)]})
+	}
+	yield := func(exchange string, timestamp int64) {
+		yielded[key(exchange, time.Unix(0, timestamp).Truncate(time.Minute).UTC())]++
+	}
+	check := func(name string, atBoundary bool) {
+		t.Helper()
+		if len(reports) != 2*10 {
+			t.Fatalf("%s: %d shards reported", name, len(reports))
+		}
+		for _, r := range reports {
+			if r.lines != 6 || (atBoundary && r.yielded != r.lines) {
+				t.Fatalf("%s: %+v reported", name, r)
+			}
+		}
+		reports = nil
+		yielded = make(map[string]int)
+	}
+
+	raw := prepareFakeRawRequest(t, srv)
+	raw.onShard = onShard
+	if _, serr := raw.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	check("download", false)
+	for _, reverse := range []bool{false, true} {
+		itr, serr := raw.stream(context.Background(), 2, reverse)
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		for {
+			line, ok, serr := itr.Next()
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			if !ok {
+				break
+			}
+			// Snapshots are not in shards reported
+			if !strings.Contains(string(line.Message), "snapshot") {
+				yield(line.Exchange, line.Timestamp)
+			}
+		}
+		itr.Close()
+		check("raw stream", true)
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{OnShard: onShard})
+	for _, stream := range []func() (StructLineIterator, error){req.Stream, req.StreamReverse} {
+		itr, serr := stream()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		for {
+			line, ok, serr := itr.Next()
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			if !ok {
+				break
+			}
+			yield(line.Exchange, line.Timestamp)
+		}
+		itr.Close()
+		check("replay stream", true)
+	}
+}
@@ -0,0 +1,199 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// QualityParam is the parameters of `QualityReport`.
+type QualityParam struct {
+	// A channel without messages for longer than this is reported as a gap.
+	// Optional, defaults to 1 minute.
+	MinGap *time.Duration
+}
+
+// DataQuality is the health of data in a range made by `QualityReport`.
+type DataQuality struct {
+	// Range of the request in nanoseconds since the unix epoch, `End` is exclusive.
+	Start int64
+	End   int64
+	// Number of message lines for each exchange and channel.
+	Counts LineCounts
+	// Number of message lines of each minute, `map[exchange]map[channel][]count`,
+	// indexed by minutes from the one `Start` is in.
+	Minutes map[string]map[string][]int64
+	// Number of error lines for each exchange.
+	Errors map[string]int64
+	// Periods without messages longer than `QualityParam.MinGap`, in order of exchanges and time.
+	Gaps []Gap
+	// Definitions of channels changed in the range, in order of time.
+	DefinitionChanges []DefinitionChangeAt
+}
+
+// Gap is a period a channel had no message.
+type Gap struct {
+	Exchange string
+	Channel  string
+	// Timestamp of the last message before the gap, or the start of the range.
+	Start int64
+	// Timestamp of the first message after the gap, or the end of the range.
+	End int64
+}
+
+// DefinitionChangeAt is a change of the definition of a channel found by `QualityReport`.
+type DefinitionChangeAt struct {
+	Exchange  string
+	Channel   string
+	Timestamp int64
+	DefinitionChange
+}
+
+// qualityCollector builds `DataQuality` from lines given in order.
+type qualityCollector struct {
+	report *DataQuality
+	minGap int64
+	// Timestamp of the last message of each channel in the current range, map[exchange]map[channel]timestamp
+	lasts map[string]map[string]int64
+	// Last definition of each channel, map[exchange]map[channel]definition
+	defs map[string]map[string]map[string]string
+}
+
+// startRange starts tracking gaps in the range of a request, channels in the filter are expected to have messages.
+func (c *qualityCollector) startRange(req *RawRequest) {
+	c.lasts = make(map[string]map[string]int64, len(req.filter))
+	for exchange, channels := range req.filter {
+		c.lasts[exchange] = make(map[string]int64, len(channels))
+		for _, channel := range channels {
+			c.lasts[exchange][channel] = req.start
+		}
+	}
+}
+
+// endRange reports channels without messages until the end of the range as gaps.
+func (c *qualityCollector) endRange(req *RawRequest) {
+	for exchange, channels := range c.lasts {
+		for channel, last := range channels {
+			c.addGap(exchange, channel, last, req.end)
+		}
+	}
+}
+
+func (c *qualityCollector) addGap(exchange string, channel string, start int64, end int64) {
+	if end-start > c.minGap {
+		c.report.Gaps = append(c.report.Gaps, Gap{exchange, channel, start, end})
+	}
+}
+
+// addDefinition records the definition of a channel sent in the line.
+func (c *qualityCollector) addDefinition(line *StringLine, def map[string]string) {
+	channels, ok := c.defs[line.Exchange]
+	if !ok {
+		channels = make(map[string]map[string]string)
+		c.defs[line.Exchange] = channels
+	}
+	old, ok := channels[*line.Channel]
+	channels[*line.Channel] = def
+	if ok && !reflect.DeepEqual(old, def) {
+		c.report.DefinitionChanges = append(c.report.DefinitionChanges, DefinitionChangeAt{
+			Exchange:         line.Exchange,
+			Channel:          *line.Channel,
+			Timestamp:        line.Timestamp,
+			DefinitionChange: DefinitionChange{Old: old, New: def},
+		})
+	}
+}
+
+// addMessage counts a message line.
+func (c *qualityCollector) addMessage(line *StringLine) {
+	exchange, channel := line.Exchange, *line.Channel
+	c.report.Counts.add(exchange, channel)
+	channels, ok := c.report.Minutes[exchange]
+	if !ok {
+		channels = make(map[string][]int64)
+		c.report.Minutes[exchange] = channels
+	}
+	minutes, ok := channels[channel]
+	if !ok {
+		startMinute := c.report.Start / int64(time.Minute)
+		minutes = make([]int64, (c.report.End-1)/int64(time.Minute)-startMinute+1)
+		channels[channel] = minutes
+	}
+	// Corrected timestamps can be out of the range
+	if index := line.Timestamp/int64(time.Minute) - c.report.Start/int64(time.Minute); index >= 0 && index < int64(len(minutes)) {
+		minutes[index]++
+	}
+	if _, ok := c.lasts[exchange]; !ok {
+		c.lasts[exchange] = make(map[string]int64)
+	}
+	last, ok := c.lasts[exchange][channel]
+	if ok {
+		c.addGap(exchange, channel, last, line.Timestamp)
+	}
+	c.lasts[exchange][channel] = line.Timestamp
+}
+
+// QualityReport downloads the range of the request and reports line counts of each channel per minute,
+// error lines, gaps and changes of definitions, to assess the health of data before using it.
+// With `Ranges`, gaps between ranges are not reported.
+// Lines are not kept, so it uses memory only for shards being downloaded.
+func QualityReport(ctx context.Context, req *ReplayRequest, param QualityParam) (*DataQuality, error) {
+	minGap := time.Minute
+	if param.MinGap != nil {
+		if *param.MinGap <= 0 {
+			return nil, errors.New("'MinGap' must be positive")
+		}
+		minGap = *param.MinGap
+	}
+	c := &qualityCollector{
+		report: &DataQuality{
+			Start:   req.raw.start,
+			End:     req.raw.end,
+			Counts:  make(LineCounts),
+			Minutes: make(map[string]map[string][]int64),
+			Errors:  make(map[string]int64),
+		},
+		minGap: int64(minGap),
+		defs:   make(map[string]map[string]map[string]string),
+	}
+	requests := req.segments
+	if len(requests) == 0 {
+		requests = []*ReplayRequest{req}
+	}
+	for _, segment := range requests {
+		c.startRange(segment.raw)
+		processor := newRawLineProcessor()
+		serr := segment.raw.DownloadFunc(ctx, func(line *StringLine) error {
+			def, skip, serr := processor.track(line)
+			if serr != nil {
+				return serr
+			}
+			switch {
+			case skip:
+				c.addDefinition(line, def)
+			case line.Type == LineTypeMessage:
+				c.addMessage(line)
+			case line.Type == LineTypeError:
+				c.report.Errors[line.Exchange]++
+			}
+			return nil
+		})
+		if serr != nil {
+			return nil, serr
+		}
+		c.endRange(segment.raw)
+	}
+	gaps := c.report.Gaps
+	sort.Slice(gaps, func(a, b int) bool {
+		if gaps[a].Exchange != gaps[b].Exchange {
+			return gaps[a].Exchange < gaps[b].Exchange
+		}
+		if gaps[a].Start != gaps[b].Start {
+			return gaps[a].Start < gaps[b].Start
+		}
+		return gaps[a].Channel < gaps[b].Channel
+	})
+	return c.report, nil
+}
@@ -0,0 +1,58 @@
+package exdgo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestQualityReport(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	changed := start.Add(2 * time.Minute)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != fmt.Sprintf("/filter/bitmex/%d", changed.Unix()/60) {
+			return true
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "err\t%d\t%s\n", changed.UnixNano(), `{"reason":"disconnected"}`)
+		fmt.Fprintf(w, "start\t%d\t\n", changed.UnixNano())
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano(), `{"price":"string"}`)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", changed.UnixNano()+1, `{"price":"1.5"}`)
+		return false
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	report, serr := QualityReport(context.Background(), req, QualityParam{})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	minutes := report.Minutes["bitmex"]["orderBookL2_XBTUSD"]
+	if len(minutes) != 10 || minutes[1] != 6 || minutes[2] != 1 {
+		t.Fatalf("lines per minute %v", minutes)
+	}
+	if report.Counts["bitmex"]["orderBookL2_XBTUSD"] != 6*9+1 || report.Counts["bitfinex"]["trades_tBTCUSD"] != 6*10 {
+		t.Fatalf("counts %v", report.Counts)
+	}
+	if report.Errors["bitmex"] != 1 || report.Errors["bitfinex"] != 0 {
+		t.Fatalf("errors %v", report.Errors)
+	}
+	// No message from the changed one until the next minute
+	expected := Gap{"bitmex", "orderBookL2_XBTUSD", changed.UnixNano() + 1, changed.Add(time.Minute).UnixNano() + 6}
+	if len(report.Gaps) != 1 || report.Gaps[0] != expected {
+		t.Fatalf("gaps %+v", report.Gaps)
+	}
+	if len(report.DefinitionChanges) != 1 || report.DefinitionChanges[0].New["price"] != "string" {
+		t.Fatalf("definition changes %+v", report.DefinitionChanges)
+	}
+	minGap := 5 * time.Minute
+	report, serr = QualityReport(context.Background(), req, QualityParam{MinGap: &minGap})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(report.Gaps) != 0 {
+		t.Fatalf("gaps shorter than MinGap reported: %+v", report.Gaps)
+	}
+}
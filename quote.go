@@ -0,0 +1,83 @@
+package exdgo
+
+import "errors"
+
+// QuoteJoinParam is the parameters for `JoinQuotes`.
+type QuoteJoinParam struct {
+	// Returns the best bid and ask price if the line is a quote, such as of "quote" channel of BitMEX.
+	Quote func(line *StructLine) (bid float64, ask float64, ok bool)
+	// Reports whether the line is a trade.
+	Trade func(line *StructLine) bool
+	// Returns the key to match quotes with trades, such as the symbol of the instrument.
+	// Optional, the exchange of the line by default.
+	Key func(line *StructLine) string
+}
+
+// QuotedTrade is a trade with the quote prevailing when it was executed.
+type QuotedTrade struct {
+	Trade StructLine
+	// False if no quote for the trade had been seen yet, then other fields are zero
+	HasQuote bool
+	Bid      float64
+	Ask      float64
+	// Timestamp of the quote line in unixtime nanoseconds
+	QuoteTimestamp int64
+}
+
+// QuotedTradeIterator yields trades annotated with quotes. See `JoinQuotes`.
+type QuotedTradeIterator struct {
+	source StructLineIterator
+	param  QuoteJoinParam
+	// The last quote of each key
+	quotes map[string]QuotedTrade
+	trade  QuotedTrade
+	closed bool
+}
+
+// JoinQuotes returns an iterator yielding trades from `itr`, each with the last quote of the same key
+// yielded before it, which is at or just before the time of the trade.
+// This is the quote-at-trade dataset used in analyses of effective spreads and executions.
+// Lines other than trades are consumed, and `itr` is closed when the returned iterator is closed.
+func JoinQuotes(itr StructLineIterator, param QuoteJoinParam) (*QuotedTradeIterator, error) {
+	if param.Quote == nil || param.Trade == nil {
+		return nil, errors.New("'Quote' and 'Trade' are required")
+	}
+	if param.Key == nil {
+		param.Key = func(line *StructLine) string { return line.Exchange }
+	}
+	return &QuotedTradeIterator{source: itr, param: param, quotes: make(map[string]QuotedTrade)}, nil
+}
+
+// Next returns the next trade.
+// The trade returned is only valid until the next call.
+func (i *QuotedTradeIterator) Next() (*QuotedTrade, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		line, ok, serr := i.source.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		if bid, ask, isQuote := i.param.Quote(line); isQuote {
+			i.quotes[i.param.Key(line)] = QuotedTrade{HasQuote: true, Bid: bid, Ask: ask, QuoteTimestamp: line.Timestamp}
+			continue
+		}
+		if !i.param.Trade(line) {
+			continue
+		}
+		i.trade = i.quotes[i.param.Key(line)]
+		i.trade.Trade = *line
+		return &i.trade, true, nil
+	}
+}
+
+// Close closes the underlying iterator.
+// Calling it again does nothing and returns nil.
+func (i *QuotedTradeIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
@@ -0,0 +1,51 @@
+package exdgo
+
+import "testing"
+
+func TestJoinQuotes(t *testing.T) {
+	quote := "quote"
+	trade := "trade"
+	lines := []StructLine{
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 1, Channel: &trade, Message: map[string]interface{}{"symbol": "XBTUSD"}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 2, Channel: &quote, Message: map[string]interface{}{"symbol": "XBTUSD", "bidPrice": 100.0, "askPrice": 101.0}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 3, Channel: &quote, Message: map[string]interface{}{"symbol": "ETHUSD", "bidPrice": 10.0, "askPrice": 11.0}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 4, Channel: &trade, Message: map[string]interface{}{"symbol": "XBTUSD"}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 5, Channel: &quote, Message: map[string]interface{}{"symbol": "XBTUSD", "bidPrice": 99.0, "askPrice": 100.0}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 5, Channel: &trade, Message: map[string]interface{}{"symbol": "XBTUSD"}},
+	}
+	itr, serr := JoinQuotes(newSliceIterator(lines), QuoteJoinParam{
+		Quote: func(line *StructLine) (float64, float64, bool) {
+			if *line.Channel != "quote" {
+				return 0, 0, false
+			}
+			msg := line.Message.(map[string]interface{})
+			return msg["bidPrice"].(float64), msg["askPrice"].(float64), true
+		},
+		Trade: func(line *StructLine) bool { return *line.Channel == "trade" },
+		Key:   func(line *StructLine) string { return line.Message.(map[string]interface{})["symbol"].(string) },
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	expected := []QuotedTrade{
+		{},
+		{HasQuote: true, Bid: 100, Ask: 101, QuoteTimestamp: 2},
+		{HasQuote: true, Bid: 99, Ask: 100, QuoteTimestamp: 5},
+	}
+	for k, e := range expected {
+		qt, ok, serr := itr.Next()
+		if !ok {
+			t.Fatalf("trade %d: not yielded: %v", k, serr)
+		}
+		if qt.HasQuote != e.HasQuote || qt.Bid != e.Bid || qt.Ask != e.Ask || qt.QuoteTimestamp != e.QuoteTimestamp {
+			t.Fatalf("trade %d: %+v", k, qt)
+		}
+	}
+	if _, ok, serr := itr.Next(); ok || serr != nil {
+		t.Fatalf("expected the end, got %v %v", ok, serr)
+	}
+	if _, serr := JoinQuotes(newSliceIterator(lines), QuoteJoinParam{}); serr == nil {
+		t.Fatal("missing functions accepted")
+	}
+}
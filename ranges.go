@@ -0,0 +1,150 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TimeRange is a range of time from `Start` to `End`.
+// `End` is exclusive unless `InclusiveEnd` of the parameter is set.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// setupSegmentedReplayRequest sets up a request serving multiple ranges.
+// The request itself covers from the start of the first range to the end of the last range,
+// but lines are read from requests for each range.
+func setupSegmentedReplayRequest(cli *Client, param ReplayRequestParam) (*ReplayRequest, error) {
+	var errs ParamErrors
+	if !param.Start.IsZero() || !param.End.IsZero() || param.StartNanos != nil || param.EndNanos != nil {
+		errs.add(errors.New("'Ranges' can not be used with 'Start' or 'End'"))
+	}
+	// Indexes of ranges in order of time, errors are reported by indexes given
+	order := make([]int, len(param.Ranges))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool { return param.Ranges[order[a]].Start.Before(param.Ranges[order[b]].Start) })
+	ranges := make([]TimeRange, len(order))
+	for k, index := range order {
+		ranges[k] = param.Ranges[index]
+	}
+	for k := 1; k < len(ranges); k++ {
+		if ranges[k].Start.Before(ranges[k-1].End) {
+			errs.add(fmt.Errorf("'Ranges' overlap at %v", ranges[k].Start))
+		}
+	}
+	whole := param
+	whole.Ranges = nil
+	whole.Start = ranges[0].Start
+	whole.End = ranges[len(ranges)-1].End
+	whole.StartNanos = nil
+	whole.EndNanos = nil
+	req, serr := setupReplayRequest(cli, whole)
+	errs.add(serr)
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
+	for k, rng := range ranges {
+		segment := whole
+		segment.Start = rng.Start
+		segment.End = rng.End
+		// The line at the boundary of adjacent ranges belongs to the later one
+		if k+1 < len(ranges) && ranges[k+1].Start.Equal(rng.End) {
+			segment.InclusiveEnd = false
+		}
+		seg, serr := setupReplayRequest(cli, segment)
+		var segErrs ParamErrors
+		segErrs.add(serr)
+		for _, serr := range segErrs {
+			errs.add(fmt.Errorf("Ranges[%d]: %v", order[k], serr))
+		}
+		req.segments = append(req.segments, seg)
+	}
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
+	return req, nil
+}
+
+// downloadSegments downloads all ranges and concatenates them.
+func (r *ReplayRequest) downloadSegments(ctx context.Context, concurrency int) ([]StructLine, error) {
+	result := make([]StructLine, 0)
+	for _, seg := range r.segments {
+		lines, serr := seg.download(ctx, concurrency)
+		if serr != nil {
+			return nil, serr
+		}
+		result = append(result, lines...)
+	}
+	return result, nil
+}
+
+// streamSegments returns an iterator streaming ranges one after another.
+func (r *ReplayRequest) streamSegments(ctx context.Context, bufferSize int, reverse bool) StructLineIterator {
+	return &concatIterator{
+		count: len(r.segments),
+		open: func(k int) (StructLineIterator, error) {
+			if reverse {
+				return r.segments[len(r.segments)-1-k].streamReverse(ctx, bufferSize)
+			}
+			return r.segments[k].stream(ctx, bufferSize)
+		},
+	}
+}
+
+// concatIterator yields lines from iterators one after another.
+// Iterators are opened when the previous one reached the end.
+type concatIterator struct {
+	count int
+	open  func(k int) (StructLineIterator, error)
+	// Index of the current iterator
+	index   int
+	current StructLineIterator
+	closed  bool
+}
+
+func (i *concatIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		if i.current == nil {
+			if i.index >= i.count {
+				return nil, false, nil
+			}
+			itr, serr := i.open(i.index)
+			if serr != nil {
+				return nil, false, serr
+			}
+			i.current = itr
+		}
+		line, ok, serr := i.current.Next()
+		if ok {
+			return line, true, nil
+		}
+		if serr != nil {
+			return nil, false, serr
+		}
+		if serr := i.current.Close(); serr != nil {
+			return nil, false, serr
+		}
+		i.current = nil
+		i.index++
+	}
+}
+
+func (i *concatIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	if i.current != nil {
+		return i.current.Close()
+	}
+	return nil
+}
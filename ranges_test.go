@@ -0,0 +1,141 @@
+package exdgo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayRanges(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	filter := map[string][]string{
+		"bitmex":   []string{"orderBookL2_XBTUSD"},
+		"bitfinex": []string{"trades_tBTCUSD"},
+	}
+	ranges := []TimeRange{
+		{start.Add(7 * time.Minute), start.Add(8 * time.Minute)},
+		{start.Add(2 * time.Minute), start.Add(4 * time.Minute)},
+	}
+	cli := srv.client(t)
+	req, serr := cli.Replay(ReplayRequestParam{Filter: filter, Ranges: ranges, VerifyOrder: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	expected := make([]StructLine, 0)
+	for _, k := range []int{1, 0} {
+		single, serr := cli.Replay(ReplayRequestParam{Filter: filter, Start: ranges[k].Start, End: ranges[k].End})
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		lines, serr := single.Download()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		expected = append(expected, lines...)
+	}
+	// 2 exchanges * 6 lines * 3 minutes
+	if len(expected) != 2*6*3 {
+		t.Fatalf("len(expected) = %d", len(expected))
+	}
+	downloaded, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, downloaded)
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	itr, serr = req.StreamReverse()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	reverseStructLines(expected)
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	checkGoroutineLeak(t)
+
+	if _, serr := cli.Replay(ReplayRequestParam{Filter: filter, Ranges: []TimeRange{
+		{start, start.Add(2 * time.Minute)},
+		{start.Add(time.Minute), start.Add(3 * time.Minute)},
+	}}); serr == nil {
+		t.Fatal("overlapping ranges accepted")
+	}
+	if _, serr := cli.Replay(ReplayRequestParam{Filter: filter, Start: start, Ranges: ranges}); serr == nil {
+		t.Fatal("'Start' with 'Ranges' accepted")
+	}
+}
+
+func TestReplayRangesAdjacentInclusiveEnd(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	filter := map[string][]string{
+		"bitmex":   []string{"orderBookL2_XBTUSD"},
+		"bitfinex": []string{"trades_tBTCUSD"},
+	}
+	// A line of bitmex is recorded exactly at the boundary
+	boundary := start.Add(4*time.Minute + 6)
+	cli := srv.client(t)
+	req, serr := cli.Replay(ReplayRequestParam{
+		Filter:       filter,
+		Ranges:       []TimeRange{{start.Add(2 * time.Minute), boundary}, {boundary, start.Add(6 * time.Minute)}},
+		InclusiveEnd: true,
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	single, serr := cli.Replay(ReplayRequestParam{Filter: filter, Start: start.Add(2 * time.Minute), End: start.Add(6 * time.Minute)})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	expected, serr := single.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	downloaded, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, downloaded)
+	// The end of the last range is still inclusive
+	last := start.Add(5*time.Minute + 6)
+	req, serr = cli.Replay(ReplayRequestParam{
+		Filter:       filter,
+		Ranges:       []TimeRange{{start.Add(2 * time.Minute), boundary}, {boundary, last}},
+		InclusiveEnd: true,
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	downloaded, serr = req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if end := downloaded[len(downloaded)-1].Timestamp; end != last.UnixNano() {
+		t.Fatalf("last line at %d", end)
+	}
+}
+
+func TestReplayRangesErrors(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	_, serr := srv.client(t).Replay(ReplayRequestParam{
+		Filter: map[string][]string{"bitmex": []string{"orderBookL2_XBTUSD"}},
+		Ranges: []TimeRange{
+			{start.Add(5 * time.Minute), start.Add(4 * time.Minute)},
+			{start, start.Add(time.Minute)},
+			{start.Add(9 * time.Minute), start.Add(8 * time.Minute)},
+		},
+	})
+	errs, ok := serr.(ParamErrors)
+	if !ok {
+		t.Fatalf("expected ParamErrors, got %v", serr)
+	}
+	if len(errs) != 2 || !strings.HasPrefix(errs[0].Error(), "Ranges[0]: ") || !strings.HasPrefix(errs[1].Error(), "Ranges[2]: ") {
+		t.Fatalf("errs = %v", errs)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"regexp"
+	"sync"
 	"time"
 )
 
@@ -11,14 +14,93 @@ import (
 type RawRequestParam struct {
 	// Map of exchanges and and its channels to filter-in.
 	Filter map[string][]string
+	// Map of exchanges and its channels to filter-out.
+	// Channels are removed from `Filter`, and lines of them are dropped even if the server returned them.
+	// Channels not in `Filter` are never requested, so to replay all channels of an exchange except some,
+	// list all of them in `Filter` and the ones to exclude here.
+	// It is an error if no channel of an exchange in `Filter` is left.
+	// Optional.
+	FilterOut map[string][]string
+	// Map of exchanges and regular expressions of channels to keep.
+	// Channels in `Filter` not matching any of expressions are not requested, and lines of channels
+	// not matching are dropped even if the server returned them.
+	// It is an error if no channel of an exchange matches.
+	// Optional.
+	ChannelRegex map[string][]string
 	// Start date-time.
 	Start time.Time
 	// End date-time.
+	// Exclusive by default, so consecutive ranges can be chained by using `End` of one as `Start` of the next
+	// without duplicated or missing lines.
 	End time.Time
+	// Start in nanoseconds since the unix epoch instead of `Start`,
+	// such as a timestamp of a line from the previous request.
+	// Optional, can not be given with `Start`.
+	StartNanos *int64
+	// End in nanoseconds since the unix epoch instead of `End`.
+	// Optional, can not be given with `End`.
+	EndNanos *int64
+	// If true, lines recorded exactly at `End` are included.
+	InclusiveEnd bool
+	// Time the server takes to make recorded data available.
+	// If set, `End` later than the current time minus this is clamped to it,
+	// instead of requesting data not available yet. See `RawRequest.Clamped`.
+	// Optional.
+	ClampEnd *time.Duration
+	// Base URL of the API to send requests of this request to, instead of the one of the client,
+	// such as a local mock or a regional mirror.
+	// Limits of the client such as `MaxConcurrentRequests` are still shared.
+	// Optional.
+	Endpoint string
 	// What format to receive response with.
 	// If you specify raw, then you will get result in raw format that the exchanges are providing with.
 	// If you specify json, then you will get result formatted in JSON format.
+	// Other formats the server supports can be specified, the response for each shard is
+	// returned as a line of `LineTypeUndecoded` without decoding.
 	Format *string
+	// Number of shards to download ahead of the consumer while streaming, per exchange.
+	// This is independent from the buffer size given to `Stream`, which limits how many
+	// shards can be downloading or kept waiting for the consumer, so prefetching beyond it has no effect.
+	// Optional, defaults to the buffer size.
+	Prefetch *int
+	// Called every time a shard is downloaded by `Download`, with the progress and the estimated time left.
+	// Called from one goroutine at a time.
+	// Optional.
+	OnProgress func(p Progress)
+	// If `Next` of a stream waits for a shard to be downloaded longer than this, it returns `ErrStalled`.
+	// Downloads continue in background, and calling `Next` again waits for the shard again.
+	// The first shard of each exchange is waited for when a stream is made, and if it stalls,
+	// the stream is still returned and its `Next` waits for the shard again.
+	// Optional, waits forever by default.
+	StallTimeout *time.Duration
+	// If a shard download while streaming takes longer than this percentile of download time observed,
+	// a duplicate request is sent and whichever finishes first is used, to reduce tail latency.
+	// In the range of (0, 1), for example 0.95.
+	// Optional, no duplicate request is sent by default.
+	HedgePercentile *float64
+	// Number of retries allowed in total for all shards of each `Download` or stream,
+	// so a flapping endpoint can not make it take unboundedly long.
+	// Each shard is still retried at most `MaxRetries` times of the client.
+	// Optional, unlimited by default.
+	RetryBudget *int
+	// Called with each shard of a minute of an exchange and its size, to checkpoint, log progress
+	// or rotate output files exactly at boundaries of shards. Snapshots are not reported.
+	// While streaming, a shard is reported when `Next` is called after its last line was yielded,
+	// from the goroutine calling `Next`, or decoding lines in background if `DecodeWorkers` is set.
+	// While downloading, called as shards are downloaded from one goroutine at a time.
+	// Optional.
+	OnShard func(exchange string, minute time.Time, lines, bytes int)
+	// Map of exchanges and minutes known to be bad, such as ones of data incidents.
+	// They are skipped without being requested, as if there were no lines in them.
+	// Times are truncated to minutes.
+	// Optional.
+	SkipMinutes map[string][]time.Time
+	// Offset added to timestamps of lines for each exchange, correcting known skews of clocks
+	// which recorded them, so lines of exchanges are merged in the corrected order.
+	// `Start` and `End` are compared with timestamps before corrected.
+	// See `EstimateClockSkew`.
+	// Optional.
+	ClockOffsets map[string]time.Duration
 }
 
 // RawRequest replays market data in raw format.
@@ -29,28 +111,138 @@ type RawRequestParam struct {
 type RawRequest struct {
 	cli    *Client
 	filter map[string][]string
-	start  int64
-	end    int64
-	format *string
+	// Channels to drop, nil if not specified
+	exclude map[string]map[string]bool
+	// Channels to keep, nil if not specified
+	patterns map[string][]*regexp.Regexp
+	start    int64
+	end      int64
+	// True if `end` was clamped by `ClampEnd`
+	clamped bool
+	format  *string
+	// nil if not specified
+	prefetch   *int
+	onProgress func(p Progress)
+	// Zero if disabled
+	stallTimeout time.Duration
+	// nil if disabled
+	hedge *hedger
+	// nil if unlimited
+	retryBudget *int
+	// nil if not reported
+	onShard func(exchange string, minute time.Time, lines, bytes int)
+	// Minutes since the unix epoch to skip for each exchange, nil if none
+	skip map[string]map[int64]bool
+	// Nanoseconds added to timestamps for each exchange, nil if none
+	offsets map[string]int64
 }
 
 // setupRawRequest validates parameter and creates new `RawRequest`.
 // req is nil if err is reported.
+// All of problems in parameters are reported at once in `ParamErrors`.
 func setupRawRequest(cli *Client, param RawRequestParam) (*RawRequest, error) {
 	if cli == nil {
 		return nil, errors.New("'cli' can not be nil")
 	}
+	var errs ParamErrors
 	req := new(RawRequest)
 	req.cli = cli
+	// Optional parameter
+	if param.Endpoint != "" {
+		overridden, serr := cli.withEndpoint(param.Endpoint)
+		errs.add(serr)
+		if serr == nil {
+			req.cli = overridden
+		}
+	}
 	var serr error
-	req.filter, serr = copyFilter(param.Filter)
-	if serr != nil {
-		return nil, fmt.Errorf("Filter: %v", serr)
+	req.filter, serr = copyFilter("Filter", param.Filter)
+	errs.add(serr)
+	// Optional parameter
+	if param.FilterOut != nil {
+		filterOut, serr := copyFilter("FilterOut", param.FilterOut)
+		errs.add(serr)
+		req.exclude = make(map[string]map[string]bool)
+		for exchange, channels := range filterOut {
+			req.exclude[exchange] = make(map[string]bool)
+			for _, ch := range channels {
+				req.exclude[exchange][ch] = true
+			}
+		}
+		for exchange, channels := range req.filter {
+			kept := channels[:0]
+			for _, ch := range channels {
+				if !req.exclude[exchange][ch] {
+					kept = append(kept, ch)
+				}
+			}
+			if len(kept) == 0 {
+				// Requesting no channel would be sent without the filter
+				errs.add(fmt.Errorf("all channels of exchange %q are filtered out by 'FilterOut'", exchange))
+			}
+			req.filter[exchange] = kept
+		}
+	}
+	// Optional parameter
+	if param.ChannelRegex != nil {
+		req.patterns = make(map[string][]*regexp.Regexp)
+		for exchange, exprs := range param.ChannelRegex {
+			if _, ok := param.Filter[exchange]; !ok {
+				errs.add(fmt.Errorf("exchange '%s' in 'ChannelRegex' is not in 'Filter'", exchange))
+				continue
+			}
+			for _, expr := range exprs {
+				pattern, serr := regexp.Compile(expr)
+				if serr != nil {
+					errs.add(fmt.Errorf("ChannelRegex: %v", serr))
+					continue
+				}
+				req.patterns[exchange] = append(req.patterns[exchange], pattern)
+			}
+			if req.filter != nil && len(req.filter[exchange]) > 0 {
+				req.filter[exchange] = matchChannels(req.filter[exchange], req.patterns[exchange])
+				if len(req.filter[exchange]) == 0 {
+					errs.add(fmt.Errorf("no channel of exchange %q matches 'ChannelRegex'", exchange))
+				}
+			}
+		}
 	}
 	start := param.Start.UnixNano()
+	// Optional parameter
+	if param.StartNanos != nil {
+		if !param.Start.IsZero() {
+			errs.add(errors.New("'Start' and 'StartNanos' can not be given at the same time"))
+		}
+		start = *param.StartNanos
+	}
 	end := param.End.UnixNano()
-	if start >= end {
-		return nil, errors.New("'Start' >= 'End'")
+	// Optional parameter
+	if param.EndNanos != nil {
+		if !param.End.IsZero() {
+			errs.add(errors.New("'End' and 'EndNanos' can not be given at the same time"))
+		}
+		end = *param.EndNanos
+	}
+	if param.InclusiveEnd {
+		end++
+	}
+	// Optional parameter
+	if param.ClampEnd != nil {
+		if *param.ClampEnd < 0 {
+			errs.add(errors.New("'ClampEnd' negative"))
+		} else {
+			horizon := time.Now().Add(-*param.ClampEnd)
+			if end > horizon.UnixNano() {
+				end = horizon.UnixNano()
+				req.clamped = true
+				if start >= end {
+					errs.add(fmt.Errorf("'Start' is after the latest available data at %v", horizon))
+				}
+			}
+		}
+	}
+	if start >= end && !req.clamped {
+		errs.add(errors.New("'Start' >= 'End'"))
 	}
 	req.start = start
 	req.end = end
@@ -58,22 +250,168 @@ func setupRawRequest(cli *Client, param RawRequestParam) (*RawRequest, error) {
 	if param.Format != nil {
 		// Validate Format
 		if !regexName.MatchString(*param.Format) {
-			return nil, errors.New("invalid characters in 'Format'")
+			errs.add(errors.New("invalid characters in 'Format'"))
 		}
 		req.format = param.Format
 	}
+	// Optional parameter
+	if param.Prefetch != nil {
+		if *param.Prefetch < 1 {
+			errs.add(errors.New("'Prefetch' must be positive"))
+		}
+		prefetch := *param.Prefetch
+		req.prefetch = &prefetch
+	}
+	req.onProgress = param.OnProgress
+	req.onShard = param.OnShard
+	// Optional parameter
+	if param.SkipMinutes != nil {
+		req.skip = make(map[string]map[int64]bool)
+		for exchange, minutes := range param.SkipMinutes {
+			if _, ok := param.Filter[exchange]; !ok {
+				errs.add(fmt.Errorf("exchange '%s' in 'SkipMinutes' is not in 'Filter'", exchange))
+				continue
+			}
+			req.skip[exchange] = make(map[int64]bool)
+			for _, minute := range minutes {
+				req.skip[exchange][minute.Unix()/60] = true
+			}
+		}
+	}
+	// Optional parameter
+	if param.ClockOffsets != nil {
+		req.offsets = make(map[string]int64)
+		for exchange, offset := range param.ClockOffsets {
+			if _, ok := param.Filter[exchange]; !ok {
+				errs.add(fmt.Errorf("exchange '%s' in 'ClockOffsets' is not in 'Filter'", exchange))
+				continue
+			}
+			req.offsets[exchange] = int64(offset)
+		}
+	}
+	// Optional parameter
+	if param.StallTimeout != nil {
+		if *param.StallTimeout <= 0 {
+			errs.add(errors.New("'StallTimeout' must be positive"))
+		}
+		req.stallTimeout = *param.StallTimeout
+	}
+	// Optional parameter
+	if param.HedgePercentile != nil {
+		if *param.HedgePercentile <= 0 || *param.HedgePercentile >= 1 {
+			errs.add(errors.New("'HedgePercentile' must be in the range of (0, 1)"))
+		} else {
+			req.hedge = newHedger(*param.HedgePercentile)
+		}
+	}
+	// Optional parameter
+	if param.RetryBudget != nil {
+		if *param.RetryBudget < 0 {
+			errs.add(errors.New("'RetryBudget' negative"))
+		}
+		budget := *param.RetryBudget
+		req.retryBudget = &budget
+	}
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
 	return req, nil
 }
 
+// End returns the end of the range to replay.
+// It is earlier than `End` of the parameter if it was clamped by `ClampEnd`.
+func (r *RawRequest) End() time.Time {
+	return time.Unix(0, r.end).UTC()
+}
+
+// Clamped reports whether `End` was clamped to the latest available data by `ClampEnd`.
+func (r *RawRequest) Clamped() bool {
+	return r.clamped
+}
+
+// matchChannels returns channels matching any of the patterns.
+func matchChannels(channels []string, patterns []*regexp.Regexp) []string {
+	matched := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		if matchAny(ch, patterns) {
+			matched = append(matched, ch)
+		}
+	}
+	return matched
+}
+
+func matchAny(channel string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveChannels returns channels matching any of regular expressions given,
+// such as channels listed in a catalog.
+func ResolveChannels(channels []string, exprs []string) ([]string, error) {
+	patterns := make([]*regexp.Regexp, len(exprs))
+	for j, expr := range exprs {
+		var serr error
+		patterns[j], serr = regexp.Compile(expr)
+		if serr != nil {
+			return nil, serr
+		}
+	}
+	return matchChannels(channels, patterns), nil
+}
+
+// dropExcluded removes lines of channels filtered-out, or not matching `ChannelRegex` from the shard.
+func (r *RawRequest) dropExcluded(exchange string, shard []StringLine) []StringLine {
+	exclude := r.exclude[exchange]
+	patterns, hasPatterns := r.patterns[exchange]
+	if len(exclude) == 0 && !hasPatterns {
+		return shard
+	}
+	kept := shard[:0]
+	for _, line := range shard {
+		if line.Channel != nil && (exclude[*line.Channel] || (hasPatterns && !matchAny(*line.Channel, patterns))) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// correctClock adds the offset of the exchange to timestamps of lines in the shard.
+func (r *RawRequest) correctClock(exchange string, shard []StringLine) {
+	offset := r.offsets[exchange]
+	if offset == 0 {
+		return
+	}
+	for i := range shard {
+		shard[i].Timestamp += offset
+	}
+}
+
 // Converts snapshots into lines.
 // This function is called only once per a request so calling this is not that much of a bottleneck.
-func convertSnapshotsToLines(exchange string, snapshots []Snapshot) []StringLine {
+func convertSnapshotsToLines(exchange string, format *string, snapshots []Snapshot) []StringLine {
 	converted := make([]StringLine, len(snapshots))
+	if !isKnownFormat(format) {
+		// Snapshot holds the undecoded response
+		for i, ss := range snapshots {
+			converted[i] = StringLine{
+				Type:      LineTypeUndecoded,
+				Exchange:  exchange,
+				Timestamp: ss.Timestamp,
+				Message:   ss.Snapshot,
+			}
+		}
+		return converted
+	}
 	for i, ss := range snapshots {
 		converted[i] = StringLine{
 			Type:      LineTypeMessage,
 			Exchange:  exchange,
-			Channel:   &ss.Channel,
+			Channel:   &snapshots[i].Channel,
 			Timestamp: ss.Timestamp,
 			Message:   ss.Snapshot,
 		}
@@ -98,39 +436,49 @@ type rawDownloadJob struct {
 type rawDownloadJobResult struct {
 	job    *rawDownloadJob
 	result []StringLine
+	// This is non-nil if and only if the job had failed
+	err error
 }
 
-// Works on job provided by `jobs` channel.
-// `err` is used for multiple workers, so it won't be closed from worker.
-// Instead, worker will close `stopped` channel to let others know that this worker had stopped.
-func rawDownloadWorker(ctx context.Context, cli *Client, jobs chan *rawDownloadJob, results chan *rawDownloadJobResult, err chan error, stopped chan struct{}) {
-	defer close(stopped)
+// Works on job provided by `jobs` channel until it is closed or the context is done.
+// Both results and errors are sent through `results`, sending is abandoned when the context is done
+// so a worker never blocks forever.
+// Worker will call `wg.Done()` to let others know that this worker had stopped.
+func rawDownloadWorker(ctx context.Context, cli *Client, jobs chan *rawDownloadJob, results chan *rawDownloadJobResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer cli.startBackground()()
 	// Do job if it can and jobs are available
 	for job := range jobs {
-		if job.typ == rawDownloadJobSnapshot {
-			setting := job.setting.(snapshotSetting)
-			ret, serr := httpSnapshot(ctx, cli, setting)
-			if serr != nil {
-				err <- serr
-			}
-			// Real struct is too big to send through channel
-			results <- &rawDownloadJobResult{
-				job:    job,
-				result: convertSnapshotsToLines(setting.exchange, ret),
-			}
-		} else if job.typ == rawDonwloadJobFilter {
-			setting := job.setting.(filterSetting)
-			ret, serr := httpFilter(ctx, cli, setting)
-			if serr != nil {
-				err <- serr
-			}
-			// Real struct is too big to send through channel
-			results <- &rawDownloadJobResult{
-				job:    job,
-				result: ret,
+		if ctx.Err() != nil {
+			// No one is waiting for the result
+			return
+		}
+		// Real struct is too big to send through channel
+		res := &rawDownloadJobResult{job: job}
+		// Each shard is retried independently, so a failure of one shard does not
+		// fail the whole request unless it runs out of retries
+		res.err = retry(ctx, cli, func() error {
+			if job.typ == rawDownloadJobSnapshot {
+				setting := job.setting.(snapshotSetting)
+				ret, serr := httpSnapshot(ctx, cli, setting)
+				if serr != nil {
+					return serr
+				}
+				res.result = convertSnapshotsToLines(setting.exchange, setting.format, ret)
+				return nil
+			} else if job.typ == rawDonwloadJobFilter {
+				var serr error
+				res.result, serr = httpFilter(ctx, cli, job.setting.(filterSetting))
+				return serr
 			}
-		} else {
-			err <- errors.New("unknown download job type")
+			return errors.New("unknown download job type")
+		})
+		if res.err == nil {
+			summaryCollectorFrom(ctx).addShard()
+		}
+		select {
+		case results <- res:
+		case <-ctx.Done():
 			return
 		}
 	}
@@ -146,45 +494,11 @@ func (r *RawRequest) downloadAllShards(ctx context.Context, concurrency int) (ma
 	shardsPerExchange := 1 + int(endMinute-startMinute+1)
 	amountOfJobs := len(r.filter) * shardsPerExchange
 
-	// Channel to get error from worker, used in all workers
-	errCh := make(chan error)
-	defer close(errCh)
-	resultsCh := make(chan *rawDownloadJobResult)
-	defer close(resultsCh)
-	// Context for all worker
-	childCtx, cancelChild := context.WithCancel(ctx)
-	defer cancelChild()
-	// Worker pool are the slice of channel that gets closed when a worker had stopped
-	workerPool := make([]chan struct{}, concurrency)
-	defer func() {
-		// Defer function prevents worker from being left alone
-		// This will stop http query
-		cancelChild()
-		for _, worker := range workerPool {
-			// Wait for the worker to stop
-			select {
-			case <-worker:
-				// This two are needed to unblock channels so a worker can stop
-				// which not neccesarily the worker that we are waiting for
-			case <-errCh:
-			case <-resultsCh:
-				// Jobs channels are already closed, so we don't have to think about it
-			}
-		}
-	}()
 	// Channel to send workers jobs, it won't get blocked by sending
 	jobsCh := make(chan *rawDownloadJob, amountOfJobs)
-	defer close(jobsCh)
-	// Run all worker
-	for i := 0; i < concurrency; i++ {
-		workerPool[i] = make(chan struct{})
-		go rawDownloadWorker(childCtx, r.cli, jobsCh, resultsCh, errCh, workerPool[i])
-	}
-
 	// Send jobs to worker
 	for exchange, channels := range r.filter {
 		// Take snapshot of channels
-		// FIXME Can change to blocking one if we use select
 		jobsCh <- &rawDownloadJob{
 			typ: rawDownloadJobSnapshot,
 			setting: snapshotSetting{
@@ -197,6 +511,11 @@ func (r *RawRequest) downloadAllShards(ctx context.Context, concurrency int) (ma
 
 		// Download the rest of data
 		for minute := startMinute; minute <= endMinute; minute++ {
+			if r.skip[exchange][minute] {
+				// Left empty
+				amountOfJobs--
+				continue
+			}
 			jobsCh <- &rawDownloadJob{
 				typ: rawDonwloadJobFilter,
 				setting: filterSetting{
@@ -210,6 +529,25 @@ func (r *RawRequest) downloadAllShards(ctx context.Context, concurrency int) (ma
 			}
 		}
 	}
+	// All jobs are sent, workers stop when the channel became empty
+	close(jobsCh)
+
+	// Channel to get results and errors from worker, used in all workers
+	resultsCh := make(chan *rawDownloadJobResult)
+	// Context for all worker
+	childCtx, cancelChild := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		// Defer function prevents worker from being left alone
+		// This will stop http query and unblock workers trying to send results
+		cancelChild()
+		wg.Wait()
+	}()
+	// Run all worker
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go rawDownloadWorker(childCtx, r.cli, jobsCh, resultsCh, &wg)
+	}
 
 	// Map to store final result
 	shards := make(map[string][][]StringLine)
@@ -218,23 +556,36 @@ func (r *RawRequest) downloadAllShards(ctx context.Context, concurrency int) (ma
 		shards[exchange] = make([][]StringLine, shardsPerExchange)
 	}
 
+	var progress *progressTracker
+	if r.onProgress != nil {
+		progress = newProgressTracker(amountOfJobs, r.onProgress)
+	}
 	// How many jobs has been done
 	over := 0
 	for over < amountOfJobs {
 		select {
 		case result := <-resultsCh:
+			if result.err != nil {
+				return nil, fmt.Errorf("worker: %w", result.err)
+			}
 			if result.job.typ == rawDownloadJobSnapshot {
 				setting := result.job.setting.(snapshotSetting)
+				result.result = r.dropExcluded(setting.exchange, result.result)
+				r.correctClock(setting.exchange, result.result)
 				shards[setting.exchange][0] = result.result
 			} else if result.job.typ == rawDonwloadJobFilter {
 				setting := result.job.setting.(filterSetting)
+				result.result = r.dropExcluded(setting.exchange, result.result)
+				r.correctClock(setting.exchange, result.result)
 				shards[setting.exchange][setting.minute-startMinute+1] = result.result
+				if r.onShard != nil {
+					r.onShard(setting.exchange, time.Unix(setting.minute*60, 0).UTC(), len(result.result), int(shardBytes(result.result)))
+				}
 			} else {
 				return nil, errors.New("unknown download job type")
 			}
 			over++
-		case serr := <-errCh:
-			return nil, fmt.Errorf("worker: %v", serr)
+			progress.shardDone(result.result)
 		case <-ctx.Done():
 			// Context is cancelled
 			return nil, fmt.Errorf("context done: %v", ctx.Err())
@@ -272,7 +623,15 @@ type rawDownloadIteratorAndLastLine struct {
 // DownloadWithContext is same as `Download()`, but sends requests in given concurrency
 // in given context.
 func (r *RawRequest) DownloadWithContext(ctx context.Context, concurrency int) ([]StringLine, error) {
-	mapped, serr := r.downloadAllShards(ctx, concurrency)
+	if concurrency < 1 {
+		return nil, errors.New("'concurrency' must be positive")
+	}
+	end, serr := r.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	defer end()
+	mapped, serr := r.downloadAllShards(r.withRetryBudget(ctx), concurrency)
 	if serr != nil {
 		return nil, serr
 	}
@@ -333,10 +692,21 @@ func (r *RawRequest) DownloadConcurrency(concurrency int) ([]StringLine, error)
 }
 
 // Download sends request and download response in an slice.
+// Requests are sent in the concurrency set by `Concurrency` in `ClientParam`.
 // Returns slice if and only if error was not reported.
 // Otherwise, slice is non-nil.
 func (r *RawRequest) Download() ([]StringLine, error) {
-	return r.DownloadWithContext(context.Background(), downloadBatchSize)
+	return r.DownloadWithContext(context.Background(), r.cli.concurrency)
+}
+
+// DownloadFunc calls `fn` with each line in order as they are downloaded, without keeping all lines in memory.
+// See `ReplayRequest.DownloadFunc`.
+func (r *RawRequest) DownloadFunc(ctx context.Context, fn func(line *StringLine) error) error {
+	itr, serr := r.StreamWithContext(ctx, r.cli.concurrency)
+	if serr != nil {
+		return serr
+	}
+	return ForEachString(itr, fn)
 }
 
 type rawStreamShardResult struct {
@@ -355,18 +725,28 @@ type rawStreamShardResult struct {
 // the main goroutine will serve as a manager for all of individual
 // download routine and is called background, others are called download.
 type rawExchangeStreamShardIterator struct {
+	// Context given when initialized
+	ctx        context.Context
 	request    *RawRequest
 	exchange   string
 	bufferSize int
+	prefetch   int
+	order      shardOrder
 	// Channel to get result from background goroutine
 	results chan []StringLine
 	// Channel to receive error from background goroutine
 	bgErr chan error
 	// Cancels context background runs on
 	cancelBGCtx context.CancelFunc
+	// nil if the stream can not be paused
+	pause *pauseControl
+	// Notified when the stream is resumed or the reader waits for a shard while paused
+	wake chan struct{}
+	// nil if calls can not be cancelled
+	call *callContext
 }
 
-func (i *rawExchangeStreamShardIterator) downloadSnapshot(ctx context.Context, results chan *rawStreamShardResult) {
+func (i *rawExchangeStreamShardIterator) downloadSnapshot(ctx context.Context) ([]StringLine, error) {
 	result, serr := httpSnapshot(ctx, i.request.cli, snapshotSetting{
 		exchange: i.exchange,
 		channels: i.request.filter[i.exchange],
@@ -374,17 +754,13 @@ func (i *rawExchangeStreamShardIterator) downloadSnapshot(ctx context.Context, r
 		format:   i.request.format,
 	})
 	if serr != nil {
-		results <- &rawStreamShardResult{err: serr}
-		return
-	}
-	results <- &rawStreamShardResult{
-		index: 0,
-		shard: convertSnapshotsToLines(i.exchange, result),
+		return nil, serr
 	}
+	return convertSnapshotsToLines(i.exchange, i.request.format, result), nil
 }
 
-func (i *rawExchangeStreamShardIterator) downloadFilter(ctx context.Context, minute int64, index int, results chan *rawStreamShardResult) {
-	result, serr := httpFilter(ctx, i.request.cli, filterSetting{
+func (i *rawExchangeStreamShardIterator) downloadFilter(ctx context.Context, minute int64) ([]StringLine, error) {
+	return httpFilter(ctx, i.request.cli, filterSetting{
 		exchange: i.exchange,
 		channels: i.request.filter[i.exchange],
 		minute:   minute,
@@ -392,32 +768,95 @@ func (i *rawExchangeStreamShardIterator) downloadFilter(ctx context.Context, min
 		end:      &i.request.end,
 		format:   i.request.format,
 	})
-	if serr != nil {
-		results <- &rawStreamShardResult{err: serr}
+}
+
+// shardOrder is the order shards are returned from `rawExchangeStreamShardIterator`.
+type shardOrder struct {
+	// The newest shard first, the snapshot is the last
+	reverse bool
+	// The snapshot is not downloaded
+	skipSnapshot bool
+}
+
+// download downloads the shard at the given index and sends the result to `results`.
+// Index 0 is the snapshot, and index n is the filter of the (n-1)th minute from the start.
+// Failed downloads are retried as the client allows, so a transient error does not
+// surface to the consumer of the stream.
+func (i *rawExchangeStreamShardIterator) download(ctx context.Context, index int, results chan *rawStreamShardResult) {
+	defer i.request.cli.startBackground()()
+	res := &rawStreamShardResult{index: index}
+	startMinute := i.request.start / int64(time.Minute)
+	if index > 0 && i.request.skip[i.exchange][startMinute+int64(index-1)] {
+		res.shard = make([]StringLine, 0)
+		results <- res
 		return
 	}
-	results <- &rawStreamShardResult{
-		index: index,
-		shard: result,
+	res.err = retry(ctx, i.request.cli, func() error {
+		var serr error
+		res.shard, serr = i.request.hedge.do(ctx, func(ctx context.Context) ([]StringLine, error) {
+			if index == 0 {
+				return i.downloadSnapshot(ctx)
+			}
+			return i.downloadFilter(ctx, startMinute+int64(index-1))
+		})
+		return serr
+	})
+	res.shard = i.request.dropExcluded(i.exchange, res.shard)
+	i.request.correctClock(i.exchange, res.shard)
+	results <- res
+}
+
+// firstIndex returns the index of the first shard, the snapshot takes index 0.
+func (i *rawExchangeStreamShardIterator) firstIndex() int {
+	if i.order.skipSnapshot {
+		return 1
+	}
+	return 0
+}
+
+// lastIndex returns the index of the last shard.
+func (i *rawExchangeStreamShardIterator) lastIndex() int {
+	startMinute := i.request.start / int64(time.Minute)
+	// End is exclusive
+	endMinute := (i.request.end - 1) / int64(time.Minute)
+	return int(endMinute-startMinute) + 1
+}
+
+// indexAt returns the index of the shard to be returned at the position.
+func (i *rawExchangeStreamShardIterator) indexAt(position int) int {
+	if i.order.reverse {
+		return i.lastIndex() - position
 	}
+	return i.firstIndex() + position
 }
 
 // background is the goroutine to manage all download goroutine associated with this iterator.
 // The goroutine will run on the context given, and stops its execution if the context was cancelled.
 // out should be put to a results field in `rawExchageStreamShardIterator` by the caller.
+//
+// Downloads are started as long as the shard is within `prefetch` shards from the current
+// position and there are less than `bufferSize` shards being downloaded or waiting to be returned.
 func (i *rawExchangeStreamShardIterator) background(ctx context.Context, out chan []StringLine, err chan error) {
+	defer i.request.cli.startBackground()()
 	defer close(out)
 	defer close(err)
-	startMinute := i.request.start / int64(time.Minute)
-	nextMinute := startMinute
-	// End is exclusive
-	endMinute := (i.request.end - 1) / int64(time.Minute)
+	lastPosition := i.lastIndex() - i.firstIndex()
+	indexAt := i.indexAt
 	results := make(chan *rawStreamShardResult)
 	defer close(results)
-	// Buffer to store results from download goroutines
-	buffer := make([][]StringLine, i.bufferSize)
-	// Current read position in the buffer
+	// Shards downloaded but not yet returned, keyed by its index
+	ready := make(map[int][]StringLine)
+	memory := i.request.cli.memory
+	defer func() {
+		// Shards returned are released by the reader
+		for _, shard := range ready {
+			memory.addBuffered(-shardBytes(shard))
+		}
+	}()
+	// Position of the shard to be returned next
 	position := 0
+	// Position of the shard to be downloaded next
+	nextPosition := 0
 	// Number of running background goroutines
 	// This routine will not stop until this value is 0
 	running := 0
@@ -433,84 +872,88 @@ func (i *rawExchangeStreamShardIterator) background(ctx context.Context, out cha
 	// Context for download routine
 	downloadCtx, cancelDLCtx := context.WithCancel(ctx)
 	defer cancelDLCtx()
-	// Run snapshot download
-	go i.downloadSnapshot(downloadCtx, results)
-	running++
-	// Run initial filter downloads
-	for j := 1; j < i.bufferSize && nextMinute <= endMinute; j++ {
-		// Execute download routine
-		go i.downloadFilter(downloadCtx, nextMinute, int(nextMinute-startMinute+1), results)
-		running++
-		nextMinute++
-	}
-	// Set this flag true to stop this loop
-	stop := false
-	for !stop {
-		if buffer[position%i.bufferSize] == nil {
-			select {
-			case res := <-results:
-				// Got a result or an error
-				running--
-				if res.err != nil {
-					// Received an error
-					stop = true
-					err <- fmt.Errorf("download: %v", res.err)
-					// Cancel download routine context to stop them
-					cancelDLCtx()
+	// True if the reader waits for the shard at the position while paused
+	needed := false
+	for position <= lastPosition {
+		// Start downloads as far as prefetch and buffer allow, only the shard needed while paused
+		paused := i.pause.isPaused()
+		for nextPosition <= lastPosition && nextPosition < position+i.prefetch && running+len(ready) < i.bufferSize && (!paused || needed && nextPosition == position) {
+			go i.download(downloadCtx, indexAt(nextPosition), results)
+			running++
+			nextPosition++
+		}
+		// Sending to nil channel blocks forever, so shard is sent only if it is ready
+		var send chan []StringLine
+		shard, ok := ready[indexAt(position)]
+		if ok {
+			send = out
+		}
+		select {
+		case res := <-results:
+			// Got a result or an error
+			running--
+			if res.err != nil {
+				if ctx.Err() != nil {
+					// Failed by the cancellation below, which may be selected later than this
+					if i.ctx.Err() != nil {
+						err <- fmt.Errorf("context: %w", i.ctx.Err())
+					}
+					return
 				}
-				// Set shard in the buffer
-				buffer[res.index%i.bufferSize] = res.shard
-			case <-ctx.Done():
-				// Context is cancelled
-				stop = true
-				err <- fmt.Errorf("context: %v", ctx.Err())
+				// Received an error
+				err <- fmt.Errorf("download: %w", res.err)
+				// Download routines are stopped by defer functions
+				return
 			}
-		} else {
-			select {
-			case res := <-results:
-				running--
-				if res.err != nil {
-					stop = true
-					err <- fmt.Errorf("download: %v", res.err)
-					cancelDLCtx()
-				}
-				buffer[res.index%i.bufferSize] = res.shard
-			case out <- buffer[position%i.bufferSize]:
-				buffer[position%i.bufferSize] = nil
-				if nextMinute <= endMinute {
-					go i.downloadFilter(downloadCtx, nextMinute, int(nextMinute-startMinute+1), results)
-					nextMinute++
-					running++
-				} else if int64(position-1)+startMinute == endMinute {
-					// Last shard was returned
-					// This background routine's job is done
-					stop = true
-				}
-				position++
-			case <-ctx.Done():
-				stop = true
-				err <- fmt.Errorf("context: %v", ctx.Err())
+			ready[res.index] = res.shard
+			memory.addBuffered(shardBytes(res.shard))
+		case send <- shard:
+			delete(ready, indexAt(position))
+			position++
+			needed = false
+		case <-i.wake:
+			// Resumed, or the reader waits while paused
+			needed = true
+		case <-ctx.Done():
+			// Context is cancelled
+			if i.ctx.Err() != nil {
+				// Cancelled by the user, not by `close()`
+				err <- fmt.Errorf("context: %w", i.ctx.Err())
 			}
+			return
 		}
 	}
-	// Stop is gracelly handled by defer functions defined before
+	// All shards were returned, stop is gracefully handled by defer functions defined before
 }
 
 // init initialize this iterator by setting field value and start downloading
 // to fill buffer.
 // Context given will be used by this iterator for its lifetime.
 // This includes `next()` and other operations.
-func newRawExchangeStreamShardIterator(ctx context.Context, request *RawRequest, exchange string, bufferSize int) *rawExchangeStreamShardIterator {
+func newRawExchangeStreamShardIterator(ctx context.Context, request *RawRequest, exchange string, bufferSize int, order shardOrder) *rawExchangeStreamShardIterator {
 	i := new(rawExchangeStreamShardIterator)
+	i.ctx = ctx
 	i.request = request
 	i.exchange = exchange
 	i.bufferSize = bufferSize
+	i.order = order
+	i.pause = pauseControlFrom(ctx)
+	i.call = callContextFrom(ctx)
+	if i.pause != nil {
+		i.wake = i.pause.register()
+	}
+	if request.prefetch != nil {
+		i.prefetch = *request.prefetch
+	} else {
+		i.prefetch = bufferSize
+	}
 	// Make child context for background
 	var childCtx context.Context
 	childCtx, i.cancelBGCtx = context.WithCancel(ctx)
 	// Make channels for communication
 	i.results = make(chan []StringLine)
-	i.bgErr = make(chan error)
+	// Background sends at most one error, buffering it lets background stop without waiting for the reader
+	i.bgErr = make(chan error, 1)
 	// Run background routine
 	go i.background(childCtx, i.results, i.bgErr)
 	return i
@@ -523,11 +966,29 @@ func newRawExchangeStreamShardIterator(ctx context.Context, request *RawRequest,
 //
 // Returns error if background download goroutines had encountered an error which has not been reported yet.
 // Returns nil if next shard is absent.
+// Returns `ErrStalled` if the shard was not available within the stall timeout,
+// the iterator can be used after that.
 //
 // This function runs on the context which was given when an iterator was initialized.
 func (i *rawExchangeStreamShardIterator) next() ([]StringLine, error) {
+	// Receiving from nil channel blocks forever, so it never stalls if disabled
+	var stall <-chan time.Time
+	if i.request.stallTimeout > 0 {
+		timer := time.NewTimer(i.request.stallTimeout)
+		defer timer.Stop()
+		stall = timer.C
+	}
+	if i.pause.isPaused() {
+		// Let background download the shard waited for
+		notify(i.wake)
+	}
 	// Check for background error
 	select {
+	case <-stall:
+		return nil, ErrStalled
+	case <-i.call.done():
+		// The shard is still sent by background in the next call
+		return nil, i.call.err()
 	case serr, ok := <-i.bgErr:
 		if ok {
 			return nil, serr
@@ -541,49 +1002,103 @@ func (i *rawExchangeStreamShardIterator) next() ([]StringLine, error) {
 	return nil, nil
 }
 
-// close stops goroutine used by this iterator
-// After non-nil return from the call, this iterator could be
+// close stops goroutine used by this iterator and waits for all of them to exit,
+// including download goroutines and their in-flight requests.
+// After the return from the call, this iterator could be
 // safely ignored until gc will take care of them.
 // Returns error if there is an unreported error from background.
 func (i *rawExchangeStreamShardIterator) close() error {
 	// This will stop background
 	i.cancelBGCtx()
-	// Error channel will either be closed or return error
-	serr, ok := <-i.bgErr
-	if ok {
+	// Error channel is closed after all download goroutines had stopped
+	var serr error
+	for err := range i.bgErr {
 		// Report error from background
-		return serr
+		serr = err
 	}
-	return nil
+	if i.pause != nil {
+		i.pause.unregister(i.wake)
+	}
+	return serr
 }
 
 type rawExchangeStreamIterator struct {
 	shardIterator *rawExchangeStreamShardIterator
 	shard         []StringLine
 	position      int
+	reverse       bool
+	notifier      *shardNotifier
+	// Number of shards received
+	shards int
+	// True if the current shard was reported to `notifier`
+	reported bool
+	// True if the very first shard was received
+	started bool
 }
 
-func newRawExchangeStreamIterator(ctx context.Context, request *RawRequest, exchange string, bufferSize int) (*rawExchangeStreamIterator, error) {
+func newRawExchangeStreamIterator(ctx context.Context, request *RawRequest, exchange string, bufferSize int, reverse bool, notifier *shardNotifier) *rawExchangeStreamIterator {
 	i := new(rawExchangeStreamIterator)
-	i.shardIterator = newRawExchangeStreamShardIterator(ctx, request, exchange, bufferSize)
-	// Get the very first shard
-	var serr error
-	i.shard, serr = i.shardIterator.next()
-	if serr != nil {
-		return nil, serr
+	i.reverse = reverse
+	i.notifier = notifier
+	i.shardIterator = newRawExchangeStreamShardIterator(ctx, request, exchange, bufferSize, shardOrder{reverse: reverse})
+	return i
+}
+
+// nextShard returns the next shard, with lines reversed if the iterator is reversed.
+func (i *rawExchangeStreamIterator) nextShard() ([]StringLine, error) {
+	shard, serr := i.shardIterator.next()
+	if shard != nil {
+		i.shards++
+	}
+	if i.reverse {
+		reverseStringLines(shard)
+	}
+	return shard, serr
+}
+
+// report records the current shard read through to the notifier, except for the snapshot.
+func (i *rawExchangeStreamIterator) report() {
+	if i.reported {
+		return
+	}
+	i.reported = true
+	index := i.shardIterator.indexAt(i.shards - 1)
+	if index == 0 {
+		return
+	}
+	request := i.shardIterator.request
+	minute := request.start/int64(time.Minute) + int64(index-1)
+	i.notifier.add(i.shardIterator.exchange, minute, len(i.shard), shardBytes(i.shard))
+}
+
+func reverseStringLines(lines []StringLine) {
+	for a, b := 0, len(lines)-1; a < b; a, b = a+1, b-1 {
+		lines[a], lines[b] = lines[b], lines[a]
 	}
-	return i, nil
 }
 
 func (i *rawExchangeStreamIterator) next() (*StringLine, error) {
+	if !i.started {
+		// Get the very first shard, tried again by the next call on an error such as `ErrStalled`
+		shard, serr := i.nextShard()
+		if serr != nil {
+			return nil, serr
+		}
+		i.started = true
+		i.shard = shard
+	}
 	// Skip shards does not have any more lines (or empty) as long as available
 	for i.shard != nil && len(i.shard) <= i.position {
-		var serr error
-		i.shard, serr = i.shardIterator.next()
-		i.position = 0
+		i.report()
+		shard, serr := i.nextShard()
 		if serr != nil {
+			// Shard is kept, so the next call tries again
 			return nil, serr
 		}
+		i.release()
+		i.shard = shard
+		i.position = 0
+		i.reported = false
 	}
 	if i.shard == nil {
 		// Reached the last line
@@ -594,7 +1109,14 @@ func (i *rawExchangeStreamIterator) next() (*StringLine, error) {
 	return line, nil
 }
 
+// release stops counting the current shard as buffered.
+func (i *rawExchangeStreamIterator) release() {
+	i.shardIterator.request.cli.memory.addBuffered(-shardBytes(i.shard))
+}
+
 func (i *rawExchangeStreamIterator) close() error {
+	i.release()
+	i.shard = nil
 	return i.shardIterator.close()
 }
 
@@ -607,45 +1129,81 @@ type rawStreamIterator struct {
 	// Map of exchange vs struct
 	states    map[string]*rawStreamIteratorAndLastLine
 	exchanges []string
+	// Exchanges whose first line is not received yet because their first shard stalled
+	pending []string
+	// Yields the newest line first
+	reverse bool
+	closed  bool
+	// nil if shards are not reported
+	notifier *shardNotifier
 }
 
-func newRawStreamIterator(ctx context.Context, request *RawRequest, bufferSize int) (*rawStreamIterator, error) {
+func newRawStreamIterator(ctx context.Context, request *RawRequest, bufferSize int, reverse bool) (*rawStreamIterator, error) {
 	i := new(rawStreamIterator)
+	i.reverse = reverse
+	i.notifier = newShardNotifier(request.onShard)
 	i.states = make(map[string]*rawStreamIteratorAndLastLine)
 	i.exchanges = make([]string, 0, len(request.filter))
 	for exchange := range request.filter {
-		iterator, serr := newRawExchangeStreamIterator(ctx, request, exchange, bufferSize)
-		if serr != nil {
-			return nil, serr
+		i.states[exchange] = &rawStreamIteratorAndLastLine{
+			iterator: newRawExchangeStreamIterator(ctx, request, exchange, bufferSize, reverse, i.notifier),
 		}
-		next, serr := iterator.next()
+		i.pending = append(i.pending, exchange)
+	}
+	// A stall is waited for again by `Next`
+	if serr := i.start(); serr != nil && serr != ErrStalled {
+		i.Close()
+		return nil, serr
+	}
+	return i, nil
+}
+
+// start receives the first line of pending exchanges, and adds them to ones yielding lines,
+// or closes their iterator if they return no line.
+// An exchange returned an error is kept pending, so the next call tries again.
+func (i *rawStreamIterator) start() error {
+	for len(i.pending) > 0 {
+		exchange := i.pending[0]
+		state := i.states[exchange]
+		next, serr := state.iterator.next()
 		if serr != nil {
-			return nil, serr
+			return serr
 		}
+		i.pending = i.pending[1:]
 		// Skip if an exchange iterator returns no line
 		if next == nil {
+			if serr := state.iterator.close(); serr != nil {
+				return serr
+			}
 			continue
 		}
-		i.states[exchange] = &rawStreamIteratorAndLastLine{
-			iterator: iterator,
-			lastLine: next,
-		}
+		state.lastLine = next
 		i.exchanges = append(i.exchanges, exchange)
 	}
-	return i, nil
+	return nil
 }
 
 func (i *rawStreamIterator) Next() (next *StringLine, ok bool, err error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	// Lines of shards read through were all yielded by the last call
+	i.notifier.flush()
+	// Lines can not be ordered until the first line of all exchanges are received
+	if serr := i.start(); serr != nil {
+		return nil, false, serr
+	}
 	if len(i.exchanges) == 0 {
 		// All lines returned
 		return nil, false, nil
 	}
-	// Return the line that has the smallest timestamp across exchanges
+	// Return the line that has the smallest timestamp across exchanges,
+	// or the largest if reversed
 	argmin := 0
 	min := i.states[i.exchanges[argmin]].lastLine.Timestamp
 	for j := 1; j < len(i.exchanges); j++ {
 		lastLine := i.states[i.exchanges[j]].lastLine
-		if lastLine.Timestamp < min {
+		if (!i.reverse && lastLine.Timestamp < min) || (i.reverse && lastLine.Timestamp > min) {
 			argmin = j
 			min = lastLine.Timestamp
 		}
@@ -670,8 +1228,12 @@ func (i *rawStreamIterator) Next() (next *StringLine, ok bool, err error) {
 }
 
 func (i *rawStreamIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
 	var serr error
-	for _, exchange := range i.exchanges {
+	for _, exchange := range append(i.exchanges, i.pending...) {
 		// This will ignore errors other then the first one
 		if serr == nil {
 			// This could return error
@@ -688,6 +1250,7 @@ func (i *rawStreamIterator) Close() error {
 }
 
 // StringLineIterator is the interface of iterator which yields `*StringLine`.
+// Iterators are not safe for concurrent use, wrap one by `SynchronizedString` to share it between goroutines.
 type StringLineIterator interface {
 	// Next returns the next line from the iterator.
 	// If the next line exists, `ok` is true ad `line` is non-nil, otherwise false and `line` is nil.
@@ -695,8 +1258,11 @@ type StringLineIterator interface {
 	Next() (line *StringLine, ok bool, err error)
 
 	// Close frees resources this iterator is using.
+	// All background goroutines and in-flight requests are stopped before it returns.
+	// `Next` returns `ErrIteratorClosed` after this is called.
+	// Calling it again does nothing and returns nil, so it can be deferred even if closed explicitly.
 	// **Must** always be called after the use of this iterator.
-	Close() error
+	io.Closer
 }
 
 // Stream sends requests to the server and returns an iterator for reading the response.
@@ -709,12 +1275,13 @@ type StringLineIterator interface {
 // Higher responsiveness than `download` is expected as it does not have to wait for
 // the entire data to be downloaded.
 func (r *RawRequest) Stream() (StringLineIterator, error) {
-	return r.StreamWithContext(context.Background(), defaultBufferSize)
+	return r.StreamWithContext(context.Background(), r.cli.bufferSize)
 }
 
 // StreamBufferSize is same as Stream but with custom bufferSize.
 // `bufferSize` is the desired buffer size to store streaming data.
 // One shard is equavalent to one minute.
+// How far ahead to download is controlled separately by `Prefetch` in `RawRequestParam`.
 func (r *RawRequest) StreamBufferSize(bufferSize int) (StringLineIterator, error) {
 	return r.StreamWithContext(context.Background(), bufferSize)
 }
@@ -723,13 +1290,62 @@ func (r *RawRequest) StreamBufferSize(bufferSize int) (StringLineIterator, error
 // Background downloads and the returned iterator will use the context for their lifetime.
 // Cancelling the context will stop running background downloads, and future `next` calls to the iterator might produce error.
 func (r *RawRequest) StreamWithContext(ctx context.Context, bufferSize int) (StringLineIterator, error) {
-	itr, serr := newRawStreamIterator(ctx, r, bufferSize)
+	end, serr := r.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	ctx, control, call := withStreamControls(r.withRetryBudget(ctx))
+	itr, serr := r.stream(ctx, bufferSize, false)
+	if serr != nil {
+		end()
+		return nil, serr
+	}
+	return &streamStringIterator{r.cli.trackStringIterator(itr), control, call, end}, nil
+}
+
+// withRetryBudget returns the context carrying the retry budget for an operation on the request.
+func (r *RawRequest) withRetryBudget(ctx context.Context) context.Context {
+	if r.retryBudget == nil {
+		return ctx
+	}
+	return withRetryBudget(ctx, *r.retryBudget)
+}
+
+// stream returns an iterator not tracked by the client.
+func (r *RawRequest) stream(ctx context.Context, bufferSize int, reverse bool) (StringLineIterator, error) {
+	if bufferSize < 1 {
+		return nil, errors.New("'bufferSize' must be positive")
+	}
+	itr, serr := newRawStreamIterator(ctx, r, bufferSize, reverse)
 	if serr != nil {
 		return nil, serr
 	}
 	return itr, nil
 }
 
+// StreamReverse is same as `Stream`, but lines are yielded in the reverse order, the newest first.
+// Shards are downloaded from the end of the range, so the iterator starts yielding
+// without downloading the whole range.
+// Snapshots taken at the start of the range are yielded at last.
+func (r *RawRequest) StreamReverse() (StringLineIterator, error) {
+	return r.StreamReverseWithContext(context.Background(), r.cli.bufferSize)
+}
+
+// StreamReverseWithContext is same as `StreamReverse` but a context and a buffer size can be given.
+func (r *RawRequest) StreamReverseWithContext(ctx context.Context, bufferSize int) (StringLineIterator, error) {
+	end, serr := r.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	ctx, control, call := withStreamControls(r.withRetryBudget(ctx))
+	itr, serr := r.stream(ctx, bufferSize, true)
+	if serr != nil {
+		end()
+		return nil, serr
+	}
+	return &streamStringIterator{r.cli.trackStringIterator(itr), control, call, end}, nil
+}
+
 // Raw creates new `RawRequest` with the given parameters and returns its pointer.
 // `*RawRequest` is nil if an error was returned.
 func Raw(clientParam ClientParam, param RawRequestParam) (*RawRequest, error) {
@@ -3,6 +3,10 @@ package exdgo
 import (
 	"bytes"
 	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -92,3 +96,789 @@ func TestRawDownloadAndStream(t *testing.T) {
 		t.Fatal("len(lines) != i")
 	}
 }
+
+func prepareFakeRawRequest(t *testing.T, srv *fakeServer) *RawRequest {
+	start, serr := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter: map[string][]string{
+			"bitmex":   []string{"orderBookL2"},
+			"bitfinex": []string{"trades_tBTCUSD"},
+		},
+		Start: start,
+		End:   start.Add(10 * time.Minute),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return req
+}
+
+func TestRawStreamCloseStopsGoroutines(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	itr, serr := req.StreamBufferSize(3)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	checkGoroutineLeak(t)
+	if _, ok, serr := itr.Next(); ok || serr != ErrIteratorClosed {
+		t.Fatalf("Next after Close: ok = %v, err = %v", ok, serr)
+	}
+}
+
+func TestRawStreamCancelStopsGoroutines(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	// Requests for later minutes never respond until they are cancelled
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Path, "/filter/") && !strings.HasSuffix(r.URL.Path, "/26297280") {
+			<-r.Context().Done()
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	itr, serr := req.StreamWithContext(ctx, 5)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	cancel()
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr == nil {
+				t.Fatal("stream ended without error after cancel")
+			}
+			break
+		}
+	}
+	itr.Close()
+	checkGoroutineLeak(t)
+}
+
+func TestRawDownloadErrorStopsGoroutines(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "/26297283") {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	if _, serr := req.DownloadConcurrency(4); serr == nil {
+		t.Fatal("expected error")
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestRawDownloadAndStreamFake(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// 2 snapshots and 2 channels * 6 lines * 10 minutes
+	if len(lines) != 2+2*6*10 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	i := 0
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if line.Timestamp != lines[i].Timestamp || line.Exchange != lines[i].Exchange {
+			t.Fatalf("line %d differ", i)
+		}
+		i++
+	}
+	if i != len(lines) {
+		t.Fatalf("streamed %d lines, downloaded %d", i, len(lines))
+	}
+}
+
+func TestRawStreamPrefetchBeyondBufferSize(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	prefetch := 8
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter:   map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:    start,
+		End:      start.Add(10 * time.Minute),
+		Prefetch: &prefetch,
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// Bytes of the largest shard
+	shards := make(map[int64]int64)
+	var largest int64
+	for _, line := range lines {
+		minute := line.Timestamp / int64(time.Minute)
+		shards[minute] += int64(len(line.Message))
+		if shards[minute] > largest {
+			largest = shards[minute]
+		}
+	}
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatal(serr)
+	}
+	// Let downloads run ahead of the reader
+	time.Sleep(100 * time.Millisecond)
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+	}
+	// The shard being read and the ones buffered
+	if peak := req.cli.Stats().PeakBufferedBytes; peak > 3*largest {
+		t.Fatalf("%d bytes buffered at most, more than 3 shards of %d bytes", peak, largest)
+	}
+}
+
+func TestRawDownloadRetriesShard(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var failed int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		// Fail the first two requests for one minute
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297283") && atomic.AddInt32(&failed, 1) <= 2 {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) != 2+2*6*10 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	if requests := atomic.LoadInt64(&srv.requests); requests != 2*11+2 {
+		t.Fatalf("requests = %d", requests)
+	}
+}
+
+func TestRawStreamRetriesShard(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var failed int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		// Fail the first two requests for one minute
+		if strings.HasSuffix(r.URL.Path, "bitfinex/26297285") && atomic.AddInt32(&failed, 1) <= 2 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	count := 0
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if count != 2+2*6*10 {
+		t.Fatalf("count = %d", count)
+	}
+	if atomic.LoadInt32(&failed) < 3 {
+		t.Fatal("failure was not injected")
+	}
+}
+
+func TestRawSkipMinutes(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297283") {
+			t.Errorf("skipped minute was requested: %s", r.URL.Path)
+		}
+		return true
+	}
+	start, serr := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter: map[string][]string{
+			"bitmex":   []string{"orderBookL2"},
+			"bitfinex": []string{"trades_tBTCUSD"},
+		},
+		Start:       start,
+		End:         start.Add(10 * time.Minute),
+		SkipMinutes: map[string][]time.Time{"bitmex": []time.Time{start.Add(3*time.Minute + 30*time.Second)}},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) != 2+2*6*10-6 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	count := 0
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if count != len(lines) {
+		t.Fatalf("count = %d", count)
+	}
+	if requests := atomic.LoadInt64(&srv.requests); requests != 2*(2*11-1) {
+		t.Fatalf("requests = %d", requests)
+	}
+}
+
+func TestRawSkipMinutesUnknownExchange(t *testing.T) {
+	_, serr := Raw(ClientParam{APIKey: "demo"}, RawRequestParam{
+		Filter:      map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:       time.Unix(1577836800, 0),
+		End:         time.Unix(1577836860, 0),
+		SkipMinutes: map[string][]time.Time{"bitflyer": nil},
+	})
+	if serr == nil || !strings.Contains(serr.Error(), "SkipMinutes") {
+		t.Fatalf("serr = %v", serr)
+	}
+}
+
+func TestRawClockOffsets(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start, serr := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter: map[string][]string{
+			"bitmex":   []string{"orderBookL2"},
+			"bitfinex": []string{"trades_tBTCUSD"},
+		},
+		Start: start,
+		End:   start.Add(10 * time.Minute),
+		// Lines of bitmex are 2ns ahead of ones of bitfinex at the same second, and now 3ns behind
+		ClockOffsets: map[string]time.Duration{"bitmex": 5},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) != 2+2*6*10 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	expected := map[string]int64{"bitmex": 11, "bitfinex": 8}
+	for i := range lines {
+		if lines[i].Timestamp%int64(time.Second) != expected[lines[i].Exchange] {
+			t.Fatalf("line %d: %+v", i, lines[i])
+		}
+		if i > 0 && lines[i].Timestamp < lines[i-1].Timestamp {
+			t.Fatalf("line %d is not in order: %+v", i, lines[i])
+		}
+	}
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	for i := 0; ; i++ {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if line.Exchange != lines[i].Exchange || line.Timestamp != lines[i].Timestamp {
+			t.Fatalf("line %d: %+v, expected %+v", i, line, lines[i])
+		}
+	}
+}
+
+func TestRawDownloadRetryBudget(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var mutex sync.Mutex
+	failed := make(map[string]bool)
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		// Fail the first request for every shard
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !failed[r.URL.Path] {
+			failed[r.URL.Path] = true
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	budget := 3
+	req.retryBudget = &budget
+	_, serr := req.DownloadConcurrency(1)
+	if serr == nil || !strings.Contains(serr.Error(), "retry budget used up") {
+		t.Fatalf("unexpected error: %v", serr)
+	}
+	// Budget is for each download
+	budget = 2 * 11
+	req.retryBudget = &budget
+	mutex.Lock()
+	failed = make(map[string]bool)
+	mutex.Unlock()
+	if _, serr := req.DownloadConcurrency(1); serr != nil {
+		t.Fatal(serr)
+	}
+
+	// Retrying would not finish before the deadline
+	wait := time.Hour
+	req.cli.retryWait = wait
+	req.retryBudget = nil
+	mutex.Lock()
+	failed = make(map[string]bool)
+	mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	started := time.Now()
+	_, serr = req.DownloadWithContext(ctx, 1)
+	if serr == nil || !strings.Contains(serr.Error(), "deadline") || time.Since(started) > 10*time.Second {
+		t.Fatalf("unexpected error: %v", serr)
+	}
+}
+
+func TestRawStreamStallTimeout(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297284") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	req.stallTimeout = 50 * time.Millisecond
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	count := 0
+	stalled := 0
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr == ErrStalled {
+				// Continue waiting
+				stalled++
+				continue
+			}
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if stalled == 0 {
+		t.Fatal("stall was not reported")
+	}
+	if count != 2+2*6*10 {
+		t.Fatalf("count = %d", count)
+	}
+}
+
+func TestRawStreamStallFirstShard(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Path, "/snapshot/bitmex/") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	req.stallTimeout = 50 * time.Millisecond
+	// Stream is made even if the first shard stalls
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	count := 0
+	stalled := 0
+	for {
+		_, ok, serr := itr.Next()
+		if !ok {
+			if serr == ErrStalled {
+				stalled++
+				continue
+			}
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		count++
+	}
+	if stalled == 0 {
+		t.Fatal("stall was not reported")
+	}
+	if count != 2+2*6*10 {
+		t.Fatalf("count = %d", count)
+	}
+}
+
+func TestRawDownloadDoesNotRetryClientError(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return false
+	}
+	req := prepareFakeRawRequest(t, srv)
+	_, serr := req.DownloadConcurrency(1)
+	if serr == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(serr.Error(), "forbidden") || strings.Contains(serr.Error(), "retries") {
+		t.Fatalf("unexpected error: %v", serr)
+	}
+}
+
+func TestRawUnknownFormatUndecoded(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	format := "csv"
+	req.format = &format
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// One line for each shard
+	if len(lines) != 2*11 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+	for _, line := range lines {
+		if line.Type != LineTypeUndecoded || len(line.Message) == 0 || line.Channel != nil {
+			t.Fatalf("line not undecoded: %+v", line)
+		}
+	}
+	if lines[0].Timestamp != req.start {
+		t.Fatalf("snapshot timestamp = %d", lines[0].Timestamp)
+	}
+}
+
+func TestRawUnusualChannelNames(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	channel := "BTC/USD perp&ü"
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter: map[string][]string{"bitmex": []string{channel}},
+		Start:  time.Unix(1577836800, 0),
+		End:    time.Unix(1577836800+60, 0),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) == 0 {
+		t.Fatal("no line")
+	}
+	for _, line := range lines {
+		if line.Channel == nil || *line.Channel != channel {
+			t.Fatalf("channel was not sent as is: %+v", line)
+		}
+	}
+}
+
+func TestRawFilterOut(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter: map[string][]string{
+			"bitmex": []string{"orderBookL2", "trade", "instrument"},
+		},
+		FilterOut: map[string][]string{
+			"bitmex": []string{"trade"},
+		},
+		Start: time.Unix(1577836800, 0),
+		End:   time.Unix(1577836800+60, 0),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(req.filter["bitmex"]) != 2 {
+		t.Fatalf("filter = %v", req.filter)
+	}
+	// Pretend the server returned a channel filtered-out
+	channel := "trade"
+	shard := req.dropExcluded("bitmex", []StringLine{{Channel: &channel}, {Type: LineTypeStart}})
+	if len(shard) != 1 || shard[0].Type != LineTypeStart {
+		t.Fatalf("shard = %v", shard)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	for _, line := range lines {
+		if line.Channel != nil && *line.Channel == "trade" {
+			t.Fatal("channel filtered-out was returned")
+		}
+	}
+	if _, serr := srv.client(t).Raw(RawRequestParam{
+		Filter:    map[string][]string{"bitmex": []string{"trade"}, "bitfinex": []string{"trades_tBTCUSD"}},
+		FilterOut: map[string][]string{"bitmex": []string{"trade"}},
+		Start:     time.Unix(1577836800, 0),
+		End:       time.Unix(1577836800+60, 0),
+	}); serr == nil {
+		t.Fatal("exchange without channels accepted")
+	}
+}
+
+func TestRawChannelRegex(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req, serr := srv.client(t).Raw(RawRequestParam{
+		Filter: map[string][]string{
+			"bitmex": []string{"trade_XBTUSD", "trade_ETHUSD", "orderBookL2_XBTUSD"},
+		},
+		ChannelRegex: map[string][]string{
+			"bitmex": []string{"^trade_"},
+		},
+		Start: time.Unix(1577836800, 0),
+		End:   time.Unix(1577836800+60, 0),
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(req.filter["bitmex"]) != 2 {
+		t.Fatalf("filter = %v", req.filter)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) == 0 {
+		t.Fatal("no line")
+	}
+	for _, line := range lines {
+		if line.Channel != nil && !strings.HasPrefix(*line.Channel, "trade_") {
+			t.Fatalf("channel not matching was returned: %s", *line.Channel)
+		}
+	}
+	if _, serr := srv.client(t).Raw(RawRequestParam{
+		Filter:       map[string][]string{"bitmex": []string{"trade"}},
+		ChannelRegex: map[string][]string{"bitmex": []string{"("}},
+		Start:        time.Unix(1577836800, 0),
+		End:          time.Unix(1577836800+60, 0),
+	}); serr == nil {
+		t.Fatal("invalid expression accepted")
+	}
+	if _, serr := srv.client(t).Raw(RawRequestParam{
+		Filter:       map[string][]string{"bitmex": []string{"trade_XBTUSD", "orderBookL2_XBTUSD"}},
+		ChannelRegex: map[string][]string{"bitmex": []string{"^quote_"}},
+		Start:        time.Unix(1577836800, 0),
+		End:          time.Unix(1577836800+60, 0),
+	}); serr == nil {
+		t.Fatal("expression matching no channel accepted")
+	}
+	resolved, serr := ResolveChannels([]string{"trade_XBTUSD", "quote_XBTUSD", "trade_ETHUSD"}, []string{"XBT", "^trade_ETH"})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(resolved) != 3 {
+		t.Fatalf("resolved = %v", resolved)
+	}
+}
+
+func TestRawRequestClampEnd(t *testing.T) {
+	cli, serr := setupClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	now := time.Now()
+	delay := 10 * time.Minute
+	param := RawRequestParam{
+		Filter:   map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:    now.Add(-time.Hour),
+		End:      now.Add(time.Hour),
+		ClampEnd: &delay,
+	}
+	req, serr := setupRawRequest(&cli, param)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if !req.Clamped() {
+		t.Fatal("End not clamped")
+	}
+	if end := req.End(); end.Before(now.Add(-delay)) || end.After(time.Now().Add(-delay)) {
+		t.Fatalf("End clamped to %v", end)
+	}
+	param.End = now.Add(-time.Hour / 2)
+	req, serr = setupRawRequest(&cli, param)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if req.Clamped() || !req.End().Equal(param.End) {
+		t.Fatal("End clamped while available")
+	}
+	param.Start = now.Add(-delay / 2)
+	param.End = now
+	if _, serr := setupRawRequest(&cli, param); serr == nil {
+		t.Fatal("Start after the latest available data accepted")
+	}
+}
+
+func TestRawRequestInclusiveEnd(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	param := RawRequestParam{
+		Filter: map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:  start,
+		// Exactly at the first line of the second minute
+		End: start.Add(time.Minute + time.Duration(len("bitmex"))),
+	}
+	for _, inclusive := range []bool{false, true} {
+		param.InclusiveEnd = inclusive
+		req, serr := setupRawRequest(srv.client(t), param)
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		lines, serr := req.Download()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		// A snapshot and 6 lines in the first minute
+		expected := 1 + 6
+		if inclusive {
+			expected++
+		}
+		if len(lines) != expected {
+			t.Fatalf("inclusive %v: len(lines) = %d", inclusive, len(lines))
+		}
+	}
+}
+
+func TestRawRequestNanos(t *testing.T) {
+	cli, serr := setupClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	start := int64(1577836800123456789)
+	end := start + int64(time.Minute)
+	filter := map[string][]string{"bitmex": []string{"orderBookL2"}}
+	req, serr := setupRawRequest(&cli, RawRequestParam{Filter: filter, StartNanos: &start, EndNanos: &end})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if req.start != start || req.end != end {
+		t.Fatalf("range %d-%d", req.start, req.end)
+	}
+	if _, serr := setupRawRequest(&cli, RawRequestParam{Filter: filter, Start: time.Unix(0, start), StartNanos: &start, EndNanos: &end}); serr == nil {
+		t.Fatal("both Start and StartNanos accepted")
+	}
+	if _, serr := setupRawRequest(&cli, RawRequestParam{Filter: filter, StartNanos: &end, EndNanos: &start}); serr == nil {
+		t.Fatal("reversed range accepted")
+	}
+}
+
+func TestRawRequestEndpoint(t *testing.T) {
+	mirror := newFakeServer()
+	defer mirror.close()
+	cli, serr := setupClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	// The default endpoint of the client is not reachable in tests
+	cli.endpoint = "http://127.0.0.1:1/"
+	start := time.Unix(1577836800, 0)
+	req, serr := setupRawRequest(&cli, RawRequestParam{
+		Filter:   map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:    start,
+		End:      start.Add(time.Minute),
+		Endpoint: mirror.server.URL,
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	if cli.endpoint != "http://127.0.0.1:1/" {
+		t.Fatal("endpoint of the client changed")
+	}
+	if _, serr := setupRawRequest(&cli, RawRequestParam{
+		Filter:   map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:    start,
+		End:      start.Add(time.Minute),
+		Endpoint: "mirror.local",
+	}); serr == nil {
+		t.Fatal("relative endpoint accepted")
+	}
+}
@@ -0,0 +1,304 @@
+package exdgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BytesLineIterator yields lines as they are in responses, without parsing them into `StringLine`.
+type BytesLineIterator interface {
+	// Next returns the next line and the exchange it is from.
+	// The line is in the form of the response of Filter HTTP Endpoint without the newline,
+	// and only valid until the next call, copy it to keep.
+	// `ok` is false if there is no more line or an error occurred.
+	Next() (exchange string, line []byte, ok bool, err error)
+	// Close stops downloading and frees resources, it must be called even after reaching the end.
+	Close() error
+}
+
+// rawBytesShard is the response for a shard, `body` is nil if data were not recorded.
+type rawBytesShard struct {
+	body []byte
+	err  error
+}
+
+// rawBytesExchangeIterator yields lines of an exchange from shards downloaded ahead.
+type rawBytesExchangeIterator struct {
+	req      *RawRequest
+	exchange string
+	// Results of shards in order, each is filled by a goroutine downloading the shard
+	shards chan chan *rawBytesShard
+	// Lines of the current shard not yet yielded
+	rest []byte
+}
+
+// background starts downloading shards of the exchange in order, up to the capacity of `shards` ahead.
+// `shards` is closed after all downloads have started.
+func (i *rawBytesExchangeIterator) background(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer i.req.cli.startBackground()()
+	defer close(i.shards)
+	start := func(fn func(ctx context.Context) ([]byte, error)) bool {
+		result := make(chan *rawBytesShard, 1)
+		select {
+		case i.shards <- result:
+		case <-ctx.Done():
+			return false
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer i.req.cli.startBackground()()
+			shard := new(rawBytesShard)
+			shard.err = retry(ctx, i.req.cli, func() error {
+				var serr error
+				shard.body, serr = fn(ctx)
+				return serr
+			})
+			// Never blocks
+			result <- shard
+		}()
+		return true
+	}
+	if !start(i.downloadSnapshot) {
+		return
+	}
+	startMinute := i.req.start / int64(time.Minute)
+	// End is exclusive
+	endMinute := (i.req.end - 1) / int64(time.Minute)
+	for minute := startMinute; minute <= endMinute; minute++ {
+		if i.req.skip[i.exchange][minute] {
+			continue
+		}
+		setting := filterSetting{
+			exchange: i.exchange,
+			channels: i.req.filter[i.exchange],
+			minute:   minute,
+			start:    &i.req.start,
+			end:      &i.req.end,
+			format:   i.req.format,
+		}
+		if !start(func(ctx context.Context) ([]byte, error) { return httpFilterBody(ctx, i.req.cli, setting) }) {
+			return
+		}
+	}
+}
+
+// downloadSnapshot returns snapshots in the form of lines of Filter HTTP Endpoint.
+func (i *rawBytesExchangeIterator) downloadSnapshot(ctx context.Context) ([]byte, error) {
+	snapshots, serr := httpSnapshot(ctx, i.req.cli, snapshotSetting{
+		exchange: i.exchange,
+		channels: i.req.filter[i.exchange],
+		at:       i.req.start,
+		format:   i.req.format,
+	})
+	if serr != nil {
+		return nil, serr
+	}
+	var buf bytes.Buffer
+	for _, ss := range snapshots {
+		buf.WriteString(string(LineTypeMessage))
+		buf.WriteByte('\t')
+		buf.WriteString(strconv.FormatInt(ss.Timestamp, 10))
+		buf.WriteByte('\t')
+		buf.WriteString(ss.Channel)
+		buf.WriteByte('\t')
+		// Snapshots keep the newline of the response
+		buf.Write(bytes.TrimSuffix(ss.Snapshot, []byte{'\n'}))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// next returns the next line of the exchange and its timestamp, nil if there is no more line.
+func (i *rawBytesExchangeIterator) next() ([]byte, int64, error) {
+	for {
+		for len(i.rest) == 0 {
+			result, ok := <-i.shards
+			if !ok {
+				return nil, 0, nil
+			}
+			shard := <-result
+			if shard.err != nil {
+				return nil, 0, shard.err
+			}
+			i.rest = shard.body
+		}
+		// Every line ends with a newline
+		newline := bytes.IndexByte(i.rest, '\n')
+		line := i.rest[:newline]
+		i.rest = i.rest[newline+1:]
+		typ, timestamp, channel, serr := parseBytesLine(line)
+		if serr != nil {
+			return nil, 0, fmt.Errorf("%s: %v", i.exchange, serr)
+		}
+		if typ == LineTypeEnd {
+			// Lines after an end line are ignored
+			i.rest = nil
+		}
+		if channel != nil && i.req.excluded(i.exchange, channel) {
+			continue
+		}
+		// Only the order is corrected, lines are yielded as they are
+		return line, timestamp + i.req.offsets[i.exchange], nil
+	}
+}
+
+// excluded reports whether lines of the channel are dropped by `FilterOut` or `ChannelRegex`.
+func (r *RawRequest) excluded(exchange string, channel []byte) bool {
+	exclude := r.exclude[exchange]
+	patterns, hasPatterns := r.patterns[exchange]
+	if len(exclude) == 0 && !hasPatterns {
+		return false
+	}
+	return exclude[string(channel)] || (hasPatterns && !matchAny(string(channel), patterns))
+}
+
+// parseBytesLine returns the type, the timestamp and the channel of a line of Filter HTTP Endpoint.
+// The channel is nil if the line has none.
+func parseBytesLine(line []byte) (typ LineType, timestamp int64, channel []byte, err error) {
+	tab := bytes.IndexByte(line, '\t')
+	if tab == -1 {
+		return "", 0, nil, fmt.Errorf("no timestamp: %q", line)
+	}
+	typ = LineType(line[:tab])
+	rest := line[tab+1:]
+	end := bytes.IndexByte(rest, '\t')
+	if end == -1 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return "", 0, nil, fmt.Errorf("empty timestamp: %q", line)
+	}
+	for _, c := range rest[:end] {
+		if c < '0' || c > '9' {
+			return "", 0, nil, fmt.Errorf("invalid timestamp: %q", line)
+		}
+		timestamp = timestamp*10 + int64(c-'0')
+	}
+	if typ == LineTypeMessage || typ == LineTypeSend {
+		rest = rest[end:]
+		if len(rest) == 0 {
+			return "", 0, nil, fmt.Errorf("no channel: %q", line)
+		}
+		rest = rest[1:]
+		if tab := bytes.IndexByte(rest, '\t'); tab != -1 {
+			channel = rest[:tab]
+		}
+	}
+	return typ, timestamp, channel, nil
+}
+
+// rawBytesIterator merges lines of exchanges in the order of timestamps.
+type rawBytesIterator struct {
+	cancel context.CancelFunc
+	// Tells the client the stream finished
+	end       func()
+	wg        sync.WaitGroup
+	iterators []*rawBytesExchangeIterator
+	// Next line and its timestamp for each exchange, nil if the exchange reached the end
+	heads      [][]byte
+	timestamps []int64
+	// Exchange of the line yielded by the last call, -1 if none
+	yielded int
+	started bool
+	closed  bool
+}
+
+func (i *rawBytesIterator) Next() (string, []byte, bool, error) {
+	if i.closed {
+		return "", nil, false, ErrIteratorClosed
+	}
+	if !i.started {
+		for j := range i.iterators {
+			if serr := i.advance(j); serr != nil {
+				return "", nil, false, serr
+			}
+		}
+		i.started = true
+	} else if i.yielded != -1 {
+		if serr := i.advance(i.yielded); serr != nil {
+			return "", nil, false, serr
+		}
+	}
+	argmin := -1
+	for j, head := range i.heads {
+		if head != nil && (argmin == -1 || i.timestamps[j] < i.timestamps[argmin]) {
+			argmin = j
+		}
+	}
+	i.yielded = argmin
+	if argmin == -1 {
+		return "", nil, false, nil
+	}
+	return i.iterators[argmin].exchange, i.heads[argmin], true, nil
+}
+
+func (i *rawBytesIterator) advance(j int) error {
+	line, timestamp, serr := i.iterators[j].next()
+	if serr != nil {
+		return serr
+	}
+	i.heads[j] = line
+	i.timestamps[j] = timestamp
+	return nil
+}
+
+func (i *rawBytesIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	i.cancel()
+	i.wg.Wait()
+	i.end()
+	return nil
+}
+
+// StreamBytes streams lines without parsing them, for consumers which forward or archive lines as they are.
+// Lines are in the form of the response of Filter HTTP Endpoint, snapshots are in the form of message lines,
+// and lines of exchanges are merged in the order of timestamps as `Stream` does.
+// `FilterOut`, `ChannelRegex` and `SkipMinutes` are applied,
+// and `ClockOffsets` only corrects the order, timestamps in lines are not rewritten.
+//
+// Up to `bufferSize` shards are downloaded concurrently ahead for each exchange.
+// `Format` must be a format this client can decode, such as "json" or "raw".
+func (r *RawRequest) StreamBytes(ctx context.Context, bufferSize int) (BytesLineIterator, error) {
+	if bufferSize < 1 {
+		return nil, errors.New("'bufferSize' must be positive")
+	}
+	if !isKnownFormat(r.format) {
+		return nil, fmt.Errorf("lines of format '%s' can not be streamed", *r.format)
+	}
+	end, serr := r.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	ctx, cancel := context.WithCancel(r.withRetryBudget(ctx))
+	i := &rawBytesIterator{cancel: cancel, end: end, yielded: -1}
+	exchanges := make([]string, 0, len(r.filter))
+	for exchange := range r.filter {
+		exchanges = append(exchanges, exchange)
+	}
+	// Lines of the same timestamp are yielded in the same order every time
+	sort.Strings(exchanges)
+	for _, exchange := range exchanges {
+		itr := &rawBytesExchangeIterator{
+			req:      r,
+			exchange: exchange,
+			shards:   make(chan chan *rawBytesShard, bufferSize),
+		}
+		i.iterators = append(i.iterators, itr)
+		i.wg.Add(1)
+		go itr.background(ctx, &i.wg)
+	}
+	i.heads = make([][]byte, len(exchanges))
+	i.timestamps = make([]int64, len(exchanges))
+	return i, nil
+}
@@ -0,0 +1,89 @@
+package exdgo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRawStreamBytes(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := req.StreamBytes(context.Background(), 3)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	count := 0
+	for ; ; count++ {
+		exchange, line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if count >= len(expected) {
+			t.Fatalf("more lines than %d", len(expected))
+		}
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		writeStringLine(w, &expected[count])
+		w.Flush()
+		// Messages of snapshots keep the newline of the response
+		if exchange != expected[count].Exchange || string(line) != strings.TrimRight(buf.String(), "\n") {
+			t.Fatalf("line %d: %s %q, expected %s %q", count, exchange, line, expected[count].Exchange, buf.String())
+		}
+	}
+	if count != len(expected) {
+		t.Fatalf("count = %d, expected %d", count, len(expected))
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, _, _, serr := itr.Next(); serr != ErrIteratorClosed {
+		t.Fatalf("serr = %v after close", serr)
+	}
+}
+
+func TestRawStreamBytesCloseStopsGoroutines(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	itr, serr := req.StreamBytes(context.Background(), 2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	if n := req.cli.Debug().BackgroundGoroutines; n != 0 {
+		t.Fatalf("%d goroutines left", n)
+	}
+}
+
+func TestParseBytesLine(t *testing.T) {
+	typ, timestamp, channel, serr := parseBytesLine([]byte("msg\t1577836800000000000\ttrade\t{\"a\":1}"))
+	if serr != nil || typ != LineTypeMessage || timestamp != 1577836800000000000 || string(channel) != "trade" {
+		t.Fatalf("%v %d %q %v", typ, timestamp, channel, serr)
+	}
+	typ, timestamp, channel, serr = parseBytesLine([]byte("end\t1577836800000000001"))
+	if serr != nil || typ != LineTypeEnd || timestamp != 1577836800000000001 || channel != nil {
+		t.Fatalf("%v %d %q %v", typ, timestamp, channel, serr)
+	}
+	for _, line := range []string{"msg", "msg\t\ttrade\t{}", "msg\t12a\ttrade\t{}", "msg\t1"} {
+		if _, _, _, serr := parseBytesLine([]byte(line)); serr == nil {
+			t.Errorf("%q parsed", line)
+		}
+	}
+}
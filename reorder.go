@@ -0,0 +1,71 @@
+package exdgo
+
+import (
+	"container/heap"
+	"errors"
+	"time"
+)
+
+type reorderIterator struct {
+	itr     StructLineIterator
+	window  int64
+	reverse bool
+	pending timedLineHeap
+	seq     int64
+	// The newest key seen
+	latest int64
+	ended  bool
+	line   StructLine
+}
+
+// Reorder returns an iterator which sorts lines from the given iterator within the time window,
+// so lines out of order by less than the window are yielded in the order of timestamp.
+// Lines are held until a line newer by the window arrives, thus the consumer sees lines
+// delayed by the window in terms of timestamp.
+// Lines with the same timestamp are yielded in the original order.
+//
+// The iterator given is closed when the returned iterator is closed.
+func Reorder(itr StructLineIterator, window time.Duration) (StructLineIterator, error) {
+	if window < 0 {
+		return nil, errors.New("negative window")
+	}
+	return newReorderIterator(itr, window, false), nil
+}
+
+func newReorderIterator(itr StructLineIterator, window time.Duration, reverse bool) *reorderIterator {
+	return &reorderIterator{itr: itr, window: int64(window), reverse: reverse}
+}
+
+func (i *reorderIterator) Next() (*StructLine, bool, error) {
+	for {
+		if len(i.pending) > 0 && (i.ended || i.latest-i.pending[0].at >= i.window) {
+			i.line = heap.Pop(&i.pending).(timedLine).line
+			return &i.line, true, nil
+		}
+		if i.ended {
+			return nil, false, nil
+		}
+		line, ok, serr := i.itr.Next()
+		if !ok {
+			if serr != nil {
+				return nil, false, serr
+			}
+			i.ended = true
+			continue
+		}
+		// Key is negated if reversed, so the heap yields the newest first
+		key := line.Timestamp
+		if i.reverse {
+			key = -key
+		}
+		if i.seq == 0 || key > i.latest {
+			i.latest = key
+		}
+		heap.Push(&i.pending, timedLine{line: *line, at: key, seq: i.seq})
+		i.seq++
+	}
+}
+
+func (i *reorderIterator) Close() error {
+	return i.itr.Close()
+}
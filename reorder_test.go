@@ -0,0 +1,43 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReorder(t *testing.T) {
+	lines := testLines(6, []string{"bitmex", "bitfinex"}, []string{"trades"})
+	// Swap timestamps of neighbours, and make a line which is too late for the window
+	lines[1].Timestamp, lines[2].Timestamp = lines[2].Timestamp, lines[1].Timestamp
+	lines[5].Timestamp = lines[0].Timestamp
+	itr, serr := Reorder(newSliceIterator(lines), 2*time.Second)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	reordered := readAllStructLines(t, itr)
+	// The late line is released as soon as it arrives
+	expected := []int64{0, 1, 2, 0, 3, 4}
+	if len(reordered) != len(expected) {
+		t.Fatalf("len(reordered) = %d", len(reordered))
+	}
+	for j := range expected {
+		if reordered[j].Timestamp != expected[j]*int64(time.Second) {
+			t.Fatalf("line %d has timestamp %d", j, reordered[j].Timestamp)
+		}
+	}
+	if _, serr := Reorder(newSliceIterator(lines), -time.Second); serr == nil {
+		t.Fatal("negative window accepted")
+	}
+}
+
+func TestReorderReverse(t *testing.T) {
+	lines := testLines(4, []string{"bitmex"}, []string{"trades"})
+	reverseStructLines(lines)
+	lines[0].Timestamp, lines[1].Timestamp = lines[1].Timestamp, lines[0].Timestamp
+	reordered := readAllStructLines(t, newReorderIterator(newSliceIterator(lines), time.Second, true))
+	for j := 1; j < len(reordered); j++ {
+		if reordered[j].Timestamp > reordered[j-1].Timestamp {
+			t.Fatalf("not in reverse order: %d", j)
+		}
+	}
+}
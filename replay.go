@@ -1,10 +1,13 @@
 package exdgo
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"strconv"
 	"time"
 )
@@ -13,10 +16,120 @@ import (
 type ReplayRequestParam struct {
 	// Map of exchanges and and its channels to filter-in.
 	Filter map[string][]string
+	// Map of exchanges and its channels to filter-out.
+	// See `RawRequestParam`.
+	// Optional.
+	FilterOut map[string][]string
+	// Map of exchanges and regular expressions of channels to keep.
+	// See `RawRequestParam`.
+	// Optional.
+	ChannelRegex map[string][]string
 	// Start date-time.
 	Start time.Time
-	// End date-time.
+	// End date-time, exclusive by default.
+	// See `RawRequestParam`.
 	End time.Time
+	// Start in nanoseconds since the unix epoch instead of `Start`.
+	// See `RawRequestParam`.
+	// Optional, can not be given with `Start`.
+	StartNanos *int64
+	// End in nanoseconds since the unix epoch instead of `End`.
+	// Optional, can not be given with `End`.
+	EndNanos *int64
+	// If true, lines recorded exactly at `End`, or at the end of each range of `Ranges`, are included.
+	// The end of a range is still exclusive if the next range starts at it, so lines are not yielded twice.
+	InclusiveEnd bool
+	// Time the server takes to make recorded data available.
+	// See `RawRequestParam`.
+	// With `Ranges`, each range is clamped, and a range starting after the latest available data is an error.
+	// Optional.
+	ClampEnd *time.Duration
+	// Multiple time ranges to replay instead of `Start` and `End`.
+	// Ranges must not overlap, and are served in the order of time as one stream,
+	// each of them starting with snapshots.
+	// Optional.
+	Ranges []TimeRange
+	// Base URL of the API to send requests of this request to, instead of the one of the client.
+	// See `RawRequestParam`.
+	// Optional.
+	Endpoint string
+	// Number of shards to download ahead of the consumer while streaming, per exchange.
+	// See `RawRequestParam`.
+	Prefetch *int
+	// Number of goroutines to decode messages into `StructLine` in parallel.
+	// Lines are yielded in the same order regardless of this value.
+	// Optional, defaults to 1 which decodes on the goroutine calling `Next`.
+	DecodeWorkers *int
+	// Retain the original JSON bytes of message in `StructLine.Raw` in addition to the converted map.
+	KeepRaw bool
+	// Report `*SchemaError` when a message has a field absent from its definition,
+	// or a value not matching the declared type,
+	// instead of passing it through as is.
+	Strict bool
+	// Convert fields of type "timestamp" into `time.Time` in UTC and "duration" into `time.Duration`
+	// instead of int64 in nanoseconds.
+	TimeTypes bool
+	// Decode numbers into `json.Number` instead of float64 to keep their precision.
+	// Fields of type "int" are still converted into int64, without going through float64
+	// unless written as a float such as 1.0 or 1e3.
+	UseNumber bool
+	// Set `StructLine.Message` to `map[string]json.RawMessage` without any conversion,
+	// letting fields be decoded only when needed.
+	// Can not be used with `Strict`, `TimeTypes` and `UseNumber`.
+	RawFields bool
+	// Yield messages as decoded from JSON without converting fields by their definitions,
+	// so fields of type "timestamp" stay strings and "int" stay float64, for consumers which type fields themselves.
+	// Can not be used with `Strict`, `TimeTypes` and `RawFields`.
+	SkipConversion bool
+	// Struct types to decode messages of matching channels into, in place of maps.
+	// Other options on decoding except `KeepRaw` do not apply to those messages.
+	// Optional.
+	Decoders *DecoderRegistry
+	// Called with lines other than messages, such as start, end and error lines, in order.
+	// Those lines are not yielded if this is set, so the consumer only sees messages.
+	// Returning an error stops reading and the error is returned to the consumer.
+	// Optional.
+	OnControlLine func(line *StructLine) error
+	// Called with the event parsed from each start, end and error line, in order.
+	// Lines are still yielded unless `OnControlLine` is set. See `NotifyStreamEvents`.
+	// Optional.
+	OnEvent func(event StreamEvent) error
+	// Called every time a shard is downloaded by `Download`, with the progress and the estimated time left.
+	// Called from one goroutine at a time.
+	// Optional.
+	OnProgress func(p Progress)
+	// If `Next` of a stream waits for a shard to be downloaded longer than this, it returns `ErrStalled`.
+	// Downloads continue in background, and calling `Next` again waits for the shard again,
+	// unless `DecodeWorkers` is set, which makes the error final.
+	// A stream is returned even if the first shard of an exchange stalls.
+	// Optional, waits forever by default.
+	StallTimeout *time.Duration
+	// If a shard download while streaming takes longer than this percentile of download time observed,
+	// a duplicate request is sent and whichever finishes first is used, to reduce tail latency.
+	// In the range of (0, 1), for example 0.95.
+	// Optional, no duplicate request is sent by default.
+	HedgePercentile *float64
+	// See `RawRequestParam`.
+	// Optional.
+	RetryBudget *int
+	// See `RawRequestParam`.
+	// Optional.
+	OnShard func(exchange string, minute time.Time, lines, bytes int)
+	// See `RawRequestParam`.
+	// Optional.
+	SkipMinutes map[string][]time.Time
+	// See `RawRequestParam`.
+	// Optional.
+	ClockOffsets map[string]time.Duration
+	// If true, lines are verified to be in order of timestamp, and `*OrderError` is returned
+	// for a line out of order. See `VerifyOrder`.
+	VerifyOrder bool
+	// If set, lines out of order by less than this are sorted before yielded. See `Reorder`.
+	// Optional.
+	ReorderWindow *time.Duration
+	// If true, a line of `LineTypeDefinitionChange` is yielded when the definition of a channel changed.
+	// See `DetectDefinitionChanges`.
+	DefinitionChanges bool
 }
 
 // ReplayRequest replays market data.
@@ -25,30 +138,117 @@ type ReplayRequestParam struct {
 // - `download` to immidiately start downloading the whole response as one array.
 // - `stream` to return iterable object yields line by line.
 type ReplayRequest struct {
-	cli    *Client
-	filter map[string][]string
-	start  int64
-	end    int64
+	// Underlying request in json format
+	raw *RawRequest
+	// Number of goroutines to decode lines
+	decodeWorkers int
+	decode        decodeSetting
+	onControlLine func(line *StructLine) error
+	onEvent       func(event StreamEvent) error
+	verifyOrder   bool
+	// Yields lines of `LineTypeDefinitionChange` if true
+	definitionChanges bool
+	// Requests for each range if `Ranges` was specified
+	segments []*ReplayRequest
+	// nil if not specified
+	reorderWindow *time.Duration
 }
 
+// decodeSetting controls how messages are converted into `StructLine`.
+type decodeSetting struct {
+	keepRaw   bool
+	strict    bool
+	timeTypes bool
+	useNumber bool
+	rawFields bool
+	// Fields are not converted by definitions if true
+	skipConversion bool
+	// nil if no type is registered
+	decoders *DecoderRegistry
+}
+
+// setupReplayRequest validates parameter and creates new `ReplayRequest`.
+// All of problems in parameters are reported at once in `ParamErrors`.
 func setupReplayRequest(cli *Client, param ReplayRequestParam) (*ReplayRequest, error) {
+	if len(param.Ranges) > 0 {
+		return setupSegmentedReplayRequest(cli, param)
+	}
+	var errs ParamErrors
+	format := "json"
+	raw, serr := setupRawRequest(cli, RawRequestParam{
+		Filter:          param.Filter,
+		FilterOut:       param.FilterOut,
+		ChannelRegex:    param.ChannelRegex,
+		Start:           param.Start,
+		End:             param.End,
+		StartNanos:      param.StartNanos,
+		EndNanos:        param.EndNanos,
+		InclusiveEnd:    param.InclusiveEnd,
+		ClampEnd:        param.ClampEnd,
+		Endpoint:        param.Endpoint,
+		Format:          &format,
+		Prefetch:        param.Prefetch,
+		OnProgress:      param.OnProgress,
+		StallTimeout:    param.StallTimeout,
+		HedgePercentile: param.HedgePercentile,
+		RetryBudget:     param.RetryBudget,
+		OnShard:         param.OnShard,
+		SkipMinutes:     param.SkipMinutes,
+		ClockOffsets:    param.ClockOffsets,
+	})
+	errs.add(serr)
 	req := new(ReplayRequest)
-	req.cli = cli
-	var serr error
-	req.filter, serr = copyFilter(param.Filter)
-	if serr != nil {
-		return nil, serr
+	req.raw = raw
+	req.decodeWorkers = 1
+	// Optional parameter
+	if param.DecodeWorkers != nil {
+		if *param.DecodeWorkers < 1 {
+			errs.add(errors.New("'DecodeWorkers' must be positive"))
+		}
+		req.decodeWorkers = *param.DecodeWorkers
+	}
+	req.decode.keepRaw = param.KeepRaw
+	req.decode.strict = param.Strict
+	req.decode.timeTypes = param.TimeTypes
+	req.decode.useNumber = param.UseNumber
+	req.decode.rawFields = param.RawFields
+	req.decode.skipConversion = param.SkipConversion
+	req.decode.decoders = param.Decoders
+	req.onControlLine = param.OnControlLine
+	req.onEvent = param.OnEvent
+	req.verifyOrder = param.VerifyOrder
+	req.definitionChanges = param.DefinitionChanges
+	// Optional parameter
+	if param.ReorderWindow != nil {
+		if *param.ReorderWindow < 0 {
+			errs.add(errors.New("'ReorderWindow' negative"))
+		}
+		window := *param.ReorderWindow
+		req.reorderWindow = &window
+	}
+	if param.RawFields && (param.Strict || param.TimeTypes || param.UseNumber) {
+		errs.add(errors.New("'RawFields' can not be used with 'Strict', 'TimeTypes' or 'UseNumber'"))
+	}
+	if param.SkipConversion && (param.Strict || param.TimeTypes || param.RawFields) {
+		errs.add(errors.New("'SkipConversion' can not be used with 'Strict', 'TimeTypes' or 'RawFields'"))
 	}
-	start := param.Start.UnixNano()
-	end := param.End.UnixNano()
-	if start >= end {
-		return nil, errors.New("'Start' >= 'End'")
+	if serr := errs.err(); serr != nil {
+		return nil, serr
 	}
-	req.start = start
-	req.end = end
 	return req, nil
 }
 
+// End returns the end of the range to replay.
+// It is earlier than `End` of the parameter if it was clamped by `ClampEnd`.
+func (r *ReplayRequest) End() time.Time {
+	return r.raw.End()
+}
+
+// Clamped reports whether `End` was clamped to the latest available data by `ClampEnd`.
+func (r *ReplayRequest) Clamped() bool {
+	return r.raw.Clamped()
+}
+
 type rawLineProcessor struct {
 	// map[exchange]map[channel]map[field]type
 	defs map[string]map[string]map[string]string
@@ -60,27 +260,24 @@ func newRawLineProcessor() *rawLineProcessor {
 	return p
 }
 
-func (p *rawLineProcessor) processRawLine(line *StringLine) (ret StructLine, ok bool, err error) {
+// track updates definitions with the given line and returns the definition for it.
+// This have to be called for every line in order, while `convertRawLine` can be
+// called afterward on any goroutine.
+//
+// `skip` is true if the line is a definition and should not be yielded.
+// Definition is nil for lines other than messages.
+func (p *rawLineProcessor) track(line *StringLine) (def map[string]string, skip bool, err error) {
 	if line.Type == LineTypeStart {
 		// Delete definition
 		delete(p.defs, line.Exchange)
 	}
 	if line.Type != LineTypeMessage {
-		ret = StructLine{
-			Exchange:  line.Exchange,
-			Type:      line.Type,
-			Timestamp: line.Timestamp,
-			Channel:   line.Channel,
-			Message:   line.Message,
-		}
-		ok = true
 		return
 	}
 
 	exchange := line.Exchange
 	// Channel and message fields are always available since Type == LineTypeMsg
 	channel := *line.Channel
-	message := line.Message
 
 	if _, sok := p.defs[exchange]; !sok {
 		// This is the first line for this exchange
@@ -89,106 +286,400 @@ func (p *rawLineProcessor) processRawLine(line *StringLine) (ret StructLine, ok
 	def, sok := p.defs[exchange][channel]
 	if !sok {
 		def = make(map[string]string)
-		if serr := json.Unmarshal(message, &def); serr != nil {
+		if serr := json.Unmarshal(line.Message, &def); serr != nil {
 			err = fmt.Errorf("def update unmarshal: %v", serr)
 			return
 		}
 		p.defs[exchange][channel] = def
+		skip = true
+		return
+	}
+	return
+}
+
+// convertRawLine converts a line into `StructLine` according to the definition returned by `track`.
+func convertRawLine(line *StringLine, def map[string]string, setting *decodeSetting) (ret StructLine, err error) {
+	if line.Type != LineTypeMessage {
+		ret = StructLine{
+			Exchange:  line.Exchange,
+			Type:      line.Type,
+			Timestamp: line.Timestamp,
+			Channel:   line.Channel,
+			Message:   line.Message,
+		}
+		return
+	}
+	if typ := setting.decoders.lookup(line.Exchange, *line.Channel); typ != nil {
+		var message interface{}
+		message, err = decodeRegistered(line, typ)
+		if err != nil {
+			return
+		}
+		ret = StructLine{
+			Exchange:   line.Exchange,
+			Type:       line.Type,
+			Timestamp:  line.Timestamp,
+			Channel:    line.Channel,
+			Message:    message,
+			Definition: def,
+		}
+		if setting.keepRaw {
+			ret.Raw = json.RawMessage(line.Message)
+		}
+		return
+	}
+	if setting.rawFields {
+		fields := make(map[string]json.RawMessage)
+		if serr := json.Unmarshal(line.Message, &fields); serr != nil {
+			err = fmt.Errorf("message unmarshal: %v", serr)
+			return
+		}
+		ret = StructLine{
+			Exchange:   line.Exchange,
+			Type:       line.Type,
+			Timestamp:  line.Timestamp,
+			Channel:    line.Channel,
+			Message:    fields,
+			Definition: def,
+		}
+		if setting.keepRaw {
+			ret.Raw = json.RawMessage(line.Message)
+		}
 		return
 	}
 	msgObj := make(map[string]interface{})
-	serr := json.Unmarshal(message, &msgObj)
+	var serr error
+	if setting.useNumber {
+		decoder := json.NewDecoder(bytes.NewReader(line.Message))
+		decoder.UseNumber()
+		serr = decoder.Decode(&msgObj)
+	} else {
+		serr = json.Unmarshal(line.Message, &msgObj)
+	}
 	if serr != nil {
 		err = fmt.Errorf("message unmarshal: %v", serr)
 		return
 	}
 
-	// Type conversion according to the received definition
-	for name, typ := range def {
-		if val, sok := msgObj[name]; sok && val != nil {
-			if typ == "timestamp" || typ == "duration" {
-				msgObj[name], serr = strconv.ParseInt(val.(string), 10, 64)
-				if serr != nil {
-					err = fmt.Errorf("type conversion: %v", serr)
+	// Type conversion according to the received definition, unless skipped
+	if !setting.skipConversion {
+		for name, val := range msgObj {
+			typ, defined := def[name]
+			if !defined {
+				if setting.strict {
+					err = &SchemaError{
+						Exchange:  line.Exchange,
+						Channel:   *line.Channel,
+						Timestamp: line.Timestamp,
+						Field:     name,
+						Value:     val,
+						Reason:    "field not in definition",
+					}
+					return
+				}
+				continue
+			}
+			if val == nil {
+				continue
+			}
+			converted, serr := convertField(typ, val, setting)
+			if serr != nil {
+				if setting.strict {
+					err = &SchemaError{
+						Exchange:  line.Exchange,
+						Channel:   *line.Channel,
+						Timestamp: line.Timestamp,
+						Field:     name,
+						Type:      typ,
+						Value:     val,
+						Reason:    serr.Error(),
+					}
 					return
 				}
-			} else if typ == "int" {
-				msgObj[name] = int64(val.(float64))
+				err = fmt.Errorf("type conversion: %v", serr)
+				return
 			}
+			msgObj[name] = converted
 		}
 	}
 
 	ret = StructLine{
-		Exchange:   exchange,
+		Exchange:   line.Exchange,
 		Type:       line.Type,
 		Timestamp:  line.Timestamp,
 		Channel:    line.Channel,
 		Message:    msgObj,
 		Definition: def,
 	}
+	if setting.keepRaw {
+		ret.Raw = json.RawMessage(line.Message)
+	}
+	return
+}
+
+// convertField converts a value decoded from JSON according to the type in a definition.
+// Only types which need conversion are checked unless in strict mode.
+// Values of unknown types are returned as is.
+func convertField(typ string, val interface{}, setting *decodeSetting) (interface{}, error) {
+	strict := setting.strict
+	switch typ {
+	case "timestamp", "duration":
+		str, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", val)
+		}
+		nanos, serr := strconv.ParseInt(str, 10, 64)
+		if serr != nil {
+			return nil, serr
+		}
+		if !setting.timeTypes {
+			return nanos, nil
+		}
+		if typ == "timestamp" {
+			return time.Unix(0, nanos).UTC(), nil
+		}
+		return time.Duration(nanos), nil
+	case "int":
+		if num, ok := val.(json.Number); ok {
+			if integer, serr := num.Int64(); serr == nil {
+				return integer, nil
+			}
+			// Not in the form of an integer such as 1.0 or 1e3, checked as float64 below
+			float, serr := num.Float64()
+			if serr != nil {
+				return nil, fmt.Errorf("expected number, got %v", num)
+			}
+			val = float
+		}
+		num, ok := val.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected number, got %T", val)
+		}
+		if strict && num != math.Trunc(num) {
+			return nil, fmt.Errorf("expected integer, got %v", num)
+		}
+		return int64(num), nil
+	case "float":
+		switch val.(type) {
+		case float64, json.Number:
+		default:
+			if strict {
+				return nil, fmt.Errorf("expected number, got %T", val)
+			}
+		}
+	case "string":
+		if _, ok := val.(string); strict && !ok {
+			return nil, fmt.Errorf("expected string, got %T", val)
+		}
+	case "boolean":
+		if _, ok := val.(bool); strict && !ok {
+			return nil, fmt.Errorf("expected boolean, got %T", val)
+		}
+	}
+	return val, nil
+}
+
+// SchemaError is the error reported in strict mode when a message does not match its definition.
+type SchemaError struct {
+	Exchange  string
+	Channel   string
+	Timestamp int64
+	// Name of the field violating the definition.
+	Field string
+	// Type declared in the definition, empty if the field is not defined.
+	Type string
+	// Value of the field decoded from JSON.
+	Value interface{}
+	// Why the field violates the definition.
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("schema violation at %s %s %d field '%s': %s", e.Exchange, e.Channel, e.Timestamp, e.Field, e.Reason)
+}
+
+func (p *rawLineProcessor) processRawLine(line *StringLine, setting *decodeSetting) (ret StructLine, ok bool, err error) {
+	def, skip, err := p.track(line)
+	if err != nil || skip {
+		return
+	}
+	ret, err = convertRawLine(line, def, setting)
+	if err != nil {
+		return
+	}
 	ok = true
 	return
 }
 
+// LineDecoderParam is the parameters for `NewLineDecoderWithParam`.
+// Fields are same as ones in `ReplayRequestParam`.
+type LineDecoderParam struct {
+	KeepRaw        bool
+	Strict         bool
+	TimeTypes      bool
+	UseNumber      bool
+	RawFields      bool
+	SkipConversion bool
+}
+
+// LineDecoder converts lines in json format into `StructLine`, in the same way as `ReplayRequest`.
+// Lines from `RawRequest` with `Format` of "json", or files of them can be decoded.
+//
+// It tracks definitions of channels sent before messages, thus all lines have to be
+// given in order. Not safe for concurrent use.
+type LineDecoder struct {
+	processor *rawLineProcessor
+	setting   decodeSetting
+}
+
+// NewLineDecoder returns a `LineDecoder` with the default setting.
+func NewLineDecoder() *LineDecoder {
+	return &LineDecoder{processor: newRawLineProcessor()}
+}
+
+// NewLineDecoderWithParam returns a `LineDecoder` with the given setting.
+func NewLineDecoderWithParam(param LineDecoderParam) (*LineDecoder, error) {
+	if param.RawFields && (param.Strict || param.TimeTypes || param.UseNumber) {
+		return nil, errors.New("'RawFields' can not be used with 'Strict', 'TimeTypes' or 'UseNumber'")
+	}
+	if param.SkipConversion && (param.Strict || param.TimeTypes || param.RawFields) {
+		return nil, errors.New("'SkipConversion' can not be used with 'Strict', 'TimeTypes' or 'RawFields'")
+	}
+	d := NewLineDecoder()
+	d.setting = decodeSetting{
+		keepRaw:        param.KeepRaw,
+		strict:         param.Strict,
+		timeTypes:      param.TimeTypes,
+		useNumber:      param.UseNumber,
+		rawFields:      param.RawFields,
+		skipConversion: param.SkipConversion,
+	}
+	return d, nil
+}
+
+// Decode converts the line.
+// Returns nil without an error if the line is a definition, which should not be yielded.
+func (d *LineDecoder) Decode(line *StringLine) (*StructLine, error) {
+	decoded, ok, serr := d.processor.processRawLine(line, &d.setting)
+	if !ok {
+		return nil, serr
+	}
+	return &decoded, nil
+}
+
 // DownloadWithContext is same as `Download()`, but sends requests in given concurrency
 // in given context.
 func (r *ReplayRequest) DownloadWithContext(ctx context.Context, concurrency int) ([]StructLine, error) {
-	format := "json"
-	rawReq := RawRequest{
-		cli:    r.cli,
-		filter: r.filter,
-		start:  r.start,
-		end:    r.end,
-		format: &format,
-	}
-	slice, serr := rawReq.DownloadWithContext(ctx, concurrency)
+	end, serr := r.raw.cli.closer.begin()
 	if serr != nil {
 		return nil, serr
 	}
-	result := make([]StructLine, 0, len(slice))
-	processor := newRawLineProcessor()
-	for i := range slice {
-		processed, ok, serr := processor.processRawLine(&slice[i])
-		if !ok {
-			if serr != nil {
-				return nil, serr
+	defer end()
+	result, serr := r.download(r.raw.withRetryBudget(ctx), concurrency)
+	if serr != nil {
+		return nil, serr
+	}
+	if !r.decorated() {
+		return result, nil
+	}
+	// Apply the same options as streaming
+	return collectStructLines(r.decorate(newSliceIterator(result), false))
+}
+
+// download downloads and decodes lines without options applied by `decorate`.
+func (r *ReplayRequest) download(ctx context.Context, concurrency int) ([]StructLine, error) {
+	if len(r.segments) > 0 {
+		return r.downloadSegments(ctx, concurrency)
+	}
+	slice, serr := r.raw.DownloadWithContext(ctx, concurrency)
+	if serr != nil {
+		return nil, serr
+	}
+	var result []StructLine
+	if r.decodeWorkers > 1 {
+		result, serr = decodeParallel(slice, r.decodeWorkers, &r.decode)
+		if serr != nil {
+			return nil, serr
+		}
+	} else {
+		result = make([]StructLine, 0, len(slice))
+		processor := newRawLineProcessor()
+		for i := range slice {
+			processed, ok, serr := processor.processRawLine(&slice[i], &r.decode)
+			if !ok {
+				if serr != nil {
+					return nil, serr
+				}
+				continue
 			}
-			continue
+			result = append(result, processed)
 		}
-		result = append(result, processed)
 	}
 	return result, nil
 }
 
+// decorated reports whether any option which works on decoded lines is set.
+func (r *ReplayRequest) decorated() bool {
+	return r.onControlLine != nil || r.verifyOrder || r.reorderWindow != nil || r.definitionChanges || r.onEvent != nil
+}
+
+// decorate applies options which work on decoded lines to the iterator.
+// `reverse` is true if lines are in the reverse order.
+func (r *ReplayRequest) decorate(itr StructLineIterator, reverse bool) StructLineIterator {
+	if r.reorderWindow != nil {
+		itr = newReorderIterator(itr, *r.reorderWindow, reverse)
+	}
+	if r.verifyOrder {
+		itr = &orderVerifyIterator{itr: itr, reverse: reverse}
+	}
+	if r.definitionChanges {
+		itr = DetectDefinitionChanges(itr)
+	}
+	if r.onEvent != nil {
+		itr = NotifyStreamEvents(itr, r.onEvent)
+	}
+	if r.onControlLine != nil {
+		itr = SplitControlLines(itr, r.onControlLine)
+	}
+	return itr
+}
+
 // DownloadConcurrency is same as `Download()`, but sends requests in given concurrency.
 func (r *ReplayRequest) DownloadConcurrency(concurrency int) ([]StructLine, error) {
 	return r.DownloadWithContext(context.Background(), concurrency)
 }
 
 // Download sends request and download response in an slice.
+// Requests are sent in the concurrency set by `Concurrency` in `ClientParam`.
 // Returns slice if and only if an error was not reported.
 // Otherwise, slice is non-nil.
 func (r *ReplayRequest) Download() ([]StructLine, error) {
-	return r.DownloadWithContext(context.Background(), downloadBatchSize)
+	return r.DownloadWithContext(context.Background(), r.raw.cli.concurrency)
+}
+
+// DownloadFunc calls `fn` with each line in order as they are downloaded, without keeping all lines in memory.
+// Shards are downloaded ahead in the concurrency set by `Concurrency` in `ClientParam`.
+// The line given to `fn` is only valid during the call.
+// Returning an error from `fn` stops downloading and the error is returned, except for `ErrStop`.
+func (r *ReplayRequest) DownloadFunc(ctx context.Context, fn func(line *StructLine) error) error {
+	itr, serr := r.StreamWithContext(ctx, r.raw.cli.concurrency)
+	if serr != nil {
+		return serr
+	}
+	return ForEach(itr, fn)
 }
 
 type replayStreamIterator struct {
 	req       *ReplayRequest
 	rawItr    StringLineIterator
 	processor *rawLineProcessor
+	closed    bool
 }
 
 func newReplayStreamIterator(ctx context.Context, req *ReplayRequest, bufferSize int) (*replayStreamIterator, error) {
 	i := new(replayStreamIterator)
-	format := "json"
-	rawRequest := RawRequest{
-		cli:    req.cli,
-		filter: req.filter,
-		start:  req.start,
-		end:    req.end,
-		format: &format,
-	}
-	itr, serr := rawRequest.StreamWithContext(ctx, bufferSize)
+	i.req = req
+	itr, serr := req.raw.stream(ctx, bufferSize, false)
 	if serr != nil {
 		return nil, serr
 	}
@@ -207,7 +698,7 @@ func (i *replayStreamIterator) Next() (*StructLine, bool, error) {
 			// No more lines
 			return nil, false, nil
 		}
-		processed, ok, serr := i.processor.processRawLine(line)
+		processed, ok, serr := i.processor.processRawLine(line, &i.req.decode)
 		if !ok {
 			if serr != nil {
 				return nil, false, serr
@@ -219,6 +710,10 @@ func (i *replayStreamIterator) Next() (*StructLine, bool, error) {
 }
 
 func (i *replayStreamIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
 	serr := i.rawItr.Close()
 	if serr != nil {
 		return serr
@@ -227,6 +722,7 @@ func (i *replayStreamIterator) Close() error {
 }
 
 // StructLineIterator is the interface of iterator which yields `*StructLine`.
+// Iterators are not safe for concurrent use, wrap one by `Synchronized` to share it between goroutines.
 type StructLineIterator interface {
 	// Next returns the next line from the iterator.
 	// If the next line exists, `ok` is true ad `line` is non-nil, otherwise false and `line` is nil.
@@ -234,8 +730,11 @@ type StructLineIterator interface {
 	Next() (line *StructLine, ok bool, err error)
 
 	// Close frees resources this iterator is using.
+	// All background goroutines and in-flight requests are stopped before it returns.
+	// `Next` returns `ErrIteratorClosed` after this is called.
+	// Calling it again does nothing and returns nil, so it can be deferred even if closed explicitly.
 	// **Must** always be called after the use of this iterator.
-	Close() error
+	io.Closer
 }
 
 // Stream sends requests to the server and returns an iterator for reading the response.
@@ -248,7 +747,7 @@ type StructLineIterator interface {
 // Higher responsiveness than `download` is expected as it does not have to wait for
 // the entire data to be downloaded.
 func (r *ReplayRequest) Stream() (StructLineIterator, error) {
-	return r.StreamWithContext(context.Background(), defaultBufferSize)
+	return r.StreamWithContext(context.Background(), r.raw.cli.bufferSize)
 }
 
 // StreamBufferSize is same as Stream but with custom bufferSize.
@@ -262,6 +761,31 @@ func (r *ReplayRequest) StreamBufferSize(bufferSize int) (StructLineIterator, er
 // Background downloads and the returned iterator will use the context for their lifetime.
 // Cancelling the context will stop running background downloads, and future `next` calls to the iterator might produce error.
 func (r *ReplayRequest) StreamWithContext(ctx context.Context, bufferSize int) (StructLineIterator, error) {
+	end, serr := r.raw.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	ctx, control, call := withStreamControls(r.raw.withRetryBudget(ctx))
+	itr, serr := r.stream(ctx, bufferSize)
+	if serr != nil {
+		end()
+		return nil, serr
+	}
+	return &streamStructIterator{r.raw.cli.trackStructIterator(r.decorate(itr, false)), control, call, end}, nil
+}
+
+// stream returns an iterator without options applied by `decorate`.
+func (r *ReplayRequest) stream(ctx context.Context, bufferSize int) (StructLineIterator, error) {
+	if len(r.segments) > 0 {
+		return r.streamSegments(ctx, bufferSize, false), nil
+	}
+	if r.decodeWorkers > 1 {
+		itr, serr := newReplayParallelStreamIterator(ctx, r, bufferSize)
+		if serr != nil {
+			return nil, serr
+		}
+		return itr, nil
+	}
 	itr, serr := newReplayStreamIterator(ctx, r, bufferSize)
 	if serr != nil {
 		return nil, serr
@@ -269,6 +793,85 @@ func (r *ReplayRequest) StreamWithContext(ctx context.Context, bufferSize int) (
 	return itr, nil
 }
 
+// sliceIterator is a `StructLineIterator` yielding lines in a slice.
+type sliceIterator struct {
+	lines    []StructLine
+	position int
+	// Returned after all lines are yielded if non-nil
+	err    error
+	closed bool
+}
+
+func newSliceIterator(lines []StructLine) *sliceIterator {
+	return &sliceIterator{lines: lines}
+}
+
+func (i *sliceIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	if i.position >= len(i.lines) {
+		return nil, false, i.err
+	}
+	line := &i.lines[i.position]
+	i.position++
+	return line, true, nil
+}
+
+func (i *sliceIterator) Close() error {
+	i.closed = true
+	return nil
+}
+
+// collectStructLines reads all lines from the iterator into a slice and closes it.
+func collectStructLines(itr StructLineIterator) ([]StructLine, error) {
+	defer itr.Close()
+	lines := make([]StructLine, 0)
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				return nil, serr
+			}
+			return lines, nil
+		}
+		lines = append(lines, *line)
+	}
+}
+
+type controlSplitIterator struct {
+	itr       StructLineIterator
+	onControl func(line *StructLine) error
+}
+
+// SplitControlLines returns an iterator which only yields message lines from the given iterator.
+// Other lines, such as start, end and error lines, are passed to the callback instead, in order.
+// Returning an error from the callback makes `Next` return it.
+//
+// The iterator given is closed when the returned iterator is closed.
+func SplitControlLines(itr StructLineIterator, onControl func(line *StructLine) error) StructLineIterator {
+	return &controlSplitIterator{itr: itr, onControl: onControl}
+}
+
+func (i *controlSplitIterator) Next() (*StructLine, bool, error) {
+	for {
+		line, ok, serr := i.itr.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		if line.Type == LineTypeMessage {
+			return line, true, nil
+		}
+		if serr := i.onControl(line); serr != nil {
+			return nil, false, serr
+		}
+	}
+}
+
+func (i *controlSplitIterator) Close() error {
+	return i.itr.Close()
+}
+
 // Replay creates new `ReplayRequest` with the given parameters and returns its pointer.
 // Return is nil if an error was returned.
 func Replay(clientParam ClientParam, param ReplayRequestParam) (*ReplayRequest, error) {
@@ -2,6 +2,12 @@ package exdgo
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -93,3 +99,470 @@ func TestReplayDownloadAndStream(t *testing.T) {
 		t.Fatal("len(lines) != i")
 	}
 }
+
+func prepareFakeReplayRequest(t *testing.T, srv *fakeServer, param ReplayRequestParam) *ReplayRequest {
+	start, serr := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	param.Filter = map[string][]string{
+		"bitmex":   []string{"orderBookL2_XBTUSD"},
+		"bitfinex": []string{"trades_tBTCUSD"},
+	}
+	param.Start = start
+	param.End = start.Add(10 * time.Minute)
+	req, serr := srv.client(t).Replay(param)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return req
+}
+
+func readAllStructLines(t *testing.T, itr StructLineIterator) []StructLine {
+	defer itr.Close()
+	lines := make([]StructLine, 0)
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			return lines
+		}
+		lines = append(lines, *line)
+	}
+}
+
+func compareStructLines(t *testing.T, a []StructLine, b []StructLine) {
+	t.Helper()
+	if len(a) != len(b) {
+		t.Fatalf("len differ: %d != %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].Exchange != b[i].Exchange || a[i].Type != b[i].Type || a[i].Timestamp != b[i].Timestamp {
+			t.Fatalf("line %d differ", i)
+		}
+		// Control lines do not have channels
+		if (a[i].Channel == nil) != (b[i].Channel == nil) || a[i].Channel != nil && *a[i].Channel != *b[i].Channel {
+			t.Fatalf("channel of line %d differ", i)
+		}
+		if !reflect.DeepEqual(a[i].Message, b[i].Message) {
+			t.Fatalf("message of line %d differ: %v != %v", i, a[i].Message, b[i].Message)
+		}
+	}
+}
+
+func TestReplayDecodeWorkers(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	sequential := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	workers := 4
+	parallel := prepareFakeReplayRequest(t, srv, ReplayRequestParam{DecodeWorkers: &workers})
+
+	expected, serr := sequential.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// 2 channels * 6 lines * 10 minutes, definitions are not yielded
+	if len(expected) != 2*6*10 {
+		t.Fatalf("len(expected) = %d", len(expected))
+	}
+	if size := expected[0].Message.(map[string]interface{})["size"]; size != int64(0) {
+		t.Fatalf("size not converted: %#v", size)
+	}
+	downloaded, serr := parallel.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, downloaded)
+	itr, serr := parallel.StreamBufferSize(3)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	checkGoroutineLeak(t)
+}
+
+func TestReplayDecodeWorkersClose(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	workers := 3
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{DecodeWorkers: &workers})
+	itr, serr := req.StreamBufferSize(2)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, ok, serr := itr.Next(); !ok {
+		t.Fatalf("no line: %v", serr)
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	checkGoroutineLeak(t)
+	if _, _, serr := itr.Next(); serr != ErrIteratorClosed {
+		t.Fatalf("Next after Close: %v", serr)
+	}
+}
+
+func TestReplayKeepRaw(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{KeepRaw: true})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	for _, line := range lines {
+		var decoded map[string]interface{}
+		if serr := json.Unmarshal(line.Raw, &decoded); serr != nil {
+			t.Fatalf("raw is not JSON: %v", serr)
+		}
+		if decoded["timestamp"] != strconv.FormatInt(line.Timestamp, 10) {
+			t.Fatalf("raw differ from original: %s", line.Raw)
+		}
+	}
+}
+
+// testLines generates message lines alternating exchanges and channels given.
+func testLines(n int, exchanges []string, channels []string) []StructLine {
+	lines := make([]StructLine, n)
+	for j := range lines {
+		channel := channels[j%len(channels)]
+		lines[j] = StructLine{
+			Exchange:  exchanges[j%len(exchanges)],
+			Type:      LineTypeMessage,
+			Timestamp: int64(j) * int64(time.Second),
+			Channel:   &channel,
+			Message:   map[string]interface{}{"index": j},
+		}
+	}
+	return lines
+}
+
+func TestConvertRawLineStrict(t *testing.T) {
+	channel := "trades"
+	def := map[string]string{"price": "float", "size": "int", "timestamp": "timestamp"}
+	cases := []struct {
+		message string
+		field   string
+	}{
+		{`{"price":1.5,"size":2,"timestamp":"100"}`, ""},
+		{`{"price":1.5,"size":2,"timestamp":"100","side":"buy"}`, "side"},
+		{`{"price":"1.5","size":2,"timestamp":"100"}`, "price"},
+		{`{"price":1.5,"size":2.5,"timestamp":"100"}`, "size"},
+		{`{"price":1.5,"size":2,"timestamp":100}`, "timestamp"},
+	}
+	for _, c := range cases {
+		line := &StringLine{
+			Exchange:  "bitmex",
+			Type:      LineTypeMessage,
+			Timestamp: 1,
+			Channel:   &channel,
+			Message:   []byte(c.message),
+		}
+		_, serr := convertRawLine(line, def, &decodeSetting{strict: true})
+		if c.field == "" {
+			if serr != nil {
+				t.Fatalf("%s: %v", c.message, serr)
+			}
+			continue
+		}
+		schemaErr, ok := serr.(*SchemaError)
+		if !ok {
+			t.Fatalf("%s: expected SchemaError, got %v", c.message, serr)
+		}
+		if schemaErr.Field != c.field {
+			t.Fatalf("%s: field = %s", c.message, schemaErr.Field)
+		}
+		// Not strict, unknown fields and unchecked types are passed through without panic
+		if _, serr := convertRawLine(line, def, &decodeSetting{}); serr != nil && c.field != "timestamp" {
+			t.Fatalf("%s: not strict: %v", c.message, serr)
+		}
+	}
+}
+
+func TestReplayTimeTypes(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{TimeTypes: true})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	for _, line := range lines {
+		ts, ok := line.Message.(map[string]interface{})["timestamp"].(time.Time)
+		if !ok {
+			t.Fatal("timestamp is not time.Time")
+		}
+		if ts.UnixNano() != line.Timestamp || ts.Location() != time.UTC {
+			t.Fatalf("timestamp = %v", ts)
+		}
+	}
+	channel := "funding"
+	converted, serr := convertRawLine(&StringLine{
+		Exchange: "bitmex",
+		Type:     LineTypeMessage,
+		Channel:  &channel,
+		Message:  []byte(`{"interval":"28800000000000"}`),
+	}, map[string]string{"interval": "duration"}, &decodeSetting{timeTypes: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if interval := converted.Message.(map[string]interface{})["interval"]; interval != 8*time.Hour {
+		t.Fatalf("interval = %#v", interval)
+	}
+}
+
+func TestConvertRawLineUseNumber(t *testing.T) {
+	channel := "trades"
+	line := &StringLine{
+		Exchange: "bitmex",
+		Type:     LineTypeMessage,
+		Channel:  &channel,
+		Message:  []byte(`{"price":0.123456789012345678,"id":9007199254740993}`),
+	}
+	def := map[string]string{"price": "float", "id": "int"}
+	converted, serr := convertRawLine(line, def, &decodeSetting{useNumber: true, strict: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	msg := converted.Message.(map[string]interface{})
+	if msg["price"] != json.Number("0.123456789012345678") {
+		t.Fatalf("price = %#v", msg["price"])
+	}
+	// 2^53 + 1 can not be represented in float64
+	if msg["id"] != int64(9007199254740993) {
+		t.Fatalf("id = %#v", msg["id"])
+	}
+}
+
+func TestConvertRawLineUseNumberNotInteger(t *testing.T) {
+	channel := "trades"
+	line := &StringLine{
+		Exchange: "bitmex",
+		Type:     LineTypeMessage,
+		Channel:  &channel,
+		Message:  []byte(`{"id":1e3,"size":1.5}`),
+	}
+	def := map[string]string{"id": "int", "size": "int"}
+	// Truncated if not strict
+	converted, serr := convertRawLine(line, def, &decodeSetting{useNumber: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	msg := converted.Message.(map[string]interface{})
+	if msg["id"] != int64(1000) || msg["size"] != int64(1) {
+		t.Fatalf("id = %#v, size = %#v", msg["id"], msg["size"])
+	}
+	// Only integral values are accepted if strict
+	if _, serr := convertRawLine(line, def, &decodeSetting{useNumber: true, strict: true}); serr == nil {
+		t.Fatal("size 1.5 was accepted")
+	}
+	line.Message = []byte(`{"id":1e3,"size":1.0}`)
+	converted, serr = convertRawLine(line, def, &decodeSetting{useNumber: true, strict: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	msg = converted.Message.(map[string]interface{})
+	if msg["id"] != int64(1000) || msg["size"] != int64(1) {
+		t.Fatalf("id = %#v, size = %#v", msg["id"], msg["size"])
+	}
+}
+
+func TestReplayRawFields(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{RawFields: true})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) == 0 {
+		t.Fatal("no line")
+	}
+	for _, line := range lines {
+		fields := line.Message.(map[string]json.RawMessage)
+		if string(fields["timestamp"]) != strconv.Quote(strconv.FormatInt(line.Timestamp, 10)) {
+			t.Fatalf("timestamp = %s", fields["timestamp"])
+		}
+	}
+	if _, serr := srv.client(t).Replay(ReplayRequestParam{
+		Filter:    map[string][]string{"bitmex": []string{"trades"}},
+		Start:     time.Unix(0, 0),
+		End:       time.Unix(60, 0),
+		RawFields: true,
+		Strict:    true,
+	}); serr == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReplaySkipConversion(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{SkipConversion: true})
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(lines) == 0 {
+		t.Fatal("no line")
+	}
+	for _, line := range lines {
+		if line.Type != LineTypeMessage {
+			continue
+		}
+		message := line.Message.(map[string]interface{})
+		if message["timestamp"] != strconv.FormatInt(line.Timestamp, 10) {
+			t.Fatalf("timestamp = %#v", message["timestamp"])
+		}
+		if _, ok := message["size"].(float64); !ok {
+			t.Fatalf("size = %#v", message["size"])
+		}
+	}
+	if _, serr := srv.client(t).Replay(ReplayRequestParam{
+		Filter:         map[string][]string{"bitmex": []string{"trades"}},
+		Start:          time.Unix(0, 0),
+		End:            time.Unix(60, 0),
+		SkipConversion: true,
+		TimeTypes:      true,
+	}); serr == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSplitControlLines(t *testing.T) {
+	lines := testLines(4, []string{"bitmex"}, []string{"trades"})
+	lines = append([]StructLine{{Exchange: "bitmex", Type: LineTypeStart}}, lines...)
+	lines = append(lines, StructLine{Exchange: "bitmex", Type: LineTypeEnd, Timestamp: lines[len(lines)-1].Timestamp})
+	var control []LineType
+	itr := SplitControlLines(newSliceIterator(lines), func(line *StructLine) error {
+		control = append(control, line.Type)
+		return nil
+	})
+	messages := readAllStructLines(t, itr)
+	if len(messages) != 4 {
+		t.Fatalf("len(messages) = %d", len(messages))
+	}
+	if len(control) != 2 || control[0] != LineTypeStart || control[1] != LineTypeEnd {
+		t.Fatalf("control lines: %v", control)
+	}
+
+	stop := errors.New("stop")
+	itr = SplitControlLines(newSliceIterator(lines), func(line *StructLine) error {
+		return stop
+	})
+	if _, ok, serr := itr.Next(); ok || serr != stop {
+		t.Fatalf("callback error not returned: %v", serr)
+	}
+}
+
+func TestLineDecoder(t *testing.T) {
+	channel := "trades"
+	lines := []StringLine{
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 1, Message: []byte("wss://example")},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 1, Channel: &channel, Message: []byte(`{"price":"float","timestamp":"timestamp"}`)},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 2, Channel: &channel, Message: []byte(`{"price":1.5,"timestamp":"2"}`)},
+	}
+	decoder, serr := NewLineDecoderWithParam(LineDecoderParam{TimeTypes: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	decoded := make([]*StructLine, len(lines))
+	for j := range lines {
+		if decoded[j], serr = decoder.Decode(&lines[j]); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if decoded[0] == nil || decoded[0].Type != LineTypeStart {
+		t.Fatal("start line not decoded")
+	}
+	if decoded[1] != nil {
+		t.Fatal("definition yielded")
+	}
+	message := decoded[2].Message.(map[string]interface{})
+	if message["price"] != 1.5 || message["timestamp"] != time.Unix(0, 2).UTC() {
+		t.Fatalf("message = %v", message)
+	}
+	if _, serr := NewLineDecoderWithParam(LineDecoderParam{RawFields: true, Strict: true}); serr == nil {
+		t.Fatal("invalid param accepted")
+	}
+}
+
+func TestIteratorsCloseTwice(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	workers := 2
+	opens := map[string]func() (io.Closer, error){
+		"raw": func() (io.Closer, error) {
+			return prepareFakeRawRequest(t, srv).Stream()
+		},
+		"replay": func() (io.Closer, error) {
+			return prepareFakeReplayRequest(t, srv, ReplayRequestParam{VerifyOrder: true}).Stream()
+		},
+		"parallel": func() (io.Closer, error) {
+			return prepareFakeReplayRequest(t, srv, ReplayRequestParam{DecodeWorkers: &workers}).Stream()
+		},
+		"reverse": func() (io.Closer, error) {
+			return prepareFakeReplayRequest(t, srv, ReplayRequestParam{}).StreamReverse()
+		},
+		"merge": func() (io.Closer, error) {
+			return Merge(MergeParam{Iterators: []StructLineIterator{newSliceIterator(testLines(4, []string{"bitmex"}, []string{"trade"}))}}), nil
+		},
+	}
+	for name, open := range opens {
+		itr, serr := open()
+		if serr != nil {
+			t.Fatalf("%s: %v", name, serr)
+		}
+		if serr := itr.Close(); serr != nil {
+			t.Fatalf("%s: %v", name, serr)
+		}
+		if serr := itr.Close(); serr != nil {
+			t.Fatalf("%s: second close: %v", name, serr)
+		}
+		var nextErr error
+		switch i := itr.(type) {
+		case StructLineIterator:
+			_, _, nextErr = i.Next()
+		case StringLineIterator:
+			_, _, nextErr = i.Next()
+		}
+		if !errors.Is(nextErr, ErrIteratorClosed) {
+			t.Fatalf("%s: Next after Close returned %v", name, nextErr)
+		}
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestReplayDownloadFunc(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	visited := make([]StructLine, 0)
+	serr = req.DownloadFunc(context.Background(), func(line *StructLine) error {
+		visited = append(visited, *line)
+		return nil
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, visited)
+	stop := errors.New("stop")
+	count := 0
+	serr = req.DownloadFunc(context.Background(), func(line *StructLine) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	})
+	if serr != stop || count != 3 {
+		t.Fatalf("callback error not returned: %v after %d lines", serr, count)
+	}
+	checkGoroutineLeak(t)
+}
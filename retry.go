@@ -0,0 +1,111 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// isRetryable reports whether a failed request is worth retrying.
+// Client errors reported by the server will never succeed by retrying, except for
+// request timeout and too many requests.
+func isRetryable(err error) bool {
+	var nerr *NotCachedError
+	if errors.As(err, &nerr) {
+		// Offline, the cache does not change by retrying
+		return false
+	}
+	if errors.Is(err, ErrSessionBudgetExceeded) {
+		return false
+	}
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.StatusCode >= 500 ||
+			serr.StatusCode == http.StatusRequestTimeout ||
+			serr.StatusCode == http.StatusTooManyRequests
+	}
+	// Network errors, timeouts and broken responses
+	return true
+}
+
+// retryBudget is the number of retries left shared by all downloads of an operation on a request.
+type retryBudget struct {
+	mutex sync.Mutex
+	left  int
+}
+
+type retryBudgetKey struct{}
+
+// withRetryBudget returns the context carrying a new budget of retries,
+// or the context as is if it already has one.
+func withRetryBudget(ctx context.Context, retries int) context.Context {
+	if ctx.Value(retryBudgetKey{}) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, retryBudgetKey{}, &retryBudget{left: retries})
+}
+
+// take uses a retry from the budget, and returns false if none is left.
+func (b *retryBudget) take() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.left <= 0 {
+		return false
+	}
+	b.left--
+	return true
+}
+
+// retry calls `fn` until it succeeds, fails with an error which is not retryable,
+// or it had been retried for `cli.maxRetries` times.
+// Wait between trials grows exponentially from `cli.retryWait`.
+// It also gives up if the wait would pass the deadline of the context,
+// or the retry budget in the context is used up.
+// Returns the last error from `fn`, or the context error if the context is done.
+func retry(ctx context.Context, cli *Client, fn func() error) error {
+	wait := cli.retryWait
+	budget, _ := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	for trial := 0; ; trial++ {
+		serr := fn()
+		if serr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			// Error is likely to be caused by the context
+			return serr
+		}
+		if cli.closer.isClosed() {
+			if errors.Is(serr, ErrClientClosed) {
+				return serr
+			}
+			return fmt.Errorf("%w: %v", ErrClientClosed, serr)
+		}
+		if trial >= cli.maxRetries || !isRetryable(serr) {
+			if trial > 0 {
+				return fmt.Errorf("gave up after %d retries: %w", trial, serr)
+			}
+			return serr
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			// The context would be done before retrying
+			return fmt.Errorf("gave up before the deadline after %d retries: %w", trial, serr)
+		}
+		if budget != nil && !budget.take() {
+			return fmt.Errorf("retry budget used up after %d retries: %w", trial, serr)
+		}
+		cli.logf("exdgo: retrying in %v: %v", wait, serr)
+		summaryCollectorFrom(ctx).addRetry()
+		cli.session.addRetry()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return serr
+		}
+		wait *= 2
+	}
+}
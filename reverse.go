@@ -0,0 +1,266 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// clone returns a copy of the processor which tracks definitions independently.
+func (p *rawLineProcessor) clone() *rawLineProcessor {
+	c := newRawLineProcessor()
+	for exchange, channels := range p.defs {
+		copied := make(map[string]map[string]string, len(channels))
+		for channel, def := range channels {
+			// Definitions are replaced but never modified
+			copied[channel] = def
+		}
+		c.defs[exchange] = copied
+	}
+	return c
+}
+
+func reverseStructLines(lines []StructLine) {
+	for a, b := 0, len(lines)-1; a < b; a, b = a+1, b-1 {
+		lines[a], lines[b] = lines[b], lines[a]
+	}
+}
+
+// replayReverseExchangeIterator yields decoded lines of an exchange, the newest first.
+//
+// Definitions needed to decode messages are sent at the beginning of the stream,
+// so they are taken from the snapshot first, and each shard is decoded in the original order
+// with them before reversed.
+type replayReverseExchangeIterator struct {
+	req    *ReplayRequest
+	shards *rawExchangeStreamShardIterator
+	// Knows definitions at the start of the range
+	processor *rawLineProcessor
+	// Decoded lines of the current shard, reversed
+	lines    []StructLine
+	position int
+	// Number of lines in `lines` counted as decoded, the snapshot is not counted
+	counted  int64
+	notifier *shardNotifier
+	// Number of shards received
+	received int
+	// Size of the shard `lines` are from, not yet reported, nil if none
+	unreported *shardReport
+	// Decoded lines of the snapshot, reversed and yielded at last
+	snapshot   []StructLine
+	shardsDone bool
+}
+
+func newReplayReverseExchangeIterator(ctx context.Context, req *ReplayRequest, exchange string, bufferSize int, notifier *shardNotifier) (*replayReverseExchangeIterator, error) {
+	i := new(replayReverseExchangeIterator)
+	i.req = req
+	i.notifier = notifier
+	i.processor = newRawLineProcessor()
+	var snapshots []Snapshot
+	serr := retry(ctx, req.raw.cli, func() error {
+		var serr error
+		snapshots, serr = httpSnapshot(ctx, req.raw.cli, snapshotSetting{
+			exchange: exchange,
+			channels: req.raw.filter[exchange],
+			at:       req.raw.start,
+			format:   req.raw.format,
+		})
+		return serr
+	})
+	if serr != nil {
+		return nil, fmt.Errorf("snapshot: %v", serr)
+	}
+	lines := convertSnapshotsToLines(exchange, req.raw.format, snapshots)
+	req.raw.correctClock(exchange, lines)
+	for j := range lines {
+		processed, ok, serr := i.processor.processRawLine(&lines[j], &req.decode)
+		if !ok {
+			if serr != nil {
+				return nil, serr
+			}
+			continue
+		}
+		i.snapshot = append(i.snapshot, processed)
+	}
+	reverseStructLines(i.snapshot)
+	i.shards = newRawExchangeStreamShardIterator(ctx, req.raw, exchange, bufferSize, shardOrder{reverse: true, skipSnapshot: true})
+	return i, nil
+}
+
+// decodeShard decodes lines in a shard with definitions known at the start, and reverses them.
+func (i *replayReverseExchangeIterator) decodeShard(shard []StringLine) ([]StructLine, error) {
+	processor := i.processor.clone()
+	decoded := make([]StructLine, 0, len(shard))
+	for j := range shard {
+		processed, ok, serr := processor.processRawLine(&shard[j], &i.req.decode)
+		if !ok {
+			if serr != nil {
+				return nil, serr
+			}
+			continue
+		}
+		decoded = append(decoded, processed)
+	}
+	reverseStructLines(decoded)
+	return decoded, nil
+}
+
+func (i *replayReverseExchangeIterator) next() (*StructLine, error) {
+	for i.position >= len(i.lines) {
+		if i.shardsDone {
+			return nil, nil
+		}
+		if i.unreported != nil {
+			i.notifier.add(i.unreported.exchange, i.unreported.minute, i.unreported.lines, int64(i.unreported.bytes))
+			i.unreported = nil
+		}
+		shard, serr := i.shards.next()
+		if serr != nil {
+			return nil, serr
+		}
+		i.release()
+		i.position = 0
+		if shard == nil {
+			// Shards are all read, snapshot is the last
+			i.shardsDone = true
+			i.lines = i.snapshot
+			i.snapshot = nil
+			continue
+		}
+		i.received++
+		minute := i.req.raw.start/int64(time.Minute) + int64(i.shards.indexAt(i.received-1)-1)
+		i.unreported = &shardReport{i.shards.exchange, minute, len(shard), int(shardBytes(shard))}
+		i.lines, serr = i.decodeShard(shard)
+		memory := i.req.raw.cli.memory
+		memory.addBuffered(-shardBytes(shard))
+		if serr != nil {
+			return nil, serr
+		}
+		i.counted = int64(len(i.lines))
+		memory.addDecoded(i.counted)
+	}
+	line := &i.lines[i.position]
+	i.position++
+	return line, nil
+}
+
+// release stops counting the current lines as decoded.
+func (i *replayReverseExchangeIterator) release() {
+	i.req.raw.cli.memory.addDecoded(-i.counted)
+	i.counted = 0
+}
+
+func (i *replayReverseExchangeIterator) close() error {
+	i.release()
+	return i.shards.close()
+}
+
+// replayReverseIterator merges exchanges yielding the newest line first.
+type replayReverseIterator struct {
+	iterators []*replayReverseExchangeIterator
+	// Next line for each exchange, nil if the exchange reached the end
+	lasts  []*StructLine
+	closed bool
+	// nil if shards are not reported
+	notifier *shardNotifier
+}
+
+func newReplayReverseIterator(ctx context.Context, req *ReplayRequest, bufferSize int) (*replayReverseIterator, error) {
+	i := new(replayReverseIterator)
+	i.notifier = newShardNotifier(req.raw.onShard)
+	for exchange := range req.raw.filter {
+		itr, serr := newReplayReverseExchangeIterator(ctx, req, exchange, bufferSize, i.notifier)
+		if serr != nil {
+			i.Close()
+			return nil, serr
+		}
+		i.iterators = append(i.iterators, itr)
+		last, serr := itr.next()
+		if serr != nil {
+			i.Close()
+			return nil, serr
+		}
+		i.lasts = append(i.lasts, last)
+	}
+	return i, nil
+}
+
+func (i *replayReverseIterator) Next() (*StructLine, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	// Lines of shards read through were all yielded by the last call
+	i.notifier.flush()
+	argmax := -1
+	for j, last := range i.lasts {
+		if last != nil && (argmax == -1 || last.Timestamp > i.lasts[argmax].Timestamp) {
+			argmax = j
+		}
+	}
+	if argmax == -1 {
+		return nil, false, nil
+	}
+	line := i.lasts[argmax]
+	next, serr := i.iterators[argmax].next()
+	if serr != nil {
+		return nil, false, serr
+	}
+	i.lasts[argmax] = next
+	return line, true, nil
+}
+
+func (i *replayReverseIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	var serr error
+	for _, itr := range i.iterators {
+		if cerr := itr.close(); cerr != nil && serr == nil {
+			serr = cerr
+		}
+	}
+	return serr
+}
+
+// StreamReverse is same as `Stream`, but lines are yielded in the reverse order, the newest first.
+// Shards are downloaded from the end of the range, so the iterator starts yielding
+// without downloading the whole range.
+// Snapshots taken at the start of the range are yielded at last.
+//
+// Messages are decoded with definitions at the start of the range and ones sent in the same minute,
+// `DecodeWorkers` is not used.
+func (r *ReplayRequest) StreamReverse() (StructLineIterator, error) {
+	return r.StreamReverseWithContext(context.Background(), r.raw.cli.bufferSize)
+}
+
+// StreamReverseWithContext is same as `StreamReverse` but a context and a buffer size can be given.
+func (r *ReplayRequest) StreamReverseWithContext(ctx context.Context, bufferSize int) (StructLineIterator, error) {
+	if bufferSize < 1 {
+		return nil, errors.New("'bufferSize' must be positive")
+	}
+	end, serr := r.raw.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	ctx, control, call := withStreamControls(r.raw.withRetryBudget(ctx))
+	itr, serr := r.streamReverse(ctx, bufferSize)
+	if serr != nil {
+		end()
+		return nil, serr
+	}
+	return &streamStructIterator{r.raw.cli.trackStructIterator(r.decorate(itr, true)), control, call, end}, nil
+}
+
+// streamReverse returns a reversed iterator without options applied by `decorate`.
+func (r *ReplayRequest) streamReverse(ctx context.Context, bufferSize int) (StructLineIterator, error) {
+	if len(r.segments) > 0 {
+		return r.streamSegments(ctx, bufferSize, true), nil
+	}
+	itr, serr := newReplayReverseIterator(ctx, r, bufferSize)
+	if serr != nil {
+		return nil, serr
+	}
+	return itr, nil
+}
@@ -0,0 +1,59 @@
+package exdgo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRawStreamReverse(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeRawRequest(t, srv)
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := req.StreamReverseWithContext(context.Background(), 3)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	j := len(lines) - 1
+	for ; ; j-- {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			break
+		}
+		if j < 0 {
+			t.Fatal("too many lines")
+		}
+		if line.Timestamp != lines[j].Timestamp || line.Exchange != lines[j].Exchange || string(line.Message) != string(lines[j].Message) {
+			t.Fatalf("line %d differ", j)
+		}
+	}
+	if j != -1 {
+		t.Fatalf("%d lines missing", j+1)
+	}
+	itr.Close()
+	checkGoroutineLeak(t)
+}
+
+func TestReplayStreamReverse(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	reverseStructLines(expected)
+	itr, serr := req.StreamReverse()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	checkGoroutineLeak(t)
+}
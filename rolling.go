@@ -0,0 +1,235 @@
+package exdgo
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// RollingTrade is a trade in the window of `Rolling`.
+type RollingTrade struct {
+	// Unixtime in nanoseconds
+	Timestamp int64
+	Price     float64
+	Size      float64
+}
+
+// RollingStatistic is a statistic over trades in a window of event time, updated incrementally.
+// Implement it to add statistics to `Rolling` other than ones provided.
+type RollingStatistic interface {
+	// Add is called with a trade entering the window.
+	Add(trade RollingTrade)
+	// Remove is called with a trade leaving the window, in the order they were added.
+	Remove(trade RollingTrade)
+	// Value returns the statistic of trades currently in the window of the duration.
+	Value(window time.Duration) float64
+}
+
+// RollingParam is the parameters for `Rolling`.
+type RollingParam struct {
+	// Length of the window in event time, trades older than this from the latest trade leave the window.
+	Window time.Duration
+	// Returns the price and the size if the line is a trade.
+	Trade func(line *StructLine) (price float64, size float64, ok bool)
+	// Returns the key to compute statistics separately for, such as the symbol of the instrument.
+	// Optional, the exchange of the line by default.
+	Key func(line *StructLine) string
+	// Creates statistics for each key, such as `NewRollingVWAP`.
+	Statistics []func() RollingStatistic
+}
+
+// RollingValue is values of statistics after a trade.
+type RollingValue struct {
+	Key string
+	// Timestamp of the trade in unixtime nanoseconds
+	Timestamp int64
+	// Values in the order of `Statistics` in the parameter
+	Values []float64
+}
+
+// rollingWindow is trades in the window of a key and statistics over them.
+type rollingWindow struct {
+	trades     []RollingTrade
+	statistics []RollingStatistic
+}
+
+// RollingIterator yields values of rolling statistics. See `Rolling`.
+type RollingIterator struct {
+	source  StructLineIterator
+	param   RollingParam
+	windows map[string]*rollingWindow
+	value   RollingValue
+	closed  bool
+}
+
+// Rolling returns an iterator yielding values of statistics over the window after each trade from `itr`.
+// Lines other than trades are consumed, and `itr` is closed when the returned iterator is closed.
+func Rolling(itr StructLineIterator, param RollingParam) (*RollingIterator, error) {
+	if param.Window <= 0 {
+		return nil, errors.New("'Window' must be positive")
+	}
+	if param.Trade == nil {
+		return nil, errors.New("'Trade' is required")
+	}
+	if len(param.Statistics) == 0 {
+		return nil, errors.New("'Statistics' is empty")
+	}
+	if param.Key == nil {
+		param.Key = func(line *StructLine) string { return line.Exchange }
+	}
+	return &RollingIterator{source: itr, param: param, windows: make(map[string]*rollingWindow)}, nil
+}
+
+// Next returns values after the next trade.
+// The value returned is only valid until the next call.
+func (i *RollingIterator) Next() (*RollingValue, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for {
+		line, ok, serr := i.source.Next()
+		if !ok {
+			return nil, false, serr
+		}
+		price, size, isTrade := i.param.Trade(line)
+		if !isTrade {
+			continue
+		}
+		key := i.param.Key(line)
+		window, ok := i.windows[key]
+		if !ok {
+			window = new(rollingWindow)
+			for _, create := range i.param.Statistics {
+				window.statistics = append(window.statistics, create())
+			}
+			i.windows[key] = window
+		}
+		trade := RollingTrade{Timestamp: line.Timestamp, Price: price, Size: size}
+		window.trades = append(window.trades, trade)
+		for _, stat := range window.statistics {
+			stat.Add(trade)
+		}
+		oldest := line.Timestamp - int64(i.param.Window)
+		for len(window.trades) > 0 && window.trades[0].Timestamp <= oldest {
+			for _, stat := range window.statistics {
+				stat.Remove(window.trades[0])
+			}
+			window.trades = window.trades[1:]
+		}
+		i.value.Key = key
+		i.value.Timestamp = line.Timestamp
+		i.value.Values = make([]float64, len(window.statistics))
+		for k, stat := range window.statistics {
+			i.value.Values[k] = stat.Value(i.param.Window)
+		}
+		return &i.value, true, nil
+	}
+}
+
+// Close closes the underlying iterator.
+// Calling it again does nothing and returns nil.
+func (i *RollingIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
+
+type rollingVWAP struct {
+	notional float64
+	volume   float64
+}
+
+// NewRollingVWAP creates a statistic of the volume weighted average price.
+// NaN if there is no volume in the window.
+func NewRollingVWAP() RollingStatistic {
+	return new(rollingVWAP)
+}
+
+func (s *rollingVWAP) Add(trade RollingTrade) {
+	s.notional += trade.Price * trade.Size
+	s.volume += trade.Size
+}
+
+func (s *rollingVWAP) Remove(trade RollingTrade) {
+	s.notional -= trade.Price * trade.Size
+	s.volume -= trade.Size
+}
+
+func (s *rollingVWAP) Value(window time.Duration) float64 {
+	if s.volume <= 0 {
+		return math.NaN()
+	}
+	return s.notional / s.volume
+}
+
+type realizedVolatility struct {
+	// Log returns between consecutive trades in the window
+	returns []float64
+	sum     float64
+	last    float64
+	// Returns removed since the sum was recalculated
+	removed int
+}
+
+// NewRealizedVolatility creates a statistic of the realized volatility,
+// the square root of the sum of squared log returns between trades in the window.
+func NewRealizedVolatility() RollingStatistic {
+	return new(realizedVolatility)
+}
+
+func (s *realizedVolatility) Add(trade RollingTrade) {
+	if s.last > 0 && trade.Price > 0 {
+		r := math.Log(trade.Price / s.last)
+		s.returns = append(s.returns, r)
+		s.sum += r * r
+	}
+	s.last = trade.Price
+}
+
+func (s *realizedVolatility) Remove(trade RollingTrade) {
+	// The return from the trade leaving to the next one
+	if len(s.returns) > 0 {
+		s.sum -= s.returns[0] * s.returns[0]
+		s.returns = s.returns[1:]
+		s.removed++
+	}
+	if s.removed >= len(s.returns) {
+		// Errors accumulated by subtractions are cleared, in amortized constant time
+		s.sum = 0
+		for _, r := range s.returns {
+			s.sum += r * r
+		}
+		s.removed = 0
+	}
+}
+
+func (s *realizedVolatility) Value(window time.Duration) float64 {
+	if s.sum <= 0 {
+		// Prevents rounding errors from making it NaN
+		return 0
+	}
+	return math.Sqrt(s.sum)
+}
+
+type tradeIntensity struct {
+	count int
+}
+
+// NewTradeIntensity creates a statistic of the number of trades per second in the window.
+func NewTradeIntensity() RollingStatistic {
+	return new(tradeIntensity)
+}
+
+func (s *tradeIntensity) Add(trade RollingTrade) {
+	s.count++
+}
+
+func (s *tradeIntensity) Remove(trade RollingTrade) {
+	s.count--
+}
+
+func (s *tradeIntensity) Value(window time.Duration) float64 {
+	return float64(s.count) / window.Seconds()
+}
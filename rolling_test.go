@@ -0,0 +1,56 @@
+package exdgo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRolling(t *testing.T) {
+	trade := "trade"
+	prices := []float64{100, 110, 99, 99}
+	sizes := []float64{1, 3, 2, 2}
+	lines := make([]StructLine, len(prices))
+	for k := range prices {
+		lines[k] = StructLine{
+			Exchange:  "bitmex",
+			Type:      LineTypeMessage,
+			Timestamp: int64(k) * int64(time.Second),
+			Channel:   &trade,
+			Message:   map[string]interface{}{"price": prices[k], "size": sizes[k]},
+		}
+	}
+	itr, serr := Rolling(newSliceIterator(lines), RollingParam{
+		Window: 2 * time.Second,
+		Trade: func(line *StructLine) (float64, float64, bool) {
+			msg := line.Message.(map[string]interface{})
+			return msg["price"].(float64), msg["size"].(float64), true
+		},
+		Statistics: []func() RollingStatistic{NewRollingVWAP, NewRealizedVolatility, NewTradeIntensity},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	// Window covers the trade and the previous one
+	expected := [][]float64{
+		{100, 0, 0.5},
+		{(100 + 330) / 4.0, math.Abs(math.Log(1.1)), 1},
+		{(330 + 198) / 5.0, math.Abs(math.Log(99.0 / 110)), 1},
+		{99, 0, 1},
+	}
+	for k, e := range expected {
+		value, ok, serr := itr.Next()
+		if !ok {
+			t.Fatalf("trade %d: not yielded: %v", k, serr)
+		}
+		for s := range e {
+			if math.Abs(value.Values[s]-e[s]) > 1e-9 {
+				t.Fatalf("trade %d: values %v, expected %v", k, value.Values, e)
+			}
+		}
+	}
+	if _, serr := Rolling(newSliceIterator(lines), RollingParam{Window: time.Second}); serr == nil {
+		t.Fatal("missing 'Trade' accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package exdgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Range of the sample data served by `NewSampleClient`, `SampleEnd` is exclusive.
+var (
+	SampleStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	SampleEnd   = SampleStart.Add(10 * time.Minute)
+)
+
+// SampleExchanges are exchanges in the sample data, each has "trade" and "quote" channels.
+var SampleExchanges = []string{"bitmex", "bitfinex"}
+
+// Seed of the sample data, changing it changes the data users see in examples
+const sampleSeed = 20200101
+
+var (
+	sampleOnce  sync.Once
+	sampleLines []StringLine
+)
+
+// NewSampleClient creates a client serving the sample data without the network,
+// so examples run end-to-end without an API-key.
+// The data is synthetic and generated by `GenerateFixture`, it is the same on every call.
+// Only `SampleExchanges` have the data, in the range from `SampleStart` to `SampleEnd`.
+// Messages are in the same form whatever the format requested.
+// Options such as `WithCache` can be given, except for ones on the API-key and the HTTP client.
+func NewSampleClient(opts ...Option) (*Client, error) {
+	param := ClientParam{}
+	for _, opt := range opts {
+		opt(&param)
+	}
+	param.APIKey = "sample"
+	param.Credentials = nil
+	param.HTTPClient = &http.Client{Transport: sampleTransport{}}
+	return CreateClient(param)
+}
+
+// sampleTransport serves Filter and Snapshot HTTP Endpoints from the sample data.
+type sampleTransport struct{}
+
+func (sampleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sampleOnce.Do(func() {
+		var serr error
+		sampleLines, serr = GenerateFixture(FixtureParam{
+			Seed:      sampleSeed,
+			Exchanges: SampleExchanges,
+			Start:     SampleStart,
+			End:       SampleEnd,
+		})
+		if serr != nil {
+			panic(fmt.Sprintf("generating sample: %v", serr))
+		}
+	})
+	// Path after the version of the API
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) < 3 {
+		return sampleResponse(req, http.StatusNotFound, nil), nil
+	}
+	parts = parts[len(parts)-3:]
+	exchange := parts[1]
+	num, serr := strconv.ParseInt(parts[2], 10, 64)
+	if serr != nil {
+		return sampleResponse(req, http.StatusBadRequest, []byte(serr.Error())), nil
+	}
+	query := req.URL.Query()
+	channels := make(map[string]bool)
+	for _, ch := range query["channels"] {
+		channels[ch] = true
+	}
+	body := new(bytes.Buffer)
+	switch parts[0] {
+	case "snapshot":
+		// Channels have no state, only definitions are returned
+		for _, line := range sampleLines {
+			if line.Timestamp > num {
+				break
+			}
+			if line.Exchange == exchange && line.Type == LineTypeMessage && channels[*line.Channel] && line.Timestamp == SampleStart.UnixNano() {
+				fmt.Fprintf(body, "%d\t%s\t%s\n", num, *line.Channel, line.Message)
+			}
+		}
+	case "filter":
+		start, _ := strconv.ParseInt(query.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(query.Get("end"), 10, 64)
+		from := num * int64(time.Minute)
+		to := from + int64(time.Minute)
+		for _, line := range sampleLines {
+			// Definitions are given by snapshots
+			if line.Exchange != exchange || line.Type != LineTypeMessage || line.Timestamp == SampleStart.UnixNano() {
+				continue
+			}
+			if line.Timestamp < from || line.Timestamp < start || line.Timestamp >= to || line.Timestamp >= end || !channels[*line.Channel] {
+				continue
+			}
+			fmt.Fprintf(body, "msg\t%d\t%s\t%s\n", line.Timestamp, *line.Channel, line.Message)
+		}
+	default:
+		return sampleResponse(req, http.StatusNotFound, nil), nil
+	}
+	return sampleResponse(req, http.StatusOK, body.Bytes()), nil
+}
+
+func sampleResponse(req *http.Request, statusCode int, body []byte) *http.Response {
+	header := http.Header{"Content-Type": []string{"text/plain"}}
+	if statusCode == http.StatusOK {
+		// Lets the cache store responses
+		sum := sha256.Sum256(body)
+		header.Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
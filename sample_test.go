@@ -0,0 +1,44 @@
+package exdgo
+
+import (
+	"testing"
+)
+
+func TestSampleClient(t *testing.T) {
+	cli, serr := NewSampleClient()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	req, serr := cli.Replay(ReplayRequestParam{
+		Filter: map[string][]string{"bitmex": {"trade"}, "bitfinex": {"trade", "quote"}},
+		Start:  SampleStart,
+		End:    SampleEnd,
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	lines, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	counts := make(map[string]int)
+	for _, line := range lines {
+		if line.Type != LineTypeMessage {
+			continue
+		}
+		counts[line.Exchange+" "+*line.Channel]++
+		if *line.Channel == "trade" {
+			if _, ok := line.Message.(map[string]interface{})["price"].(float64); !ok {
+				t.Fatalf("price not decoded: %v", line.Message)
+			}
+		}
+	}
+	if len(counts) != 3 || counts["bitfinex trade"] != counts["bitfinex quote"] || counts["bitmex trade"] < 300 {
+		t.Fatalf("counts %v", counts)
+	}
+	again, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, lines, again)
+}
@@ -0,0 +1,221 @@
+package exdgo
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerClosed is returned for jobs submitted to or queued in a closed `Scheduler`.
+var ErrSchedulerClosed = errors.New("scheduler closed")
+
+// SchedulerParam is the parameters for a `Scheduler`.
+type SchedulerParam struct {
+	// Number of jobs to run at once.
+	// Optional, defaults to 1.
+	Concurrency *int
+	// Minimum interval between the starts of two jobs, limits the rate of jobs.
+	// Optional, no limit by default.
+	Interval *time.Duration
+}
+
+// Job is a unit of work run by a `Scheduler`, such as downloading a replay request.
+// The context given is the one given on submission.
+type Job func(ctx context.Context) error
+
+// ScheduledJob is a handle for a job submitted to a `Scheduler`.
+type ScheduledJob struct {
+	ctx      context.Context
+	job      Job
+	priority int
+	// Order submitted, to run jobs with the same priority in FIFO order
+	seq  int64
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel which is closed when the job finished or was cancelled.
+func (j *ScheduledJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait waits for the job to finish and returns its error.
+// If the context of the job was done before it starts, the context error is returned.
+func (j *ScheduledJob) Wait() error {
+	<-j.done
+	return j.err
+}
+
+// scheduledJobHeap is a heap of jobs ordered by priority, the highest first.
+type scheduledJobHeap []*ScheduledJob
+
+func (h scheduledJobHeap) Len() int { return len(h) }
+func (h scheduledJobHeap) Less(a, b int) bool {
+	if h[a].priority != h[b].priority {
+		return h[a].priority > h[b].priority
+	}
+	return h[a].seq < h[b].seq
+}
+func (h scheduledJobHeap) Swap(a, b int)       { h[a], h[b] = h[b], h[a] }
+func (h *scheduledJobHeap) Push(x interface{}) { *h = append(*h, x.(*ScheduledJob)) }
+func (h *scheduledJobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// Scheduler queues submitted jobs and runs them in the order of priority,
+// under the concurrency and the rate configured.
+// Useful for services which fan out many requests to share the limits.
+type Scheduler struct {
+	concurrency int
+	interval    time.Duration
+
+	mutex     sync.Mutex
+	queue     scheduledJobHeap
+	seq       int64
+	running   int
+	lastStart time.Time
+	closed    bool
+	// Wakes the dispatcher up, buffered
+	wake chan struct{}
+	// Closed when the dispatcher exited
+	dispatcherDone chan struct{}
+	jobs           sync.WaitGroup
+}
+
+// setupScheduler validates the parameters and starts a `Scheduler`.
+func setupScheduler(param SchedulerParam) (*Scheduler, error) {
+	s := new(Scheduler)
+	if param.Concurrency == nil {
+		s.concurrency = 1
+	} else {
+		if *param.Concurrency < 1 {
+			return nil, errors.New("parameter 'Concurrency' must be positive")
+		}
+		s.concurrency = *param.Concurrency
+	}
+	if param.Interval != nil {
+		if *param.Interval < 0 {
+			return nil, errors.New("parameter 'Interval' negative")
+		}
+		s.interval = *param.Interval
+	}
+	s.wake = make(chan struct{}, 1)
+	s.dispatcherDone = make(chan struct{})
+	go s.dispatch()
+	return s, nil
+}
+
+// Scheduler creates a new `Scheduler` to run jobs using this client under shared limits.
+// `Close` must be called after the use.
+func (c *Client) Scheduler(param SchedulerParam) (*Scheduler, error) {
+	return setupScheduler(param)
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Submit queues the job with the priority given, jobs with higher priority run first.
+// Jobs with the same priority run in the order submitted.
+// The job will not run if the context is done before it starts.
+func (s *Scheduler) Submit(ctx context.Context, priority int, job Job) (*ScheduledJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil, ErrSchedulerClosed
+	}
+	j := &ScheduledJob{
+		ctx:      ctx,
+		job:      job,
+		priority: priority,
+		seq:      s.seq,
+		done:     make(chan struct{}),
+	}
+	s.seq++
+	heap.Push(&s.queue, j)
+	s.notify()
+	return j, nil
+}
+
+// dispatch starts queued jobs when allowed, until the scheduler is closed.
+func (s *Scheduler) dispatch() {
+	defer close(s.dispatcherDone)
+	for {
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			return
+		}
+		if s.running >= s.concurrency || len(s.queue) == 0 {
+			s.mutex.Unlock()
+			<-s.wake
+			continue
+		}
+		if s.interval > 0 && !s.lastStart.IsZero() {
+			if wait := s.interval - time.Since(s.lastStart); wait > 0 {
+				s.mutex.Unlock()
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-s.wake:
+					timer.Stop()
+				}
+				continue
+			}
+		}
+		j := heap.Pop(&s.queue).(*ScheduledJob)
+		if serr := j.ctx.Err(); serr != nil {
+			// Cancelled while waiting in the queue
+			s.mutex.Unlock()
+			j.err = serr
+			close(j.done)
+			continue
+		}
+		s.running++
+		s.lastStart = time.Now()
+		s.jobs.Add(1)
+		s.mutex.Unlock()
+		go s.run(j)
+	}
+}
+
+func (s *Scheduler) run(j *ScheduledJob) {
+	defer s.jobs.Done()
+	j.err = j.job(j.ctx)
+	close(j.done)
+	s.mutex.Lock()
+	s.running--
+	s.mutex.Unlock()
+	s.notify()
+}
+
+// Close stops the scheduler and waits for running jobs to finish.
+// Jobs still in the queue will not run, and `ErrSchedulerClosed` is reported to them.
+func (s *Scheduler) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	queued := s.queue
+	s.queue = nil
+	s.mutex.Unlock()
+	s.notify()
+	<-s.dispatcherDone
+	for _, j := range queued {
+		j.err = ErrSchedulerClosed
+		close(j.done)
+	}
+	s.jobs.Wait()
+	return nil
+}
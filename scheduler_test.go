@@ -0,0 +1,121 @@
+package exdgo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerPriority(t *testing.T) {
+	s, serr := setupScheduler(SchedulerParam{})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer s.Close()
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	order := make([]int, 0)
+	record := func(n int) Job {
+		return func(ctx context.Context) error {
+			mutex.Lock()
+			order = append(order, n)
+			mutex.Unlock()
+			return nil
+		}
+	}
+	blocking, serr := s.Submit(context.Background(), 0, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	// Wait for the blocking job to start so others are queued
+	time.Sleep(10 * time.Millisecond)
+	jobs := make([]*ScheduledJob, 0)
+	for i, priority := range []int{0, 2, 1, 2} {
+		j, serr := s.Submit(context.Background(), priority, record(i))
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		jobs = append(jobs, j)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled, serr := s.Submit(ctx, 3, record(-1))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	close(release)
+	if serr := blocking.Wait(); serr != nil {
+		t.Fatal(serr)
+	}
+	for _, j := range jobs {
+		if serr := j.Wait(); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := cancelled.Wait(); serr != context.Canceled {
+		t.Fatalf("cancelled job: %v", serr)
+	}
+	expected := []int{1, 3, 2, 0}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("order = %v", order)
+		}
+	}
+}
+
+func TestSchedulerInterval(t *testing.T) {
+	concurrency := 3
+	interval := 20 * time.Millisecond
+	s, serr := setupScheduler(SchedulerParam{Concurrency: &concurrency, Interval: &interval})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	start := time.Now()
+	jobs := make([]*ScheduledJob, 3)
+	for i := range jobs {
+		jobs[i], serr = s.Submit(context.Background(), 0, func(ctx context.Context) error { return nil })
+		if serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	for _, j := range jobs {
+		j.Wait()
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Fatalf("jobs started too fast: %v", elapsed)
+	}
+	s.Close()
+	if _, serr := s.Submit(context.Background(), 0, func(ctx context.Context) error { return nil }); serr != ErrSchedulerClosed {
+		t.Fatalf("submit after close: %v", serr)
+	}
+	checkGoroutineLeak(t)
+}
+
+func TestSchedulerCloseQueued(t *testing.T) {
+	s, serr := setupScheduler(SchedulerParam{})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	release := make(chan struct{})
+	running, _ := s.Submit(context.Background(), 0, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	queued, _ := s.Submit(context.Background(), 0, func(ctx context.Context) error { return nil })
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	s.Close()
+	if serr := running.Wait(); serr != nil {
+		t.Fatal(serr)
+	}
+	if serr := queued.Wait(); serr != ErrSchedulerClosed {
+		t.Fatalf("queued job: %v", serr)
+	}
+}
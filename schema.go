@@ -0,0 +1,111 @@
+package exdgo
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// JSON Schema of a field for each type in definitions, as it appears in messages in JSON
+var jsonSchemaTypes = map[string]map[string]interface{}{
+	// Nanoseconds in a string not to lose precision
+	"timestamp": {"type": "string", "pattern": "^-?[0-9]+$"},
+	"duration":  {"type": "string", "pattern": "^-?[0-9]+$"},
+	"int":       {"type": "integer"},
+	"float":     {"type": "number"},
+	"string":    {"type": "string"},
+	"boolean":   {"type": "boolean"},
+}
+
+// Go type and option of the JSON tag of a field for each type in definitions
+var goStructTypes = map[string][2]string{
+	"timestamp": {"int64", ",string"},
+	"duration":  {"int64", ",string"},
+	"int":       {"int64", ""},
+	"float":     {"float64", ""},
+	"string":    {"string", ""},
+	"boolean":   {"bool", ""},
+}
+
+// DefinitionJSONSchema converts the definition of a channel into JSON Schema (draft-07) of its messages in JSON,
+// as they appear in lines of `RawRequest` with json format.
+// Fields of types unknown are allowed to be any value.
+// The returned value is to be encoded by `json.Marshal`.
+func DefinitionJSONSchema(definition map[string]string) map[string]interface{} {
+	properties := make(map[string]interface{}, len(definition))
+	for field, typ := range definition {
+		schema, ok := jsonSchemaTypes[typ]
+		if !ok {
+			properties[field] = map[string]interface{}{}
+			continue
+		}
+		copied := make(map[string]interface{}, len(schema))
+		for k, v := range schema {
+			copied[k] = v
+		}
+		properties[field] = copied
+	}
+	return map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// goFieldName converts a field name in a message into an exported Go identifier.
+func goFieldName(field string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range field {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
+
+// DefinitionGoStruct generates the source of a Go struct type named `name` to decode messages of the channel
+// in JSON by `encoding/json`. Timestamps and durations are decoded into nanoseconds in `int64`,
+// and fields of types unknown into `json.RawMessage`.
+func DefinitionGoStruct(name string, definition map[string]string) (string, error) {
+	fields := make([]string, 0, len(definition))
+	for field := range definition {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "type %s struct {\n", name)
+	used := make(map[string]int)
+	for _, field := range fields {
+		ident := goFieldName(field)
+		used[ident]++
+		if used[ident] > 1 {
+			// Different fields converted into the same name
+			ident = fmt.Sprintf("%s%d", ident, used[ident])
+		}
+		typ, ok := goStructTypes[definition[field]]
+		if !ok {
+			typ = [2]string{"json.RawMessage", ""}
+		}
+		fmt.Fprintf(&src, "%s %s `json:%q`\n", ident, typ[0], field+typ[1])
+	}
+	src.WriteString("}\n")
+	formatted, serr := format.Source(src.Bytes())
+	if serr != nil {
+		return "", fmt.Errorf("formatting struct %s: %v", name, serr)
+	}
+	return string(formatted), nil
+}
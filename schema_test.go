@@ -0,0 +1,35 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDefinitionJSONSchema(t *testing.T) {
+	def := map[string]string{"timestamp": "timestamp", "price": "float", "size": "int", "tags": "array"}
+	encoded, serr := json.Marshal(DefinitionJSONSchema(def))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	expected := `{"$schema":"http://json-schema.org/draft-07/schema#","properties":{"price":{"type":"number"},"size":{"type":"integer"},"tags":{},"timestamp":{"pattern":"^-?[0-9]+$","type":"string"}},"type":"object"}`
+	if string(encoded) != expected {
+		t.Fatalf("schema %s", encoded)
+	}
+}
+
+func TestDefinitionGoStruct(t *testing.T) {
+	def := map[string]string{"timestamp": "timestamp", "trade_id": "string", "1st": "boolean", "extra": "array"}
+	src, serr := DefinitionGoStruct("Trade", def)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	expected := "type Trade struct {\n" +
+		"\tF1st      bool            `json:\"1st\"`\n" +
+		"\tExtra     json.RawMessage `json:\"extra\"`\n" +
+		"\tTimestamp int64           `json:\"timestamp,string\"`\n" +
+		"\tTradeId   string          `json:\"trade_id\"`\n" +
+		"}\n"
+	if src != expected {
+		t.Fatalf("source:\n%s", src)
+	}
+}
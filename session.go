@@ -0,0 +1,180 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionBudgetExceeded is the error reported by requests of a session which used up its budget.
+var ErrSessionBudgetExceeded = errors.New("session budget exceeded")
+
+// SessionParam is the parameters for `Client.Session`.
+type SessionParam struct {
+	// Maximum number of HTTP requests sent in the session, including retries.
+	// Optional, unlimited by default.
+	MaxRequests *int64
+	// Maximum bytes of responses downloaded in the session.
+	// Checked before sending a request, so the last response could exceed it.
+	// Optional, unlimited by default.
+	MaxBytes *int64
+	// Maximum download bandwidth in bytes per second shared by requests in the session,
+	// applied on top of the limit of the client.
+	// Optional, unlimited by default.
+	MaxBytesPerSecond *int64
+	// Maximum number of HTTP requests in the session in flight at the same time,
+	// applied on top of the limit of the client.
+	// Optional, unlimited by default.
+	MaxConcurrentRequests *int
+}
+
+// SessionMetrics is aggregated over all requests in a session.
+type SessionMetrics struct {
+	// Number of HTTP requests sent, including retries.
+	Requests int64
+	// Bytes of responses downloaded.
+	Bytes int64
+	// Number of requests retried.
+	Retries int64
+	// Number of responses served from the cache, including ones revalidated by the server.
+	CacheHits int64
+}
+
+// Session groups requests under shared budgets and aggregates their metrics,
+// so a job composed of many requests can be limited and monitored as a unit.
+// Created by `Client.Session`. Safe for concurrent use.
+type Session struct {
+	cli *Client
+}
+
+// sessionState is budgets and metrics of a session, shared by requests in it.
+// Methods can be called on nil, which does nothing.
+type sessionState struct {
+	// Zero if unlimited
+	maxRequests int64
+	maxBytes    int64
+	// nil if unlimited
+	slots     chan struct{}
+	bandwidth *bandwidthLimiter
+	mutex     sync.Mutex
+	metrics   SessionMetrics
+}
+
+// Session creates a session whose requests are sent by this client under the budgets given.
+func (c *Client) Session(param SessionParam) (*Session, error) {
+	state := new(sessionState)
+	var errs ParamErrors
+	if param.MaxRequests != nil {
+		if *param.MaxRequests < 1 {
+			errs.add(errors.New("'MaxRequests' must be positive"))
+		} else {
+			state.maxRequests = *param.MaxRequests
+		}
+	}
+	if param.MaxBytes != nil {
+		if *param.MaxBytes < 1 {
+			errs.add(errors.New("'MaxBytes' must be positive"))
+		} else {
+			state.maxBytes = *param.MaxBytes
+		}
+	}
+	if param.MaxBytesPerSecond != nil {
+		if *param.MaxBytesPerSecond < 1 {
+			errs.add(errors.New("'MaxBytesPerSecond' must be positive"))
+		} else {
+			state.bandwidth = newBandwidthLimiter(*param.MaxBytesPerSecond)
+		}
+	}
+	if param.MaxConcurrentRequests != nil {
+		if *param.MaxConcurrentRequests < 1 {
+			errs.add(errors.New("'MaxConcurrentRequests' must be positive"))
+		} else {
+			state.slots = make(chan struct{}, *param.MaxConcurrentRequests)
+		}
+	}
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
+	copied := *c
+	copied.session = state
+	return &Session{cli: &copied}, nil
+}
+
+// Client returns the client sending requests in the session, to be used with functions such as `Prefetch`.
+// It shares the cache, limits and the state of `Close` with the original client.
+func (s *Session) Client() *Client {
+	return s.cli
+}
+
+// Raw creates a raw request in the session.
+func (s *Session) Raw(param RawRequestParam) (*RawRequest, error) {
+	return s.cli.Raw(param)
+}
+
+// Replay creates a replay request in the session.
+func (s *Session) Replay(param ReplayRequestParam) (*ReplayRequest, error) {
+	return s.cli.Replay(param)
+}
+
+// Metrics returns metrics aggregated over requests in the session so far.
+func (s *Session) Metrics() SessionMetrics {
+	s.cli.session.mutex.Lock()
+	defer s.cli.session.mutex.Unlock()
+	return s.cli.session.metrics
+}
+
+// acquire checks budgets and counts a request, and waits for a slot of the session.
+// Returned function must be called to release the slot.
+func (s *sessionState) acquire(ctx context.Context) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mutex.Lock()
+	if s.maxRequests > 0 && s.metrics.Requests >= s.maxRequests {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %d requests sent", ErrSessionBudgetExceeded, s.maxRequests)
+	}
+	if s.maxBytes > 0 && s.metrics.Bytes >= s.maxBytes {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %d bytes downloaded", ErrSessionBudgetExceeded, s.metrics.Bytes)
+	}
+	s.metrics.Requests++
+	s.mutex.Unlock()
+	if s.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *sessionState) addBytes(n int64) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.metrics.Bytes += n
+	s.mutex.Unlock()
+}
+
+func (s *sessionState) addRetry() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.metrics.Retries++
+	s.mutex.Unlock()
+}
+
+func (s *sessionState) addCacheHit() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.metrics.CacheHits++
+	s.mutex.Unlock()
+}
@@ -0,0 +1,79 @@
+package exdgo
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSessionMetrics(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var failed int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297283") && atomic.AddInt32(&failed, 1) == 1 {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	}
+	concurrent := 2
+	session, serr := srv.client(t).Session(SessionParam{MaxConcurrentRequests: &concurrent})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	for i := 0; i < 2; i++ {
+		req := prepareFakeRawRequest(t, srv)
+		req.cli = session.Client()
+		if _, serr := req.Download(); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	metrics := session.Metrics()
+	// One retry in the first download
+	if metrics.Requests != 2*2*11+1 || metrics.Retries != 1 || metrics.Bytes <= 0 || metrics.CacheHits != 0 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestSessionBudget(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	maxRequests := int64(30)
+	session, serr := srv.client(t).Session(SessionParam{MaxRequests: &maxRequests})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	req := prepareFakeRawRequest(t, srv)
+	req.cli = session.Client()
+	if _, serr := req.Download(); serr != nil {
+		t.Fatal(serr)
+	}
+	// The second download needs 22 more requests
+	if _, serr := req.Download(); !errors.Is(serr, ErrSessionBudgetExceeded) {
+		t.Fatalf("serr = %v", serr)
+	}
+	// Requests in flight are cancelled when another fails
+	if requests := atomic.LoadInt64(&srv.requests); requests > maxRequests {
+		t.Fatalf("%d requests sent", requests)
+	}
+	if metrics := session.Metrics(); metrics.Requests != maxRequests || metrics.Retries != 0 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestSessionParam(t *testing.T) {
+	cli, serr := CreateClient(ClientParam{APIKey: "demo"})
+	if serr != nil {
+		t.Fatalf("testing error: %v", serr)
+	}
+	zero := int64(0)
+	negative := -1
+	_, serr = cli.Session(SessionParam{MaxBytes: &zero, MaxConcurrentRequests: &negative})
+	var errs ParamErrors
+	if !errors.As(serr, &errs) || len(errs) != 2 {
+		t.Fatalf("serr = %v", serr)
+	}
+}
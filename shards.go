@@ -0,0 +1,151 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// ShardBatch is decoded lines of a shard, lines of an exchange in a minute or snapshots.
+type ShardBatch struct {
+	Exchange string
+	// Start of the minute of the shard, or the start of the range for snapshots.
+	// Lines can be later than `Minute` even for snapshots, and the first minute can start before the range.
+	Minute time.Time
+	// True if lines are snapshots taken at the start of the range.
+	Snapshot bool
+	// Lines in the shard in order, empty if there is no data in the minute.
+	Lines []StructLine
+}
+
+// ShardBatchIterator yields lines grouped by shards.
+type ShardBatchIterator interface {
+	// Next returns the next shard.
+	// `ok` is false if there is no more shard or an error occurred.
+	Next() (batch *ShardBatch, ok bool, err error)
+	// Close stops downloading and frees resources, it must be called even after reaching the end.
+	io.Closer
+}
+
+// shardBatchIterator yields shards of exchanges in turn, see `StreamShards`.
+type shardBatchIterator struct {
+	req    *ReplayRequest
+	cancel context.CancelFunc
+	// Tells the client the stream finished
+	end       func()
+	exchanges []string
+	shards    []*rawExchangeStreamShardIterator
+	// Number of shards received from each exchange
+	received []int
+	// Exchanges all shards were received from
+	done      []bool
+	remaining int
+	// Index of the exchange to take the next shard from
+	turn      int
+	processor *rawLineProcessor
+	closed    bool
+}
+
+func (i *shardBatchIterator) Next() (*ShardBatch, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for i.remaining > 0 {
+		j := i.turn
+		if i.done[j] {
+			i.turn = (i.turn + 1) % len(i.shards)
+			continue
+		}
+		shard, serr := i.shards[j].next()
+		if serr != nil {
+			// Takes the same shard again if the iterator can still be used, such as `ErrStalled`
+			return nil, false, serr
+		}
+		i.turn = (i.turn + 1) % len(i.shards)
+		if shard == nil {
+			i.done[j] = true
+			i.remaining--
+			continue
+		}
+		i.req.raw.cli.memory.addBuffered(-shardBytes(shard))
+		index := i.shards[j].indexAt(i.received[j])
+		i.received[j]++
+		batch := &ShardBatch{Exchange: i.exchanges[j], Lines: make([]StructLine, 0, len(shard))}
+		if index == 0 {
+			batch.Snapshot = true
+			batch.Minute = time.Unix(0, i.req.raw.start)
+		} else {
+			minute := i.req.raw.start/int64(time.Minute) + int64(index-1)
+			batch.Minute = time.Unix(0, minute*int64(time.Minute))
+		}
+		for k := range shard {
+			processed, ok, serr := i.processor.processRawLine(&shard[k], &i.req.decode)
+			if !ok {
+				if serr != nil {
+					return nil, false, serr
+				}
+				continue
+			}
+			batch.Lines = append(batch.Lines, processed)
+		}
+		return batch, true, nil
+	}
+	return nil, false, nil
+}
+
+func (i *shardBatchIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	i.cancel()
+	var serr error
+	for _, shards := range i.shards {
+		if cerr := shards.close(); cerr != nil && serr == nil {
+			serr = cerr
+		}
+	}
+	i.end()
+	return serr
+}
+
+// StreamShards streams lines grouped by shards, for consumers working on a minute of an exchange at a time
+// such as batch writers and aggregators.
+// Snapshots of all exchanges come first, and then shards of each minute of exchanges in order of their names.
+// A shard is yielded even if there is no line in it, so every minute in the range is seen.
+//
+// Up to `bufferSize` shards are downloaded ahead for each exchange.
+// Options on streams of lines such as `OnControlLine`, `VerifyOrder` and `ReorderWindow` are not applied,
+// and `Ranges` can not be used.
+func (r *ReplayRequest) StreamShards(ctx context.Context, bufferSize int) (ShardBatchIterator, error) {
+	if bufferSize < 1 {
+		return nil, errors.New("'bufferSize' must be positive")
+	}
+	if len(r.segments) > 0 {
+		return nil, errors.New("shards of a request with 'Ranges' can not be streamed")
+	}
+	end, serr := r.raw.cli.closer.begin()
+	if serr != nil {
+		return nil, serr
+	}
+	ctx, cancel := context.WithCancel(r.raw.withRetryBudget(ctx))
+	i := &shardBatchIterator{
+		req:       r,
+		cancel:    cancel,
+		end:       end,
+		processor: newRawLineProcessor(),
+	}
+	for exchange := range r.raw.filter {
+		i.exchanges = append(i.exchanges, exchange)
+	}
+	sort.Strings(i.exchanges)
+	for _, exchange := range i.exchanges {
+		i.shards = append(i.shards, newRawExchangeStreamShardIterator(ctx, r.raw, exchange, bufferSize, shardOrder{}))
+	}
+	i.received = make([]int, len(i.exchanges))
+	i.done = make([]bool, len(i.exchanges))
+	i.remaining = len(i.exchanges)
+	return i, nil
+}
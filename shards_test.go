@@ -0,0 +1,74 @@
+package exdgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReplayStreamShards(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	start := time.Unix(1577836800, 0)
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{
+		SkipMinutes: map[string][]time.Time{"bitmex": []time.Time{start.Add(3 * time.Minute)}},
+	})
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	itr, serr := req.StreamShards(context.Background(), 3)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer itr.Close()
+	lines := 0
+	batches := 0
+	for {
+		batch, ok, serr := itr.Next()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if !ok {
+			break
+		}
+		// Snapshots of both exchanges, then minutes with bitfinex first
+		exchange := "bitfinex"
+		if batches%2 == 1 {
+			exchange = "bitmex"
+		}
+		if batch.Exchange != exchange || batch.Snapshot != (batches < 2) {
+			t.Fatalf("batch %d of %s, snapshot %v", batches, batch.Exchange, batch.Snapshot)
+		}
+		if !batch.Snapshot {
+			minute := start.Add(time.Duration(batches/2-1) * time.Minute)
+			if !batch.Minute.Equal(minute) {
+				t.Fatalf("batch %d of minute %v, expected %v", batches, batch.Minute, minute)
+			}
+			want := 6
+			if batch.Exchange == "bitmex" && minute.Equal(start.Add(3*time.Minute)) {
+				want = 0
+			}
+			if len(batch.Lines) != want {
+				t.Fatalf("batch %d has %d lines, expected %d", batches, len(batch.Lines), want)
+			}
+			for _, line := range batch.Lines {
+				if line.Exchange != batch.Exchange || line.Timestamp/int64(time.Minute) != minute.Unix()/60 {
+					t.Fatalf("line of %s at %d in batch of %v", line.Exchange, line.Timestamp, minute)
+				}
+			}
+		}
+		lines += len(batch.Lines)
+		batches++
+	}
+	if batches != 2*11 || lines != len(expected) {
+		t.Fatalf("%d batches of %d lines, expected %d lines", batches, lines, len(expected))
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	if _, _, serr := itr.Next(); serr != ErrIteratorClosed {
+		t.Fatalf("Next after Close returned %v", serr)
+	}
+	checkGoroutineLeak(t)
+}
@@ -0,0 +1,66 @@
+package exdgo
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// EstimateClockSkew estimates how much the clock which recorded `other` is ahead of the one which recorded
+// `reference`, from timestamps of the same events seen in both, such as trades of an arbitrage
+// or reactions to the same news.
+// Each timestamp in `other` is paired with the nearest one in `reference` within `window`,
+// and the median of their differences is returned, so events seen only on one side barely affect it.
+// Give the negated skew to `ClockOffsets` for the exchange of `other` to correct it.
+//
+// Timestamps are in nanoseconds and must be sorted in ascending order.
+func EstimateClockSkew(reference []int64, other []int64, window time.Duration) (time.Duration, error) {
+	if window <= 0 {
+		return 0, errors.New("'window' must be positive")
+	}
+	if !sortedInt64s(reference) || !sortedInt64s(other) {
+		return 0, errors.New("timestamps must be sorted")
+	}
+	diffs := make([]int64, 0, len(other))
+	for _, timestamp := range other {
+		// First reference at or after the timestamp, the nearest is either it or the one before
+		j := sort.Search(len(reference), func(k int) bool { return reference[k] >= timestamp })
+		found := false
+		var nearest, nearestAbs int64
+		for _, k := range []int{j - 1, j} {
+			if k < 0 || k >= len(reference) {
+				continue
+			}
+			diff := timestamp - reference[k]
+			abs := diff
+			if abs < 0 {
+				abs = -abs
+			}
+			if abs <= int64(window) && (!found || abs < nearestAbs) {
+				found = true
+				nearest, nearestAbs = diff, abs
+			}
+		}
+		if found {
+			diffs = append(diffs, nearest)
+		}
+	}
+	if len(diffs) == 0 {
+		return 0, errors.New("no events are paired within the window")
+	}
+	sort.Slice(diffs, func(a, b int) bool { return diffs[a] < diffs[b] })
+	middle := len(diffs) / 2
+	if len(diffs)%2 == 1 {
+		return time.Duration(diffs[middle]), nil
+	}
+	return time.Duration((diffs[middle-1] + diffs[middle]) / 2), nil
+}
+
+func sortedInt64s(s []int64) bool {
+	for i := 1; i < len(s); i++ {
+		if s[i] < s[i-1] {
+			return false
+		}
+	}
+	return true
+}
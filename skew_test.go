@@ -0,0 +1,35 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateClockSkew(t *testing.T) {
+	reference := []int64{1000, 2000, 3000, 4000, 5000}
+	// 30ns ahead, with an event not seen in the reference and one seen late
+	other := []int64{1030, 2030, 2500, 3030, 4030, 5090}
+	skew, serr := EstimateClockSkew(reference, other, 100)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if skew != 30 {
+		t.Fatalf("skew = %v", skew)
+	}
+	skew, serr = EstimateClockSkew(other[:5], reference[:4], 100)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if skew != -30 {
+		t.Fatalf("skew = %v", skew)
+	}
+	if _, serr := EstimateClockSkew(reference, []int64{10000}, 100); serr == nil {
+		t.Fatal("estimated without pairs")
+	}
+	if _, serr := EstimateClockSkew(reference, []int64{2, 1}, time.Second); serr == nil {
+		t.Fatal("unsorted timestamps accepted")
+	}
+	if _, serr := EstimateClockSkew(reference, other, 0); serr == nil {
+		t.Fatal("zero window accepted")
+	}
+}
@@ -0,0 +1,114 @@
+package exdgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// SpillParam is the parameters for `DownloadSpill`.
+type SpillParam struct {
+	// Lines are kept in memory until their encoded size exceeds this in bytes,
+	// then all lines are moved to a temporary file.
+	MemoryLimit int64
+	// Directory to create the temporary file in.
+	// Optional, defaults to the default directory for temporary files.
+	Dir string
+}
+
+// spillWriter writes encoded lines into memory, and into a temporary file after exceeding the limit.
+type spillWriter struct {
+	param  SpillParam
+	memory bytes.Buffer
+	file   *os.File
+	writer *LineWriter
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	if w.file != nil {
+		return w.file.Write(p)
+	}
+	if int64(w.memory.Len()+len(p)) <= w.param.MemoryLimit {
+		return w.memory.Write(p)
+	}
+	// Spill lines in memory to the file
+	file, serr := ioutil.TempFile(w.param.Dir, "exdgo-spill-")
+	if serr != nil {
+		return 0, fmt.Errorf("creating spill file: %v", serr)
+	}
+	w.file = file
+	if _, serr := w.memory.WriteTo(file); serr != nil {
+		return 0, serr
+	}
+	w.memory = bytes.Buffer{}
+	return file.Write(p)
+}
+
+// remove deletes the temporary file if it is created.
+func (w *spillWriter) remove() {
+	if w.file != nil {
+		w.file.Close()
+		os.Remove(w.file.Name())
+	}
+}
+
+// spillFile is the temporary file of spilled lines, deleted on close.
+type spillFile struct {
+	*os.File
+}
+
+func (f spillFile) Close() error {
+	serr := f.File.Close()
+	if rerr := os.Remove(f.Name()); rerr != nil && serr == nil {
+		serr = rerr
+	}
+	return serr
+}
+
+// DownloadSpill downloads all lines like `Download`, but lines are moved to a temporary file
+// when their size exceeds the memory limit, so ranges larger than memory can be downloaded.
+// Returns an iterator reading downloaded lines, in the same order as `Download`.
+//
+// The temporary file is deleted when the iterator is closed.
+func (r *ReplayRequest) DownloadSpill(ctx context.Context, param SpillParam) (StructLineIterator, error) {
+	if param.MemoryLimit < 0 {
+		return nil, errors.New("parameter 'MemoryLimit' negative")
+	}
+	itr, serr := r.StreamWithContext(ctx, r.raw.cli.bufferSize)
+	if serr != nil {
+		return nil, serr
+	}
+	defer itr.Close()
+	spill := &spillWriter{param: param}
+	spill.writer = NewLineWriter(spill)
+	for {
+		line, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				spill.remove()
+				return nil, serr
+			}
+			break
+		}
+		if serr := spill.writer.Write(line); serr != nil {
+			spill.remove()
+			return nil, fmt.Errorf("spill: %v", serr)
+		}
+	}
+	if serr := spill.writer.Flush(); serr != nil {
+		spill.remove()
+		return nil, fmt.Errorf("spill: %v", serr)
+	}
+	if spill.file == nil {
+		return NewLineReader(&spill.memory), nil
+	}
+	if _, serr := spill.file.Seek(0, io.SeekStart); serr != nil {
+		spill.remove()
+		return nil, fmt.Errorf("spill: %v", serr)
+	}
+	return NewLineReader(spillFile{spill.file}), nil
+}
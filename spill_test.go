@@ -0,0 +1,74 @@
+package exdgo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// controlLineHook returns a hook of `fakeServer` responding control lines with messages and without,
+// in the shard of bitmex at the minute.
+func controlLineHook(minute time.Time) func(w http.ResponseWriter, r *http.Request) bool {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != fmt.Sprintf("/filter/bitmex/%d", minute.Unix()/60) {
+			return true
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "err\t%d\t%s\n", minute.UnixNano(), `{"reason":"disconnected"}`)
+		fmt.Fprintf(w, "start\t%d\twss://www.bitmex.com/realtime\n", minute.UnixNano()+1)
+		fmt.Fprintf(w, "msg\t%d\torderBookL2_XBTUSD\t%s\n", minute.UnixNano()+1, `{"price":"float","size":"int","timestamp":"timestamp"}`)
+		fmt.Fprintf(w, "end\t%d\n", minute.UnixNano()+2)
+		return false
+	}
+}
+
+func TestReplayDownloadSpill(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = controlLineHook(time.Unix(1577836800, 0).Add(2 * time.Minute))
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	expected, serr := req.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	controls := 0
+	for _, line := range expected {
+		if line.Type != LineTypeMessage {
+			controls++
+		}
+	}
+	if controls != 3 {
+		t.Fatalf("%d control lines downloaded", controls)
+	}
+	dir, serr := ioutil.TempDir("", "exdgo-test-")
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer os.RemoveAll(dir)
+
+	// Everything fits in memory
+	itr, serr := req.DownloadSpill(context.Background(), SpillParam{MemoryLimit: 1 << 30, Dir: dir})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+
+	itr, serr = req.DownloadSpill(context.Background(), SpillParam{MemoryLimit: 1024, Dir: dir})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("%d files spilled", len(files))
+	}
+	compareStructLines(t, expected, readAllStructLines(t, itr))
+	files, _ = ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Fatal("spilled file not removed")
+	}
+	checkGoroutineLeak(t)
+}
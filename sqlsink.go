@@ -0,0 +1,311 @@
+package exdgo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sqlSinkDefaultTable     = "lines"
+	sqlSinkDefaultBatchSize = 1000
+	// Maximum number of parameters in a statement, the lowest among databases supported,
+	// which is of SQLite before 3.32
+	sqlSinkMaxParams = 999
+)
+
+// sqlTypes are column types for types of fields in definitions, common to SQLite and PostgreSQL.
+var sqlTypes = map[string]string{
+	"timestamp": "BIGINT",
+	"duration":  "BIGINT",
+	"int":       "BIGINT",
+	"float":     "DOUBLE PRECISION",
+	"string":    "TEXT",
+	"boolean":   "BOOLEAN",
+}
+
+// Characters not allowed in table names derived from exchanges and channels
+var regexSQLUnsafe = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// SQLSinkParam is the parameters for `NewSQLSink`.
+type SQLSinkParam struct {
+	// Database to write lines into, opened with a driver such as of SQLite or PostgreSQL.
+	DB *sql.DB
+	// If true, messages are written into a table for each channel named "<exchange>_<channel>",
+	// with a column for each field in the definition of the channel.
+	// Otherwise all lines are written into one table with columns of
+	// exchange, type, timestamp, channel and the message in JSON.
+	PerChannel bool
+	// Name of the table for all lines when `PerChannel` is false.
+	// Optional, defaults to "lines".
+	Table string
+	// If true, placeholders are numbered as "$1" as PostgreSQL requires, otherwise "?" is used.
+	NumberedPlaceholders bool
+	// Number of lines buffered before they are written in a transaction.
+	// Optional, defaults to 1000.
+	BatchSize *int
+}
+
+// sqlTable is a table lines are written into and rows buffered for it.
+type sqlTable struct {
+	name    string
+	columns []string
+	rows    [][]interface{}
+}
+
+// SQLSink writes lines into a SQL database through `database/sql`.
+// Tables are created if they do not exist, with the schema derived from the definition of the channel
+// when the first line of the channel is written.
+// Fields not in the schema are not written.
+// `Flush` must be called after writing all lines. Not safe for concurrent use.
+//
+// Statements are generated for SQLite and PostgreSQL, but only checked against a fake driver
+// since this package has no dependencies on database drivers.
+// Neither dialect has been run against a real database, so try it on yours before relying on it.
+type SQLSink struct {
+	db         *sql.DB
+	perChannel bool
+	table      string
+	numbered   bool
+	batchSize  int
+	// Tables by name
+	tables   map[string]*sqlTable
+	buffered int
+}
+
+// NewSQLSink creates a `SQLSink`.
+func NewSQLSink(param SQLSinkParam) (*SQLSink, error) {
+	var errs ParamErrors
+	if param.DB == nil {
+		errs.add(errors.New("'DB' is required"))
+	}
+	s := &SQLSink{
+		db:         param.DB,
+		perChannel: param.PerChannel,
+		table:      sqlSinkDefaultTable,
+		numbered:   param.NumberedPlaceholders,
+		batchSize:  sqlSinkDefaultBatchSize,
+		tables:     make(map[string]*sqlTable),
+	}
+	if param.Table != "" {
+		if param.PerChannel {
+			errs.add(errors.New("'Table' can not be used with 'PerChannel'"))
+		}
+		if regexSQLUnsafe.MatchString(param.Table) {
+			errs.add(errors.New("invalid characters in 'Table'"))
+		}
+		s.table = param.Table
+	}
+	if param.BatchSize != nil {
+		if *param.BatchSize < 1 {
+			errs.add(errors.New("'BatchSize' must be positive"))
+		}
+		s.batchSize = *param.BatchSize
+	}
+	if serr := errs.err(); serr != nil {
+		return nil, serr
+	}
+	return s, nil
+}
+
+// quoteIdent quotes the identifier for both SQLite and PostgreSQL.
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// tableFor returns the table the line is written into, creating it if it does not exist.
+func (s *SQLSink) tableFor(ctx context.Context, line *StructLine) (*sqlTable, error) {
+	name := s.table
+	if s.perChannel {
+		name = regexSQLUnsafe.ReplaceAllString(line.Exchange+"_"+*line.Channel, "_")
+	}
+	if table, ok := s.tables[name]; ok {
+		return table, nil
+	}
+	table := &sqlTable{name: name}
+	var defs []string
+	if s.perChannel {
+		table.columns = append(table.columns, "timestamp")
+		defs = append(defs, quoteIdent("timestamp")+" BIGINT")
+		fields := make([]string, 0, len(line.Definition))
+		for field := range line.Definition {
+			if field != "timestamp" {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			typ, ok := sqlTypes[line.Definition[field]]
+			if !ok {
+				// Written in JSON
+				typ = "TEXT"
+			}
+			table.columns = append(table.columns, field)
+			defs = append(defs, quoteIdent(field)+" "+typ)
+		}
+	} else {
+		table.columns = []string{"exchange", "type", "timestamp", "channel", "message"}
+		defs = []string{
+			quoteIdent("exchange") + " TEXT",
+			quoteIdent("type") + " TEXT",
+			quoteIdent("timestamp") + " BIGINT",
+			quoteIdent("channel") + " TEXT",
+			quoteIdent("message") + " TEXT",
+		}
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(name), strings.Join(defs, ", "))
+	if _, serr := s.db.ExecContext(ctx, query); serr != nil {
+		return nil, fmt.Errorf("creating table %s: %v", name, serr)
+	}
+	s.tables[name] = table
+	return table, nil
+}
+
+// sqlValue converts a value in a message into one a driver accepts.
+func sqlValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case nil, bool, int64, float64, string:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		return v.String(), nil
+	case time.Time:
+		return v.UnixNano(), nil
+	case time.Duration:
+		return int64(v), nil
+	default:
+		encoded, serr := json.Marshal(v)
+		if serr != nil {
+			return nil, serr
+		}
+		return string(encoded), nil
+	}
+}
+
+// Write buffers the line, and writes buffered lines if there are `BatchSize` of them.
+// In `PerChannel` mode, only message lines are written.
+func (s *SQLSink) Write(ctx context.Context, line *StructLine) error {
+	if s.perChannel && (line.Type != LineTypeMessage || line.Channel == nil) {
+		return nil
+	}
+	table, serr := s.tableFor(ctx, line)
+	if serr != nil {
+		return serr
+	}
+	var row []interface{}
+	if s.perChannel {
+		msg, ok := line.Message.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("message of %s is not an object", *line.Channel)
+		}
+		row = make([]interface{}, len(table.columns))
+		row[0] = line.Timestamp
+		for k, column := range table.columns[1:] {
+			if row[k+1], serr = sqlValue(msg[column]); serr != nil {
+				return fmt.Errorf("field %s: %v", column, serr)
+			}
+		}
+	} else {
+		var channel interface{}
+		if line.Channel != nil {
+			channel = *line.Channel
+		}
+		var message interface{}
+		if line.Message != nil {
+			encoded, serr := json.Marshal(line.Message)
+			if serr != nil {
+				return serr
+			}
+			message = string(encoded)
+		}
+		row = []interface{}{line.Exchange, string(line.Type), line.Timestamp, channel, message}
+	}
+	table.rows = append(table.rows, row)
+	s.buffered++
+	if s.buffered >= s.batchSize {
+		return s.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush writes all lines buffered in a transaction.
+func (s *SQLSink) Flush(ctx context.Context) (err error) {
+	if s.buffered == 0 {
+		return nil
+	}
+	tx, serr := s.db.BeginTx(ctx, nil)
+	if serr != nil {
+		return fmt.Errorf("beginning transaction: %v", serr)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	names := make([]string, 0, len(s.tables))
+	for name := range s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if serr := s.insert(ctx, tx, s.tables[name]); serr != nil {
+			return fmt.Errorf("inserting into %s: %v", name, serr)
+		}
+	}
+	if serr := tx.Commit(); serr != nil {
+		return fmt.Errorf("committing transaction: %v", serr)
+	}
+	for _, table := range s.tables {
+		table.rows = nil
+	}
+	s.buffered = 0
+	return nil
+}
+
+// insert inserts buffered rows of the table with statements of multiple rows.
+func (s *SQLSink) insert(ctx context.Context, tx *sql.Tx, table *sqlTable) error {
+	columns := make([]string, len(table.columns))
+	for k, column := range table.columns {
+		columns[k] = quoteIdent(column)
+	}
+	perStatement := sqlSinkMaxParams / len(columns)
+	for rows := table.rows; len(rows) > 0; {
+		n := len(rows)
+		if n > perStatement {
+			n = perStatement
+		}
+		var query strings.Builder
+		fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", quoteIdent(table.name), strings.Join(columns, ", "))
+		args := make([]interface{}, 0, n*len(columns))
+		for r, row := range rows[:n] {
+			if r > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('(')
+			for c := range row {
+				if c > 0 {
+					query.WriteString(", ")
+				}
+				if s.numbered {
+					fmt.Fprintf(&query, "$%d", len(args)+1)
+				} else {
+					query.WriteByte('?')
+				}
+				args = append(args, row[c])
+			}
+			query.WriteByte(')')
+		}
+		if _, serr := tx.ExecContext(ctx, query.String(), args...); serr != nil {
+			return serr
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package exdgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingDriver is a database driver recording statements executed.
+type recordingDriver struct {
+	mutex      sync.Mutex
+	statements []string
+	args       [][]driver.Value
+	commits    int
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ driver *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c.driver, query}, nil
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return &recordingTx{c.driver}, nil }
+
+type recordingTx struct{ driver *recordingDriver }
+
+func (t *recordingTx) Commit() error {
+	t.driver.mutex.Lock()
+	defer t.driver.mutex.Unlock()
+	t.driver.commits++
+	return nil
+}
+func (t *recordingTx) Rollback() error { return nil }
+
+type recordingStmt struct {
+	driver *recordingDriver
+	query  string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mutex.Lock()
+	defer s.driver.mutex.Unlock()
+	s.driver.statements = append(s.driver.statements, s.query)
+	s.driver.args = append(s.driver.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// recordingDrivers maps names of data sources to drivers, so tests have their own one.
+var recordingDrivers sync.Map
+
+type recordingDriverSwitch struct{}
+
+func (recordingDriverSwitch) Open(name string) (driver.Conn, error) {
+	drv, _ := recordingDrivers.Load(name)
+	return drv.(*recordingDriver).Open(name)
+}
+
+var registerRecordingDriver sync.Once
+
+func openRecordingDB(t *testing.T) (*sql.DB, *recordingDriver) {
+	drv := new(recordingDriver)
+	registerRecordingDriver.Do(func() { sql.Register("exdgo-recording", &recordingDriverSwitch{}) })
+	recordingDrivers.Store(t.Name(), drv)
+	db, serr := sql.Open("exdgo-recording", t.Name())
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return db, drv
+}
+
+func TestSQLSinkLines(t *testing.T) {
+	db, drv := openRecordingDB(t)
+	defer db.Close()
+	batch := 4
+	sink, serr := NewSQLSink(SQLSinkParam{DB: db, BatchSize: &batch})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	ctx := context.Background()
+	for _, line := range testLines(6, []string{"bitmex"}, []string{"trade"}) {
+		if serr := sink.Write(ctx, &line); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := sink.Flush(ctx); serr != nil {
+		t.Fatal(serr)
+	}
+	if drv.commits != 2 || len(drv.statements) != 3 {
+		t.Fatalf("%d commits, statements %v", drv.commits, drv.statements)
+	}
+	if !strings.HasPrefix(drv.statements[0], `CREATE TABLE IF NOT EXISTS "lines"`) {
+		t.Fatalf("statement %s", drv.statements[0])
+	}
+	if strings.Count(drv.statements[1], "(?, ?, ?, ?, ?)") != 4 || len(drv.args[2]) != 2*5 {
+		t.Fatalf("statement %s with %d args", drv.statements[1], len(drv.args[1]))
+	}
+	if drv.args[1][4] != `{"index":0}` || drv.args[1][0] != "bitmex" || drv.args[1][1] != "msg" {
+		t.Fatalf("args %v", drv.args[1])
+	}
+	if _, serr := NewSQLSink(SQLSinkParam{}); serr == nil {
+		t.Fatal("missing 'DB' accepted")
+	}
+}
+
+func TestSQLSinkPerChannel(t *testing.T) {
+	db, drv := openRecordingDB(t)
+	defer db.Close()
+	sink, serr := NewSQLSink(SQLSinkParam{DB: db, PerChannel: true, NumberedPlaceholders: true})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	ctx := context.Background()
+	channel := "orderBookL2_XBTUSD"
+	def := map[string]string{"timestamp": "timestamp", "price": "float", "size": "int", "side": "string"}
+	lines := []StructLine{
+		{Exchange: "bitmex", Type: LineTypeStart, Timestamp: 1, Channel: &channel},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 2, Channel: &channel, Definition: def,
+			Message: map[string]interface{}{"price": 100.5, "size": int64(3), "side": "Buy", "extra": 1.0}},
+		{Exchange: "bitmex", Type: LineTypeMessage, Timestamp: 3, Channel: &channel, Definition: def,
+			Message: map[string]interface{}{"price": 101.0, "size": int64(4)}},
+	}
+	for k := range lines {
+		if serr := sink.Write(ctx, &lines[k]); serr != nil {
+			t.Fatal(serr)
+		}
+	}
+	if serr := sink.Flush(ctx); serr != nil {
+		t.Fatal(serr)
+	}
+	expected := []string{
+		`CREATE TABLE IF NOT EXISTS "bitmex_orderBookL2_XBTUSD" ("timestamp" BIGINT, "price" DOUBLE PRECISION, "side" TEXT, "size" BIGINT)`,
+		`INSERT INTO "bitmex_orderBookL2_XBTUSD" ("timestamp", "price", "side", "size") VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)`,
+	}
+	if len(drv.statements) != len(expected) {
+		t.Fatalf("statements %v", drv.statements)
+	}
+	for k := range expected {
+		if drv.statements[k] != expected[k] {
+			t.Fatalf("statement %d: %s", k, drv.statements[k])
+		}
+	}
+	args := drv.args[1]
+	if args[0] != int64(2) || args[1] != 100.5 || args[2] != "Buy" || args[3] != int64(3) || args[6] != nil {
+		t.Fatalf("args %v", args)
+	}
+}
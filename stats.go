@@ -0,0 +1,65 @@
+package exdgo
+
+import "sync/atomic"
+
+// Stats is the memory used by streams of a client, to size buffers. See `Client.Stats`.
+type Stats struct {
+	// Bytes of messages in shards downloaded but not yet read through.
+	BufferedBytes int64
+	// The largest `BufferedBytes` since the client was created.
+	PeakBufferedBytes int64
+	// Lines decoded ahead of the reader and not yet read,
+	// by streams with `DecodeWorkers` and reversed streams.
+	DecodedLines int64
+}
+
+// memoryStats counts the memory in use, shared by copies of a client.
+// Methods do nothing on nil.
+type memoryStats struct {
+	buffered int64
+	peak     int64
+	decoded  int64
+}
+
+// addBuffered adds bytes buffered, negative if released.
+func (m *memoryStats) addBuffered(bytes int64) {
+	if m == nil {
+		return
+	}
+	buffered := atomic.AddInt64(&m.buffered, bytes)
+	for {
+		peak := atomic.LoadInt64(&m.peak)
+		if buffered <= peak || atomic.CompareAndSwapInt64(&m.peak, peak, buffered) {
+			return
+		}
+	}
+}
+
+// addDecoded adds lines decoded, negative if read.
+func (m *memoryStats) addDecoded(lines int64) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.decoded, lines)
+}
+
+// shardBytes returns bytes of messages in the shard.
+func shardBytes(shard []StringLine) int64 {
+	var bytes int64
+	for j := range shard {
+		bytes += int64(len(shard[j].Message))
+	}
+	return bytes
+}
+
+// Stats returns the memory used by streams of the client at the moment.
+func (c *Client) Stats() Stats {
+	if c.memory == nil {
+		return Stats{}
+	}
+	return Stats{
+		BufferedBytes:     atomic.LoadInt64(&c.memory.buffered),
+		PeakBufferedBytes: atomic.LoadInt64(&c.memory.peak),
+		DecodedLines:      atomic.LoadInt64(&c.memory.decoded),
+	}
+}
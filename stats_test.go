@@ -0,0 +1,63 @@
+package exdgo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClientStats(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	workers := 2
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{DecodeWorkers: &workers})
+	cli := req.raw.cli
+	streams := map[string]func() (StructLineIterator, error){
+		"parallel": req.Stream,
+		"reverse":  req.StreamReverse,
+	}
+	for name, stream := range streams {
+		itr, serr := stream()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if _, _, serr := itr.Next(); serr != nil {
+			t.Fatal(serr)
+		}
+		stats := cli.Stats()
+		if stats.DecodedLines <= 0 || stats.PeakBufferedBytes <= 0 {
+			t.Fatalf("%s: stats %+v while streaming", name, stats)
+		}
+		if serr := itr.Close(); serr != nil {
+			t.Fatal(serr)
+		}
+		stats = cli.Stats()
+		if stats.BufferedBytes != 0 || stats.DecodedLines != 0 {
+			t.Fatalf("%s: stats %+v after closed", name, stats)
+		}
+	}
+	itr, serr := req.raw.StreamWithContext(context.Background(), 4)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if _, _, serr := itr.Next(); serr != nil {
+		t.Fatal(serr)
+	}
+	if stats := cli.Stats(); stats.BufferedBytes <= 0 {
+		t.Fatalf("stats %+v while streaming raw", stats)
+	}
+	for {
+		_, ok, serr := itr.Next()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		if !ok {
+			break
+		}
+	}
+	if serr := itr.Close(); serr != nil {
+		t.Fatal(serr)
+	}
+	if stats := cli.Stats(); stats.BufferedBytes != 0 {
+		t.Fatalf("stats %+v after read", stats)
+	}
+}
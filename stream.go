@@ -0,0 +1,109 @@
+package exdgo
+
+import (
+	"context"
+)
+
+// StructLineContextIterator is implemented by iterators returned by `Stream` methods of `ReplayRequest`.
+// Use a type assertion to get it, as iterators wrapping them do not implement it.
+type StructLineContextIterator interface {
+	StructLineIterator
+	// NextContext is same as `Next`, but stops waiting for the line when the context is done,
+	// independently from the context of the stream.
+	// The error of the context is returned then, and the iterator can be used after that.
+	NextContext(ctx context.Context) (*StructLine, bool, error)
+}
+
+// StringLineContextIterator is implemented by iterators returned by `Stream` methods of `RawRequest`.
+// See `StructLineContextIterator`.
+type StringLineContextIterator interface {
+	StringLineIterator
+	NextContext(ctx context.Context) (*StringLine, bool, error)
+}
+
+// callContext holds the context of the current call of `NextContext`.
+// Only accessed by the goroutine reading the stream.
+type callContext struct {
+	ctx context.Context
+}
+
+type callContextKey struct{}
+
+// withCallContext returns the context carrying a holder of contexts of calls,
+// which shard iterators created on it follow.
+func withCallContext(ctx context.Context) (context.Context, *callContext) {
+	call := new(callContext)
+	return context.WithValue(ctx, callContextKey{}, call), call
+}
+
+// withoutCallContext returns the context shard iterators created on do not follow contexts of calls,
+// for ones read by other goroutines than the one reading the stream.
+func withoutCallContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callContextKey{}, (*callContext)(nil))
+}
+
+// callContextFrom returns the holder in the context, or nil if none.
+func callContextFrom(ctx context.Context) *callContext {
+	call, _ := ctx.Value(callContextKey{}).(*callContext)
+	return call
+}
+
+// done returns the channel closed when the current call is cancelled, or nil if it can not be.
+func (c *callContext) done() <-chan struct{} {
+	if c == nil || c.ctx == nil {
+		return nil
+	}
+	return c.ctx.Done()
+}
+
+// err returns the error of the context of the current call.
+func (c *callContext) err() error {
+	return c.ctx.Err()
+}
+
+// streamStructIterator is an iterator of a stream, which can be paused and read with a context.
+type streamStructIterator struct {
+	StructLineIterator
+	*pauseControl
+	call *callContext
+	// Tells the client the stream finished
+	end func()
+}
+
+func (i *streamStructIterator) Close() error {
+	defer i.end()
+	return i.StructLineIterator.Close()
+}
+
+func (i *streamStructIterator) NextContext(ctx context.Context) (*StructLine, bool, error) {
+	i.call.ctx = ctx
+	defer func() { i.call.ctx = nil }()
+	return i.StructLineIterator.Next()
+}
+
+// streamStringIterator is an iterator of a stream, which can be paused and read with a context.
+type streamStringIterator struct {
+	StringLineIterator
+	*pauseControl
+	call *callContext
+	// Tells the client the stream finished
+	end func()
+}
+
+func (i *streamStringIterator) Close() error {
+	defer i.end()
+	return i.StringLineIterator.Close()
+}
+
+func (i *streamStringIterator) NextContext(ctx context.Context) (*StringLine, bool, error) {
+	i.call.ctx = ctx
+	defer func() { i.call.ctx = nil }()
+	return i.StringLineIterator.Next()
+}
+
+// withStreamControls returns the context carrying controls of a stream, see `streamStructIterator`.
+func withStreamControls(ctx context.Context) (context.Context, *pauseControl, *callContext) {
+	ctx, control := withPauseControl(ctx)
+	ctx, call := withCallContext(ctx)
+	return ctx, control, call
+}
@@ -0,0 +1,79 @@
+package exdgo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamNextContext(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297284") {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return true
+	}
+	// Reads lines giving up each call after a while, and returns the number of lines and calls given up
+	read := func(next func(ctx context.Context) (bool, error)) (lines int, timeouts int) {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			ok, serr := next(ctx)
+			cancel()
+			if serr == context.DeadlineExceeded {
+				timeouts++
+				continue
+			}
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			if !ok {
+				return
+			}
+			lines++
+		}
+	}
+	raw := prepareFakeRawRequest(t, srv)
+	expected, serr := raw.Download()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	stringItr, serr := raw.StreamWithContext(context.Background(), 1)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	defer stringItr.Close()
+	lines, timeouts := read(func(ctx context.Context) (bool, error) {
+		_, ok, serr := stringItr.(StringLineContextIterator).NextContext(ctx)
+		return ok, serr
+	})
+	if lines != len(expected) || timeouts == 0 {
+		t.Fatalf("%d lines read with %d timeouts, expected %d lines", lines, timeouts, len(expected))
+	}
+
+	workers := 2
+	for _, param := range []ReplayRequestParam{{}, {DecodeWorkers: &workers}} {
+		req := prepareFakeReplayRequest(t, srv, param)
+		expected, serr := req.Download()
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		itr, serr := req.StreamWithContext(context.Background(), 1)
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		lines, timeouts := read(func(ctx context.Context) (bool, error) {
+			_, ok, serr := itr.(StructLineContextIterator).NextContext(ctx)
+			return ok, serr
+		})
+		if serr := itr.Close(); serr != nil {
+			t.Fatal(serr)
+		}
+		if lines != len(expected) || timeouts == 0 {
+			t.Fatalf("%d lines read with %d timeouts, expected %d lines", lines, timeouts, len(expected))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package exdgo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DownloadSummary is what a download cost, returned by `DownloadWithSummary` so jobs can log it.
+type DownloadSummary struct {
+	// Number of shards fetched including snapshots, whether from the server or the cache.
+	Shards int
+	// Bytes of response bodies transferred from the server, including ones of failed requests.
+	Bytes int64
+	// Number of requests retried.
+	Retries int
+	// Number of responses served from the cache, including ones revalidated by the server.
+	CacheHits int
+	// Time the download took.
+	Duration time.Duration
+	// Number of lines for each exchange and channel, lines without a channel are not counted.
+	Lines map[string]map[string]int
+}
+
+// summaryCollector counts what downloads in the context cost.
+// Methods can be called on nil, which counts nothing.
+type summaryCollector struct {
+	mutex   sync.Mutex
+	summary DownloadSummary
+}
+
+type summaryCollectorKey struct{}
+
+// withSummaryCollector returns the context carrying a new collector.
+func withSummaryCollector(ctx context.Context) (context.Context, *summaryCollector) {
+	c := &summaryCollector{summary: DownloadSummary{Lines: make(map[string]map[string]int)}}
+	return context.WithValue(ctx, summaryCollectorKey{}, c), c
+}
+
+// summaryCollectorFrom returns the collector in the context, nil if none.
+func summaryCollectorFrom(ctx context.Context) *summaryCollector {
+	c, _ := ctx.Value(summaryCollectorKey{}).(*summaryCollector)
+	return c
+}
+
+func (c *summaryCollector) addShard() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.summary.Shards++
+	c.mutex.Unlock()
+}
+
+func (c *summaryCollector) addBytes(n int64) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.summary.Bytes += n
+	c.mutex.Unlock()
+}
+
+func (c *summaryCollector) addRetry() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.summary.Retries++
+	c.mutex.Unlock()
+}
+
+func (c *summaryCollector) addCacheHit() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.summary.CacheHits++
+	c.mutex.Unlock()
+}
+
+// addLine counts a line of the channel.
+// Only called after downloads are done, so it is not locked.
+func (c *summaryCollector) addLine(exchange string, channel *string) {
+	if channel == nil {
+		return
+	}
+	channels, ok := c.summary.Lines[exchange]
+	if !ok {
+		channels = make(map[string]int)
+		c.summary.Lines[exchange] = channels
+	}
+	channels[*channel]++
+}
+
+// finish returns the summary of the download started at `started`.
+func (c *summaryCollector) finish(started time.Time) *DownloadSummary {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	summary := c.summary
+	summary.Duration = time.Since(started)
+	return &summary
+}
+
+// DownloadWithSummary is same as `DownloadWithContext` in the concurrency set by `Concurrency` in `ClientParam`,
+// but also returns the summary of what the download cost.
+// The summary is returned even if the download failed, without lines counted.
+func (r *RawRequest) DownloadWithSummary(ctx context.Context) ([]StringLine, *DownloadSummary, error) {
+	started := time.Now()
+	ctx, collector := withSummaryCollector(ctx)
+	lines, serr := r.DownloadWithContext(ctx, r.cli.concurrency)
+	for i := range lines {
+		collector.addLine(lines[i].Exchange, lines[i].Channel)
+	}
+	return lines, collector.finish(started), serr
+}
+
+// DownloadWithSummary is same as `DownloadWithContext` in the concurrency set by `Concurrency` in `ClientParam`,
+// but also returns the summary of what the download cost.
+// The summary is returned even if the download failed, without lines counted.
+func (r *ReplayRequest) DownloadWithSummary(ctx context.Context) ([]StructLine, *DownloadSummary, error) {
+	started := time.Now()
+	ctx, collector := withSummaryCollector(ctx)
+	lines, serr := r.DownloadWithContext(ctx, r.raw.cli.concurrency)
+	for i := range lines {
+		collector.addLine(lines[i].Exchange, lines[i].Channel)
+	}
+	return lines, collector.finish(started), serr
+}
@@ -0,0 +1,56 @@
+package exdgo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRawDownloadWithSummary(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	var failed int32
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297283") && atomic.AddInt32(&failed, 1) == 1 {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	}
+	req := prepareFakeRawRequest(t, srv)
+	lines, summary, serr := req.DownloadWithSummary(context.Background())
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if summary.Shards != 2*11 || summary.Retries != 1 || summary.CacheHits != 0 || summary.Bytes <= 0 || summary.Duration <= 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if summary.Lines["bitmex"]["orderBookL2"] != 1+6*10 || summary.Lines["bitfinex"]["trades_tBTCUSD"] != 1+6*10 {
+		t.Fatalf("lines = %v", summary.Lines)
+	}
+	if len(lines) != 2+2*6*10 {
+		t.Fatalf("len(lines) = %d", len(lines))
+	}
+}
+
+func TestReplayDownloadWithSummaryFailed(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "bitmex/26297283") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	req := prepareFakeReplayRequest(t, srv, ReplayRequestParam{})
+	_, summary, serr := req.DownloadWithSummary(context.Background())
+	if serr == nil {
+		t.Fatal("no error")
+	}
+	if summary == nil || summary.Retries != 0 || len(summary.Lines) != 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
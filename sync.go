@@ -0,0 +1,77 @@
+package exdgo
+
+import "sync"
+
+// Synchronized returns an iterator safe for concurrent use, reading lines from `itr`,
+// so multiple goroutines can share the work of consuming one stream.
+// Each line is yielded to only one of them, and a line returned stays valid after other calls.
+// `Close` waits for a call of `Next` in progress to return.
+func Synchronized(itr StructLineIterator) StructLineIterator {
+	return &syncIterator{source: itr}
+}
+
+type syncIterator struct {
+	mutex  sync.Mutex
+	source StructLineIterator
+	closed bool
+}
+
+func (i *syncIterator) Next() (*StructLine, bool, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	line, ok, serr := i.source.Next()
+	if !ok {
+		return nil, false, serr
+	}
+	// The line of the source is overwritten by the next call from another goroutine
+	copied := *line
+	return &copied, true, nil
+}
+
+func (i *syncIterator) Close() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
+
+// SynchronizedString is same as `Synchronized` for `StringLineIterator`.
+func SynchronizedString(itr StringLineIterator) StringLineIterator {
+	return &syncStringIterator{source: itr}
+}
+
+type syncStringIterator struct {
+	mutex  sync.Mutex
+	source StringLineIterator
+	closed bool
+}
+
+func (i *syncStringIterator) Next() (*StringLine, bool, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	line, ok, serr := i.source.Next()
+	if !ok {
+		return nil, false, serr
+	}
+	copied := *line
+	return &copied, true, nil
+}
+
+func (i *syncStringIterator) Close() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
@@ -0,0 +1,37 @@
+package exdgo
+
+import (
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestSynchronized(t *testing.T) {
+	lines := testLines(1000, []string{"bitmex"}, []string{"trade"})
+	itr := Synchronized(newSliceIterator(lines))
+	defer itr.Close()
+	var mutex sync.Mutex
+	received := make([]StructLine, 0, len(lines))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				line, ok, serr := itr.Next()
+				if !ok {
+					if serr != nil {
+						t.Error(serr)
+					}
+					return
+				}
+				mutex.Lock()
+				received = append(received, *line)
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(received, func(a, b int) bool { return received[a].Timestamp < received[b].Timestamp })
+	compareStructLines(t, lines, received)
+}
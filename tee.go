@@ -0,0 +1,133 @@
+package exdgo
+
+import "sync"
+
+// Maximum number of lines the fastest iterator of `Tee` can be ahead of the slowest one
+const teeMaxDivergence = 1024
+
+// Tee returns `n` iterators each yielding all lines from `itr`, so multiple consumers such as a recorder and
+// a strategy can read one download.
+// Lines are buffered until all iterators have read them, and an iterator blocks when it is
+// 1024 lines ahead of the slowest one, so consumers diverging more than that must run on separate goroutines.
+// Messages are shared between iterators and must not be modified.
+// `itr` is closed when all iterators are closed, after `Next` of `itr` running at that time returned.
+func Tee(itr StructLineIterator, n int) []StructLineIterator {
+	t := &tee{source: itr, positions: make([]int64, n), closed: make([]bool, n), open: n}
+	t.cond = sync.NewCond(&t.mutex)
+	itrs := make([]StructLineIterator, n)
+	for k := range itrs {
+		itrs[k] = &teeIterator{tee: t, index: k}
+	}
+	if n == 0 {
+		itr.Close()
+	}
+	return itrs
+}
+
+type tee struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	source StructLineIterator
+	// Lines not yet read by all iterators, and the position of the first one
+	lines []StructLine
+	base  int64
+	// Position of the next line for each iterator
+	positions []int64
+	closed    []bool
+	open      int
+	// True while an iterator is reading from the source without the lock
+	reading bool
+	end     bool
+	err     error
+}
+
+// slowest returns the position of the slowest iterator not closed.
+func (t *tee) slowest() int64 {
+	min := t.base + int64(len(t.lines))
+	for k, pos := range t.positions {
+		if !t.closed[k] && pos < min {
+			min = pos
+		}
+	}
+	return min
+}
+
+// trim drops lines read by all iterators.
+func (t *tee) trim() {
+	drop := int(t.slowest() - t.base)
+	if drop == 0 {
+		return
+	}
+	t.lines = t.lines[drop:]
+	t.base += int64(drop)
+	if cap(t.lines) > 2*teeMaxDivergence && len(t.lines) < cap(t.lines)/4 {
+		// Free the array grown
+		t.lines = append(make([]StructLine, 0, len(t.lines)), t.lines...)
+	}
+}
+
+type teeIterator struct {
+	tee   *tee
+	index int
+	line  StructLine
+}
+
+func (i *teeIterator) Next() (*StructLine, bool, error) {
+	t := i.tee
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for {
+		if t.closed[i.index] {
+			return nil, false, ErrIteratorClosed
+		}
+		pos := t.positions[i.index]
+		if pos < t.base+int64(len(t.lines)) {
+			i.line = t.lines[pos-t.base]
+			t.positions[i.index]++
+			t.trim()
+			// Others may wait for this iterator to advance
+			t.cond.Broadcast()
+			return &i.line, true, nil
+		}
+		if t.end {
+			return nil, false, t.err
+		}
+		if t.reading || pos-t.slowest() >= teeMaxDivergence {
+			t.cond.Wait()
+			continue
+		}
+		t.reading = true
+		t.mutex.Unlock()
+		line, ok, serr := t.source.Next()
+		t.mutex.Lock()
+		t.reading = false
+		if ok {
+			t.lines = append(t.lines, *line)
+		} else {
+			t.end = true
+			t.err = serr
+		}
+		t.cond.Broadcast()
+	}
+}
+
+func (i *teeIterator) Close() error {
+	t := i.tee
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed[i.index] {
+		return nil
+	}
+	t.closed[i.index] = true
+	t.open--
+	t.trim()
+	t.cond.Broadcast()
+	if t.open == 0 {
+		// The source is not safe for concurrent use, so wait for the iterator reading it
+		for t.reading {
+			t.cond.Wait()
+		}
+		return t.source.Close()
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package exdgo
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTee(t *testing.T) {
+	lines := testLines(3*teeMaxDivergence, []string{"bitmex"}, []string{"trade"})
+	source := &closeCounter{StructLineIterator: newSliceIterator(lines)}
+	itrs := Tee(source, 3)
+	results := make([][]StructLine, len(itrs))
+	var wg sync.WaitGroup
+	for k := range itrs {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			serr := ForEach(itrs[k], func(line *StructLine) error {
+				results[k] = append(results[k], *line)
+				return nil
+			})
+			if serr != nil {
+				t.Error(serr)
+			}
+		}(k)
+	}
+	wg.Wait()
+	for k := range results {
+		compareStructLines(t, lines, results[k])
+	}
+	if source.closed != 1 {
+		t.Fatalf("source closed %d times", source.closed)
+	}
+
+	// An iterator closed early does not block others
+	source = &closeCounter{StructLineIterator: newSliceIterator(lines)}
+	itrs = Tee(source, 2)
+	itrs[1].Close()
+	compareStructLines(t, lines, readAllStructLines(t, itrs[0]))
+	if source.closed != 1 {
+		t.Fatalf("source closed %d times", source.closed)
+	}
+}
+
+func TestTeeCloseWhileReading(t *testing.T) {
+	lines := testLines(10, []string{"bitmex"}, []string{"trade"})
+	source := newSliceIterator(lines)
+	itrs := Tee(&slowIterator{StructLineIterator: source, delay: 100 * time.Millisecond}, 2)
+	returned := make(chan struct{})
+	go func() {
+		itrs[0].Next()
+		close(returned)
+	}()
+	// Close both while the source is being read
+	time.Sleep(20 * time.Millisecond)
+	itrs[1].Close()
+	itrs[0].Close()
+	<-returned
+	if !source.closed {
+		t.Fatal("source is not closed")
+	}
+}
@@ -0,0 +1,68 @@
+package exdgo
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Maximum bytes read at once by a throttled reader, keeps the rate smooth
+const throttleChunkSize = 32 * 1024
+
+// bandwidthLimiter limits the rate of bytes read, shared by all readers of a client.
+type bandwidthLimiter struct {
+	// Bytes per second
+	rate  int64
+	mutex sync.Mutex
+	// Time the bytes reserved so far are all allowed to be read
+	next time.Time
+}
+
+func newBandwidthLimiter(rate int64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: rate}
+}
+
+// wait reserves `n` bytes and waits until they are allowed to be read.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mutex.Unlock()
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader is a reader which reads no faster than the limiter allows.
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunkSize {
+		p = p[:throttleChunkSize]
+	}
+	n, serr := r.reader.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, serr
+}
@@ -0,0 +1,37 @@
+package exdgo
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestThrottledReader(t *testing.T) {
+	limiter := newBandwidthLimiter(128 * 1024)
+	data := make([]byte, 96*1024)
+	reader := &throttledReader{ctx: context.Background(), reader: bytes.NewReader(data), limiter: limiter}
+	start := time.Now()
+	read, serr := ioutil.ReadAll(reader)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(read) != len(data) {
+		t.Fatalf("read %d bytes", len(read))
+	}
+	// The last chunk is allowed at 64KiB / 128KiB/s
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("read too fast: %v", elapsed)
+	}
+}
+
+func TestThrottledReaderContext(t *testing.T) {
+	limiter := newBandwidthLimiter(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	reader := &throttledReader{ctx: ctx, reader: bytes.NewReader(make([]byte, 1024)), limiter: limiter}
+	if _, serr := ioutil.ReadAll(reader); serr != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", serr)
+	}
+}
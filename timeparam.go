@@ -0,0 +1,125 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Boundaries of magnitude to tell the unit of integer unix time, same as other clients of Exchangedataset.
+// Seconds in 10^11 is year 5138, and millis in 10^14 is also year 5138.
+const (
+	unixSecondsLimit = 1e11
+	unixMillisLimit  = 1e14
+	unixMicrosLimit  = 1e17
+)
+
+// ParseTimeParam converts a value in one of forms below into `time.Time`,
+// allowing time parameters in the same forms as clients for other languages.
+//
+// - `time.Time` or `*time.Time`
+// - string in RFC3339 such as "2020-01-01T00:00:00Z", or a date such as "2020-01-01" in UTC
+// - string or integer of unix time, in seconds, milliseconds, microseconds or nanoseconds,
+// told from its magnitude
+// - float of unix time in seconds
+func ParseTimeParam(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, fmt.Errorf("nil time")
+		}
+		return *t, nil
+	case string:
+		if parsed, serr := time.Parse(time.RFC3339Nano, t); serr == nil {
+			return parsed, nil
+		}
+		if parsed, serr := time.Parse("2006-01-02", t); serr == nil {
+			return parsed, nil
+		}
+		if integer, serr := strconv.ParseInt(t, 10, 64); serr == nil {
+			return unixTimeByMagnitude(integer), nil
+		}
+		return time.Time{}, fmt.Errorf("can not parse %q as time", t)
+	case json.Number:
+		return ParseTimeParam(string(t))
+	case int:
+		return unixTimeByMagnitude(int64(t)), nil
+	case int32:
+		return unixTimeByMagnitude(int64(t)), nil
+	case int64:
+		return unixTimeByMagnitude(t), nil
+	case uint32:
+		return unixTimeByMagnitude(int64(t)), nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("time %d overflows", t)
+		}
+		return unixTimeByMagnitude(int64(t)), nil
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return time.Time{}, fmt.Errorf("invalid time %v", t)
+		}
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported type of time %T", v)
+}
+
+// unixTimeByMagnitude converts integer unix time in unknown unit.
+func unixTimeByMagnitude(x int64) time.Time {
+	abs := x
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < unixSecondsLimit:
+		return time.Unix(x, 0).UTC()
+	case abs < unixMillisLimit:
+		return time.Unix(0, x*int64(time.Millisecond)).UTC()
+	case abs < unixMicrosLimit:
+		return time.Unix(0, x*int64(time.Microsecond)).UTC()
+	}
+	return time.Unix(0, x).UTC()
+}
+
+// ReplayRequestFlexParam is the parameters to make new `ReplayRequest` with start and end in
+// any form accepted by `ParseTimeParam`, for code ported from clients for other languages.
+type ReplayRequestFlexParam struct {
+	// Map of exchanges and and its channels to filter-in.
+	Filter map[string][]string
+	// Start date-time.
+	Start interface{}
+	// End date-time, exclusive.
+	End interface{}
+	// Other parameters, `Filter`, `Start` and `End` in it are ignored.
+	Param ReplayRequestParam
+}
+
+// Resolve converts it into `ReplayRequestParam`.
+func (p ReplayRequestFlexParam) Resolve() (ReplayRequestParam, error) {
+	param := p.Param
+	param.Filter = p.Filter
+	var serr error
+	param.Start, serr = ParseTimeParam(p.Start)
+	if serr != nil {
+		return ReplayRequestParam{}, fmt.Errorf("Start: %v", serr)
+	}
+	param.End, serr = ParseTimeParam(p.End)
+	if serr != nil {
+		return ReplayRequestParam{}, fmt.Errorf("End: %v", serr)
+	}
+	return param, nil
+}
+
+// ReplayFlex creates new `ReplayRequest` with start and end in any form accepted by `ParseTimeParam`.
+func (c *Client) ReplayFlex(param ReplayRequestFlexParam) (*ReplayRequest, error) {
+	resolved, serr := param.Resolve()
+	if serr != nil {
+		return nil, serr
+	}
+	return c.Replay(resolved)
+}
@@ -0,0 +1,63 @@
+package exdgo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	expected := time.Unix(1577836800, 0).UTC()
+	for _, v := range []interface{}{
+		expected,
+		&expected,
+		"2020-01-01T00:00:00Z",
+		"2020-01-01T09:00:00+09:00",
+		"2020-01-01",
+		"1577836800",
+		json.Number("1577836800000"),
+		1577836800,
+		int64(1577836800000),
+		int64(1577836800000000),
+		int64(1577836800000000000),
+		float64(1577836800),
+	} {
+		parsed, serr := ParseTimeParam(v)
+		if serr != nil {
+			t.Fatalf("%v: %v", v, serr)
+		}
+		if !parsed.Equal(expected) {
+			t.Fatalf("%v: parsed as %v", v, parsed)
+		}
+	}
+	parsed, serr := ParseTimeParam(1577836800.5)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if !parsed.Equal(expected.Add(time.Second / 2)) {
+		t.Fatalf("fraction parsed as %v", parsed)
+	}
+	for _, v := range []interface{}{"yesterday", nil, []int{1}} {
+		if _, serr := ParseTimeParam(v); serr == nil {
+			t.Fatalf("%v accepted", v)
+		}
+	}
+}
+
+func TestReplayRequestFlexParam(t *testing.T) {
+	param, serr := ReplayRequestFlexParam{
+		Filter: map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:  "2020-01-01T00:00:00Z",
+		End:    1577836860,
+		Param:  ReplayRequestParam{KeepRaw: true},
+	}.Resolve()
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if param.End.Sub(param.Start) != time.Minute || !param.KeepRaw || len(param.Filter) != 1 {
+		t.Fatalf("resolved into %+v", param)
+	}
+	if _, serr := (ReplayRequestFlexParam{Start: "2020-01-01", End: "tomorrow"}).Resolve(); serr == nil {
+		t.Fatal("invalid End accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// validateRemote asks the server for snapshots at the start of the request,
+// to find errors in authentication and channels not existing before downloading data.
+// Channels missing in the snapshot are reported in `ParamErrors`.
+func (r *RawRequest) validateRemote(ctx context.Context) error {
+	// Definitions are returned for every channel having data in json format
+	format := "json"
+	var errs ParamErrors
+	for exchange, channels := range r.filter {
+		if len(channels) == 0 {
+			continue
+		}
+		var snapshots []Snapshot
+		serr := retry(ctx, r.cli, func() error {
+			var serr error
+			snapshots, serr = httpSnapshot(ctx, r.cli, snapshotSetting{
+				exchange: exchange,
+				channels: channels,
+				at:       r.start,
+				format:   &format,
+			})
+			return serr
+		})
+		if serr != nil {
+			// Not a problem of parameters, such as authentication
+			if errors.Is(serr, ErrUnauthorized) {
+				return fmt.Errorf("validating with the server: %w", serr)
+			}
+			return fmt.Errorf("validating with the server: %v", serr)
+		}
+		found := make(map[string]bool, len(snapshots))
+		for _, ss := range snapshots {
+			found[ss.Channel] = true
+		}
+		for _, ch := range channels {
+			if !found[ch] {
+				errs.add(fmt.Errorf("no data for channel %q of exchange %q at 'Start'", ch, exchange))
+			}
+		}
+	}
+	return errs.err()
+}
+
+// RawWithContext is same as `Raw`, but the parameters are also validated with the server
+// within the context, so errors such as an invalid API-key or a channel without data are
+// reported before downloading.
+func RawWithContext(ctx context.Context, clientParam ClientParam, param RawRequestParam) (*RawRequest, error) {
+	cliSetting, serr := setupClient(clientParam)
+	if serr != nil {
+		return nil, serr
+	}
+	return cliSetting.RawContext(ctx, param)
+}
+
+// RawContext is same as `Raw`, but the parameters are also validated with the server.
+// See `RawWithContext`.
+func (c *Client) RawContext(ctx context.Context, param RawRequestParam) (*RawRequest, error) {
+	req, serr := setupRawRequest(c, param)
+	if serr != nil {
+		return nil, serr
+	}
+	if serr := req.validateRemote(ctx); serr != nil {
+		return nil, serr
+	}
+	return req, nil
+}
+
+// ReplayWithContext is same as `Replay`, but the parameters are also validated with the server
+// within the context. See `RawWithContext`.
+func ReplayWithContext(ctx context.Context, clientParam ClientParam, param ReplayRequestParam) (*ReplayRequest, error) {
+	cliSetting, serr := setupClient(clientParam)
+	if serr != nil {
+		return nil, serr
+	}
+	return cliSetting.ReplayContext(ctx, param)
+}
+
+// ReplayContext is same as `Replay`, but the parameters are also validated with the server.
+// See `RawWithContext`.
+func (c *Client) ReplayContext(ctx context.Context, param ReplayRequestParam) (*ReplayRequest, error) {
+	req, serr := setupReplayRequest(c, param)
+	if serr != nil {
+		return nil, serr
+	}
+	if serr := req.raw.validateRemote(ctx); serr != nil {
+		return nil, serr
+	}
+	return req, nil
+}
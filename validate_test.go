@@ -0,0 +1,51 @@
+package exdgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReplayContextValidates(t *testing.T) {
+	srv := newFakeServer()
+	defer srv.close()
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer demo" {
+			http.Error(w, `{"error":"invalid API-key"}`, http.StatusUnauthorized)
+			return false
+		}
+		// Pretend "missing" has no data
+		query := r.URL.Query()
+		channels := query["channels"][:0]
+		for _, ch := range query["channels"] {
+			if ch != "missing" {
+				channels = append(channels, ch)
+			}
+		}
+		query["channels"] = channels
+		r.URL.RawQuery = query.Encode()
+		return true
+	}
+	start := time.Unix(1577836800, 0)
+	param := ReplayRequestParam{
+		Filter: map[string][]string{"bitmex": []string{"orderBookL2"}},
+		Start:  start,
+		End:    start.Add(time.Minute),
+	}
+	cli := srv.client(t)
+	if _, serr := cli.ReplayContext(context.Background(), param); serr != nil {
+		t.Fatal(serr)
+	}
+	param.Filter["bitmex"] = append(param.Filter["bitmex"], "missing")
+	_, serr := cli.ReplayContext(context.Background(), param)
+	if _, ok := serr.(ParamErrors); !ok {
+		t.Fatalf("missing channel not reported: %v", serr)
+	}
+	param.Filter["bitmex"] = []string{"orderBookL2"}
+	cli.apikey = "invalid"
+	if _, serr := cli.ReplayContext(context.Background(), param); !errors.Is(serr, ErrUnauthorized) {
+		t.Fatalf("invalid API-key not reported: %v", serr)
+	}
+}
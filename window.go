@@ -0,0 +1,146 @@
+package exdgo
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Window is lines of a channel within a range of event time.
+type Window struct {
+	Exchange string
+	Channel  string
+	// Lines are from `Start` inclusive to `End` exclusive
+	Start time.Time
+	End   time.Time
+	Lines []StructLine
+}
+
+// WindowIterator yields windows of lines. See `TumblingWindows` and `SlidingWindows`.
+type WindowIterator struct {
+	source StructLineIterator
+	size   int64
+	step   int64
+	// Windows not yet ended
+	open []*Window
+	// Windows ended but not yet yielded, in order
+	ready  []*Window
+	end    bool
+	closed bool
+}
+
+// TumblingWindows returns an iterator grouping lines from `itr` into consecutive windows of the size
+// for each channel of each exchange, aligned to multiples of the size from the unix epoch.
+// See `SlidingWindows`.
+func TumblingWindows(itr StructLineIterator, size time.Duration) (*WindowIterator, error) {
+	return SlidingWindows(itr, size, size)
+}
+
+// SlidingWindows returns an iterator grouping lines from `itr` into windows of the size starting every `step`
+// for each channel of each exchange, so a line belongs to multiple windows if `step` is shorter than the size.
+//
+// A window is yielded once a line at or after its end is read, or `itr` reached the end,
+// in the order of their ends, and then exchanges and channels. Windows without lines are not yielded.
+// Lines without a channel are dropped.
+// Lines from `itr` must be ordered by timestamp, and `itr` is closed when the returned iterator is closed.
+func SlidingWindows(itr StructLineIterator, size time.Duration, step time.Duration) (*WindowIterator, error) {
+	if size <= 0 || step <= 0 {
+		return nil, errors.New("window size and step must be positive")
+	}
+	return &WindowIterator{source: itr, size: int64(size), step: int64(step)}, nil
+}
+
+// floorDiv returns the largest multiple of `d` not greater than `n`.
+func floorDiv(n int64, d int64) int64 {
+	q := n / d * d
+	if q > n {
+		q -= d
+	}
+	return q
+}
+
+// flush moves windows ended at or before `until` to `ready`.
+func (i *WindowIterator) flush(until int64) {
+	kept := i.open[:0]
+	ended := make([]*Window, 0)
+	for _, w := range i.open {
+		if w.End.UnixNano() <= until {
+			ended = append(ended, w)
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	i.open = kept
+	sort.Slice(ended, func(a, b int) bool {
+		if !ended[a].End.Equal(ended[b].End) {
+			return ended[a].End.Before(ended[b].End)
+		}
+		if ended[a].Exchange != ended[b].Exchange {
+			return ended[a].Exchange < ended[b].Exchange
+		}
+		return ended[a].Channel < ended[b].Channel
+	})
+	i.ready = append(i.ready, ended...)
+}
+
+// add adds the line to all windows it belongs to.
+func (i *WindowIterator) add(line *StructLine) {
+	for start := floorDiv(line.Timestamp, i.step); start+i.size > line.Timestamp; start -= i.step {
+		var window *Window
+		for _, w := range i.open {
+			if w.Exchange == line.Exchange && w.Channel == *line.Channel && w.Start.UnixNano() == start {
+				window = w
+				break
+			}
+		}
+		if window == nil {
+			window = &Window{
+				Exchange: line.Exchange,
+				Channel:  *line.Channel,
+				Start:    time.Unix(0, start),
+				End:      time.Unix(0, start+i.size),
+			}
+			i.open = append(i.open, window)
+		}
+		window.Lines = append(window.Lines, *line)
+	}
+}
+
+// Next returns the next window.
+func (i *WindowIterator) Next() (*Window, bool, error) {
+	if i.closed {
+		return nil, false, ErrIteratorClosed
+	}
+	for len(i.ready) == 0 {
+		if i.end {
+			return nil, false, nil
+		}
+		line, ok, serr := i.source.Next()
+		if serr != nil {
+			return nil, false, serr
+		}
+		if !ok {
+			i.end = true
+			i.flush(math.MaxInt64)
+			continue
+		}
+		i.flush(line.Timestamp)
+		if line.Channel != nil {
+			i.add(line)
+		}
+	}
+	w := i.ready[0]
+	i.ready = i.ready[1:]
+	return w, true, nil
+}
+
+// Close closes the underlying iterator.
+// Calling it again does nothing and returns nil.
+func (i *WindowIterator) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	return i.source.Close()
+}
@@ -0,0 +1,71 @@
+package exdgo
+
+import (
+	"testing"
+	"time"
+)
+
+func readAllWindows(t *testing.T, itr *WindowIterator) []*Window {
+	defer itr.Close()
+	windows := make([]*Window, 0)
+	for {
+		w, ok, serr := itr.Next()
+		if !ok {
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			return windows
+		}
+		windows = append(windows, w)
+	}
+}
+
+func TestTumblingWindows(t *testing.T) {
+	// A line every second alternating channels
+	lines := testLines(10, []string{"bitmex"}, []string{"trade", "quote"})
+	itr, serr := TumblingWindows(newSliceIterator(lines), 4*time.Second)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	windows := readAllWindows(t, itr)
+	expected := []struct {
+		channel string
+		start   int64
+		lines   int
+	}{
+		{"quote", 0, 2}, {"trade", 0, 2},
+		{"quote", 4, 2}, {"trade", 4, 2},
+		{"quote", 8, 1}, {"trade", 8, 1},
+	}
+	if len(windows) != len(expected) {
+		t.Fatalf("%d windows", len(windows))
+	}
+	for k, e := range expected {
+		w := windows[k]
+		if w.Channel != e.channel || w.Start.Unix() != e.start || w.End.Sub(w.Start) != 4*time.Second || len(w.Lines) != e.lines {
+			t.Fatalf("window %d: %s %v %d lines", k, w.Channel, w.Start, len(w.Lines))
+		}
+	}
+	if _, serr := TumblingWindows(newSliceIterator(lines), 0); serr == nil {
+		t.Fatal("zero size accepted")
+	}
+}
+
+func TestSlidingWindows(t *testing.T) {
+	lines := testLines(6, []string{"bitmex"}, []string{"trade"})
+	itr, serr := SlidingWindows(newSliceIterator(lines), 4*time.Second, 2*time.Second)
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	windows := readAllWindows(t, itr)
+	// Windows start at -2, 0, 2 and 4 seconds
+	counts := []int{2, 4, 4, 2}
+	if len(windows) != len(counts) {
+		t.Fatalf("%d windows", len(windows))
+	}
+	for k, count := range counts {
+		if windows[k].Start.Unix() != int64(2*k-2) || len(windows[k].Lines) != count {
+			t.Fatalf("window %d: %v %d lines", k, windows[k].Start, len(windows[k].Lines))
+		}
+	}
+}